@@ -0,0 +1,126 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+)
+
+// artifactMountDir is where an agent writes its declared artifacts;
+// upstreamMountDir is where the artifacts of the steps it depends on appear,
+// in one directory per step.
+const (
+	artifactMountDir = "/artifacts"
+	upstreamMountDir = "/upstream"
+)
+
+// UpstreamArtifacts returns the container paths of the artifacts produced by
+// the deps steps, by step and artifact name, as mounted by RunAgent and
+// RunContainer. The artifacts of a for_each step's instances are named
+// <index>/<artifact>, and those of a pipeline step's sub-steps
+// <sub-step>/<artifact>.
+func UpstreamArtifacts(deps []string, results map[string]StepResult) map[string]map[string]string {
+	var up map[string]map[string]string
+	for _, dep := range deps {
+		arts := results[dep].Artifacts
+		if len(arts) == 0 {
+			continue
+		}
+		if up == nil {
+			up = map[string]map[string]string{}
+		}
+		up[dep] = make(map[string]string, len(arts))
+		for name := range arts {
+			up[dep][name] = path.Join(upstreamMountDir, dep, name)
+		}
+	}
+	return up
+}
+
+// upstreamMounts returns a read-only -v flag for every artifact listed in
+// data.Artifacts, taken from where its step's result says it was collected.
+func upstreamMounts(data TemplateData) ([]string, error) {
+	var args []string
+	for _, step := range slices.Sorted(maps.Keys(data.Artifacts)) {
+		for _, name := range slices.Sorted(maps.Keys(data.Artifacts[step])) {
+			host, ok := data.Steps[step].Artifacts[name]
+			if !ok {
+				return nil, fmt.Errorf("step %s has no artifact %q", step, name)
+			}
+			args = append(args, "-v", host+":"+data.Artifacts[step][name]+":ro")
+		}
+	}
+	return args, nil
+}
+
+// newArtifactDir creates the host directory mounted at /artifacts when the
+// agent declares artifacts, or returns "" when it declares none.
+func newArtifactDir(names []string) (string, error) {
+	if len(names) == 0 {
+		return "", nil
+	}
+	dir, err := os.MkdirTemp("", "conductor-artifacts-")
+	if err != nil {
+		return "", fmt.Errorf("create artifact dir: %w", err)
+	}
+	// The container runs as uid 1000 and must be able to write here.
+	if err := os.Chmod(dir, 0777); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("chmod artifact dir: %w", err)
+	}
+	return dir, nil
+}
+
+// collectArtifacts copies the named artifacts from src into dest and returns
+// their new paths by name. Every declared artifact must have been written.
+func collectArtifacts(src, dest string, names []string) (map[string]string, error) {
+	if dest == "" {
+		return nil, fmt.Errorf("no directory to collect artifacts into")
+	}
+	if err := os.MkdirAll(dest, 0700); err != nil {
+		return nil, fmt.Errorf("create artifact dir: %w", err)
+	}
+	paths := make(map[string]string, len(names))
+	for _, name := range names {
+		from, to := filepath.Join(src, name), filepath.Join(dest, name)
+		info, err := os.Lstat(from)
+		if err != nil {
+			return nil, fmt.Errorf("artifact %q was not written to %s", name, artifactMountDir)
+		}
+		switch {
+		case info.IsDir():
+			err = os.CopyFS(to, os.DirFS(from))
+		case info.Mode().IsRegular():
+			err = copyFile(from, to)
+		default:
+			err = fmt.Errorf("not a regular file or directory")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("collect artifact %q: %w", name, err)
+		}
+		paths[name] = to
+	}
+	return paths, nil
+}
+
+// copyFile copies the regular file src to dst.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// artifactScript is a fake agent session that writes plan.md and a reports
+// directory to its /artifacts mount and echoes its arguments to stderr.
+const artifactScript = `    for a in "$@"; do case "$a" in *:/artifacts) out="${a%:/artifacts}" ;; esac; done
+    echo "args: $*" >&2
+    echo "the plan" > "$out/plan.md"
+    mkdir "$out/reports" && echo ok > "$out/reports/unit.txt"
+    echo '{"type":"result","subtype":"success","result":"###PIPELINE_OUTPUT###{\"status\":\"success\"}"}'`
+
+// TestRunAgentArtifacts verifies that declared artifacts are collected into
+// the run's artifact directory and upstream artifacts are mounted read-only.
+func TestRunAgentArtifacts(t *testing.T) {
+	fakeAgentDocker(t, artifactScript)
+	cfg := testRunConfig()
+	cfg.LogDir = t.TempDir()
+	cfg.ArtifactDir = t.TempDir()
+	def := testAgentDef()
+	def.Artifacts = []string{"plan.md", "reports"}
+	data := TemplateData{
+		Steps: map[string]StepResult{"design": {Artifacts: map[string]string{"notes.md": "/runs/r1/artifacts/design/notes.md"}}},
+	}
+	data.Artifacts = UpstreamArtifacts([]string{"design"}, data.Steps)
+
+	r, err := RunAgent(context.Background(), "build", "worker", def, data, cfg, discard)
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	want := map[string]string{
+		"plan.md": filepath.Join(cfg.ArtifactDir, "build", "plan.md"),
+		"reports": filepath.Join(cfg.ArtifactDir, "build", "reports"),
+	}
+	for name, p := range want {
+		if r.Artifacts[name] != p {
+			t.Errorf("artifact %s at %q, want %q", name, r.Artifacts[name], p)
+		}
+	}
+	if got, _ := os.ReadFile(filepath.Join(want["reports"], "unit.txt")); string(got) != "ok\n" {
+		t.Errorf("reports/unit.txt = %q", got)
+	}
+
+	logData, _ := os.ReadFile(r.LogPath)
+	if !strings.Contains(string(logData), "-v /runs/r1/artifacts/design/notes.md:/upstream/design/notes.md:ro") {
+		t.Errorf("upstream artifact not mounted read-only:\n%s", logData)
+	}
+}
+
+// TestRunAgentMissingArtifact verifies that a session not writing a declared
+// artifact fails.
+func TestRunAgentMissingArtifact(t *testing.T) {
+	fakeAgentDocker(t, artifactScript)
+	cfg := testRunConfig()
+	cfg.LogDir = t.TempDir()
+	cfg.ArtifactDir = t.TempDir()
+	def := testAgentDef()
+	def.Artifacts = []string{"plan.md", "coverage.html"}
+
+	r, err := RunAgent(context.Background(), "build", "worker", def, TemplateData{}, cfg, discard)
+	if err == nil || !strings.Contains(err.Error(), `artifact "coverage.html" was not written`) {
+		t.Fatalf("err = %v, want missing artifact", err)
+	}
+	if r.Status != "failure" {
+		t.Errorf("status = %q, want failure", r.Status)
+	}
+}
+
+// TestUpstreamArtifacts verifies the container paths of dependency
+// artifacts.
+func TestUpstreamArtifacts(t *testing.T) {
+	results := map[string]StepResult{
+		"plan":  {Artifacts: map[string]string{"plan.md": "/host/plan.md"}},
+		"lint":  {},
+		"other": {Artifacts: map[string]string{"x": "/host/x"}},
+	}
+	up := UpstreamArtifacts([]string{"plan", "lint"}, results)
+	if len(up) != 1 || up["plan"]["plan.md"] != "/upstream/plan/plan.md" {
+		t.Errorf("UpstreamArtifacts = %v", up)
+	}
+	if up := UpstreamArtifacts([]string{"lint"}, results); up != nil {
+		t.Errorf("UpstreamArtifacts without artifacts = %v, want nil", up)
+	}
+}
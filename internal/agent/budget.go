@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// checkBudget returns an error naming the first limit of b that u exceeds.
+func checkBudget(b config.Budget, u Usage) error {
+	switch {
+	case b.MaxCostUSD > 0 && u.CostUSD > b.MaxCostUSD:
+		return fmt.Errorf("budget exceeded: cost $%.4f > $%.4f", u.CostUSD, b.MaxCostUSD)
+	case b.MaxTokens > 0 && u.Tokens() > b.MaxTokens:
+		return fmt.Errorf("budget exceeded: %d tokens > %d", u.Tokens(), b.MaxTokens)
+	case b.MaxTurns > 0 && u.Turns > b.MaxTurns:
+		return fmt.Errorf("budget exceeded: %d turns > %d", u.Turns, b.MaxTurns)
+	}
+	return nil
+}
+
+// Meter adds up the usage of a run's agent sessions, finished and still
+// running, against the run budget, so that sessions running concurrently
+// cannot together spend more than it. A nil Meter enforces nothing.
+type Meter struct {
+	budget config.Budget
+
+	mu   sync.Mutex
+	done Usage            // usage of finished sessions
+	live map[string]Usage // usage so far of running sessions, by step
+}
+
+// NewMeter returns a Meter enforcing the run budget b.
+func NewMeter(b config.Budget) *Meter {
+	return &Meter{budget: b, live: map[string]Usage{}}
+}
+
+// Update records u as the usage so far of step's session and returns an
+// error if the run's total usage now exceeds the budget.
+func (m *Meter) Update(step string, u Usage) error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.live[step] = u
+	total := m.done
+	for _, l := range m.live {
+		total.Add(l)
+	}
+	if err := checkBudget(m.budget, total); err != nil {
+		return fmt.Errorf("run %w", err)
+	}
+	return nil
+}
+
+// Finish records u as the final usage of step's session.
+func (m *Meter) Finish(step string, u Usage) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.live, step)
+	m.done.Add(u)
+}
@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// TestCheckBudget verifies each budget limit and that zero means unlimited.
+func TestCheckBudget(t *testing.T) {
+	usage := Usage{InputTokens: 600, OutputTokens: 500, CostUSD: 0.75, Turns: 4}
+	tests := []struct {
+		name    string
+		budget  config.Budget
+		wantErr string
+	}{
+		{"unlimited", config.Budget{}, ""},
+		{"within limits", config.Budget{MaxCostUSD: 1, MaxTokens: 2000, MaxTurns: 10}, ""},
+		{"cost", config.Budget{MaxCostUSD: 0.5}, "cost"},
+		{"tokens", config.Budget{MaxTokens: 1000}, "1100 tokens > 1000"},
+		{"turns", config.Budget{MaxTurns: 3}, "4 turns > 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkBudget(tt.budget, usage)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("checkBudget: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestMeter verifies that the meter adds running sessions to finished ones
+// and that a nil meter enforces nothing.
+func TestMeter(t *testing.T) {
+	m := NewMeter(config.Budget{MaxCostUSD: 1})
+	if err := m.Update("a", Usage{CostUSD: 0.4}); err != nil {
+		t.Fatalf("Update a: %v", err)
+	}
+	if err := m.Update("b", Usage{CostUSD: 0.5}); err != nil {
+		t.Fatalf("Update b: %v", err)
+	}
+	m.Finish("a", Usage{CostUSD: 0.45})
+	if err := m.Update("b", Usage{CostUSD: 0.6}); err == nil || !strings.Contains(err.Error(), "run budget exceeded: cost $1.0500 > $1.0000") {
+		t.Errorf("Update b = %v, want run budget exceeded", err)
+	}
+
+	var none *Meter
+	if err := none.Update("a", Usage{CostUSD: 100}); err != nil {
+		t.Errorf("nil meter: %v", err)
+	}
+	none.Finish("a", Usage{})
+}
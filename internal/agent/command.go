@@ -0,0 +1,141 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/dmitriyb/conductor/internal/config"
+	"github.com/dmitriyb/conductor/internal/events"
+)
+
+// maxCommandOutput bounds the stdout of a command step kept for its output.
+const maxCommandOutput = 1 << 20
+
+// RunShell runs a shell step: command is executed by sh -c on the host, in
+// the checkout of the project's first repository. Cancelling ctx kills the
+// shell and everything it started.
+func RunShell(ctx context.Context, stepName, command string, cfg RunConfig,
+	logger *slog.Logger) (*StepResult, error) {
+	logger = logger.With("step", stepName)
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = cfg.promptRepo(config.AgentDef{})
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	kill := func(reason string) {
+		logger.Warn("killing shell step", "reason", reason)
+		if cmd.Process != nil {
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+	}
+	return runCommand(ctx, stepName, stepLabel(stepName), cmd, kill, cfg, logger)
+}
+
+// RunContainer runs a container step: command is executed by bash -c in the
+// project image, under the same security flags as agents, with the project
+// repositories mounted per workspace (ro when empty). As for agents, the
+// artifacts of the steps in data.Artifacts are mounted read-only under
+// /upstream.
+func RunContainer(ctx context.Context, stepName, command, workspace string, data TemplateData,
+	cfg RunConfig, logger *slog.Logger) (*StepResult, error) {
+	logger = logger.With("step", stepName)
+	if cfg.ProjectImage == "" {
+		return nil, errors.New("no project image for container steps")
+	}
+	if workspace == "" {
+		workspace = "ro"
+	}
+	def := config.AgentDef{Workspace: workspace}
+	container := stepLabel(stepName)
+	args := []string{"run", "--rm", "--name", container,
+		"--cap-drop=ALL", "--security-opt=no-new-privileges",
+		"-u", "1000:1000",
+	}
+	args = append(args, sandboxArgs(def)...)
+	if cfg.EnvFilePath != "" {
+		args = append(args, "--env-file", cfg.EnvFilePath)
+	}
+	mounts, err := repoMounts(def, cfg)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, mounts...)
+	upstream, err := upstreamMounts(data)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, upstream...)
+	// The image's entrypoint is bash -c, so the command is its one argument.
+	args = append(args, "-w", config.WorkspaceRoot, cfg.ProjectImage, command)
+
+	cmd := exec.Command("docker", args...)
+	return runCommand(ctx, stepName, container, cmd, dockerKiller(container, logger), cfg, logger)
+}
+
+// runCommand runs a command step's process, logging its output to a file
+// named after label and publishing every line as an output event. The step
+// succeeds when the process exits 0. Its output is stdout when that is a
+// JSON object, or {"stdout": ...} otherwise; exit_code is always set.
+func runCommand(ctx context.Context, stepName, label string, cmd *exec.Cmd,
+	kill func(reason string), cfg RunConfig, logger *slog.Logger) (*StepResult, error) {
+	logFile, err := createLog(cfg, label)
+	if err != nil {
+		return nil, err
+	}
+	defer logFile.Close()
+	logPath := logFile.Name()
+	fail := func(err error) (*StepResult, error) {
+		return &StepResult{Name: stepName, Status: "failure", Error: err.Error(), LogPath: logPath}, err
+	}
+
+	var stdout strings.Builder
+	publish := func(line string) {
+		cfg.Events.Publish(events.Event{Step: stepName, Kind: events.KindOutput, Text: line})
+	}
+	logger.Info("starting command", "log", logPath)
+	scanErr, waitErr := runLines(ctx, cmd, logFile,
+		func(line string) {
+			if stdout.Len()+len(line) < maxCommandOutput {
+				stdout.WriteString(line + "\n")
+			}
+			publish(line)
+		},
+		publish, kill)
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		return fail(fmt.Errorf("command cancelled: %w", ctx.Err()))
+	case waitErr != nil && !errors.As(waitErr, &exitErr):
+		return fail(fmt.Errorf("command: %w", waitErr))
+	case scanErr != nil:
+		return fail(fmt.Errorf("read command output: %w", scanErr))
+	}
+
+	result := commandResult(stepName, stdout.String(), cmd.ProcessState.ExitCode())
+	result.LogPath = logPath
+	logger.Info("command finished", "status", result.Status, "exit_code", result.Output["exit_code"])
+	return result, nil
+}
+
+// commandResult builds a command step's result from its stdout and exit code.
+func commandResult(stepName, stdout string, exitCode int) *StepResult {
+	var output map[string]any
+	trimmed := strings.TrimSpace(stdout)
+	if !strings.HasPrefix(trimmed, "{") || json.Unmarshal([]byte(trimmed), &output) != nil {
+		output = map[string]any{"stdout": stdout}
+	}
+	if _, ok := output["exit_code"]; !ok {
+		output["exit_code"] = exitCode
+	}
+	result := &StepResult{Name: stepName, Status: "success", Output: output}
+	if exitCode != 0 {
+		result.Status = "failure"
+		result.Error = fmt.Sprintf("exit status %d", exitCode)
+	}
+	return result
+}
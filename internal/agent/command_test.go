@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// commandRunConfig returns a RunConfig whose repository is an empty temp dir.
+func commandRunConfig(t *testing.T) RunConfig {
+	cfg := testRunConfig()
+	cfg.Repos = map[string]string{config.DefaultRepo: t.TempDir()}
+	cfg.LogDir = t.TempDir()
+	cfg.ProjectImage = "conductor-test:fedcba987654"
+	return cfg
+}
+
+// TestCommandResult verifies how stdout and the exit code become a step
+// result.
+func TestCommandResult(t *testing.T) {
+	tests := []struct {
+		name       string
+		stdout     string
+		exitCode   int
+		wantStatus string
+		wantOutput map[string]any
+	}{
+		{"json object", `{"coverage": 81.5}` + "\n", 0, "success",
+			map[string]any{"coverage": 81.5, "exit_code": 0}},
+		{"json exit code kept", `{"exit_code": "custom"}`, 0, "success",
+			map[string]any{"exit_code": "custom"}},
+		{"plain text", "ok\n", 0, "success",
+			map[string]any{"stdout": "ok\n", "exit_code": 0}},
+		{"json array is text", "[1]\n", 0, "success",
+			map[string]any{"stdout": "[1]\n", "exit_code": 0}},
+		{"non-zero exit", "boom\n", 2, "failure",
+			map[string]any{"stdout": "boom\n", "exit_code": 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := commandResult("lint", tt.stdout, tt.exitCode)
+			if r.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", r.Status, tt.wantStatus)
+			}
+			if len(r.Output) != len(tt.wantOutput) {
+				t.Errorf("output = %v, want %v", r.Output, tt.wantOutput)
+			}
+			for k, v := range tt.wantOutput {
+				if r.Output[k] != v {
+					t.Errorf("output[%s] = %v, want %v", k, r.Output[k], v)
+				}
+			}
+		})
+	}
+}
+
+// TestRunShell verifies that shell steps run in the repository checkout and
+// report stdout and the exit code.
+func TestRunShell(t *testing.T) {
+	cfg := commandRunConfig(t)
+
+	r, err := RunShell(context.Background(), "count", `echo '{"dir": "'"$(pwd)"'"}'; echo note >&2`, cfg, discard)
+	if err != nil || r.Status != "success" {
+		t.Fatalf("result = %+v, err = %v", r, err)
+	}
+	if r.Output["dir"] != cfg.Repos[config.DefaultRepo] {
+		t.Errorf("ran in %v, want %s", r.Output["dir"], cfg.Repos[config.DefaultRepo])
+	}
+	if r.LogPath == "" {
+		t.Error("no log path")
+	}
+
+	r, err = RunShell(context.Background(), "fail", "echo partial; exit 3", cfg, discard)
+	if err != nil || r.Status != "failure" || r.Output["exit_code"] != 3 || r.Output["stdout"] != "partial\n" {
+		t.Errorf("result = %+v, err = %v, want failure with exit code 3", r, err)
+	}
+}
+
+// TestRunShellCancel verifies that cancelling a shell step kills the shell's
+// children too.
+func TestRunShellCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	r, err := RunShell(ctx, "slow", "sleep 30; echo done", commandRunConfig(t), discard)
+	if err == nil || r.Status != "failure" {
+		t.Fatalf("result = %+v, err = %v, want cancellation failure", r, err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Errorf("RunShell took %v after cancel", time.Since(start))
+	}
+}
+
+// TestRunContainer verifies the docker invocation of a container step,
+// including the mounts of upstream artifacts.
+func TestRunContainer(t *testing.T) {
+	fakeAgentDocker(t, `    echo "$@"`)
+	cfg := commandRunConfig(t)
+	data := TemplateData{Steps: map[string]StepResult{"build": {Artifacts: map[string]string{"bin": "/host/build/bin"}}}}
+	data.Artifacts = UpstreamArtifacts([]string{"build"}, data.Steps)
+
+	r, err := RunContainer(context.Background(), "test", "make test", "", data, cfg, discard)
+	if err != nil || r.Status != "success" {
+		t.Fatalf("result = %+v, err = %v", r, err)
+	}
+	args, _ := r.Output["stdout"].(string)
+	for _, want := range []string{
+		"run --rm --name conductor-test-",
+		"--cap-drop=ALL --security-opt=no-new-privileges -u 1000:1000",
+		"--env-file /dev/shm/env",
+		cfg.Repos[config.DefaultRepo] + ":/workspace:ro",
+		"-v /host/build/bin:/upstream/build/bin:ro",
+		"-w /workspace conductor-test:fedcba987654 make test\n",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("docker args = %q, want %q", args, want)
+		}
+	}
+
+	cfg.ProjectImage = ""
+	if _, err := RunContainer(context.Background(), "test", "true", "", TemplateData{}, cfg, discard); err == nil {
+		t.Error("RunContainer without a project image returned nil error")
+	}
+}
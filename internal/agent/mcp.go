@@ -0,0 +1,93 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// mcpConfigFile is the MCP config written to the prompt dir; the init script
+// passes it to Claude when present.
+const mcpConfigFile = "mcp.json"
+
+// mcpConfig is the Claude Code MCP config file format.
+type mcpConfig struct {
+	MCPServers map[string]mcpServer `json:"mcpServers"`
+}
+
+type mcpServer struct {
+	Type    string            `json:"type"` // stdio | http | sse
+	Command string            `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// renderMCPConfig renders the agent's MCP servers as a Claude Code MCP config.
+// Secret references become ${ENV} references that Claude expands from the
+// container environment. It returns nil when the agent has no servers.
+func renderMCPConfig(def config.AgentDef, secrets map[string]config.SecretRef) ([]byte, error) {
+	if len(def.MCPServers) == 0 {
+		return nil, nil
+	}
+	expand := func(v string) string { return config.ExpandSecretRefs(v, secrets) }
+	expandMap := func(m map[string]string) map[string]string {
+		if len(m) == 0 {
+			return nil
+		}
+		out := make(map[string]string, len(m))
+		for k, v := range m {
+			out[k] = expand(v)
+		}
+		return out
+	}
+
+	cfg := mcpConfig{MCPServers: make(map[string]mcpServer, len(def.MCPServers))}
+	for name, srv := range def.MCPServers {
+		out := mcpServer{Env: expandMap(srv.Env), Headers: expandMap(srv.Headers)}
+		if srv.Command != "" {
+			out.Type = "stdio"
+			out.Command = expand(srv.Command)
+			for _, a := range srv.Args {
+				out.Args = append(out.Args, expand(a))
+			}
+		} else {
+			out.Type = srv.Transport
+			if out.Type == "" {
+				out.Type = "http"
+			}
+			out.URL = expand(srv.URL)
+		}
+		cfg.MCPServers[name] = out
+	}
+	return json.MarshalIndent(cfg, "", "  ")
+}
+
+// writeMCPConfig writes the agent's MCP config into promptDir, if it has any
+// servers.
+func writeMCPConfig(promptDir string, def config.AgentDef, secrets map[string]config.SecretRef) error {
+	data, err := renderMCPConfig(def, secrets)
+	if err != nil || data == nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(promptDir, mcpConfigFile), data, 0644); err != nil {
+		return fmt.Errorf("write mcp config: %w", err)
+	}
+	return nil
+}
+
+// mcpTools returns the --allowedTools entries that grant every tool of the
+// agent's MCP servers.
+func mcpTools(def config.AgentDef) []string {
+	names := make([]string, 0, len(def.MCPServers))
+	for name := range def.MCPServers {
+		names = append(names, "mcp__"+name)
+	}
+	sort.Strings(names)
+	return names
+}
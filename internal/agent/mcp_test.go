@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+var testSecrets = map[string]config.SecretRef{
+	"tracker_token": {Name: "tracker-token", Env: "TRACKER_TOKEN"},
+}
+
+// TestRenderMCPConfig verifies the rendered config for stdio and URL
+// servers, with secret references turned into env var references.
+func TestRenderMCPConfig(t *testing.T) {
+	def := config.AgentDef{MCPServers: map[string]config.MCPServer{
+		"tracker": {
+			Command: "tracker-mcp",
+			Args:    []string{"--project", "core"},
+			Env:     map[string]string{"TRACKER_API_TOKEN": "${secrets.tracker_token}"},
+		},
+		"docs": {
+			URL:     "https://docs.internal/mcp",
+			Headers: map[string]string{"Authorization": "Bearer ${secrets.tracker_token}"},
+		},
+	}}
+	data, err := renderMCPConfig(def, testSecrets)
+	if err != nil {
+		t.Fatalf("renderMCPConfig: %v", err)
+	}
+	if strings.Contains(string(data), "secrets.") {
+		t.Errorf("rendered config still contains secret references: %s", data)
+	}
+
+	var got mcpConfig
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("rendered config is not JSON: %v", err)
+	}
+	want := mcpConfig{MCPServers: map[string]mcpServer{
+		"tracker": {
+			Type:    "stdio",
+			Command: "tracker-mcp",
+			Args:    []string{"--project", "core"},
+			Env:     map[string]string{"TRACKER_API_TOKEN": "${TRACKER_TOKEN}"},
+		},
+		"docs": {
+			Type:    "http",
+			URL:     "https://docs.internal/mcp",
+			Headers: map[string]string{"Authorization": "Bearer ${TRACKER_TOKEN}"},
+		},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("config =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+// TestWriteMCPConfig verifies that the config file is written only for
+// agents with MCP servers.
+func TestWriteMCPConfig(t *testing.T) {
+	dir := t.TempDir()
+	if err := writeMCPConfig(dir, config.AgentDef{}, testSecrets); err != nil {
+		t.Fatalf("writeMCPConfig: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, mcpConfigFile)); !os.IsNotExist(err) {
+		t.Error("mcp config written for agent without servers")
+	}
+
+	def := config.AgentDef{MCPServers: map[string]config.MCPServer{"docs": {URL: "https://docs.internal/mcp"}}}
+	if err := writeMCPConfig(dir, def, testSecrets); err != nil {
+		t.Fatalf("writeMCPConfig: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, mcpConfigFile)); err != nil {
+		t.Errorf("mcp config not written: %v", err)
+	}
+}
+
+// TestAllowedToolsIncludeMCP verifies that a tool-restricted agent may use
+// its MCP servers.
+func TestAllowedToolsIncludeMCP(t *testing.T) {
+	def := config.AgentDef{
+		Workspace:  "ro",
+		Tools:      []string{"gh"},
+		MCPServers: map[string]config.MCPServer{"tracker": {Command: "tracker-mcp"}},
+	}
+	got := allowedTools(def)
+	if got[len(got)-1] != "mcp__tracker" {
+		t.Errorf("allowedTools = %v, want mcp__tracker", got)
+	}
+}
@@ -0,0 +1,30 @@
+package agent
+
+import (
+	"fmt"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// networkArgs translates the agent's network policy into `docker run` flags.
+// Allowlisted agents join the run's internal network and reach the outside
+// only through their egress proxy.
+func networkArgs(agent string, def config.AgentDef, cfg RunConfig) ([]string, error) {
+	switch def.Network.Mode {
+	case "none":
+		return []string{"--network=none"}, nil
+	case "allowlist":
+		proxy, ok := cfg.Proxies[agent]
+		if !ok || cfg.Network == "" {
+			return nil, fmt.Errorf("no egress proxy for agent %q", agent)
+		}
+		url := "http://" + proxy
+		return []string{"--network=" + cfg.Network,
+			"-e", "HTTPS_PROXY=" + url, "-e", "https_proxy=" + url,
+			"-e", "HTTP_PROXY=" + url, "-e", "http_proxy=" + url,
+			"-e", "NO_PROXY=localhost,127.0.0.1", "-e", "no_proxy=localhost,127.0.0.1",
+		}, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,40 @@
+package agent
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// TestNetworkArgs verifies the docker flags for each network mode.
+func TestNetworkArgs(t *testing.T) {
+	cfg := testRunConfig()
+	cfg.Network = "conductor-net"
+	cfg.Proxies = map[string]string{"worker": "172.18.0.1:41234"}
+
+	args, err := networkArgs("worker", config.AgentDef{}, cfg)
+	if err != nil || args != nil {
+		t.Errorf("open mode: args = %v, %v; want none", args, err)
+	}
+
+	args, _ = networkArgs("worker", config.AgentDef{Network: config.NetworkDef{Mode: "none"}}, cfg)
+	if !slices.Equal(args, []string{"--network=none"}) {
+		t.Errorf("none mode: args = %v", args)
+	}
+
+	def := config.AgentDef{Network: config.NetworkDef{Mode: "allowlist", Allow: []string{"github.com"}}}
+	args, err = networkArgs("worker", def, cfg)
+	if err != nil {
+		t.Fatalf("allowlist mode: %v", err)
+	}
+	for _, want := range []string{"--network=conductor-net", "HTTPS_PROXY=http://172.18.0.1:41234"} {
+		if !slices.Contains(args, want) {
+			t.Errorf("allowlist mode: args = %v, want %q", args, want)
+		}
+	}
+
+	if _, err := networkArgs("reviewer", def, cfg); err == nil {
+		t.Error("allowlist agent without proxy returned nil error")
+	}
+}
@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const pipelineMarker = "###PIPELINE_OUTPUT###"
+
+// ParseOutput extracts the payload of the last pipeline marker in output and
+// validates it against schema.
+func ParseOutput(name, output string, schema map[string]any) (*StepResult, error) {
+	payload, ok := findMarker(output)
+	if !ok {
+		return nil, fmt.Errorf("no %s marker found in output", pipelineMarker)
+	}
+	return parsePayload(name, payload, schema)
+}
+
+// findMarker returns the payload following the last marker in text.
+func findMarker(text string) (string, bool) {
+	var payload string
+	found := false
+	for _, line := range strings.Split(text, "\n") {
+		if idx := strings.Index(line, pipelineMarker); idx >= 0 {
+			payload = strings.TrimSpace(line[idx+len(pipelineMarker):])
+			found = true
+		}
+	}
+	return payload, found
+}
+
+// parsePayload decodes a marker payload into a StepResult.
+func parsePayload(name, payload string, schema map[string]any) (*StepResult, error) {
+	var parsed map[string]any
+	if err := json.Unmarshal([]byte(payload), &parsed); err != nil {
+		return nil, fmt.Errorf("invalid JSON after marker: %w", err)
+	}
+	if err := validateSchema(parsed, schema); err != nil {
+		return nil, fmt.Errorf("output schema mismatch: %w", err)
+	}
+	status, _ := parsed["status"].(string)
+	r := &StepResult{Name: name, Status: status, Output: parsed}
+	if status == "failure" {
+		r.Error, _ = parsed["error"].(string)
+	}
+	return r, nil
+}
+
+// validateSchema checks that every schema key is present in data with the
+// declared basic type.
+func validateSchema(data, schema map[string]any) error {
+	var errs []error
+	for key, typ := range schema {
+		val, ok := data[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("missing field %q", key))
+			continue
+		}
+		switch typ {
+		case "string":
+			if _, ok := val.(string); !ok {
+				errs = append(errs, fmt.Errorf("%q: want string", key))
+			}
+		case "int":
+			if _, ok := val.(float64); !ok {
+				errs = append(errs, fmt.Errorf("%q: want int", key))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestFR6_ParseOutput verifies marker extraction and schema validation.
+func TestFR6_ParseOutput(t *testing.T) {
+	schema := map[string]any{"status": "string", "pr_number": "int"}
+	tests := []struct {
+		name       string
+		output     string
+		wantStatus string
+		wantErr    string
+	}{
+		{"success", "noise\n###PIPELINE_OUTPUT###{\"status\":\"success\",\"pr_number\":42}\n", "success", ""},
+		{"last marker wins", "###PIPELINE_OUTPUT###{\"status\":\"failure\",\"pr_number\":1}\n###PIPELINE_OUTPUT###{\"status\":\"success\",\"pr_number\":2}", "success", ""},
+		{"no marker", "just text", "", "no ###PIPELINE_OUTPUT### marker"},
+		{"bad json", "###PIPELINE_OUTPUT###{oops", "", "invalid JSON"},
+		{"missing field", "###PIPELINE_OUTPUT###{\"status\":\"success\"}", "", `missing field "pr_number"`},
+		{"wrong type", "###PIPELINE_OUTPUT###{\"status\":\"success\",\"pr_number\":\"x\"}", "", `"pr_number": want int`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseOutput("step", tt.output, schema)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseOutput: %v", err)
+			}
+			if r.Status != tt.wantStatus || r.Name != "step" {
+				t.Errorf("result = %+v, want status %q", r, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// TestFR6_ParseOutputFailureError verifies that a failure payload's error
+// field is surfaced on the result.
+func TestFR6_ParseOutputFailureError(t *testing.T) {
+	r, err := ParseOutput("step", `###PIPELINE_OUTPUT###{"status":"failure","error":"tests failed"}`, nil)
+	if err != nil {
+		t.Fatalf("ParseOutput: %v", err)
+	}
+	if r.Error != "tests failed" {
+		t.Errorf("Error = %q, want %q", r.Error, "tests failed")
+	}
+}
@@ -0,0 +1,41 @@
+package agent
+
+import "strings"
+
+// modelPrice is the list price of a Claude model in USD per million tokens.
+type modelPrice struct {
+	input, output, cacheWrite, cacheRead float64
+}
+
+// modelPrices maps model ID fragments to list prices, most specific first.
+var modelPrices = []struct {
+	match string
+	price modelPrice
+}{
+	{"opus-4-5", modelPrice{5, 25, 6.25, 0.50}},
+	{"opus", modelPrice{15, 75, 18.75, 1.50}},
+	{"sonnet", modelPrice{3, 15, 3.75, 0.30}},
+	{"haiku-4", modelPrice{1, 5, 1.25, 0.10}},
+	{"haiku", modelPrice{0.80, 4, 1, 0.08}},
+}
+
+// unknownModelPrice prices models missing from modelPrices as the most
+// expensive one, so a live cost estimate errs towards stopping early.
+var unknownModelPrice = modelPrice{15, 75, 18.75, 1.50}
+
+// estimateCost returns the list-price cost of one API call of model. It
+// stands in for Claude's own cost figure, which only arrives with the
+// session's final result message.
+func estimateCost(model string, u streamUsage) float64 {
+	price := unknownModelPrice
+	for _, p := range modelPrices {
+		if strings.Contains(model, p.match) {
+			price = p.price
+			break
+		}
+	}
+	return (float64(u.InputTokens)*price.input +
+		float64(u.OutputTokens)*price.output +
+		float64(u.CacheCreationTokens)*price.cacheWrite +
+		float64(u.CacheReadTokens)*price.cacheRead) / 1e6
+}
@@ -0,0 +1,28 @@
+package agent
+
+import (
+	"math"
+	"testing"
+)
+
+// TestEstimateCost verifies list-price estimates per model family and that
+// unknown models are priced as the most expensive.
+func TestEstimateCost(t *testing.T) {
+	u := streamUsage{InputTokens: 1_000_000, OutputTokens: 100_000, CacheCreationTokens: 10_000, CacheReadTokens: 1_000_000}
+	tests := []struct {
+		model string
+		want  float64
+	}{
+		{"claude-sonnet-4-5-20250929", 3 + 1.5 + 0.0375 + 0.30},
+		{"claude-opus-4-5-20251101", 5 + 2.5 + 0.0625 + 0.50},
+		{"claude-opus-4-1-20250805", 15 + 7.5 + 0.1875 + 1.50},
+		{"claude-haiku-4-5", 1 + 0.5 + 0.0125 + 0.10},
+		{"claude-3-5-haiku-20241022", 0.80 + 0.4 + 0.01 + 0.08},
+		{"", 15 + 7.5 + 0.1875 + 1.50},
+	}
+	for _, tt := range tests {
+		if got := estimateCost(tt.model, u); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("estimateCost(%q) = %g, want %g", tt.model, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxStreamLine bounds one stream-json line; tool results can be large.
+const maxStreamLine = 16 << 20
+
+// labelUnsafe matches runs of characters docker rejects in container names,
+// such as the brackets of for_each instance names.
+var labelUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// stepLabel names a step invocation's container and log file:
+// conductor-<step>-<timestamp>-<random suffix>. The suffix keeps the names of
+// the same step in concurrent runs apart.
+func stepLabel(stepName string) string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix) // never fails
+	return labelPrefix(stepName) + time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// labelPrefix is the part of a step's labels before the timestamp.
+func labelPrefix(stepName string) string {
+	return "conductor-" + strings.Trim(labelUnsafe.ReplaceAllString(stepName, "-"), "-") + "-"
+}
+
+// StepLogs returns the log files of a step's invocations in dir, oldest
+// first.
+func StepLogs(dir, stepName string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	name := regexp.MustCompile(`^` + regexp.QuoteMeta(labelPrefix(stepName)) + `[0-9]{8}-[0-9]{6}-[0-9a-f]{6}\.log$`)
+	var logs []string
+	for _, e := range entries { // sorted by name, so by timestamp
+		if name.MatchString(e.Name()) {
+			logs = append(logs, filepath.Join(dir, e.Name()))
+		}
+	}
+	return logs, nil
+}
+
+// createLog creates the log file of a step invocation in cfg.LogDir, or the
+// system temp dir when unset.
+func createLog(cfg RunConfig, label string) (*os.File, error) {
+	dir := cfg.LogDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	f, err := os.Create(filepath.Join(dir, label+".log"))
+	if err != nil {
+		return nil, fmt.Errorf("create log file: %w", err)
+	}
+	return f, nil
+}
+
+// dockerKiller returns a function that kills the named container once,
+// logging why.
+func dockerKiller(container string, logger *slog.Logger) func(reason string) {
+	var once sync.Once
+	return func(reason string) {
+		once.Do(func() {
+			logger.Warn("killing container", "container", container, "reason", reason)
+			_ = exec.Command("docker", "kill", container).Run()
+		})
+	}
+}
+
+// runLines starts cmd and feeds its output line by line: every line is
+// written to log, stdout lines go to onStdout and stderr lines to onStderr.
+// If ctx is cancelled before cmd exits, kill is called. It returns once the
+// output is drained and cmd has exited.
+func runLines(ctx context.Context, cmd *exec.Cmd, log io.Writer,
+	onStdout, onStderr func(string), kill func(reason string)) (scanErr, waitErr error) {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start: %w", err)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			kill("cancelled")
+		case <-done:
+		}
+	}()
+
+	var mu sync.Mutex
+	writeLog := func(line string) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintln(log, line)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		scanLines(stderr, func(line string) {
+			writeLog(line)
+			onStderr(line)
+		})
+	}()
+	scanErr = scanLines(stdout, func(line string) {
+		writeLog(line)
+		onStdout(line)
+	})
+	wg.Wait()
+	return scanErr, cmd.Wait()
+}
+
+// scanLines calls fn for each line read from r, draining r even if a line
+// exceeds maxStreamLine so the writer never blocks.
+func scanLines(r io.Reader, fn func(string)) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), maxStreamLine)
+	for sc.Scan() {
+		fn(sc.Text())
+	}
+	err := sc.Err()
+	if errors.Is(err, bufio.ErrTooLong) {
+		_, _ = io.Copy(io.Discard, r)
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestStepLogs verifies that only the logs of the named step are found, in
+// the order they were written, and that a missing directory holds none.
+func TestStepLogs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"conductor-fix-0-20260301-120005-0a1b2c.log",
+		"conductor-fix-0-20260301-120000-ffffff.log",
+		"conductor-fix-20260301-120000-0a1b2c.log",
+		"conductor-fix-0-extra-20260301-120000-0a1b2c.log",
+		"conductor-fix-0-20260301-120000-0a1b2c.txt",
+		"conductor-fix-0-20260301-120000.log",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logs, err := StepLogs(dir, "fix[0]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"conductor-fix-0-20260301-120000-ffffff.log", "conductor-fix-0-20260301-120005-0a1b2c.log"}
+	if len(logs) != len(want) {
+		t.Fatalf("logs = %v, want %v", logs, want)
+	}
+	for i := range want {
+		if filepath.Base(logs[i]) != want[i] {
+			t.Errorf("logs[%d] = %s, want %s", i, logs[i], want[i])
+		}
+	}
+	if logs, err := StepLogs(filepath.Join(dir, "missing"), "fix"); logs != nil || err != nil {
+		t.Errorf("StepLogs of missing dir = %v, %v", logs, err)
+	}
+}
+
+// TestStepLabelUnique verifies that two invocations of a step started in the
+// same second, as by concurrent runs, get different container names.
+func TestStepLabelUnique(t *testing.T) {
+	a, b := stepLabel("fix[0]"), stepLabel("fix[0]")
+	if a == b {
+		t.Errorf("stepLabel returned %s twice", a)
+	}
+	if !strings.HasPrefix(a, "conductor-fix-0-") {
+		t.Errorf("stepLabel = %s, want conductor-fix-0-<timestamp>-<suffix>", a)
+	}
+}
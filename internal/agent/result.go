@@ -0,0 +1,45 @@
+package agent
+
+import "time"
+
+// StepResult is the outcome of one pipeline step.
+type StepResult struct {
+	Name      string            // step name
+	Agent     string            // agent name
+	Status    string            // "success", "failure", or "skipped"
+	Output    map[string]any    // parsed JSON payload
+	LogPath   string            // path to full output log
+	Error     string            // error description if failure
+	Usage     Usage             // tokens and cost spent by the agent session
+	Children  []StepResult      // results of a for_each step's instances
+	Queued    time.Duration     // time spent waiting for an agent slot
+	Duration  time.Duration     // time from start to finish, queueing included
+	Cached    bool              // reused from an earlier run instead of running
+	Artifacts map[string]string // artifact name → collected host path
+}
+
+// Usage is the token and cost accounting of an agent session, or the sum of
+// several sessions.
+type Usage struct {
+	InputTokens         int
+	OutputTokens        int
+	CacheCreationTokens int
+	CacheReadTokens     int
+	CostUSD             float64 // as reported by Claude; estimated while the session runs
+	Turns               int
+}
+
+// Tokens returns the total number of tokens processed, cache tokens included.
+func (u Usage) Tokens() int {
+	return u.InputTokens + u.OutputTokens + u.CacheCreationTokens + u.CacheReadTokens
+}
+
+// Add accumulates o into u.
+func (u *Usage) Add(o Usage) {
+	u.InputTokens += o.InputTokens
+	u.OutputTokens += o.OutputTokens
+	u.CacheCreationTokens += o.CacheCreationTokens
+	u.CacheReadTokens += o.CacheReadTokens
+	u.CostUSD += o.CostUSD
+	u.Turns += o.Turns
+}
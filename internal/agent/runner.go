@@ -0,0 +1,270 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/dmitriyb/conductor/internal/config"
+	"github.com/dmitriyb/conductor/internal/events"
+	"github.com/dmitriyb/conductor/internal/state"
+)
+
+// promptMountDir is where rendered prompt files appear inside the container.
+const promptMountDir = "/tmp/orchestrator-prompts"
+
+// RunConfig carries the host-side resources shared by every agent container
+// in a pipeline run.
+type RunConfig struct {
+	Images                     map[string]string // agent name → image tag
+	EnvFilePath                string
+	Secrets                    map[string]config.SecretRef // credentials.secrets, for MCP secret references
+	Project                    config.Project              // repository layout for default mounts
+	Repos                      map[string]string           // repository name → host checkout path
+	Network                    string                      // internal docker network for allowlisted agents
+	Proxies                    map[string]string           // agent name → egress proxy host:port
+	SkillsDir, SSHSock, LogDir string
+	GitName, GitEmail          string
+	Events                     *events.Bus  // receives live progress events; may be nil
+	ProjectImage               string       // image tag for container steps
+	Slots                      *Slots       // limits concurrent agent containers; may be nil
+	Cache                      *state.Cache // step result cache; nil disables caching
+	Meter                      *Meter       // run budget shared by concurrent sessions; may be nil
+	ArtifactDir                string       // where step artifacts are collected, one directory per step
+}
+
+// RunAgent renders the agent's prompts, runs its container, and streams the
+// output: every stream-json event is published to cfg.Events and logged as it
+// arrives, the full output is written to a log file, and the last pipeline
+// marker payload becomes the step result. The agent's declared artifacts are
+// collected from /artifacts into cfg.ArtifactDir, and those of the steps in
+// data.Artifacts are mounted read-only under /upstream. Cancelling ctx kills
+// the container, as does the session exceeding def.Budget or taking the
+// run's total usage in cfg.Meter over the run budget.
+func RunAgent(ctx context.Context, stepName, agentName string, def config.AgentDef,
+	data TemplateData, cfg RunConfig, logger *slog.Logger) (*StepResult, error) {
+	logger = logger.With("step", stepName, "agent", agentName)
+	system, task, err := RenderPrompts(def, cfg.promptRepo(def), data)
+	if err != nil {
+		return nil, err
+	}
+
+	promptDir, err := os.MkdirTemp("", "conductor-prompts-")
+	if err != nil {
+		return nil, fmt.Errorf("create prompt dir: %w", err)
+	}
+	defer os.RemoveAll(promptDir)
+	// The container runs as uid 1000 and must be able to read the prompts.
+	if err := os.Chmod(promptDir, 0755); err != nil {
+		return nil, fmt.Errorf("chmod prompt dir: %w", err)
+	}
+	for name, content := range map[string]string{
+		"system-prompt.txt": system,
+		"task-prompt.txt":   task,
+	} {
+		if err := os.WriteFile(filepath.Join(promptDir, name), []byte(content), 0644); err != nil {
+			return nil, fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+	if err := writeToolWrappers(promptDir, def); err != nil {
+		return nil, err
+	}
+	if err := writeMCPConfig(promptDir, def, cfg.Secrets); err != nil {
+		return nil, err
+	}
+
+	args, err := buildDockerArgs(agentName, def, cfg, promptDir)
+	if err != nil {
+		return nil, err
+	}
+	container := stepLabel(stepName)
+	opts, err := upstreamMounts(data)
+	if err != nil {
+		return nil, err
+	}
+	artifactDir, err := newArtifactDir(def.Artifacts)
+	if err != nil {
+		return nil, err
+	}
+	if artifactDir != "" {
+		defer os.RemoveAll(artifactDir)
+		opts = append(opts, "-v", artifactDir+":"+artifactMountDir)
+	}
+	args = append(append([]string{args[0], "--name", container}, opts...), args[1:]...)
+
+	logFile, err := createLog(cfg, container)
+	if err != nil {
+		return nil, err
+	}
+	defer logFile.Close()
+	logPath := logFile.Name()
+
+	parser := newStreamParser(stepName, agentName, cfg.Events, logger)
+	fail := func(err error) (*StepResult, error) {
+		return &StepResult{Name: stepName, Agent: agentName, Status: "failure",
+			Error: err.Error(), LogPath: logPath, Usage: parser.Usage()}, err
+	}
+
+	// FR8: on cancellation or an exhausted budget, kill the container; the
+	// docker client then exits.
+	kill := dockerKiller(container, logger)
+	var budgetErr error
+	logger.Info("starting agent container", "container", container, "log", logPath)
+	scanErr, waitErr := runLines(ctx, exec.Command("docker", args...), logFile,
+		func(line string) {
+			parser.Feed(line)
+			if budgetErr == nil {
+				u := parser.Usage()
+				if budgetErr = checkBudget(def.Budget, u); budgetErr == nil {
+					budgetErr = cfg.Meter.Update(stepName, u)
+				}
+				if budgetErr != nil {
+					kill(budgetErr.Error())
+				}
+			}
+		},
+		func(line string) {
+			cfg.Events.Publish(events.Event{Step: stepName, Agent: agentName,
+				Kind: events.KindOutput, Text: line})
+		},
+		kill)
+	cfg.Meter.Finish(stepName, parser.Usage())
+
+	switch {
+	case ctx.Err() != nil:
+		return fail(fmt.Errorf("agent cancelled: %w", ctx.Err()))
+	case budgetErr != nil:
+		return fail(budgetErr)
+	case waitErr != nil:
+		return fail(fmt.Errorf("agent container: %w", waitErr))
+	case scanErr != nil:
+		return fail(fmt.Errorf("read agent output: %w", scanErr))
+	}
+	payload, ok := parser.Payload()
+	if !ok {
+		return fail(fmt.Errorf("no %s marker found in output", pipelineMarker))
+	}
+	result, err := parsePayload(stepName, payload, def.OutputSchema)
+	if err != nil {
+		return fail(err)
+	}
+	if artifactDir != "" && result.Status == "success" {
+		dest := ""
+		if cfg.ArtifactDir != "" {
+			dest = filepath.Join(cfg.ArtifactDir, stepName)
+		}
+		if result.Artifacts, err = collectArtifacts(artifactDir, dest, def.Artifacts); err != nil {
+			return fail(err)
+		}
+	}
+	result.Agent = agentName
+	result.LogPath = logPath
+	result.Usage = parser.Usage()
+	logger.Info("agent finished", "status", result.Status,
+		"tokens", result.Usage.Tokens(), "cost_usd", result.Usage.CostUSD, "turns", result.Usage.Turns)
+	return result, nil
+}
+
+// promptRepo returns the host checkout holding the agent's prompt files: the
+// repository of its first workspace mount.
+func (c RunConfig) promptRepo(def config.AgentDef) string {
+	mounts := def.WorkspaceMounts(c.Project)
+	if len(mounts) == 0 {
+		return ""
+	}
+	return c.Repos[mounts[0].Repo]
+}
+
+// buildDockerArgs assembles the `docker run` arguments for one invocation of
+// the named agent: security flags, resource limits, network policy, env file,
+// working directory, tool restrictions, repository and prompt mounts,
+// optional skills and SSH agent mounts, the agent's image, and the init script.
+func buildDockerArgs(agent string, def config.AgentDef, cfg RunConfig, promptDir string) ([]string, error) {
+	image, ok := cfg.Images[agent]
+	if !ok {
+		return nil, fmt.Errorf("no image for agent %q", agent)
+	}
+	args := []string{"run", "--rm",
+		"--cap-drop=ALL", "--security-opt=no-new-privileges",
+		"-u", "1000:1000",
+	}
+	args = append(args, sandboxArgs(def)...)
+	netArgs, err := networkArgs(agent, def, cfg)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, netArgs...)
+	if cfg.EnvFilePath != "" {
+		args = append(args, "--env-file", cfg.EnvFilePath)
+	}
+	args = append(args, "-e", "AGENT_GIT_NAME="+cfg.GitName, "-e", "AGENT_GIT_EMAIL="+cfg.GitEmail,
+		"-e", "CONDUCTOR_WORKDIR="+def.WorkDir(cfg.Project))
+	args = append(args, toolEnv(def)...)
+	args = append(args, gitTokenEnv(cfg)...)
+
+	mounts, err := repoMounts(def, cfg)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, mounts...)
+
+	args = append(args, "-v", promptDir+":"+promptMountDir+":ro")
+	if cfg.SkillsDir != "" {
+		args = append(args, "-v", cfg.SkillsDir+":/home/agent/.claude/skills:ro")
+	}
+	if cfg.SSHSock != "" {
+		args = append(args, "-v", cfg.SSHSock+":/tmp/ssh-agent.sock",
+			"-e", "SSH_AUTH_SOCK=/tmp/ssh-agent.sock")
+	}
+	return append(args, image, initScript), nil
+}
+
+// envNamePattern matches the env var names a credential helper may expand.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// gitTokenEnv returns the -e flag through which the init script learns which
+// env var holds the token of each project repository, one "<url> <env>"
+// line per repository whose token secret is configured, so that git inside
+// the container authenticates to each with its own token rather than
+// AGENT_GH_TOKEN alone.
+func gitTokenEnv(cfg RunConfig) []string {
+	repos := cfg.Project.Repos()
+	var lines []string
+	for _, name := range slices.Sorted(maps.Keys(repos)) {
+		repo := repos[name]
+		ref, ok := cfg.Secrets[repo.TokenSecret()]
+		if !ok || !envNamePattern.MatchString(ref.Env) || strings.ContainsAny(repo.HTTPSURL(), " \n") {
+			continue
+		}
+		lines = append(lines, repo.HTTPSURL()+" "+ref.Env)
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return []string{"-e", "CONDUCTOR_GIT_TOKENS=" + strings.Join(lines, "\n")}
+}
+
+// repoMounts returns one -v flag per repository mounted for the agent,
+// read-only unless the mount mode is rw.
+func repoMounts(def config.AgentDef, cfg RunConfig) ([]string, error) {
+	var args []string
+	for _, m := range def.WorkspaceMounts(cfg.Project) {
+		host, ok := cfg.Repos[m.Repo]
+		if !ok {
+			return nil, fmt.Errorf("mount %s: repository %q was not cloned", m.Path, m.Repo)
+		}
+		spec := host + ":" + m.Path
+		if m.Mode != "rw" {
+			spec += ":ro"
+		}
+		args = append(args, "-v", spec)
+	}
+	return args, nil
+}
@@ -0,0 +1,536 @@
+package agent
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmitriyb/conductor/internal/config"
+	"github.com/dmitriyb/conductor/internal/events"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// testRunConfig returns a RunConfig for a single-repository project.
+func testRunConfig() RunConfig {
+	return RunConfig{
+		Images:      map[string]string{"worker": "conductor-test:0123456789ab"},
+		EnvFilePath: "/dev/shm/env",
+		Project:     config.Project{Repository: "https://github.com/test/repo.git"},
+		Repos:       map[string]string{config.DefaultRepo: "/tmp/clone/repo"},
+	}
+}
+
+// TestFR2_DockerArgsSecurity verifies the container security flags.
+func TestFR2_DockerArgsSecurity(t *testing.T) {
+	args, err := buildDockerArgs("worker", config.AgentDef{Workspace: "rw"}, testRunConfig(), "/tmp/prompts")
+	if err != nil {
+		t.Fatalf("buildDockerArgs: %v", err)
+	}
+	for _, want := range []string{"--rm", "--cap-drop=ALL", "--security-opt=no-new-privileges", "1000:1000"} {
+		if !slices.Contains(args, want) {
+			t.Errorf("args = %v, want %q", args, want)
+		}
+	}
+	if args[len(args)-2] != "conductor-test:0123456789ab" || args[len(args)-1] != initScript {
+		t.Errorf("args must end with image and init script, got %v", args[len(args)-2:])
+	}
+}
+
+// TestGitTokenEnv verifies that each repository whose token secret is
+// configured is listed with the env var of that secret, and that the list
+// reaches the container.
+func TestGitTokenEnv(t *testing.T) {
+	cfg := testRunConfig()
+	cfg.Project = config.Project{Repositories: map[string]config.Repository{
+		"app":  {URL: "git@github.com:acme/app.git"},
+		"sdk":  {URL: "https://github.com/acme/sdk.git", Token: "sdk_pat"},
+		"docs": {URL: "https://github.com/acme/docs.git", Token: "docs_pat"},
+		"bad":  {URL: "https://github.com/acme/bad.git", Token: "bad_pat"},
+	}}
+	cfg.Secrets = map[string]config.SecretRef{
+		"github_pat": {Name: "gh", Env: "AGENT_GH_TOKEN"},
+		"sdk_pat":    {Name: "sdk", Env: "SDK_GH_TOKEN"},
+		"bad_pat":    {Name: "bad", Env: "X; rm -rf /"},
+	}
+	want := []string{"-e", "CONDUCTOR_GIT_TOKENS=https://github.com/acme/app.git AGENT_GH_TOKEN\n" +
+		"https://github.com/acme/sdk.git SDK_GH_TOKEN"}
+	if got := gitTokenEnv(cfg); !slices.Equal(got, want) {
+		t.Errorf("gitTokenEnv = %q, want %q", got, want)
+	}
+	if got := gitTokenEnv(testRunConfig()); got != nil {
+		t.Errorf("gitTokenEnv without secrets = %q, want nil", got)
+	}
+
+	cfg.Repos = map[string]string{"app": "/tmp/app", "sdk": "/tmp/sdk", "docs": "/tmp/docs", "bad": "/tmp/bad"}
+	args, err := buildDockerArgs("worker", config.AgentDef{Workspace: "rw"}, cfg, "/tmp/prompts")
+	if err != nil {
+		t.Fatalf("buildDockerArgs: %v", err)
+	}
+	if !slices.Contains(args, want[1]) {
+		t.Errorf("args = %v, want %q", args, want[1])
+	}
+}
+
+// TestFR3_DockerArgsWorkspaceMode verifies that the workspace mount follows
+// the agent's rw/ro mode.
+func TestFR3_DockerArgsWorkspaceMode(t *testing.T) {
+	for mode, want := range map[string]string{
+		"rw": "/tmp/clone/repo:/workspace",
+		"ro": "/tmp/clone/repo:/workspace:ro",
+	} {
+		args, err := buildDockerArgs("worker", config.AgentDef{Workspace: mode}, testRunConfig(), "/tmp/prompts")
+		if err != nil {
+			t.Fatalf("buildDockerArgs: %v", err)
+		}
+		if !slices.Contains(args, want) {
+			t.Errorf("workspace %s: args = %v, want mount %q", mode, args, want)
+		}
+	}
+}
+
+// TestDockerArgsMultiRepoMounts verifies per-agent repository mounts with
+// individual modes.
+func TestDockerArgsMultiRepoMounts(t *testing.T) {
+	cfg := testRunConfig()
+	cfg.Project = config.Project{Repositories: map[string]config.Repository{
+		"api": {URL: "https://github.com/test/api.git"},
+		"sdk": {URL: "https://github.com/test/sdk.git"},
+	}}
+	cfg.Repos = map[string]string{"api": "/tmp/a/repo", "sdk": "/tmp/s/repo"}
+	def := config.AgentDef{Workspace: "ro", Mounts: []config.MountDef{
+		{Repo: "api", Path: "/workspace/api", Mode: "rw"},
+		{Repo: "sdk", Path: "/workspace/sdk"},
+	}}
+
+	args, err := buildDockerArgs("worker", def, cfg, "/tmp/prompts")
+	if err != nil {
+		t.Fatalf("buildDockerArgs: %v", err)
+	}
+	for _, want := range []string{"/tmp/a/repo:/workspace/api", "/tmp/s/repo:/workspace/sdk:ro"} {
+		if !slices.Contains(args, want) {
+			t.Errorf("args = %v, want mount %q", args, want)
+		}
+	}
+}
+
+// TestDockerArgsWorkDir verifies that the agent starts in its first
+// read-write mount rather than a fixed /workspace.
+func TestDockerArgsWorkDir(t *testing.T) {
+	cfg := testRunConfig()
+	cfg.Repos = map[string]string{"api": "/tmp/a/repo", "sdk": "/tmp/s/repo"}
+	def := config.AgentDef{Workspace: "ro", Mounts: []config.MountDef{
+		{Repo: "api", Path: "/src/api"},
+		{Repo: "sdk", Path: "/src/sdk", Mode: "rw"},
+	}}
+
+	args, err := buildDockerArgs("worker", def, cfg, "/tmp/prompts")
+	if err != nil {
+		t.Fatalf("buildDockerArgs: %v", err)
+	}
+	if !slices.Contains(args, "CONDUCTOR_WORKDIR=/src/sdk") {
+		t.Errorf("args = %v, want CONDUCTOR_WORKDIR=/src/sdk", args)
+	}
+}
+
+// TestDockerArgsMissingRepo verifies that mounting a repository that was
+// not cloned is an error.
+func TestDockerArgsMissingRepo(t *testing.T) {
+	cfg := testRunConfig()
+	cfg.Repos = nil
+	_, err := buildDockerArgs("worker", config.AgentDef{Workspace: "rw"}, cfg, "/tmp/prompts")
+	if err == nil || !strings.Contains(err.Error(), "was not cloned") {
+		t.Fatalf("error = %v, want missing repository error", err)
+	}
+}
+
+// TestDockerArgsPerAgentImage verifies that each agent runs in its own image.
+func TestDockerArgsPerAgentImage(t *testing.T) {
+	cfg := testRunConfig()
+	cfg.Images["reviewer"] = "conductor-test:fedcba987654"
+	def := config.AgentDef{Workspace: "ro"}
+
+	args, err := buildDockerArgs("reviewer", def, cfg, "/tmp/prompts")
+	if err != nil {
+		t.Fatalf("buildDockerArgs: %v", err)
+	}
+	if got := args[len(args)-2]; got != "conductor-test:fedcba987654" {
+		t.Errorf("image = %q, want reviewer image", got)
+	}
+	if _, err := buildDockerArgs("planner", def, cfg, "/tmp/prompts"); err == nil {
+		t.Error("buildDockerArgs for agent without image returned nil error")
+	}
+}
+
+// fakeAgentDocker installs a docker stand-in whose `run` prints script to
+// stdout and `kill <name>` terminates the matching run.
+func fakeAgentDocker(t *testing.T, script string) {
+	t.Helper()
+	dir := t.TempDir()
+	body := `#!/bin/sh
+state="` + dir + `"
+case "$1" in
+  run)
+    name="$3"
+    echo $$ > "$state/$name.pid"
+    echo "pulling nothing" >&2
+` + script + `
+    ;;
+  kill) kill "$(cat "$state/$2.pid")" ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "docker"), []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// testAgentDef is an agent with an inline system prompt, so no repository
+// content is needed.
+func testAgentDef() config.AgentDef {
+	return config.AgentDef{
+		Prompt:       config.PromptDef{System: "Be brief.\n", Task: "Issue {{.IssueNumber}}"},
+		Workspace:    "rw",
+		OutputSchema: map[string]any{"status": "string"},
+	}
+}
+
+// TestRunAgentStreamsEvents verifies that RunAgent publishes events while
+// the container runs, writes the log, and extracts the marker payload.
+func TestRunAgentStreamsEvents(t *testing.T) {
+	fakeAgentDocker(t, `    echo '{"type":"system","subtype":"init"}'
+    echo '{"type":"assistant","message":{"content":[{"type":"tool_use","name":"Bash","input":{}}]}}'
+    echo '{"type":"result","subtype":"success","result":"###PIPELINE_OUTPUT###{\"status\":\"success\"}"}'`)
+	cfg := testRunConfig()
+	cfg.LogDir = t.TempDir()
+	cfg.Events = events.NewBus()
+	ch, unsubscribe := cfg.Events.Subscribe(16)
+
+	r, err := RunAgent(context.Background(), "build", "worker", testAgentDef(), TemplateData{IssueNumber: "7"}, cfg, discard)
+	unsubscribe()
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	if r.Status != "success" || r.Agent != "worker" {
+		t.Errorf("result = %+v, want success from worker", r)
+	}
+
+	var kinds []string
+	for e := range ch {
+		kinds = append(kinds, e.Kind)
+	}
+	for _, want := range []string{events.KindInit, events.KindToolUse, events.KindResult, events.KindOutput} {
+		if !slices.Contains(kinds, want) {
+			t.Errorf("event kinds = %v, want %q", kinds, want)
+		}
+	}
+
+	logData, err := os.ReadFile(r.LogPath)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	if !strings.Contains(string(logData), "pulling nothing") || !strings.Contains(string(logData), `"type":"result"`) {
+		t.Errorf("log = %q, want stdout and stderr lines", logData)
+	}
+}
+
+// TestRunAgentMissingMarker verifies that a session without a marker fails.
+func TestRunAgentMissingMarker(t *testing.T) {
+	fakeAgentDocker(t, `    echo '{"type":"result","subtype":"success","result":"forgot"}'`)
+	cfg := testRunConfig()
+	cfg.LogDir = t.TempDir()
+
+	r, err := RunAgent(context.Background(), "build", "worker", testAgentDef(), TemplateData{}, cfg, discard)
+	if err == nil || r == nil || r.Status != "failure" {
+		t.Fatalf("result = %+v, err = %v, want failure", r, err)
+	}
+}
+
+// TestFR8_RunAgentCancelKillsContainer verifies that cancelling the context
+// kills the running container.
+func TestFR8_RunAgentCancelKillsContainer(t *testing.T) {
+	fakeAgentDocker(t, `    exec sleep 30`)
+	cfg := testRunConfig()
+	cfg.LogDir = t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	r, err := RunAgent(ctx, "build", "worker", testAgentDef(), TemplateData{}, cfg, discard)
+	if err == nil || r.Status != "failure" {
+		t.Fatalf("result = %+v, err = %v, want cancellation failure", r, err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Errorf("RunAgent took %v after cancel", time.Since(start))
+	}
+}
+
+// TestRunAgentBudgetExceeded verifies that a session going over its budget is
+// killed and reported as a failure with the usage so far.
+func TestRunAgentBudgetExceeded(t *testing.T) {
+	fakeAgentDocker(t, `    echo '{"type":"assistant","message":{"id":"m1","usage":{"input_tokens":900,"output_tokens":200},"content":[]}}'
+    exec sleep 30`)
+	cfg := testRunConfig()
+	cfg.LogDir = t.TempDir()
+	def := testAgentDef()
+	def.Budget = config.Budget{MaxTokens: 1000}
+
+	start := time.Now()
+	r, err := RunAgent(context.Background(), "build", "worker", def, TemplateData{}, cfg, discard)
+	if err == nil || !strings.Contains(err.Error(), "budget exceeded") {
+		t.Fatalf("err = %v, want budget exceeded", err)
+	}
+	if r.Status != "failure" || r.Usage.Tokens() != 1100 {
+		t.Errorf("result = %+v, want failure with usage", r)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Errorf("RunAgent took %v, want the container killed", time.Since(start))
+	}
+}
+
+// TestRunAgentCostBudgetExceeded verifies that max_cost_usd stops a session
+// while it runs, on the cost estimated from streamed usage.
+func TestRunAgentCostBudgetExceeded(t *testing.T) {
+	fakeAgentDocker(t, `    echo '{"type":"assistant","message":{"id":"m1","model":"claude-sonnet-4-5","usage":{"input_tokens":100000,"output_tokens":20000},"content":[]}}'
+    exec sleep 30`)
+	cfg := testRunConfig()
+	cfg.LogDir = t.TempDir()
+	def := testAgentDef()
+	def.Budget = config.Budget{MaxCostUSD: 0.5}
+
+	start := time.Now()
+	r, err := RunAgent(context.Background(), "build", "worker", def, TemplateData{}, cfg, discard)
+	if err == nil || !strings.Contains(err.Error(), "budget exceeded: cost $0.6000") {
+		t.Fatalf("err = %v, want cost budget exceeded", err)
+	}
+	if r.Status != "failure" {
+		t.Errorf("result = %+v, want failure", r)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Errorf("RunAgent took %v, want the container killed", time.Since(start))
+	}
+}
+
+// agentRun is what a docker stand-in saw of one RunAgent invocation.
+type agentRun struct {
+	args    []string // docker run arguments
+	prompts string   // copy of the prompt mount
+}
+
+// recordAgentRun runs def as the step "build" through RunAgent against a
+// docker stand-in that records its arguments and a copy of the prompt mount,
+// then reports success.
+func recordAgentRun(t *testing.T, agentName string, def config.AgentDef, cfg RunConfig) agentRun {
+	t.Helper()
+	dir := t.TempDir()
+	script := `#!/bin/sh
+state="` + dir + `"
+[ "$1" = run ] || exit 0
+for a; do printf '%s\0' "$a"; done > "$state/args"
+prev=
+for a; do
+    if [ "$prev" = -v ]; then
+        case "$a" in *:` + promptMountDir + `:ro) cp -R "${a%%:*}" "$state/prompts" ;; esac
+    fi
+    prev=$a
+done
+echo '{"type":"result","subtype":"success","result":"###PIPELINE_OUTPUT###{\"status\":\"success\"}"}'
+`
+	if err := os.WriteFile(filepath.Join(dir, "docker"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	cfg.LogDir = t.TempDir()
+
+	if _, err := RunAgent(context.Background(), "build", agentName, def, TemplateData{}, cfg, discard); err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return agentRun{
+		args:    strings.Split(strings.TrimSuffix(string(data), "\x00"), "\x00"),
+		prompts: filepath.Join(dir, "prompts"),
+	}
+}
+
+// env returns the container environment set by the -e flags.
+func (r agentRun) env() map[string]string {
+	env := map[string]string{}
+	for i, a := range r.args[:len(r.args)-1] {
+		if a == "-e" {
+			k, v, _ := strings.Cut(r.args[i+1], "=")
+			env[k] = v
+		}
+	}
+	return env
+}
+
+// startClaude runs the recorded init script on the host the way the
+// container would, with the -e environment and the prompt copy in place of
+// the prompt mount; the working directory and a moved home are remapped
+// into temp dirs. It returns what runInitScript does.
+func (r agentRun) startClaude(t *testing.T) string {
+	t.Helper()
+	if r.args[len(r.args)-1] != initScript {
+		t.Fatalf("docker run does not end with the init script: %q", r.args[len(r.args)-1])
+	}
+	env := r.env()
+	delete(env, "CONDUCTOR_WORKDIR")
+	env["CONDUCTOR_PROMPTS"] = r.prompts
+	if bin, ok := env["CONDUCTOR_TOOL_BIN"]; ok {
+		env["CONDUCTOR_TOOL_BIN"] = strings.Replace(bin, promptMountDir, r.prompts, 1)
+	}
+	if home, ok := env["CONDUCTOR_HOME"]; ok {
+		env["CONDUCTOR_HOME"] = filepath.Join(t.TempDir(), filepath.Base(home))
+	}
+	var vars []string
+	for k, v := range env {
+		vars = append(vars, k+"="+v)
+	}
+	return runInitScript(t, vars...)
+}
+
+// TestRunAgentMultiRepo verifies that RunAgent mounts each repository of a
+// multi-repository project at its configured path and mode, and that the
+// agent starts in its read-write mount.
+func TestRunAgentMultiRepo(t *testing.T) {
+	cfg := testRunConfig()
+	cfg.Project = config.Project{Repositories: map[string]config.Repository{
+		"api": {URL: "https://github.com/test/api.git"},
+		"sdk": {URL: "https://github.com/test/sdk.git"},
+	}}
+	cfg.Repos = map[string]string{"api": "/tmp/a/repo", "sdk": "/tmp/s/repo"}
+	def := testAgentDef()
+	def.Workspace = "ro"
+	def.Mounts = []config.MountDef{
+		{Repo: "api", Path: "/src/api"},
+		{Repo: "sdk", Path: "/src/sdk", Mode: "rw"},
+	}
+
+	run := recordAgentRun(t, "worker", def, cfg)
+	for _, want := range []string{"/tmp/a/repo:/src/api:ro", "/tmp/s/repo:/src/sdk"} {
+		if !slices.Contains(run.args, want) {
+			t.Errorf("args = %v, want mount %q", run.args, want)
+		}
+	}
+	if got := run.env()["CONDUCTOR_WORKDIR"]; got != "/src/sdk" {
+		t.Errorf("CONDUCTOR_WORKDIR = %q, want /src/sdk", got)
+	}
+	if out := run.startClaude(t); !strings.Contains(out, "Be brief.") {
+		t.Errorf("claude args = %q, want the rendered system prompt", out)
+	}
+}
+
+// TestRunAgentSandbox verifies that RunAgent applies the agent's resource
+// limits and sandbox settings, and that a read-only agent starts with a
+// writable home.
+func TestRunAgentSandbox(t *testing.T) {
+	def := testAgentDef()
+	def.Resources = config.Resources{CPUs: "1", Memory: "2g", PIDs: 128}
+	def.Sandbox = config.Sandbox{ReadOnlyRootFS: true, Seccomp: "/etc/conductor/seccomp.json"}
+
+	run := recordAgentRun(t, "worker", def, testRunConfig())
+	for _, want := range []string{"--cpus=1", "--memory=2g", "--pids-limit=128", "--read-only",
+		"/tmp:rw,nosuid,nodev,size=512m,uid=1000,gid=1000", "seccomp=/etc/conductor/seccomp.json"} {
+		if !slices.Contains(run.args, want) {
+			t.Errorf("args = %v, want %q", run.args, want)
+		}
+	}
+	if home := run.env()["CONDUCTOR_HOME"]; home != sandboxHome {
+		t.Errorf("CONDUCTOR_HOME = %q, want %s", home, sandboxHome)
+	}
+	// startClaude remaps the moved home to <temp>/home.
+	out := run.startClaude(t)
+	if i := strings.Index(out, "\nHOME="); i < 0 || !strings.HasSuffix(strings.TrimSpace(out[i:]), "/"+filepath.Base(sandboxHome)) {
+		t.Errorf("claude env = %q, want HOME moved to the sandbox home", out)
+	}
+}
+
+// TestRunAgentNetwork verifies that RunAgent puts an allowlisted agent on the
+// run's internal network behind its egress proxy, and cuts off an agent
+// with network mode none.
+func TestRunAgentNetwork(t *testing.T) {
+	cfg := testRunConfig()
+	cfg.Network = "conductor-test-net"
+	cfg.Proxies = map[string]string{"worker": "10.0.0.2:3128"}
+	def := testAgentDef()
+	def.Network = config.NetworkDef{Mode: "allowlist", Allow: []string{"api.github.com"}}
+
+	run := recordAgentRun(t, "worker", def, cfg)
+	if !slices.Contains(run.args, "--network=conductor-test-net") {
+		t.Errorf("args = %v, want the internal network", run.args)
+	}
+	if env := run.env(); env["HTTPS_PROXY"] != "http://10.0.0.2:3128" || env["NO_PROXY"] == "" {
+		t.Errorf("env = %v, want the egress proxy", env)
+	}
+	run.startClaude(t)
+
+	def.Network = config.NetworkDef{Mode: "none"}
+	run = recordAgentRun(t, "worker", def, cfg)
+	if !slices.Contains(run.args, "--network=none") || run.env()["HTTPS_PROXY"] != "" {
+		t.Errorf("args = %v, want no network and no proxy", run.args)
+	}
+}
+
+// TestRunAgentTools verifies that RunAgent passes a tool-restricted agent's
+// allowlist to Claude instead of skipping permissions, and that the tools'
+// CLI wrappers are all that is on PATH.
+func TestRunAgentTools(t *testing.T) {
+	def := testAgentDef()
+	def.Tools = []string{"git"}
+	def.RestrictPath = true
+
+	run := recordAgentRun(t, "worker", def, testRunConfig())
+	if run.env()["CONDUCTOR_ALLOWED_TOOLS"] == "" || run.env()["CONDUCTOR_TOOL_BIN"] == "" {
+		t.Fatalf("env = %v, want tool restrictions", run.env())
+	}
+	if _, err := os.Stat(filepath.Join(run.prompts, toolBinDir, "git")); err != nil {
+		t.Errorf("prompt mount lacks the git wrapper: %v", err)
+	}
+	out := run.startClaude(t)
+	if strings.Contains(out, "--dangerously-skip-permissions") || !strings.Contains(out, "--allowedTools\n") {
+		t.Errorf("claude args = %q, want --allowedTools", out)
+	}
+	if !strings.Contains(out, "PATH="+filepath.Join(run.prompts, toolBinDir)+"\n") {
+		t.Errorf("claude env = %q, want only the wrapper dir on PATH", out)
+	}
+}
+
+// TestRunAgentMCP verifies that RunAgent renders the agent's MCP servers
+// into the prompt mount and Claude uses them as its only MCP config.
+func TestRunAgentMCP(t *testing.T) {
+	cfg := testRunConfig()
+	cfg.Secrets = testSecrets
+	def := testAgentDef()
+	def.MCPServers = map[string]config.MCPServer{"tracker": {
+		Command: "tracker-mcp",
+		Env:     map[string]string{"TRACKER_API_TOKEN": "${secrets.tracker_token}"},
+	}}
+
+	run := recordAgentRun(t, "worker", def, cfg)
+	data, err := os.ReadFile(filepath.Join(run.prompts, mcpConfigFile))
+	if err != nil || !strings.Contains(string(data), "tracker-mcp") || strings.Contains(string(data), "secrets.") {
+		t.Fatalf("mcp config = %s, %v; want the rendered tracker server", data, err)
+	}
+	want := "--mcp-config\n" + filepath.Join(run.prompts, mcpConfigFile) + "\n--strict-mcp-config\n"
+	if out := run.startClaude(t); !strings.Contains(out, want) {
+		t.Errorf("claude args = %q, want %q", out, want)
+	}
+}
+
+// TestRunAgentStreamJSON verifies that the container RunAgent starts has
+// Claude report in stream-json, which the streaming parser relies on.
+func TestRunAgentStreamJSON(t *testing.T) {
+	run := recordAgentRun(t, "worker", testAgentDef(), testRunConfig())
+	if out := run.startClaude(t); !strings.Contains(out, "\n--output-format\nstream-json\n--verbose\n") {
+		t.Errorf("claude args = %q, want --output-format stream-json --verbose", out)
+	}
+}
@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// Resource defaults applied when an agent does not set its own limits.
+const (
+	defaultCPUs    = "2"
+	defaultMemory  = "4g"
+	defaultPIDs    = 512
+	defaultTmpSize = "512m"
+)
+
+// sandboxHome is the writable home of an agent with a read-only root
+// filesystem. It lies on the /tmp tmpfs; the init script seeds it from the
+// image's home, which keeps Claude's install.
+const sandboxHome = "/tmp/home"
+
+// sandboxArgs translates the agent's resources and sandbox settings into
+// `docker run` flags. Omitted limits fall back to the defaults above; swap is
+// capped at the memory limit. A read-only root filesystem always gets a
+// writable /tmp unless the agent sizes it explicitly, and HOME moves to
+// sandboxHome so git, gh and Claude can write their config.
+func sandboxArgs(def config.AgentDef) []string {
+	res := def.Resources
+	cpus, memory, pids := res.CPUs, res.Memory, res.PIDs
+	if cpus == "" {
+		cpus = defaultCPUs
+	}
+	if memory == "" {
+		memory = defaultMemory
+	}
+	if pids == 0 {
+		pids = defaultPIDs
+	}
+	args := []string{
+		"--cpus=" + cpus,
+		"--memory=" + memory, "--memory-swap=" + memory,
+		fmt.Sprintf("--pids-limit=%d", pids),
+	}
+
+	sb := def.Sandbox
+	tmpfs := sb.Tmpfs
+	if sb.ReadOnlyRootFS {
+		args = append(args, "--read-only", "-e", "CONDUCTOR_HOME="+sandboxHome)
+		if _, ok := tmpfs["/tmp"]; !ok {
+			tmpfs = map[string]string{"/tmp": defaultTmpSize}
+			for dir, size := range sb.Tmpfs {
+				tmpfs[dir] = size
+			}
+		}
+	}
+	dirs := make([]string, 0, len(tmpfs))
+	for dir := range tmpfs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		args = append(args, "--tmpfs",
+			fmt.Sprintf("%s:rw,nosuid,nodev,size=%s,uid=1000,gid=1000", dir, tmpfs[dir]))
+	}
+	if sb.Seccomp != "" {
+		args = append(args, "--security-opt", "seccomp="+sb.Seccomp)
+	}
+	return args
+}
@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// TestSandboxArgsDefaults verifies the limits applied when an agent sets none.
+func TestSandboxArgsDefaults(t *testing.T) {
+	want := []string{"--cpus=2", "--memory=4g", "--memory-swap=4g", "--pids-limit=512"}
+	if got := sandboxArgs(config.AgentDef{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("sandboxArgs = %v, want %v", got, want)
+	}
+}
+
+// TestSandboxArgsConfigured verifies the translation of explicit resources
+// and sandbox settings.
+func TestSandboxArgsConfigured(t *testing.T) {
+	def := config.AgentDef{
+		Resources: config.Resources{CPUs: "1.5", Memory: "8g", PIDs: 256},
+		Sandbox: config.Sandbox{
+			ReadOnlyRootFS: true,
+			Tmpfs:          map[string]string{"/home/agent/.cache": "1g"},
+			Seccomp:        "/etc/conductor/seccomp.json",
+		},
+	}
+	want := []string{
+		"--cpus=1.5", "--memory=8g", "--memory-swap=8g", "--pids-limit=256",
+		"--read-only", "-e", "CONDUCTOR_HOME=/tmp/home",
+		"--tmpfs", "/home/agent/.cache:rw,nosuid,nodev,size=1g,uid=1000,gid=1000",
+		"--tmpfs", "/tmp:rw,nosuid,nodev,size=512m,uid=1000,gid=1000",
+		"--security-opt", "seccomp=/etc/conductor/seccomp.json",
+	}
+	if got := sandboxArgs(def); !reflect.DeepEqual(got, want) {
+		t.Errorf("sandboxArgs =\n%v\nwant\n%v", got, want)
+	}
+}
+
+// TestSandboxArgsExplicitTmp verifies that an explicit /tmp size replaces
+// the read-only default.
+func TestSandboxArgsExplicitTmp(t *testing.T) {
+	def := config.AgentDef{Sandbox: config.Sandbox{
+		ReadOnlyRootFS: true,
+		Tmpfs:          map[string]string{"/tmp": "2g"},
+	}}
+	got := sandboxArgs(def)
+	want := "/tmp:rw,nosuid,nodev,size=2g,uid=1000,gid=1000"
+	if got[len(got)-1] != want {
+		t.Errorf("last arg = %q, want %q", got[len(got)-1], want)
+	}
+}
+
+// TestSandboxReadOnlyHome runs the init script with the environment the
+// read-only sandbox args set, /tmp standing in for the tmpfs, and verifies
+// that git writes its global config to the moved, writable home.
+func TestSandboxReadOnlyHome(t *testing.T) {
+	args := sandboxArgs(config.AgentDef{Sandbox: config.Sandbox{ReadOnlyRootFS: true}})
+	i := slices.Index(args, "-e")
+	if i < 0 || !strings.HasPrefix(args[i+1], "CONDUCTOR_HOME=") {
+		t.Fatalf("args = %v, want -e CONDUCTOR_HOME", args)
+	}
+	home := strings.TrimPrefix(args[i+1], "CONDUCTOR_HOME=")
+	if !slices.Contains(args, "/tmp:rw,nosuid,nodev,size=512m,uid=1000,gid=1000") || !strings.HasPrefix(home, "/tmp/") {
+		t.Fatalf("CONDUCTOR_HOME %s is not on the /tmp tmpfs: %v", home, args)
+	}
+
+	tmp := t.TempDir()
+	imageHome := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(imageHome, ".claude", "skills"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(imageHome, ".claude.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bin := t.TempDir()
+	for name, body := range map[string]string{"gh": "exit 0", "claude": `echo "HOME=$HOME"`} {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sandboxed := filepath.Join(tmp, strings.TrimPrefix(home, "/tmp/"))
+	cmd := exec.Command("bash", "-c", initScript)
+	cmd.Env = []string{"PATH=" + bin + ":/usr/bin:/bin", "HOME=" + imageHome,
+		"CONDUCTOR_HOME=" + sandboxed, "CONDUCTOR_WORKDIR=" + t.TempDir(),
+		"AGENT_GIT_NAME=Agent", "AGENT_GIT_EMAIL=agent@example.com"}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("init script: %v\n%s", err, out)
+	}
+
+	if !strings.Contains(string(out), "HOME="+sandboxed+"\n") {
+		t.Errorf("claude env = %q, want HOME=%s", out, sandboxed)
+	}
+	gitconfig, err := os.ReadFile(filepath.Join(sandboxed, ".gitconfig"))
+	if err != nil || !strings.Contains(string(gitconfig), "name = Agent") {
+		t.Errorf("gitconfig = %q, %v; want user.name in the moved home", gitconfig, err)
+	}
+	if _, err := os.Stat(filepath.Join(imageHome, ".gitconfig")); err == nil {
+		t.Error("git wrote to the image home")
+	}
+	for _, f := range []string{".claude.json", ".claude/skills"} {
+		if _, err := os.Stat(filepath.Join(sandboxed, f)); err != nil {
+			t.Errorf("moved home lacks %s: %v", f, err)
+		}
+	}
+}
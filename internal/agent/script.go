@@ -0,0 +1,72 @@
+package agent
+
+// initScript configures git, gh and SSH commit signing inside the container,
+// then execs Claude with the mounted prompts. With CONDUCTOR_HOME set, as
+// for a read-only root filesystem, HOME moves there first, carrying over
+// Claude's settings and skills from the image's home. It is passed as the single
+// argument to the image's `ENTRYPOINT ["/bin/bash", "-c"]`; all variable
+// parts come from container environment variables. Claude starts in
+// CONDUCTOR_WORKDIR, the agent's primary mount, and reports in stream-json
+// so the runner can follow the session as it happens.
+//
+// Each "<url> <env>" line of CONDUCTOR_GIT_TOKENS makes git authenticate to
+// that repository with the token in env alone, ahead of the gh helper.
+//
+// With CONDUCTOR_ALLOWED_TOOLS set, Claude runs with that --allowedTools list
+// instead of skipping permission checks, so any other tool is denied. An MCP
+// config in the prompt mount is passed as the only source of MCP servers. With
+// CONDUCTOR_TOOL_BIN set, that wrapper dir becomes the whole PATH once Claude's
+// own binary, its interpreter and the shell have been resolved, so Bash finds
+// only the agent's tools and the base shell utilities by name.
+const initScript = `if [ -n "${CONDUCTOR_HOME:-}" ]; then
+    mkdir -p "$CONDUCTOR_HOME/.claude"
+    cp "$HOME/.claude.json" "$CONDUCTOR_HOME/" 2>/dev/null
+    if [ -d "$HOME/.claude/skills" ]; then
+        ln -s "$HOME/.claude/skills" "$CONDUCTOR_HOME/.claude/skills"
+    fi
+    export HOME="$CONDUCTOR_HOME"
+fi
+git config --global user.name "$AGENT_GIT_NAME"
+git config --global user.email "$AGENT_GIT_EMAIL"
+echo "${AGENT_GH_TOKEN}" | gh auth login --with-token 2>/dev/null
+gh auth setup-git 2>/dev/null
+printf '%s\n' "${CONDUCTOR_GIT_TOKENS:-}" | while read -r url env; do
+    [ -n "$url" ] || continue
+    git config --global "credential.$url.helper" ""
+    git config --global --add "credential.$url.helper" \
+        "!f() { echo username=x-access-token; echo \"password=\$$env\"; }; f"
+done
+if [ -S "${SSH_AUTH_SOCK:-}" ]; then
+    signing_pubkey=$(ssh-add -L 2>/dev/null | head -1)
+    if [ -n "$signing_pubkey" ]; then
+        git config --global gpg.format ssh
+        git config --global user.signingkey "key::${signing_pubkey}"
+        git config --global commit.gpgsign true
+    fi
+fi
+cd "${CONDUCTOR_WORKDIR:-/workspace}" || exit 1
+prompts="${CONDUCTOR_PROMPTS:-/tmp/orchestrator-prompts}"
+system_prompt=$(cat "$prompts/system-prompt.txt")
+task_prompt=$(cat "$prompts/task-prompt.txt")
+if [ -n "${CONDUCTOR_ALLOWED_TOOLS:-}" ]; then
+    set -- --allowedTools "$CONDUCTOR_ALLOWED_TOOLS"
+else
+    set -- --dangerously-skip-permissions
+fi
+if [ -f "$prompts/mcp.json" ]; then
+    set -- "$@" --mcp-config "$prompts/mcp.json" --strict-mcp-config
+fi
+claude_bin=$(command -v claude)
+set -- "$claude_bin" -p "$@"
+read -r shebang < "$claude_bin"
+case "$shebang" in
+"#!/usr/bin/env "*) set -- "$(command -v "${shebang#"#!/usr/bin/env "}")" "$@" ;;
+esac
+if [ -n "${CONDUCTOR_TOOL_BIN:-}" ]; then
+    export SHELL="$(command -v bash)"
+    export CONDUCTOR_REAL_PATH="$PATH"
+    export PATH="$CONDUCTOR_TOOL_BIN"
+fi
+exec "$@" --output-format stream-json --verbose \
+    --system-prompt "$system_prompt" "$task_prompt"
+`
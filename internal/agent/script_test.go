@@ -0,0 +1,134 @@
+package agent
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runInitScript executes initScript with stub git, gh and claude binaries
+// and returns the arguments claude was invoked with, one per line, followed
+// by its PATH, working directory and HOME. Like an npm install of Claude,
+// the claude stub finds its interpreter through env. The workdir defaults to
+// a temp dir.
+func runInitScript(t *testing.T, env ...string) string {
+	t.Helper()
+	bin := t.TempDir()
+	stubs := map[string]string{
+		"git":    "exit 0",
+		"gh":     "exit 0",
+		"claude": `for a in "$@"; do echo "$a"; done; echo "PATH=$PATH"; echo "PWD=$PWD"; echo "HOME=$HOME"`,
+	}
+	for name, body := range stubs {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte("#!/usr/bin/env sh\n"+body+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command("bash", "-c", initScript)
+	cmd.Env = append([]string{"PATH=" + bin + ":/usr/bin:/bin", "HOME=" + t.TempDir(),
+		"CONDUCTOR_WORKDIR=" + t.TempDir()}, env...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("init script: %v\n%s", err, out)
+	}
+	return string(out)
+}
+
+// TestFR4_InitScriptUnrestricted verifies that agents without tools skip
+// permission checks.
+func TestFR4_InitScriptUnrestricted(t *testing.T) {
+	out := runInitScript(t)
+	if !strings.Contains(out, "--dangerously-skip-permissions\n") {
+		t.Errorf("claude args = %q, want --dangerously-skip-permissions", out)
+	}
+}
+
+// TestFR4_InitScriptWorkDir verifies that Claude starts in the agent's
+// primary mount and that a missing one aborts the session.
+func TestFR4_InitScriptWorkDir(t *testing.T) {
+	dir := t.TempDir()
+	if out := runInitScript(t, "CONDUCTOR_WORKDIR="+dir); !strings.Contains(out, "PWD="+dir+"\n") {
+		t.Errorf("claude env = %q, want PWD=%s", out, dir)
+	}
+
+	cmd := exec.Command("bash", "-c", initScript)
+	cmd.Env = []string{"PATH=/usr/bin:/bin", "HOME=" + t.TempDir(), "CONDUCTOR_WORKDIR=/nonexistent/workspace"}
+	if out, err := cmd.CombinedOutput(); err == nil {
+		t.Errorf("init script with missing workdir succeeded:\n%s", out)
+	}
+}
+
+// TestFR4_InitScriptAllowedTools verifies that a tool allowlist replaces
+// the permission bypass and that the wrapper dir replaces the system PATH.
+func TestFR4_InitScriptAllowedTools(t *testing.T) {
+	out := runInitScript(t,
+		"CONDUCTOR_ALLOWED_TOOLS=Read,Bash(git:*)",
+		"CONDUCTOR_TOOL_BIN=/tmp/orchestrator-prompts/bin")
+	if strings.Contains(out, "--dangerously-skip-permissions") {
+		t.Errorf("claude args = %q, must not skip permissions", out)
+	}
+	if !strings.Contains(out, "--allowedTools\nRead,Bash(git:*)\n") {
+		t.Errorf("claude args = %q, want --allowedTools list", out)
+	}
+	if !strings.Contains(out, "PATH=/tmp/orchestrator-prompts/bin\n") {
+		t.Errorf("claude env = %q, want only the wrapper dir on PATH", out)
+	}
+}
+
+// TestFR4_InitScriptMCPConfig verifies that a rendered MCP config in the
+// prompt mount is passed to Claude.
+func TestFR4_InitScriptMCPConfig(t *testing.T) {
+	prompts := t.TempDir()
+	if err := os.WriteFile(filepath.Join(prompts, mcpConfigFile), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := runInitScript(t, "CONDUCTOR_PROMPTS="+prompts)
+	want := "--mcp-config\n" + filepath.Join(prompts, mcpConfigFile) + "\n--strict-mcp-config\n"
+	if !strings.Contains(out, want) {
+		t.Errorf("claude args = %q, want --mcp-config", out)
+	}
+}
+
+// TestFR4_InitScriptGitTokens verifies that git inside the container
+// authenticates to each listed repository with the token in its own env
+// var, and to other repositories through gh as before.
+func TestFR4_InitScriptGitTokens(t *testing.T) {
+	bin, home := t.TempDir(), t.TempDir()
+	stubs := map[string]string{
+		"gh": `case "$1 $2" in
+"auth setup-git")
+    git config --global --add credential.https://github.com.helper ""
+    git config --global --add credential.https://github.com.helper "!gh auth git-credential" ;;
+"auth git-credential") echo username=x-access-token; echo "password=$AGENT_GH_TOKEN" ;;
+esac`,
+		"claude": "exit 0",
+	}
+	for name, body := range stubs {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	env := []string{"PATH=" + bin + ":/usr/bin:/bin", "HOME=" + home, "CONDUCTOR_WORKDIR=" + t.TempDir(),
+		"AGENT_GH_TOKEN=default-token", "SDK_GH_TOKEN=sdk-token"}
+	cmd := exec.Command("bash", "-c", initScript)
+	cmd.Env = append(env, "CONDUCTOR_GIT_TOKENS=https://github.com/acme/sdk.git SDK_GH_TOKEN")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("init script: %v\n%s", err, out)
+	}
+
+	for url, want := range map[string]string{
+		"https://github.com/acme/sdk.git":     "password=sdk-token\n",
+		"https://github.com/acme/widgets.git": "password=default-token\n",
+	} {
+		c := exec.Command("git", "credential", "fill")
+		c.Env = env
+		c.Stdin = strings.NewReader("url=" + url + "\n\n")
+		out, err := c.CombinedOutput()
+		if err != nil || !strings.Contains(string(out), want) {
+			t.Errorf("credential for %s = %q, %v, want %q", url, out, err, want)
+		}
+	}
+}
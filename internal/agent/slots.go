@@ -0,0 +1,97 @@
+package agent
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// Slots limits how many agent containers run at once, in total and per
+// agent, across all pipelines sharing it. Waiting steps are served highest
+// priority first, then in arrival order. A nil *Slots imposes no limits.
+type Slots struct {
+	max      int            // total limit, 0 = unlimited
+	perAgent map[string]int // agent name → limit; absent = unlimited
+
+	mu      sync.Mutex
+	running int
+	byAgent map[string]int
+	waiters []*slotWaiter
+	seq     int
+}
+
+// slotWaiter is a pending Acquire; ready is closed when it is granted.
+type slotWaiter struct {
+	agent    string
+	priority int
+	seq      int
+	ready    chan struct{}
+}
+
+// NewSlots returns slots allowing max containers in total (0 = unlimited)
+// and perAgent[name] containers of each listed agent.
+func NewSlots(max int, perAgent map[string]int) *Slots {
+	return &Slots{max: max, perAgent: perAgent, byAgent: map[string]int{}}
+}
+
+// Acquire blocks until a slot for agent is free or ctx is done. The
+// returned function releases the slot.
+func (s *Slots) Acquire(ctx context.Context, agent string, priority int) (release func(), err error) {
+	if s == nil {
+		return func() {}, nil
+	}
+	s.mu.Lock()
+	s.seq++
+	w := &slotWaiter{agent: agent, priority: priority, seq: s.seq, ready: make(chan struct{})}
+	s.waiters = append(s.waiters, w)
+	s.dispatch()
+	s.mu.Unlock()
+
+	release = func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.running--
+		s.byAgent[agent]--
+		s.dispatch()
+	}
+	select {
+	case <-w.ready:
+		return release, nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		select {
+		case <-w.ready:
+			// Granted while cancelling; hand the slot on.
+			s.running--
+			s.byAgent[agent]--
+			s.dispatch()
+		default:
+			s.waiters = slices.DeleteFunc(s.waiters, func(o *slotWaiter) bool { return o == w })
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// dispatch grants free slots to waiters in priority order, skipping those
+// whose agent is at its limit. The caller holds s.mu.
+func (s *Slots) dispatch() {
+	slices.SortStableFunc(s.waiters, func(a, b *slotWaiter) int {
+		if a.priority != b.priority {
+			return b.priority - a.priority
+		}
+		return a.seq - b.seq
+	})
+	s.waiters = slices.DeleteFunc(s.waiters, func(w *slotWaiter) bool {
+		if s.max > 0 && s.running >= s.max {
+			return false
+		}
+		if limit, ok := s.perAgent[w.agent]; ok && s.byAgent[w.agent] >= limit {
+			return false
+		}
+		s.running++
+		s.byAgent[w.agent]++
+		close(w.ready)
+		return true
+	})
+}
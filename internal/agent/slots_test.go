@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestSlotsLimits verifies the total and per-agent limits.
+func TestSlotsLimits(t *testing.T) {
+	s := NewSlots(3, map[string]int{"implementer": 2})
+	ctx := context.Background()
+
+	var releases []func()
+	for _, agent := range []string{"implementer", "implementer", "reviewer"} {
+		release, err := s.Acquire(ctx, agent, 0)
+		if err != nil {
+			t.Fatalf("Acquire(%s): %v", agent, err)
+		}
+		releases = append(releases, release)
+	}
+
+	for _, agent := range []string{"implementer", "reviewer"} {
+		short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		if _, err := s.Acquire(short, agent, 0); err == nil {
+			t.Errorf("Acquire(%s) beyond the limit succeeded", agent)
+		}
+		cancel()
+	}
+
+	releases[2]() // frees a total slot, but implementer stays at its limit
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := s.Acquire(short, "implementer", 0); err == nil {
+		t.Error("third implementer got a slot")
+	}
+	if release, err := s.Acquire(ctx, "reviewer", 0); err != nil {
+		t.Errorf("reviewer did not get the freed slot: %v", err)
+	} else {
+		release()
+	}
+}
+
+// TestSlotsPriority verifies that waiters are served by priority, then in
+// arrival order.
+func TestSlotsPriority(t *testing.T) {
+	s := NewSlots(1, nil)
+	ctx := context.Background()
+	hold, _ := s.Acquire(ctx, "a", 0)
+
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i, prio := range []int{0, 5, 0, 10} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := s.Acquire(ctx, "a", prio)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			release()
+		}()
+		// Let each waiter queue before the next arrives.
+		time.Sleep(20 * time.Millisecond)
+	}
+	hold()
+	wg.Wait()
+
+	want := []int{3, 1, 0, 2}
+	for i := range want {
+		if i >= len(order) || order[i] != want[i] {
+			t.Fatalf("service order = %v, want %v", order, want)
+		}
+	}
+}
+
+// TestSlotsNil verifies that a nil *Slots never blocks.
+func TestSlotsNil(t *testing.T) {
+	var s *Slots
+	release, err := s.Acquire(context.Background(), "a", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+}
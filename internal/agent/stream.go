@@ -0,0 +1,231 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+
+	"github.com/dmitriyb/conductor/internal/events"
+)
+
+// streamMessage is the subset of a Claude stream-json line the runner reads.
+type streamMessage struct {
+	Type     string       `json:"type"`
+	Subtype  string       `json:"subtype"`
+	Model    string       `json:"model"`
+	Result   string       `json:"result"`
+	IsError  bool         `json:"is_error"`
+	CostUSD  float64      `json:"total_cost_usd"`
+	NumTurns int          `json:"num_turns"`
+	Usage    *streamUsage `json:"usage"`
+	Message  struct {
+		ID      string          `json:"id"`
+		Model   string          `json:"model"`
+		Usage   *streamUsage    `json:"usage"`
+		Content []streamContent `json:"content"`
+	} `json:"message"`
+}
+
+// streamUsage is the token usage of one API call, or of the whole session
+// on the final result message.
+type streamUsage struct {
+	InputTokens         int `json:"input_tokens"`
+	OutputTokens        int `json:"output_tokens"`
+	CacheCreationTokens int `json:"cache_creation_input_tokens"`
+	CacheReadTokens     int `json:"cache_read_input_tokens"`
+}
+
+// streamContent is one content block of an assistant or user message.
+type streamContent struct {
+	Type    string          `json:"type"`
+	Text    string          `json:"text"`
+	Name    string          `json:"name"`
+	Input   map[string]any  `json:"input"`
+	Content json.RawMessage `json:"content"`
+	IsError bool            `json:"is_error"`
+}
+
+// streamParser turns Claude's stream-json output into progress events as
+// lines arrive, remembering the last pipeline marker payload it sees.
+type streamParser struct {
+	step, agent string
+	bus         *events.Bus
+	logger      *slog.Logger
+
+	payload string
+	found   bool
+
+	// Usage of the session so far: per assistant message while streaming,
+	// with cost estimated from list prices, replaced by Claude's own totals
+	// when the result message arrives.
+	calls map[string]streamCall
+	final *Usage
+}
+
+// streamCall is the usage of one API call and the model that served it.
+type streamCall struct {
+	model string
+	usage streamUsage
+}
+
+func newStreamParser(step, agent string, bus *events.Bus, logger *slog.Logger) *streamParser {
+	return &streamParser{step: step, agent: agent, bus: bus, logger: logger,
+		calls: map[string]streamCall{}}
+}
+
+// Feed parses one line of container stdout. Lines that are not stream-json
+// are published as raw output.
+func (p *streamParser) Feed(line string) {
+	var msg streamMessage
+	if err := json.Unmarshal([]byte(line), &msg); err != nil || msg.Type == "" {
+		p.scan(line)
+		p.emit(events.Event{Kind: events.KindOutput, Text: line})
+		return
+	}
+	switch msg.Type {
+	case "system":
+		if msg.Subtype == "init" {
+			p.emit(events.Event{Kind: events.KindInit, Data: map[string]any{"model": msg.Model}})
+		}
+	case "assistant":
+		if msg.Message.Usage != nil {
+			// Every content block of a message repeats the message's usage.
+			p.calls[msg.Message.ID] = streamCall{model: msg.Message.Model, usage: *msg.Message.Usage}
+		}
+		for _, c := range msg.Message.Content {
+			switch c.Type {
+			case "text":
+				p.scan(c.Text)
+				p.emit(events.Event{Kind: events.KindMessage, Text: c.Text})
+			case "tool_use":
+				p.emit(events.Event{Kind: events.KindToolUse, Tool: c.Name, Data: c.Input})
+			}
+		}
+	case "user":
+		for _, c := range msg.Message.Content {
+			if c.Type == "tool_result" {
+				p.emit(events.Event{Kind: events.KindToolResult, Text: toolResultText(c.Content),
+					Data: map[string]any{"is_error": c.IsError}})
+			}
+		}
+	case "result":
+		u := p.Usage()
+		if msg.Usage != nil {
+			u = msg.Usage.usage()
+		}
+		u.CostUSD = msg.CostUSD
+		if msg.NumTurns > 0 {
+			u.Turns = msg.NumTurns
+		}
+		p.final = &u
+		p.scan(msg.Result)
+		p.emit(events.Event{Kind: events.KindResult, Text: msg.Subtype, Data: map[string]any{
+			"is_error": msg.IsError, "cost_usd": u.CostUSD, "tokens": u.Tokens(), "turns": u.Turns,
+		}})
+	}
+}
+
+// LogText returns the human-readable text of one line of a step log: the
+// text blocks of an assistant message and the final result of a stream-json
+// line, nothing for other stream-json messages, and other lines unchanged.
+func LogText(line string) string {
+	var msg streamMessage
+	if err := json.Unmarshal([]byte(line), &msg); err != nil || msg.Type == "" {
+		return line
+	}
+	switch msg.Type {
+	case "assistant":
+		var parts []string
+		for _, c := range msg.Message.Content {
+			if c.Type == "text" && c.Text != "" {
+				parts = append(parts, c.Text)
+			}
+		}
+		return strings.Join(parts, "\n")
+	case "result":
+		return msg.Result
+	}
+	return ""
+}
+
+// Usage returns the session's usage so far. Until the result message
+// arrives, CostUSD is an estimate.
+func (p *streamParser) Usage() Usage {
+	if p.final != nil {
+		return *p.final
+	}
+	u := Usage{Turns: len(p.calls)}
+	for _, c := range p.calls {
+		cu := c.usage.usage()
+		cu.CostUSD = estimateCost(c.model, c.usage)
+		u.Add(cu)
+	}
+	return u
+}
+
+func (s streamUsage) usage() Usage {
+	return Usage{
+		InputTokens:         s.InputTokens,
+		OutputTokens:        s.OutputTokens,
+		CacheCreationTokens: s.CacheCreationTokens,
+		CacheReadTokens:     s.CacheReadTokens,
+	}
+}
+
+// Payload returns the last marker payload seen in the stream.
+func (p *streamParser) Payload() (string, bool) {
+	return p.payload, p.found
+}
+
+func (p *streamParser) scan(text string) {
+	if payload, ok := findMarker(text); ok {
+		p.payload, p.found = payload, true
+	}
+}
+
+// emit publishes e on the bus and mirrors it as a structured log record.
+// Tool calls and lifecycle events log at info, chatter at debug.
+func (p *streamParser) emit(e events.Event) {
+	e.Step, e.Agent = p.step, p.agent
+	p.bus.Publish(e)
+	level := slog.LevelDebug
+	attrs := []any{"kind", e.Kind}
+	switch e.Kind {
+	case events.KindInit, events.KindResult:
+		level = slog.LevelInfo
+	case events.KindToolUse:
+		level = slog.LevelInfo
+		attrs = append(attrs, "tool", e.Tool)
+	default:
+		attrs = append(attrs, "text", truncate(e.Text, 200))
+	}
+	p.logger.Log(context.Background(), level, "agent event", attrs...)
+}
+
+// toolResultText flattens a tool_result content field, which is either a
+// string or a list of text blocks.
+func toolResultText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var blocks []streamContent
+	if json.Unmarshal(raw, &blocks) != nil {
+		return ""
+	}
+	var parts []string
+	for _, b := range blocks {
+		if b.Type == "text" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "…"
+}
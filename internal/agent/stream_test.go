@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"math"
+	"testing"
+
+	"github.com/dmitriyb/conductor/internal/events"
+)
+
+// TestStreamParser verifies that stream-json lines become progress events
+// and that the last pipeline marker wins.
+func TestStreamParser(t *testing.T) {
+	bus := events.NewBus()
+	ch, unsubscribe := bus.Subscribe(16)
+	p := newStreamParser("build", "worker", bus, discard)
+
+	for _, line := range []string{
+		`{"type":"system","subtype":"init","model":"claude"}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"###PIPELINE_OUTPUT###{\"status\":\"failure\"}"},{"type":"tool_use","name":"Bash","input":{"command":"make"}}]}}`,
+		`{"type":"user","message":{"content":[{"type":"tool_result","content":[{"type":"text","text":"ok"}]}]}}`,
+		`{"type":"result","subtype":"success","result":"done\n###PIPELINE_OUTPUT###{\"status\":\"success\"}"}`,
+		`plain line`,
+	} {
+		p.Feed(line)
+	}
+	unsubscribe()
+
+	var got []events.Event
+	for e := range ch {
+		got = append(got, e)
+	}
+	want := []struct{ kind, tool, text string }{
+		{events.KindInit, "", ""},
+		{events.KindMessage, "", `###PIPELINE_OUTPUT###{"status":"failure"}`},
+		{events.KindToolUse, "Bash", ""},
+		{events.KindToolResult, "", "ok"},
+		{events.KindResult, "", "success"},
+		{events.KindOutput, "", "plain line"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		e := got[i]
+		if e.Kind != w.kind || e.Tool != w.tool || e.Text != w.text || e.Step != "build" || e.Agent != "worker" {
+			t.Errorf("event %d = %+v, want kind %q tool %q text %q", i, e, w.kind, w.tool, w.text)
+		}
+	}
+	if got[2].Data["command"] != "make" {
+		t.Errorf("tool_use data = %v, want tool input", got[2].Data)
+	}
+
+	payload, ok := p.Payload()
+	if !ok || payload != `{"status":"success"}` {
+		t.Errorf("Payload() = %q, %v, want last marker payload", payload, ok)
+	}
+}
+
+// TestStreamParserUsage verifies live usage and estimated cost from
+// assistant messages and that the result message's totals take over.
+func TestStreamParserUsage(t *testing.T) {
+	p := newStreamParser("build", "worker", nil, discard)
+	p.Feed(`{"type":"assistant","message":{"id":"m1","model":"claude-sonnet-4-5","usage":{"input_tokens":10,"output_tokens":5,"cache_read_input_tokens":100},"content":[{"type":"text","text":"a"}]}}`)
+	p.Feed(`{"type":"assistant","message":{"id":"m1","model":"claude-sonnet-4-5","usage":{"input_tokens":10,"output_tokens":5,"cache_read_input_tokens":100},"content":[{"type":"tool_use","name":"Bash"}]}}`)
+	p.Feed(`{"type":"assistant","message":{"id":"m2","model":"claude-sonnet-4-5","usage":{"input_tokens":20,"output_tokens":7},"content":[]}}`)
+
+	got := p.Usage()
+	// Sonnet list prices: (30*3 + 12*15 + 100*0.30) / 1e6.
+	if math.Abs(got.CostUSD-0.0003) > 1e-12 {
+		t.Errorf("live CostUSD = %g, want 0.0003", got.CostUSD)
+	}
+	got.CostUSD = 0
+	want := Usage{InputTokens: 30, OutputTokens: 12, CacheReadTokens: 100, Turns: 2}
+	if got != want {
+		t.Errorf("live Usage() = %+v, want %+v", got, want)
+	}
+
+	p.Feed(`{"type":"result","subtype":"success","total_cost_usd":0.25,"num_turns":3,"usage":{"input_tokens":40,"output_tokens":12,"cache_creation_input_tokens":8,"cache_read_input_tokens":100}}`)
+	got = p.Usage()
+	want = Usage{InputTokens: 40, OutputTokens: 12, CacheCreationTokens: 8, CacheReadTokens: 100, CostUSD: 0.25, Turns: 3}
+	if got != want {
+		t.Errorf("final Usage() = %+v, want %+v", got, want)
+	}
+}
+
+// TestLogText verifies that log lines yield assistant and result text,
+// nothing for other stream-json messages, and plain lines unchanged.
+func TestLogText(t *testing.T) {
+	for _, tc := range []struct{ line, want string }{
+		{`{"type":"system","subtype":"init","model":"claude"}`, ""},
+		{`{"type":"assistant","message":{"content":[{"type":"text","text":"one"},{"type":"tool_use","name":"Bash"},{"type":"text","text":"two"}]}}`, "one\ntwo"},
+		{`{"type":"user","message":{"content":[{"type":"tool_result","content":"ok"}]}}`, ""},
+		{`{"type":"result","subtype":"success","result":"done"}`, "done"},
+		{`make: *** [test] Error 1`, `make: *** [test] Error 1`},
+		{`{"level":"error"}`, `{"level":"error"}`},
+	} {
+		if got := LogText(tc.line); got != tc.want {
+			t.Errorf("LogText(%q) = %q, want %q", tc.line, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// TemplateData is the context task templates are rendered with.
+type TemplateData struct {
+	IssueNumber string
+	RepoURL     string
+	RepoOwner   string
+	RepoName    string
+	PRNumber    string
+	Steps       map[string]StepResult
+	Item        any                          // element of a for_each instance
+	Index       int                          // position of Item in the for_each list
+	Params      map[string]string            // params bound by the invoking pipeline step
+	Inputs      map[string]string            // run inputs given with --input or --batch
+	Artifacts   map[string]map[string]string // dependency step → artifact name → container path
+}
+
+// RenderPrompts returns the agent's system and task prompts. The system
+// prompt is used inline when it spans several lines and is otherwise read
+// from a file relative to repoPath; the output contract is appended to it.
+// The task prompt is executed as a Go template against data.
+func RenderPrompts(def config.AgentDef, repoPath string,
+	data TemplateData) (system, task string, err error) {
+	if strings.Contains(def.Prompt.System, "\n") {
+		system = def.Prompt.System
+	} else {
+		raw, err := os.ReadFile(filepath.Join(repoPath, def.Prompt.System))
+		if err != nil {
+			return "", "", fmt.Errorf("read system prompt: %w", err)
+		}
+		system = string(raw)
+	}
+	system += outputContract(def.OutputSchema)
+
+	task, err = RenderTemplate("task", def.Prompt.Task, data)
+	if err != nil {
+		return "", "", err
+	}
+	return system, task, nil
+}
+
+// RenderTemplate executes text as a Go template against data, with
+// config.CommandFuncs available; name labels errors.
+func RenderTemplate(name, text string, data TemplateData) (string, error) {
+	tmpl, err := template.New(name).Funcs(config.CommandFuncs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parse %s template: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("render %s template: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// outputContract describes the pipeline output marker protocol and the
+// fields the agent must report.
+func outputContract(schema map[string]any) string {
+	var b strings.Builder
+	b.WriteString("\n\n## Pipeline Output\n\n")
+	b.WriteString("When you are done, print exactly one line of the form:\n\n")
+	b.WriteString(pipelineMarker + `{"status":"success", ...}` + "\n\n")
+	b.WriteString("The JSON object must be on that single line. Use \"status\": \"failure\" ")
+	b.WriteString("with an \"error\" field if you could not complete the task.\n")
+	if len(schema) == 0 {
+		return b.String()
+	}
+	b.WriteString("Required fields:\n")
+	keys := make([]string, 0, len(schema))
+	for k := range schema {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "- %s (%v)\n", k, schema[k])
+	}
+	return b.String()
+}
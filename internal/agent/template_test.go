@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// TestFR1_RenderPrompts verifies system prompt loading, the appended output
+// contract, and task template rendering.
+func TestFR1_RenderPrompts(t *testing.T) {
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, "role.md"), []byte("You review code."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	def := config.AgentDef{
+		Prompt:       config.PromptDef{System: "role.md", Task: "Review PR #{{.PRNumber}} in {{.RepoOwner}}/{{.RepoName}}."},
+		OutputSchema: map[string]any{"summary": "string"},
+	}
+	system, task, err := RenderPrompts(def, repo, TemplateData{PRNumber: "42", RepoOwner: "o", RepoName: "r"})
+	if err != nil {
+		t.Fatalf("RenderPrompts: %v", err)
+	}
+	if !strings.HasPrefix(system, "You review code.") {
+		t.Errorf("system = %q, want file content first", system)
+	}
+	for _, want := range []string{pipelineMarker, "summary (string)"} {
+		if !strings.Contains(system, want) {
+			t.Errorf("system prompt missing %q", want)
+		}
+	}
+	if task != "Review PR #42 in o/r." {
+		t.Errorf("task = %q", task)
+	}
+}
+
+// TestFR1_RenderPromptsInline verifies that a multi-line system prompt is
+// used as-is and a missing prompt file is an error.
+func TestFR1_RenderPromptsInline(t *testing.T) {
+	def := config.AgentDef{Prompt: config.PromptDef{System: "line one\nline two", Task: "go"}}
+	system, _, err := RenderPrompts(def, "/nonexistent", TemplateData{})
+	if err != nil {
+		t.Fatalf("RenderPrompts: %v", err)
+	}
+	if !strings.HasPrefix(system, "line one\nline two") {
+		t.Errorf("system = %q, want inline prompt", system)
+	}
+
+	def.Prompt.System = "missing.md"
+	if _, _, err := RenderPrompts(def, "/nonexistent", TemplateData{}); err == nil {
+		t.Error("want error for missing system prompt file")
+	}
+}
@@ -0,0 +1,93 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// toolBinDir is the subdirectory of the prompt dir holding the PATH wrappers
+// of an agent with restrict_path; it appears under promptMountDir. It is the
+// agent's whole PATH, so a CLI without a wrapper cannot be run by name. Only
+// the unrestricted bash tool lets Bash name a binary by its absolute path.
+const toolBinDir = "bin"
+
+// Claude Code tools every tool-restricted agent keeps, so it can still read
+// (and, with a rw workspace, edit) the checked-out code.
+var (
+	baseTools = []string{"Read", "Glob", "Grep", "TodoWrite"}
+	editTools = []string{"Edit", "Write", "NotebookEdit"}
+)
+
+// baseBins are the shell utilities every agent with restrict_path keeps on
+// PATH besides its tools' CLIs. A wrapper for one the image lacks fails as a
+// missing command would.
+var baseBins = []string{
+	"sh", "bash", "env", "true", "false", "test", "[", "echo", "printf",
+	"cat", "ls", "pwd", "mkdir", "cp", "mv", "rm", "touch", "chmod", "ln",
+	"head", "tail", "wc", "sort", "uniq", "cut", "tr", "tee", "diff",
+	"grep", "sed", "find", "xargs", "dirname", "basename", "date", "sleep",
+}
+
+// allowedTools returns the --allowedTools list for the agent, or nil when the
+// agent declares no tools and runs unrestricted. The agent's own MCP servers
+// are always allowed.
+func allowedTools(def config.AgentDef) []string {
+	if len(def.Tools) == 0 {
+		return nil
+	}
+	allowed := append([]string{}, baseTools...)
+	if def.Workspace == "rw" {
+		allowed = append(allowed, editTools...)
+	}
+	for _, name := range def.Tools {
+		tool, _ := config.LookupTool(name)
+		allowed = append(allowed, tool.Allowed...)
+	}
+	return append(allowed, mcpTools(def)...)
+}
+
+// toolEnv returns the -e flags through which the init script learns the
+// agent's tool restrictions.
+func toolEnv(def config.AgentDef) []string {
+	var args []string
+	if tools := allowedTools(def); tools != nil {
+		args = append(args, "-e", "CONDUCTOR_ALLOWED_TOOLS="+strings.Join(tools, ","))
+	}
+	if def.RestrictPath {
+		args = append(args, "-e", "CONDUCTOR_TOOL_BIN="+promptMountDir+"/"+toolBinDir)
+	}
+	return args
+}
+
+// writeToolWrappers generates one wrapper script per CLI of the agent's tools
+// and per base shell utility in promptDir/bin. Each wrapper looks the real
+// binary up on the container's original PATH, which the init script saves
+// before replacing PATH with the wrapper dir, and execs it with PATH left
+// as is, so that a shell or make it starts is restricted too. It does
+// nothing unless the agent sets restrict_path.
+func writeToolWrappers(promptDir string, def config.AgentDef) error {
+	if !def.RestrictPath {
+		return nil
+	}
+	dir := filepath.Join(promptDir, toolBinDir)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return fmt.Errorf("tool wrappers: %w", err)
+	}
+	bins := slices.Clone(baseBins)
+	for _, name := range def.Tools {
+		tool, _ := config.LookupTool(name)
+		bins = append(bins, tool.Bins...)
+	}
+	for _, bin := range bins {
+		script := fmt.Sprintf("#!/bin/sh\nbin=$(PATH=\"$CONDUCTOR_REAL_PATH\"; command -v %s) || exit 127\nexec \"$bin\" \"$@\"\n", config.ShellQuote(bin))
+		if err := os.WriteFile(filepath.Join(dir, bin), []byte(script), 0755); err != nil {
+			return fmt.Errorf("tool wrappers: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// TestAllowedTools verifies the allowlist built from the tool catalog.
+func TestAllowedTools(t *testing.T) {
+	if got := allowedTools(config.AgentDef{Workspace: "rw"}); got != nil {
+		t.Errorf("no tools: allowedTools = %v, want nil (unrestricted)", got)
+	}
+
+	rw := allowedTools(config.AgentDef{Workspace: "rw", Tools: []string{"gh", "git"}})
+	for _, want := range []string{"Read", "Edit", "Bash(gh:*)", "Bash(git:*)"} {
+		if !slices.Contains(rw, want) {
+			t.Errorf("rw agent: allowedTools = %v, want %q", rw, want)
+		}
+	}
+	if slices.Contains(rw, "Bash") {
+		t.Errorf("rw agent: allowedTools = %v, must not grant unrestricted Bash", rw)
+	}
+
+	ro := allowedTools(config.AgentDef{Workspace: "ro", Tools: []string{"gh"}})
+	if slices.Contains(ro, "Edit") {
+		t.Errorf("ro agent: allowedTools = %v, must not include edit tools", ro)
+	}
+}
+
+// TestToolEnv verifies the env flags passed to the init script.
+func TestToolEnv(t *testing.T) {
+	def := config.AgentDef{Workspace: "ro", Tools: []string{"gh"}, RestrictPath: true}
+	args := toolEnv(def)
+	want := []string{
+		"-e", "CONDUCTOR_ALLOWED_TOOLS=Read,Glob,Grep,TodoWrite,Bash(gh:*)",
+		"-e", "CONDUCTOR_TOOL_BIN=/tmp/orchestrator-prompts/bin",
+	}
+	if !slices.Equal(args, want) {
+		t.Errorf("toolEnv = %v, want %v", args, want)
+	}
+	if args := toolEnv(config.AgentDef{}); args != nil {
+		t.Errorf("toolEnv without tools = %v, want nil", args)
+	}
+}
+
+// TestWriteToolWrappers verifies that one executable wrapper per CLI and base
+// utility is generated, that it runs the real binary from
+// CONDUCTOR_REAL_PATH, and that with the wrapper dir as PATH a CLI the agent
+// was not given cannot be found, not even from a shell a wrapper starts.
+func TestWriteToolWrappers(t *testing.T) {
+	dir := t.TempDir()
+	def := config.AgentDef{Tools: []string{"git", "web"}, RestrictPath: true}
+	if err := writeToolWrappers(dir, def); err != nil {
+		t.Fatalf("writeToolWrappers: %v", err)
+	}
+	var names []string
+	entries, _ := os.ReadDir(filepath.Join(dir, toolBinDir))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if len(names) != len(baseBins)+1 || !slices.Contains(names, "git") || !slices.Contains(names, "sh") {
+		t.Fatalf("wrappers = %v, want git and the base utilities", names)
+	}
+
+	bin := filepath.Join(dir, toolBinDir)
+	unlisted := t.TempDir()
+	if err := os.WriteFile(filepath.Join(unlisted, "gh"), []byte("#!/bin/sh\necho ran\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	realPath := unlisted + string(os.PathListSeparator) + os.Getenv("PATH")
+	cmd := exec.Command(filepath.Join(bin, "git"), "--version")
+	cmd.Env = append(os.Environ(), "PATH="+bin, "CONDUCTOR_REAL_PATH="+realPath)
+	out, err := cmd.CombinedOutput()
+	if err != nil || !strings.HasPrefix(string(out), "git version") {
+		t.Fatalf("wrapper output = %q, %v", out, err)
+	}
+
+	cmd = exec.Command(filepath.Join(bin, "sh"), "-c", "gh; sh -c gh; env gh")
+	cmd.Env = append(os.Environ(), "PATH="+bin, "CONDUCTOR_REAL_PATH="+realPath)
+	out, _ = cmd.CombinedOutput()
+	if strings.Contains(string(out), "ran") || strings.Count(string(out), "not found")+strings.Count(string(out), "No such file") < 3 {
+		t.Errorf("unlisted gh output = %q, want not found three times", out)
+	}
+
+	if err := writeToolWrappers(t.TempDir(), config.AgentDef{Tools: []string{"git"}}); err != nil {
+		t.Errorf("writeToolWrappers without restrict_path: %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dmitriyb/conductor/internal/state"
+)
+
+// route is one endpoint of the API. The table of routes both registers the
+// handlers and generates the OpenAPI document, so the two cannot drift.
+type route struct {
+	method, path string
+	summary      string
+	public       bool // served without the bearer token
+	body         any  // zero value of the request body, nil for none
+	resp         any  // zero value of the success response body
+	status       int  // success status
+	stream       bool // the response is a text/event-stream of Event
+	handle       http.HandlerFunc
+}
+
+// routes returns the endpoints of the API; runs started through them are
+// cancelled when ctx is done.
+func (s *Server) routes(ctx context.Context) []route {
+	return []route{
+		{method: "GET", path: "/v1/openapi.json", summary: "OpenAPI document of this API",
+			public: true, resp: map[string]any{}, status: http.StatusOK, handle: s.serveOpenAPI},
+		{method: "POST", path: "/v1/runs", summary: "Start a run of the served config or of a sent one",
+			body: StartRequest{}, resp: RunDetail{}, status: http.StatusAccepted,
+			handle: func(w http.ResponseWriter, r *http.Request) { s.startRun(ctx, w, r) }},
+		{method: "GET", path: "/v1/runs", summary: "List runs, newest first",
+			resp: RunList{}, status: http.StatusOK, handle: s.listRuns},
+		{method: "GET", path: "/v1/runs/{id}", summary: "Get a run and the status of its steps",
+			resp: RunDetail{}, status: http.StatusOK, handle: s.getRun},
+		{method: "GET", path: "/v1/runs/{id}/steps/{step}", summary: "Get the status and output of a step",
+			resp: state.StepRecord{}, status: http.StatusOK, handle: s.getStep},
+		{method: "GET", path: "/v1/runs/{id}/events", summary: "Stream the progress events and log lines of a run",
+			resp: Event{}, status: http.StatusOK, stream: true, handle: s.streamEvents},
+		{method: "GET", path: "/v1/runs/{id}/approvals/{step}", summary: "Get the pending approval request of a step",
+			resp: state.ApprovalRequest{}, status: http.StatusOK, handle: s.getApproval},
+		{method: "POST", path: "/v1/runs/{id}/approvals/{step}", summary: "Approve or reject the gate of a step",
+			body: DecisionRequest{}, resp: state.Decision{}, status: http.StatusOK, handle: s.decide},
+		{method: "POST", path: "/v1/runs/{id}/cancel", summary: "Cancel a run started by this server",
+			resp: RunDetail{}, status: http.StatusAccepted, handle: s.cancelRun},
+	}
+}
+
+// serveOpenAPI serves the OpenAPI document.
+func (s *Server) serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	reply(w, http.StatusOK, s.OpenAPI())
+}
+
+// pathParam matches the parameters of a route path.
+var pathParam = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// OpenAPI returns the OpenAPI 3.0 document of the API, generated from its
+// routes.
+func (s *Server) OpenAPI() map[string]any {
+	schemas := map[string]any{}
+	errorResp := map[string]any{"description": "error", "content": map[string]any{
+		"application/json": map[string]any{"schema": schemaOf(reflect.TypeOf(Error{}), schemas)}}}
+	paths := map[string]any{}
+	for _, rt := range s.routes(context.Background()) {
+		op := map[string]any{"summary": rt.summary}
+		var params []any
+		for _, m := range pathParam.FindAllStringSubmatch(rt.path, -1) {
+			params = append(params, map[string]any{"name": m[1], "in": "path", "required": true,
+				"schema": map[string]any{"type": "string"}})
+		}
+		if params != nil {
+			op["parameters"] = params
+		}
+		if rt.body != nil {
+			op["requestBody"] = map[string]any{"required": true, "content": map[string]any{
+				"application/json": map[string]any{"schema": schemaOf(reflect.TypeOf(rt.body), schemas)}}}
+		}
+		mediaType := "application/json"
+		if rt.stream {
+			mediaType = "text/event-stream"
+		}
+		op["responses"] = map[string]any{
+			strconv.Itoa(rt.status): map[string]any{
+				"description": strings.ToLower(http.StatusText(rt.status)),
+				"content": map[string]any{mediaType: map[string]any{
+					"schema": schemaOf(reflect.TypeOf(rt.resp), schemas)}},
+			},
+			"default": errorResp,
+		}
+		if !rt.public {
+			op["security"] = []any{map[string]any{"bearer": []any{}}}
+		}
+		item, _ := paths[rt.path].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[rt.path] = item
+		}
+		item[strings.ToLower(rt.method)] = op
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info":    map[string]any{"title": "conductor", "version": "1"},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+// timeType is the type of timestamps, described as date-time strings.
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaOf returns the JSON schema of values of t as encoding/json writes
+// them. Named structs are added to schemas and referenced.
+func schemaOf(t reflect.Type, schemas map[string]any) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Pointer:
+		return schemaOf(t.Elem(), schemas)
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		s := map[string]any{"type": "object"}
+		if t.Elem().Kind() != reflect.Interface {
+			s["additionalProperties"] = schemaOf(t.Elem(), schemas)
+		}
+		return s
+	case reflect.Struct:
+		ref := map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+		if _, ok := schemas[t.Name()]; ok {
+			return ref
+		}
+		schemas[t.Name()] = nil // placeholder against recursion
+		props, required := map[string]any{}, []any{}
+		structFields(t, schemas, props, &required)
+		s := map[string]any{"type": "object", "properties": props}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		schemas[t.Name()] = s
+		return ref
+	}
+	return map[string]any{} // any value
+}
+
+// structFields adds the JSON properties of struct t to props, flattening
+// embedded structs as encoding/json does. Fields without omitempty or
+// omitzero are required.
+func structFields(t reflect.Type, schemas map[string]any, props map[string]any, required *[]any) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			structFields(f.Type, schemas, props, required)
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = schemaOf(f.Type, schemas)
+		if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+// TestOpenAPI verifies that the document lists every route with its
+// parameters and security, and describes the body types as encoding/json
+// writes them.
+func TestOpenAPI(t *testing.T) {
+	s := &Server{}
+	doc := s.OpenAPI()
+	if _, err := json.Marshal(doc); err != nil {
+		t.Fatalf("document does not encode: %v", err)
+	}
+
+	paths := doc["paths"].(map[string]any)
+	for _, rt := range s.routes(t.Context()) {
+		op, ok := paths[rt.path].(map[string]any)[map[string]string{"GET": "get", "POST": "post"}[rt.method]].(map[string]any)
+		if !ok {
+			t.Errorf("%s %s missing from document", rt.method, rt.path)
+			continue
+		}
+		if _, secured := op["security"]; secured == rt.public {
+			t.Errorf("%s %s security = %v, want public %v", rt.method, rt.path, op["security"], rt.public)
+		}
+	}
+	step := paths["/v1/runs/{id}/steps/{step}"].(map[string]any)["get"].(map[string]any)
+	if params := step["parameters"].([]any); len(params) != 2 {
+		t.Errorf("step parameters = %v, want id and step", params)
+	}
+
+	schemas := doc["components"].(map[string]any)["schemas"].(map[string]any)
+	detail := schemas["RunDetail"].(map[string]any)
+	props := detail["properties"].(map[string]any)
+	for _, name := range []string{"id", "status", "started", "steps"} {
+		if _, ok := props[name]; !ok {
+			t.Errorf("RunDetail lacks property %q: %v", name, props)
+		}
+	}
+	if props["started"].(map[string]any)["format"] != "date-time" {
+		t.Errorf("started = %v, want a date-time", props["started"])
+	}
+	required := detail["required"].([]any)
+	if !slices.Contains(required, any("id")) || slices.Contains(required, any("error")) {
+		t.Errorf("RunDetail required = %v, want id but not error", required)
+	}
+	if items := props["steps"].(map[string]any)["items"].(map[string]any); items["$ref"] != "#/components/schemas/StepRecord" {
+		t.Errorf("steps items = %v", items)
+	}
+}
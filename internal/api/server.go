@@ -0,0 +1,418 @@
+// Package api serves the HTTP/JSON API of conductor api: starting runs,
+// inspecting their steps, streaming their progress, deciding approval gates
+// and cancelling runs.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dmitriyb/conductor/internal/config"
+	"github.com/dmitriyb/conductor/internal/events"
+	"github.com/dmitriyb/conductor/internal/state"
+)
+
+// maxBody bounds a request body.
+const maxBody = 1 << 20
+
+// keepAlive is how often an idle event stream sends a comment, so proxies
+// do not close it.
+var keepAlive = 15 * time.Second
+
+// Executor executes run of cfg for req, publishing its progress to bus, and
+// returns once the run has finished. cfg is the served config or, for
+// requests that send one, their validated config. The run's summary and step
+// statuses are expected in its record.
+type Executor func(ctx context.Context, cfg *config.Config, req StartRequest, run *state.Run, bus *events.Bus)
+
+// StartRequest asks for a run of the served config, or of a config sent
+// along when the served config allows it.
+type StartRequest struct {
+	Config   string            `json:"config,omitempty"`   // orchestrator.yaml to run, empty = the served config
+	Pipeline string            `json:"pipeline,omitempty"` // name in pipelines, empty = the main pipeline
+	Inputs   map[string]string `json:"inputs,omitempty"`   // run inputs, binding the pipeline's params
+}
+
+// DecisionRequest decides the approval gate of a step.
+type DecisionRequest struct {
+	Approved bool   `json:"approved"`
+	By       string `json:"by,omitempty"` // default api
+	Comment  string `json:"comment,omitempty"`
+}
+
+// RunList lists the runs of the state store, newest first.
+type RunList struct {
+	Runs []state.RunInfo `json:"runs"`
+}
+
+// RunDetail is a run's summary and the status of its steps.
+type RunDetail struct {
+	state.RunInfo
+	Steps []state.StepRecord `json:"steps"`
+}
+
+// Event is one progress event of a run, as sent on its event stream.
+type Event struct {
+	Time  time.Time      `json:"time"`
+	Step  string         `json:"step,omitempty"`
+	Agent string         `json:"agent,omitempty"`
+	Kind  string         `json:"kind"`
+	Text  string         `json:"text,omitempty"`
+	Tool  string         `json:"tool,omitempty"`
+	Data  map[string]any `json:"data,omitempty"`
+}
+
+// Error is the body of every error response.
+type Error struct {
+	Error string `json:"error"`
+}
+
+// Server answers the API requests for the runs of Runs. Runs it starts
+// execute Config through Execute.
+type Server struct {
+	Config  *config.Config
+	Runs    *state.Store
+	Token   string // bearer token every request but the OpenAPI document must present
+	Execute Executor
+	Logger  *slog.Logger
+
+	mu   sync.Mutex
+	live map[string]*liveRun // runs started by this server and not finished
+	wg   sync.WaitGroup
+}
+
+// liveRun is a run in progress started by the server.
+type liveRun struct {
+	cancel context.CancelFunc
+	bus    *events.Bus
+	done   chan struct{}
+}
+
+// Handler returns the HTTP handler of the API. Runs it starts are cancelled
+// when ctx is done.
+func (s *Server) Handler(ctx context.Context) http.Handler {
+	mux := http.NewServeMux()
+	for _, rt := range s.routes(ctx) {
+		h := rt.handle
+		if !rt.public {
+			h = s.authorize(h)
+		}
+		mux.HandleFunc(rt.method+" "+rt.path, h)
+	}
+	return mux
+}
+
+// Wait blocks until the runs started by the server have finished.
+func (s *Server) Wait() {
+	s.wg.Wait()
+}
+
+// authorize rejects requests without the bearer token.
+func (s *Server) authorize(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || s.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="conductor"`)
+			reply(w, http.StatusUnauthorized, Error{"missing or invalid bearer token"})
+			return
+		}
+		h(w, r)
+	}
+}
+
+// reply writes v as the JSON body of a response with status code.
+func reply(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// decode reads the JSON body of r into v.
+func decode(r *http.Request, v any) error {
+	dec := json.NewDecoder(io.LimitReader(r.Body, maxBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+// startRun starts a run for the request and returns its detail.
+func (s *Server) startRun(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req StartRequest
+	if err := decode(r, &req); err != nil {
+		reply(w, http.StatusBadRequest, Error{err.Error()})
+		return
+	}
+	cfg := s.Config
+	if req.Config != "" {
+		if !s.Config.API.AllowConfigs {
+			reply(w, http.StatusForbidden, Error{"this server only runs its own config (api.allow_configs is off)"})
+			return
+		}
+		var err error
+		if cfg, err = s.requestConfig(req.Config); err != nil {
+			reply(w, http.StatusBadRequest, Error{err.Error()})
+			return
+		}
+	}
+	if req.Pipeline != "" {
+		sub, ok := cfg.Pipelines[req.Pipeline]
+		if !ok {
+			reply(w, http.StatusBadRequest, Error{fmt.Sprintf("no pipeline %q", req.Pipeline)})
+			return
+		}
+		for _, param := range sub.Params {
+			if _, ok := req.Inputs[param]; !ok {
+				reply(w, http.StatusBadRequest, Error{fmt.Sprintf("missing input %q for param of pipeline %q", param, req.Pipeline)})
+				return
+			}
+		}
+	}
+	run, err := s.Runs.NewRun()
+	if err != nil {
+		reply(w, http.StatusInternalServerError, Error{err.Error()})
+		return
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	lr := &liveRun{cancel: cancel, bus: events.NewBus(), done: make(chan struct{})}
+	s.mu.Lock()
+	if s.live == nil {
+		s.live = map[string]*liveRun{}
+	}
+	s.live[run.ID] = lr
+	s.mu.Unlock()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+		s.Execute(runCtx, cfg, req, run, lr.bus)
+		s.mu.Lock()
+		delete(s.live, run.ID)
+		s.mu.Unlock()
+		close(lr.done)
+	}()
+	s.Logger.Info("api started run", "run_id", run.ID, "pipeline", req.Pipeline)
+	reply(w, http.StatusAccepted, s.detail(run))
+}
+
+// requestConfig parses and validates a config sent with a start request. It
+// gets the served config's credentials, so it can only use the secrets the
+// operator declared.
+func (s *Server) requestConfig(text string) (*config.Config, error) {
+	cfg, err := config.Parse("request config", []byte(text))
+	if err != nil {
+		return nil, err
+	}
+	cfg.Credentials = s.Config.Credentials
+	if err := config.Validate(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// running returns the run in progress with the given ID, or nil.
+func (s *Server) running(id string) *liveRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.live[id]
+}
+
+// info returns the summary of run. A run without one, because it has only
+// just started or predates summaries, gets its ID and, if this server runs
+// it, the running status.
+func (s *Server) info(run *state.Run) state.RunInfo {
+	if info, err := run.Info(); err == nil {
+		return *info
+	}
+	info := state.RunInfo{ID: run.ID}
+	if s.running(run.ID) != nil {
+		info.Status = state.StatusRunning
+	}
+	return info
+}
+
+// detail returns the summary of run and the status of its steps.
+func (s *Server) detail(run *state.Run) RunDetail {
+	d := RunDetail{RunInfo: s.info(run), Steps: []state.StepRecord{}}
+	if steps, err := run.Steps(); err == nil && steps != nil {
+		d.Steps = steps
+	}
+	return d
+}
+
+// lookup returns the run named by the request path, or replies 404.
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) (*state.Run, bool) {
+	run, err := s.Runs.Run(r.PathValue("id"))
+	if err != nil {
+		reply(w, http.StatusNotFound, Error{err.Error()})
+		return nil, false
+	}
+	return run, true
+}
+
+// stepErrorCode is the status of a failed step lookup: 400 for a malformed
+// step name, else 404.
+func stepErrorCode(err error) int {
+	if errors.Is(err, state.ErrInvalidStep) {
+		return http.StatusBadRequest
+	}
+	return http.StatusNotFound
+}
+
+// listRuns lists the runs of the store.
+func (s *Server) listRuns(w http.ResponseWriter, r *http.Request) {
+	runs, err := s.Runs.Runs()
+	if err != nil {
+		reply(w, http.StatusInternalServerError, Error{err.Error()})
+		return
+	}
+	list := RunList{Runs: []state.RunInfo{}}
+	for _, run := range slices.Backward(runs) {
+		list.Runs = append(list.Runs, s.info(run))
+	}
+	reply(w, http.StatusOK, list)
+}
+
+// getRun returns a run's summary and steps.
+func (s *Server) getRun(w http.ResponseWriter, r *http.Request) {
+	if run, ok := s.lookup(w, r); ok {
+		reply(w, http.StatusOK, s.detail(run))
+	}
+}
+
+// getStep returns the status and output of one step.
+func (s *Server) getStep(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	step, err := run.Step(r.PathValue("step"))
+	if err != nil {
+		reply(w, stepErrorCode(err), Error{err.Error()})
+		return
+	}
+	reply(w, http.StatusOK, step)
+}
+
+// getApproval returns the pending approval request of a step.
+func (s *Server) getApproval(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	req, err := run.Approval(r.PathValue("step"))
+	if err != nil {
+		reply(w, stepErrorCode(err), Error{err.Error()})
+		return
+	}
+	reply(w, http.StatusOK, req)
+}
+
+// decide records the decision for a step's approval gate.
+func (s *Server) decide(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	var req DecisionRequest
+	if err := decode(r, &req); err != nil {
+		reply(w, http.StatusBadRequest, Error{err.Error()})
+		return
+	}
+	step := r.PathValue("step")
+	if _, err := run.Approval(step); err != nil {
+		reply(w, stepErrorCode(err), Error{err.Error()})
+		return
+	}
+	d := state.Decision{Approved: req.Approved, By: req.By, Comment: req.Comment, DecidedAt: time.Now()}
+	if d.By == "" {
+		d.By = "api"
+	}
+	if err := run.Decide(step, d); err != nil {
+		reply(w, http.StatusConflict, Error{err.Error()})
+		return
+	}
+	s.Logger.Info("api decided approval", "run_id", run.ID, "step", step, "approved", d.Approved, "by", d.By)
+	reply(w, http.StatusOK, d)
+}
+
+// cancelRun cancels a run in progress started by this server.
+func (s *Server) cancelRun(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	lr := s.running(run.ID)
+	if lr == nil {
+		reply(w, http.StatusConflict, Error{fmt.Sprintf("run %s is not running in this server", run.ID)})
+		return
+	}
+	lr.cancel()
+	s.Logger.Info("api cancelled run", "run_id", run.ID)
+	reply(w, http.StatusAccepted, s.detail(run))
+}
+
+// streamEvents sends the progress events of a run as server-sent events
+// until it finishes, then an end event holding the run's detail. For a run
+// not in progress, only the end event is sent.
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		reply(w, http.StatusInternalServerError, Error{"streaming unsupported"})
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	send := func(kind string, v any) {
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", kind, data)
+		flusher.Flush()
+	}
+
+	if lr := s.running(run.ID); lr != nil {
+		ch, unsubscribe := lr.bus.Subscribe(256)
+		defer unsubscribe()
+		tick := time.NewTicker(keepAlive)
+		defer tick.Stop()
+		flusher.Flush()
+	stream:
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e := <-ch:
+				send(e.Kind, Event(e))
+			case <-tick.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+				flusher.Flush()
+			case <-lr.done:
+				break stream
+			}
+		}
+		for drained := false; !drained; { // events published before the run ended
+			select {
+			case e := <-ch:
+				send(e.Kind, Event(e))
+			default:
+				drained = true
+			}
+		}
+	}
+	send("end", s.detail(run))
+}
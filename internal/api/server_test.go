@@ -0,0 +1,357 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmitriyb/conductor/internal/config"
+	"github.com/dmitriyb/conductor/internal/events"
+	"github.com/dmitriyb/conductor/internal/state"
+)
+
+const testToken = "t0ken"
+
+// fakeExecute records a run of one step as a real run would, naming the
+// config's project as the step's agent. The step finishes when release is
+// closed, or is cancelled with the run.
+func fakeExecute(release <-chan struct{}) Executor {
+	return func(ctx context.Context, cfg *config.Config, req StartRequest, run *state.Run, bus *events.Bus) {
+		info := state.RunInfo{ID: run.ID, Status: state.StatusRunning, Inputs: req.Inputs, Started: time.Now()}
+		run.WriteInfo(info)
+		run.RecordStep(state.StepRecord{Name: "work", Agent: cfg.Project.Name, Status: state.StatusRunning, Started: info.Started})
+		bus.Publish(events.Event{Step: "work", Kind: events.KindStep, Text: state.StatusRunning})
+		bus.Publish(events.Event{Step: "work", Kind: events.KindOutput, Text: "working on " + req.Inputs["issue"]})
+		status := "success"
+		select {
+		case <-release:
+		case <-ctx.Done():
+			status = state.StatusCancelled
+		}
+		run.RecordStep(state.StepRecord{Name: "work", Agent: cfg.Project.Name, Status: status, Output: map[string]any{"issue": req.Inputs["issue"]},
+			Started: info.Started, Finished: time.Now()})
+		bus.Publish(events.Event{Step: "work", Kind: events.KindStep, Text: status})
+		info.Status, info.Finished = status, time.Now()
+		run.WriteInfo(info)
+	}
+}
+
+// testServer serves an API over a fresh state store.
+func testServer(t *testing.T, release <-chan struct{}) (*Server, *httptest.Server) {
+	t.Helper()
+	s := &Server{
+		Config: &config.Config{
+			Project:     config.Project{Name: "served"},
+			Credentials: config.Credentials{Backend: "env", Secrets: map[string]config.SecretRef{"github_pat": {Name: "GH", Env: "AGENT_GH_TOKEN"}}},
+			Pipelines:   map[string]config.PipelineDef{"fix": {Params: []string{"issue"}}},
+		},
+		Runs:    state.Open(t.TempDir()),
+		Token:   testToken,
+		Execute: fakeExecute(release),
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ts := httptest.NewServer(s.Handler(ctx))
+	t.Cleanup(func() {
+		ts.Close()
+		cancel()
+		s.Wait()
+	})
+	return s, ts
+}
+
+// call sends a request with the test token and decodes the JSON response
+// into out, if given. It returns the status code.
+func call(t *testing.T, ts *httptest.Server, method, path, body string, out any) int {
+	t.Helper()
+	req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decode: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// streamEvent is one server-sent event.
+type streamEvent struct {
+	kind string
+	data string
+}
+
+// readStream collects the events of a stream up to and including end.
+func readStream(t *testing.T, body io.Reader) []streamEvent {
+	t.Helper()
+	var got []streamEvent
+	var cur streamEvent
+	sc := bufio.NewScanner(body)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			cur.kind = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			cur.data = strings.TrimPrefix(line, "data: ")
+		case line == "" && cur.kind != "":
+			got = append(got, cur)
+			if cur.kind == "end" {
+				return got
+			}
+			cur = streamEvent{}
+		}
+	}
+	t.Fatalf("stream ended without end event: %+v", got)
+	return nil
+}
+
+// TestAuthorization verifies that every endpoint but the OpenAPI document
+// requires the bearer token.
+func TestAuthorization(t *testing.T) {
+	_, ts := testServer(t, nil)
+	for _, tt := range []struct {
+		path, auth string
+		want       int
+	}{
+		{"/v1/runs", "", http.StatusUnauthorized},
+		{"/v1/runs", "Bearer wrong", http.StatusUnauthorized},
+		{"/v1/runs", "Basic " + testToken, http.StatusUnauthorized},
+		{"/v1/runs", "Bearer " + testToken, http.StatusOK},
+		{"/v1/openapi.json", "", http.StatusOK},
+	} {
+		req, _ := http.NewRequest("GET", ts.URL+tt.path, nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("GET %s with %q = %d, want %d", tt.path, tt.auth, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+// TestRunLifecycle verifies starting a run, following its events until it
+// ends, and inspecting it, its steps and the run list afterwards.
+func TestRunLifecycle(t *testing.T) {
+	release := make(chan struct{})
+	_, ts := testServer(t, release)
+
+	var started RunDetail
+	if code := call(t, ts, "POST", "/v1/runs", `{"pipeline": "fix", "inputs": {"issue": "7"}}`, &started); code != http.StatusAccepted {
+		t.Fatalf("start = %d, want 202", code)
+	}
+	if started.ID == "" || started.Status != state.StatusRunning {
+		t.Errorf("started = %+v, want a running run", started)
+	}
+
+	req, _ := http.NewRequest("GET", ts.URL+"/v1/runs/"+started.ID+"/events", nil)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	close(release)
+	stream := readStream(t, resp.Body)
+	last := stream[len(stream)-1]
+	var end RunDetail
+	json.Unmarshal([]byte(last.data), &end)
+	if end.Status != "success" || len(end.Steps) != 1 || end.Steps[0].Status != "success" {
+		t.Errorf("end = %+v, want the finished run", end)
+	}
+	var sawStep bool
+	for _, e := range stream {
+		var ev Event
+		json.Unmarshal([]byte(e.data), &ev)
+		sawStep = sawStep || (e.kind == events.KindStep && ev.Step == "work" && ev.Text == "success")
+	}
+	if !sawStep {
+		t.Errorf("stream %+v lacks the step's success", stream)
+	}
+
+	var detail RunDetail
+	if code := call(t, ts, "GET", "/v1/runs/"+started.ID, "", &detail); code != http.StatusOK || detail.Inputs["issue"] != "7" {
+		t.Errorf("get run = %d %+v", code, detail)
+	}
+	var step state.StepRecord
+	if code := call(t, ts, "GET", "/v1/runs/"+started.ID+"/steps/work", "", &step); code != http.StatusOK || step.Output["issue"] != "7" {
+		t.Errorf("get step = %d %+v", code, step)
+	}
+	if code := call(t, ts, "GET", "/v1/runs/"+started.ID+"/steps/nope", "", nil); code != http.StatusNotFound {
+		t.Errorf("get unknown step = %d, want 404", code)
+	}
+	if code := call(t, ts, "GET", "/v1/runs/20200101-000000-abcdef", "", nil); code != http.StatusNotFound {
+		t.Errorf("get unknown run = %d, want 404", code)
+	}
+	if code := call(t, ts, "POST", "/v1/runs/"+started.ID+"/cancel", "", nil); code != http.StatusConflict {
+		t.Errorf("cancel finished run = %d, want 409", code)
+	}
+
+	var list RunList
+	if code := call(t, ts, "GET", "/v1/runs", "", &list); code != http.StatusOK || len(list.Runs) != 1 || list.Runs[0].Status != "success" {
+		t.Errorf("list = %d %+v", code, list)
+	}
+	stream = readStream(t, mustGet(t, ts, "/v1/runs/"+started.ID+"/events"))
+	if len(stream) != 1 {
+		t.Errorf("stream of finished run = %+v, want only end", stream)
+	}
+}
+
+func mustGet(t *testing.T, ts *httptest.Server, path string) io.Reader {
+	t.Helper()
+	req, _ := http.NewRequest("GET", ts.URL+path, nil)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp.Body
+}
+
+// TestStartErrors verifies that requests for unknown pipelines, unbound
+// params or with malformed bodies are rejected.
+func TestStartErrors(t *testing.T) {
+	_, ts := testServer(t, nil)
+	for body, want := range map[string]string{
+		`{"pipeline": "deploy"}`:  `no pipeline "deploy"`,
+		`{"pipeline": "fix"}`:     `missing input "issue"`,
+		`{"pipelines": "fix"}`:    "invalid request body",
+		`{"inputs": {"issue": 7}`: "invalid request body",
+	} {
+		var e Error
+		if code := call(t, ts, "POST", "/v1/runs", body, &e); code != http.StatusBadRequest || !strings.Contains(e.Error, want) {
+			t.Errorf("start %s = %d %q, want 400 %q", body, code, e.Error, want)
+		}
+	}
+}
+
+// sentConfig is a config a client sends to run instead of the served one.
+const sentConfig = `
+project: {name: sent, repository: https://github.com/acme/widgets.git}
+docker: {base_image: debian:bookworm-slim}
+agents:
+  worker: {prompt: {system: Work., task: Fix it.}, workspace: rw}
+pipeline:
+  - {name: fix, agent: worker}
+pipelines:
+  triage: {params: [issue], steps: [{name: look, agent: worker}]}
+`
+
+// TestStartConfig verifies that runs of sent configs are refused unless the
+// served config allows them, and are validated against the served
+// credentials.
+func TestStartConfig(t *testing.T) {
+	s, ts := testServer(t, nil)
+	body := func(cfg, extra string) string {
+		text, _ := json.Marshal(cfg)
+		return `{"config": ` + string(text) + extra + `}`
+	}
+	var e Error
+	if code := call(t, ts, "POST", "/v1/runs", body(sentConfig, ""), &e); code != http.StatusForbidden {
+		t.Errorf("start with config while disallowed = %d %q, want 403", code, e.Error)
+	}
+
+	s.Config.API.AllowConfigs = true
+	for cfg, want := range map[string]string{
+		"pipeline: [": "config: parse request config",
+		// The sent credentials are replaced by the served ones.
+		strings.Replace(sentConfig, "workspace: rw}", "workspace: rw, mcp_servers: {x: {command: 'x ${secrets.other}'}}}", 1) +
+			"credentials: {backend: env, secrets: {other: {name: OTHER, env: OTHER}}}\n": `undefined secret "other"`,
+		"project: {name: x}\n": "required",
+	} {
+		if code := call(t, ts, "POST", "/v1/runs", body(cfg, ""), &e); code != http.StatusBadRequest || !strings.Contains(e.Error, want) {
+			t.Errorf("start with config %q = %d %q, want 400 %q", cfg, code, e.Error, want)
+		}
+	}
+	if code := call(t, ts, "POST", "/v1/runs", body(sentConfig, `, "pipeline": "fix"`), &e); code != http.StatusBadRequest ||
+		!strings.Contains(e.Error, `no pipeline "fix"`) {
+		t.Errorf("start of served pipeline in sent config = %d %q, want 400", code, e.Error)
+	}
+
+	var started RunDetail
+	if code := call(t, ts, "POST", "/v1/runs", body(sentConfig, `, "pipeline": "triage", "inputs": {"issue": "3"}`), &started); code != http.StatusAccepted {
+		t.Fatalf("start with config = %d, want 202", code)
+	}
+	var step state.StepRecord
+	if code := call(t, ts, "GET", "/v1/runs/"+started.ID+"/steps/work", "", &step); code != http.StatusOK || step.Agent != "sent" {
+		t.Errorf("step = %d %+v, want a run of the sent config", code, step)
+	}
+}
+
+// TestCancel verifies that a running run can be cancelled.
+func TestCancel(t *testing.T) {
+	s, ts := testServer(t, nil)
+	var started RunDetail
+	call(t, ts, "POST", "/v1/runs", `{}`, &started)
+	if code := call(t, ts, "POST", "/v1/runs/"+started.ID+"/cancel", "", nil); code != http.StatusAccepted {
+		t.Fatalf("cancel = %d, want 202", code)
+	}
+	s.Wait()
+	var detail RunDetail
+	call(t, ts, "GET", "/v1/runs/"+started.ID, "", &detail)
+	if detail.Status != state.StatusCancelled {
+		t.Errorf("status = %q, want cancelled", detail.Status)
+	}
+}
+
+// TestApprovals verifies reading a pending approval request and deciding it
+// once.
+func TestApprovals(t *testing.T) {
+	s, ts := testServer(t, nil)
+	run, err := s.Runs.NewRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "/v1/runs/" + run.ID + "/approvals/gate"
+	if code := call(t, ts, "GET", path, "", nil); code != http.StatusNotFound {
+		t.Errorf("get missing approval = %d, want 404", code)
+	}
+	run.RequestApproval(state.ApprovalRequest{Step: "gate", Message: "Deploy?"})
+
+	var req state.ApprovalRequest
+	if code := call(t, ts, "GET", path, "", &req); code != http.StatusOK || req.Message != "Deploy?" {
+		t.Errorf("get approval = %d %+v", code, req)
+	}
+	var d state.Decision
+	if code := call(t, ts, "POST", path, `{"approved": true, "comment": "go"}`, &d); code != http.StatusOK {
+		t.Fatalf("decide = %d, want 200", code)
+	}
+	if !d.Approved || d.By != "api" || d.Comment != "go" {
+		t.Errorf("decision = %+v", d)
+	}
+	if code := call(t, ts, "POST", path, `{"approved": false}`, nil); code != http.StatusConflict {
+		t.Errorf("second decision = %d, want 409", code)
+	}
+
+	// An encoded traversal must not reach the run's other files.
+	escape := "/v1/runs/" + run.ID + "/approvals/..%2Finfo"
+	if code := call(t, ts, "GET", escape, "", nil); code != http.StatusBadRequest {
+		t.Errorf("get %s = %d, want 400", escape, code)
+	}
+	if code := call(t, ts, "POST", escape, `{"approved": true}`, nil); code != http.StatusBadRequest {
+		t.Errorf("decide %s = %d, want 400", escape, code)
+	}
+	if code := call(t, ts, "GET", "/v1/runs/"+run.ID+"/steps/..%2Finfo", "", nil); code != http.StatusBadRequest {
+		t.Errorf("get step ..%%2Finfo = %d, want 400", code)
+	}
+}
@@ -0,0 +1,243 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// CommandFuncs are the template functions available to step commands and
+// prompts beyond text/template's builtins.
+var CommandFuncs = template.FuncMap{"shellquote": ShellQuote}
+
+// ShellQuote renders v as one single-quoted sh word, so it reaches the
+// command as a literal argument whatever it contains.
+func ShellQuote(v any) string {
+	return "'" + strings.ReplaceAll(fmt.Sprint(v), "'", `'\''`) + "'"
+}
+
+// CheckCommand returns an error unless the shell or container step command
+// parses and passes every value it prints through shellquote last, outside
+// any shell quoting, comment or here-document. Outputs, inputs and params can
+// come from agents or PR comments, so unquoted, or quoted where the quotes of
+// shellquote have no effect, they would be a shell injection.
+func CheckCommand(text string) error {
+	tmpl, err := template.New("command").Funcs(CommandFuncs).Parse(text)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			var sc shellScan
+			errs = append(errs, sc.check(t.Tree.Root))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// shellScan follows the sh syntax of a command template's text far enough
+// to tell where its actions print: in a plain word, where a shellquoted value
+// is one literal argument, or somewhere its quotes would not hold.
+type shellScan struct {
+	open     string   // open contexts, innermost last: ' " ` $ (ANSI-C quote), ( (command substitution), # (comment)
+	escaped  bool     // the previous character was an unquoted or double-quoted backslash
+	prev     byte     // the previous character
+	heredocs []string // delimiters of here-documents whose bodies start on the next line
+	body     string   // delimiter of the here-document being read, "" outside one
+	line     string   // the here-document line read so far
+}
+
+// context identifies where the scan is, ignoring the text read.
+func (sc *shellScan) context() string {
+	return fmt.Sprintf("%s|%v|%s|%s", sc.open, sc.escaped, strings.Join(sc.heredocs, " "), sc.body)
+}
+
+// check walks the nodes of a command template, requiring each action that
+// prints to end in shellquote and to print in a plain word. Branches and loop
+// bodies must leave the scan in the context they started in.
+func (sc *shellScan) check(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, c := range n.Nodes {
+			if err := sc.check(c); err != nil {
+				return err
+			}
+		}
+	case *parse.TextNode:
+		sc.scan(string(n.Text))
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) > 0 {
+			return nil // assigns a variable, prints nothing
+		}
+		last := n.Pipe.Cmds[len(n.Pipe.Cmds)-1]
+		if id, ok := last.Args[0].(*parse.IdentifierNode); !ok || id.Ident != "shellquote" {
+			return fmt.Errorf("%s must be quoted, e.g. {{%s | shellquote}}", n, strings.Trim(n.String(), "{}"))
+		}
+		switch {
+		case sc.body != "":
+			return fmt.Errorf("%s is inside a here-document, where quotes do not protect it", n)
+		case strings.Contains(sc.open, "#"):
+			return fmt.Errorf("%s is inside a shell comment, which a newline in it would end", n)
+		case strings.Trim(sc.open, "(") != "":
+			return fmt.Errorf("%s is inside shell quotes, where shellquote does not protect it; leave it unquoted", n)
+		case sc.escaped || sc.prev == '$':
+			return fmt.Errorf("%s follows a backslash or $, which would change its quotes", n)
+		}
+		sc.prev = '\''
+	case *parse.IfNode:
+		return sc.branches(n, n.List, n.ElseList)
+	case *parse.RangeNode:
+		return sc.branches(n, n.List, n.ElseList)
+	case *parse.WithNode:
+		return sc.branches(n, n.List, n.ElseList)
+	case *parse.TemplateNode:
+		return fmt.Errorf("%s: template calls are not allowed", n)
+	}
+	return nil
+}
+
+// branches checks the lists of an if, range or with node, each from the
+// context before the node, and requires each to end in that context.
+func (sc *shellScan) branches(n parse.Node, lists ...*parse.ListNode) error {
+	before := *sc
+	for _, list := range lists {
+		*sc = before
+		sc.heredocs = slices.Clone(before.heredocs)
+		if err := sc.check(list); err != nil {
+			return err
+		}
+		if sc.context() != before.context() {
+			return fmt.Errorf("%s: its branches must close the shell quotes and comments they open", strings.SplitN(n.String(), "}}", 2)[0]+"}}")
+		}
+	}
+	return nil
+}
+
+// scan advances over text.
+func (sc *shellScan) scan(text string) {
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if sc.body != "" {
+			sc.scanBody(c)
+			continue
+		}
+		if sc.escaped {
+			sc.escaped = false
+			sc.prev = c
+			continue
+		}
+		top := byte(0)
+		if sc.open != "" {
+			top = sc.open[len(sc.open)-1]
+		}
+		switch top {
+		case '\'':
+			if c == '\'' {
+				sc.pop()
+			}
+		case '$':
+			if c == '\\' {
+				sc.escaped = true
+			} else if c == '\'' {
+				sc.pop()
+			}
+		case '`':
+			if c == '\\' {
+				sc.escaped = true
+			} else if c == '`' {
+				sc.pop()
+			}
+		case '"':
+			switch {
+			case c == '\\':
+				sc.escaped = true
+			case c == '"':
+				sc.pop()
+			case c == '`':
+				sc.open += "`"
+			case c == '(' && sc.prev == '$':
+				sc.open += "("
+			}
+		case '#':
+			if c == '\n' {
+				sc.pop()
+				sc.newline()
+			}
+		default: // a plain word context, at the top or in a command substitution
+			switch {
+			case c == '\\':
+				sc.escaped = true
+			case c == '\'' && sc.prev == '$':
+				sc.open += "$"
+			case c == '\'' || c == '"' || c == '`' || c == '(':
+				sc.open += string(c)
+			case c == ')' && top == '(':
+				sc.pop()
+			case c == '#' && (i == 0 && sc.prev == 0 || strings.IndexByte(" \t\n;&|()", sc.prev) >= 0):
+				sc.open += "#"
+			case c == '<' && strings.HasPrefix(text[i:], "<<") && !strings.HasPrefix(text[i:], "<<<"):
+				i += sc.heredoc(text[i+2:]) + 1
+				c = text[i]
+			case c == '\n':
+				sc.newline()
+			}
+		}
+		sc.prev = c
+	}
+}
+
+// pop closes the innermost open context.
+func (sc *shellScan) pop() {
+	sc.open = sc.open[:len(sc.open)-1]
+}
+
+// newline starts the body of the first pending here-document, if any.
+func (sc *shellScan) newline() {
+	if len(sc.heredocs) > 0 {
+		sc.body, sc.heredocs = sc.heredocs[0], sc.heredocs[1:]
+		sc.line = ""
+	}
+}
+
+// heredoc records the here-document whose operator precedes text and
+// returns the length of its delimiter word in text.
+func (sc *shellScan) heredoc(text string) int {
+	n := 0
+	if strings.HasPrefix(text, "-") {
+		n++
+	}
+	for n < len(text) && (text[n] == ' ' || text[n] == '\t') {
+		n++
+	}
+	end := n
+	for end < len(text) && strings.IndexByte(" \t\n;&|<>()", text[end]) < 0 {
+		end++
+	}
+	delim := strings.NewReplacer(`'`, "", `"`, "", `\`, "").Replace(text[n:end])
+	if delim == "" {
+		delim = "\n" // never matches a line: the rest of the command is the body
+	}
+	sc.heredocs = append(sc.heredocs, delim)
+	return end
+}
+
+// scanBody advances over one character of a here-document body, ending the
+// body at a line that is its delimiter.
+func (sc *shellScan) scanBody(c byte) {
+	if c != '\n' {
+		sc.line += string(c)
+		return
+	}
+	if strings.TrimLeft(sc.line, "\t") == sc.body {
+		sc.body = ""
+		sc.newline()
+	}
+	sc.line = ""
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+// TestShellQuote verifies that quoted values reach sh as one literal word.
+func TestShellQuote(t *testing.T) {
+	for _, v := range []any{"plain", "it's; rm -rf / #", "$(id) `id` $HOME", "", 42.0} {
+		out, err := exec.Command("sh", "-c", "printf %s "+ShellQuote(v)).Output()
+		if err != nil {
+			t.Fatalf("sh: %v", err)
+		}
+		if want := fmt.Sprint(v); string(out) != want {
+			t.Errorf("ShellQuote(%q) ran as %q, want %q", v, out, want)
+		}
+	}
+}
+
+// TestCheckCommand verifies that every printed value must be shellquoted.
+func TestCheckCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		wantErr string
+	}{
+		{"make test", ""},
+		{"gh pr merge {{.PRNumber | shellquote}}", ""},
+		{"gh pr merge {{shellquote .PRNumber}}", ""},
+		{`test {{index .Steps.lint.Output "issues" | shellquote}} = 2`, ""},
+		{"{{range .Inputs}}echo {{. | shellquote}}; {{end}}", ""},
+		{"{{if .PRNumber}}gh pr view {{.PRNumber | shellquote}}{{else}}true{{end}}", ""},
+		{"{{$pr := .PRNumber}}gh pr view {{$pr | shellquote}}", ""},
+		{"gh pr merge {{.PRNumber}}", "{{.PRNumber}} must be quoted"},
+		{"gh pr merge '{{.PRNumber}}'", "{{.PRNumber}} must be quoted"},
+		{"echo {{.Inputs.args | shellquote | printf \"%s\"}}", "must be quoted"},
+		{"{{if .PRNumber}}gh pr view {{.PRNumber}}{{end}}", "{{.PRNumber}} must be quoted"},
+		{`{{define "x"}}{{.}}{{end}}{{template "x" .PRNumber}}`, "must be quoted"},
+		{"echo {{.PRNumber", "unclosed action"},
+		{`echo "a" {{.PRNumber | shellquote}} 'b' # done`, ""},
+		{"git commit -m {{.PRNumber | shellquote}}\n# {{\"note\"}}", "must be quoted"},
+		{"cat <<'EOF' | sh\nexit 0\nEOF\necho {{.PRNumber | shellquote}}", ""},
+		{"cat <<-EOF\n\thi\n\tEOF\necho {{.PRNumber | shellquote}}", ""},
+		{"cat <<< {{.PRNumber | shellquote}}", ""},
+		{"(cd sub && make {{.PRNumber | shellquote}})", ""},
+		{`echo "{{.PRNumber | shellquote}}"`, "inside shell quotes"},
+		{`echo 'x{{.PRNumber | shellquote}}'`, "inside shell quotes"},
+		{"echo `echo {{.PRNumber | shellquote}}`", "inside shell quotes"},
+		{`echo "$(echo {{.PRNumber | shellquote}})"`, "inside shell quotes"},
+		{`echo $'{{.PRNumber | shellquote}}'`, "inside shell quotes"},
+		{"echo hi # {{.PRNumber | shellquote}}", "inside a shell comment"},
+		{"cat <<EOF\n{{.PRNumber | shellquote}}\nEOF", "inside a here-document"},
+		{`echo \{{.PRNumber | shellquote}}`, "follows a backslash or $"},
+		{`echo ${{.PRNumber | shellquote}}`, "follows a backslash or $"},
+		{`{{if .PRNumber}}echo "{{end}}{{.PRNumber | shellquote}}"`, "branches must close"},
+	}
+	for _, tt := range tests {
+		err := CheckCommand(tt.command)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("CheckCommand(%q): %v", tt.command, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("CheckCommand(%q) = %v, want %q", tt.command, err, tt.wantErr)
+		}
+	}
+}
+
+// TestCheckCommandHostileValue verifies that a value built to break out of
+// quotes runs no command in templates CheckCommand accepts, and that the
+// templates where it would are rejected.
+func TestCheckCommandHostileValue(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "pwned")
+	value := `$(touch pwned)` + "`touch pwned`" + `'"; touch pwned; echo "'`
+	for _, tt := range []struct {
+		command string
+		safe    bool
+	}{
+		{"echo {{. | shellquote}}", true},
+		{"printf '%s\\n' {{. | shellquote}} | wc -c", true},
+		{"echo {{. | shellquote}} # {{\"x\" | shellquote}}x", false},
+		{`echo "{{. | shellquote}}"`, false},
+		{"echo `echo {{. | shellquote}}`", false},
+	} {
+		err := CheckCommand(tt.command)
+		if (err == nil) != tt.safe {
+			t.Errorf("CheckCommand(%q) = %v, want accepted %v", tt.command, err, tt.safe)
+		}
+		var b strings.Builder
+		template.Must(template.New("c").Funcs(CommandFuncs).Parse(tt.command)).Execute(&b, value)
+		cmd := exec.Command("sh", "-c", b.String())
+		cmd.Dir = dir
+		cmd.Run()
+		_, statErr := os.Stat(marker)
+		if ran := statErr == nil; ran && tt.safe {
+			t.Errorf("%q ran the value as a command", tt.command)
+		}
+		os.Remove(marker)
+	}
+}
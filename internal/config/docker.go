@@ -0,0 +1,29 @@
+package config
+
+// Override returns d with the non-empty fields of o applied on top.
+// Build args are merged key by key, with o taking precedence.
+func (d Docker) Override(o Docker) Docker {
+	merged := d
+	if o.BaseImage != "" {
+		merged.BaseImage = o.BaseImage
+	}
+	if o.Dockerfile != "" {
+		merged.Dockerfile = o.Dockerfile
+	}
+	if len(o.BuildArgs) > 0 {
+		merged.BuildArgs = make(map[string]string, len(d.BuildArgs)+len(o.BuildArgs))
+		for k, v := range d.BuildArgs {
+			merged.BuildArgs[k] = v
+		}
+		for k, v := range o.BuildArgs {
+			merged.BuildArgs[k] = v
+		}
+	}
+	return merged
+}
+
+// AgentDocker returns the effective docker config of the named agent: the
+// project docker config with the agent's overrides applied.
+func (c *Config) AgentDocker(agent string) Docker {
+	return c.Docker.Override(c.Agents[agent].Docker)
+}
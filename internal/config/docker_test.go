@@ -0,0 +1,40 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestAgentDocker verifies that agent docker overrides replace set fields,
+// inherit unset ones, and merge build args.
+func TestAgentDocker(t *testing.T) {
+	cfg := &Config{
+		Docker: Docker{
+			BaseImage:  "debian:bookworm-slim",
+			Dockerfile: "Dockerfile.agent",
+			BuildArgs:  map[string]string{"ZIG_VERSION": "0.14.1", "GO_VERSION": "1.25"},
+		},
+		Agents: map[string]AgentDef{
+			"implementer": {Docker: Docker{
+				BaseImage: "rust:1.80-bookworm",
+				BuildArgs: map[string]string{"ZIG_VERSION": "0.15.0"},
+			}},
+			"reviewer": {},
+		},
+	}
+
+	want := Docker{
+		BaseImage:  "rust:1.80-bookworm",
+		Dockerfile: "Dockerfile.agent",
+		BuildArgs:  map[string]string{"ZIG_VERSION": "0.15.0", "GO_VERSION": "1.25"},
+	}
+	if got := cfg.AgentDocker("implementer"); !reflect.DeepEqual(got, want) {
+		t.Errorf("AgentDocker(implementer) = %+v, want %+v", got, want)
+	}
+	if got := cfg.AgentDocker("reviewer"); !reflect.DeepEqual(got, cfg.Docker) {
+		t.Errorf("AgentDocker(reviewer) = %+v, want project docker %+v", got, cfg.Docker)
+	}
+	if cfg.Docker.BuildArgs["ZIG_VERSION"] != "0.14.1" {
+		t.Error("AgentDocker mutated the project build args")
+	}
+}
@@ -14,9 +14,15 @@ func Load(path string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("config: read %s: %w", path, err)
 	}
+	return Parse(path, data)
+}
+
+// Parse parses orchestrator.yaml content, naming it source in errors. Like
+// Load, it performs no validation.
+func Parse(source string, data []byte) (*Config, error) {
 	var cfg Config
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("config: parse %s: %w", path, err)
+		return nil, fmt.Errorf("config: parse %s: %w", source, err)
 	}
 	return &cfg, nil
 }
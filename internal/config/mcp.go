@@ -0,0 +1,51 @@
+package config
+
+import (
+	"regexp"
+	"sort"
+)
+
+// secretRefPattern matches ${secrets.<key>} references to credentials.secrets.
+var secretRefPattern = regexp.MustCompile(`\$\{secrets\.([A-Za-z0-9_-]+)\}`)
+
+// SecretRefs returns the credentials.secrets keys referenced by the server,
+// sorted and deduplicated.
+func (s MCPServer) SecretRefs() []string {
+	seen := map[string]bool{}
+	collect := func(v string) {
+		for _, m := range secretRefPattern.FindAllStringSubmatch(v, -1) {
+			seen[m[1]] = true
+		}
+	}
+	collect(s.Command)
+	collect(s.URL)
+	for _, a := range s.Args {
+		collect(a)
+	}
+	for _, v := range s.Env {
+		collect(v)
+	}
+	for _, v := range s.Headers {
+		collect(v)
+	}
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ExpandSecretRefs replaces each ${secrets.<key>} in v with ${<ENV>}, where
+// ENV is the container environment variable the secret is exposed as. The
+// agent resolves the variable at runtime, so secret values never appear in
+// rendered files. References to unknown keys are left untouched.
+func ExpandSecretRefs(v string, secrets map[string]SecretRef) string {
+	return secretRefPattern.ReplaceAllStringFunc(v, func(m string) string {
+		ref, ok := secrets[secretRefPattern.FindStringSubmatch(m)[1]]
+		if !ok {
+			return m
+		}
+		return "${" + ref.Env + "}"
+	})
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestMCPServerSecretRefs verifies that secret references are collected
+// from every field that may hold them.
+func TestMCPServerSecretRefs(t *testing.T) {
+	srv := MCPServer{
+		Command: "tracker-mcp",
+		Args:    []string{"--token=${secrets.b}"},
+		Env:     map[string]string{"X": "${secrets.a}", "Y": "plain", "Z": "${secrets.b}"},
+	}
+	if got := srv.SecretRefs(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("SecretRefs = %v, want [a b]", got)
+	}
+	srv = MCPServer{URL: "https://x/${secrets.c}", Headers: map[string]string{"Authorization": "Bearer ${secrets.d}"}}
+	if got := srv.SecretRefs(); !reflect.DeepEqual(got, []string{"c", "d"}) {
+		t.Errorf("SecretRefs = %v, want [c d]", got)
+	}
+	srv = MCPServer{Command: "/opt/${secrets.e}/mcp"}
+	if got := srv.SecretRefs(); !reflect.DeepEqual(got, []string{"e"}) {
+		t.Errorf("SecretRefs = %v, want [e]", got)
+	}
+}
+
+// TestExpandSecretRefs verifies that references become container env var
+// references and that unknown keys are left as written.
+func TestExpandSecretRefs(t *testing.T) {
+	secrets := map[string]SecretRef{"gh": {Name: "pat", Env: "AGENT_GH_TOKEN"}}
+	got := ExpandSecretRefs("Bearer ${secrets.gh} ${secrets.nope}", secrets)
+	if want := "Bearer ${AGENT_GH_TOKEN} ${secrets.nope}"; got != want {
+		t.Errorf("ExpandSecretRefs = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultRepo is the name under which project.repository is exposed
+	// alongside any named project.repositories.
+	DefaultRepo = "default"
+
+	// DefaultTokenSecret is the credentials.secrets key used to authenticate
+	// clones when a repository does not name its own token.
+	DefaultTokenSecret = "github_pat"
+
+	// WorkspaceRoot is the container path under which repositories are mounted.
+	WorkspaceRoot = "/workspace"
+)
+
+// Repos returns every repository of the project keyed by name. A single
+// project.repository is included as DefaultRepo with the default token.
+func (p Project) Repos() map[string]Repository {
+	repos := make(map[string]Repository, len(p.Repositories)+1)
+	for name, r := range p.Repositories {
+		repos[name] = r
+	}
+	if p.Repository != "" {
+		repos[DefaultRepo] = Repository{URL: p.Repository}
+	}
+	return repos
+}
+
+// TokenSecret returns the credentials.secrets key used to clone r.
+func (r Repository) TokenSecret() string {
+	if r.Token == "" {
+		return DefaultTokenSecret
+	}
+	return r.Token
+}
+
+// HTTPSURL returns the URL r is cloned from: GitHub SSH URLs are rewritten
+// to their HTTPS form, others are returned unchanged.
+func (r Repository) HTTPSURL() string {
+	if strings.HasPrefix(r.URL, "git@github.com:") {
+		return strings.Replace(r.URL, "git@github.com:", "https://github.com/", 1)
+	}
+	return r.URL
+}
+
+// WorkspaceMounts returns the repositories mounted into the agent's container.
+// Without explicit mounts, a project with a single repository gets it at
+// /workspace, and a multi-repository project gets each repository at
+// /workspace/<name> in sorted order. Mounts without a mode inherit the
+// agent's workspace mode.
+func (a AgentDef) WorkspaceMounts(p Project) []MountDef {
+	if len(a.Mounts) > 0 {
+		mounts := make([]MountDef, len(a.Mounts))
+		for i, m := range a.Mounts {
+			if m.Mode == "" {
+				m.Mode = a.Workspace
+			}
+			mounts[i] = m
+		}
+		return mounts
+	}
+	if p.Repository != "" {
+		return []MountDef{{Repo: DefaultRepo, Path: WorkspaceRoot, Mode: a.Workspace}}
+	}
+	names := make([]string, 0, len(p.Repositories))
+	for name := range p.Repositories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	mounts := make([]MountDef, 0, len(names))
+	for _, name := range names {
+		mounts = append(mounts, MountDef{Repo: name, Path: path.Join(WorkspaceRoot, name), Mode: a.Workspace})
+	}
+	return mounts
+}
+
+// WorkDir returns the container directory the agent starts in: the path of
+// its first read-write mount, else of its first mount, else WorkspaceRoot.
+func (a AgentDef) WorkDir(p Project) string {
+	mounts := a.WorkspaceMounts(p)
+	for _, m := range mounts {
+		if m.Mode == "rw" {
+			return m.Path
+		}
+	}
+	if len(mounts) > 0 {
+		return mounts[0].Path
+	}
+	return WorkspaceRoot
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestLoadMultiRepo verifies that named repositories and per-agent mounts
+// load from YAML and pass validation.
+func TestLoadMultiRepo(t *testing.T) {
+	cfg, err := Load("testdata/multirepo.yaml")
+	if err != nil {
+		t.Fatalf("Load returned unexpected error: %v", err)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("Validate returned unexpected error: %v", err)
+	}
+
+	wantRepos := map[string]Repository{
+		"api": {URL: "https://github.com/example/api.git"},
+		"sdk": {URL: "git@github.com:example/sdk.git", Token: "sdk_pat"},
+	}
+	if !reflect.DeepEqual(cfg.Project.Repositories, wantRepos) {
+		t.Errorf("Project.Repositories = %v, want %v", cfg.Project.Repositories, wantRepos)
+	}
+
+	wantMounts := []MountDef{
+		{Repo: "api", Path: "/workspace/api"},
+		{Repo: "sdk", Path: "/workspace/sdk"},
+	}
+	if got := cfg.Agents["implementer"].Mounts; !reflect.DeepEqual(got, wantMounts) {
+		t.Errorf("implementer.Mounts = %v, want %v", got, wantMounts)
+	}
+}
+
+// TestProjectRepos verifies that project.repository is exposed as
+// DefaultRepo alongside named repositories.
+func TestProjectRepos(t *testing.T) {
+	p := Project{
+		Repository:   "https://github.com/test/main.git",
+		Repositories: map[string]Repository{"sdk": {URL: "https://github.com/test/sdk.git"}},
+	}
+	want := map[string]Repository{
+		DefaultRepo: {URL: "https://github.com/test/main.git"},
+		"sdk":       {URL: "https://github.com/test/sdk.git"},
+	}
+	if got := p.Repos(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Repos() = %v, want %v", got, want)
+	}
+}
+
+// TestRepositoryTokenSecret verifies the default and explicit token keys.
+func TestRepositoryTokenSecret(t *testing.T) {
+	if got := (Repository{}).TokenSecret(); got != DefaultTokenSecret {
+		t.Errorf("TokenSecret() = %q, want %q", got, DefaultTokenSecret)
+	}
+	if got := (Repository{Token: "sdk_pat"}).TokenSecret(); got != "sdk_pat" {
+		t.Errorf("TokenSecret() = %q, want %q", got, "sdk_pat")
+	}
+}
+
+// TestWorkspaceMounts verifies the default mount layouts and mode
+// inheritance for explicit mounts.
+func TestWorkspaceMounts(t *testing.T) {
+	single := Project{Repository: "https://github.com/test/repo.git"}
+	multi := Project{Repositories: map[string]Repository{
+		"sdk": {URL: "https://github.com/test/sdk.git"},
+		"api": {URL: "https://github.com/test/api.git"},
+	}}
+
+	tests := []struct {
+		name    string
+		agent   AgentDef
+		project Project
+		want    []MountDef
+	}{
+		{
+			name:    "single repository at workspace root",
+			agent:   AgentDef{Workspace: "rw"},
+			project: single,
+			want:    []MountDef{{Repo: DefaultRepo, Path: "/workspace", Mode: "rw"}},
+		},
+		{
+			name:    "named repositories under workspace root",
+			agent:   AgentDef{Workspace: "ro"},
+			project: multi,
+			want: []MountDef{
+				{Repo: "api", Path: "/workspace/api", Mode: "ro"},
+				{Repo: "sdk", Path: "/workspace/sdk", Mode: "ro"},
+			},
+		},
+		{
+			name: "explicit mounts inherit workspace mode",
+			agent: AgentDef{Workspace: "ro", Mounts: []MountDef{
+				{Repo: "api", Path: "/workspace/api", Mode: "rw"},
+				{Repo: "sdk", Path: "/workspace/sdk"},
+			}},
+			project: multi,
+			want: []MountDef{
+				{Repo: "api", Path: "/workspace/api", Mode: "rw"},
+				{Repo: "sdk", Path: "/workspace/sdk", Mode: "ro"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.agent.WorkspaceMounts(tt.project); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("WorkspaceMounts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestWorkDir verifies that agents start in their first read-write mount,
+// else their first mount.
+func TestWorkDir(t *testing.T) {
+	p := Project{Repositories: map[string]Repository{"api": {}, "sdk": {}}}
+	tests := []struct {
+		agent AgentDef
+		want  string
+	}{
+		{AgentDef{Workspace: "ro"}, "/workspace/api"},
+		{AgentDef{Workspace: "ro", Mounts: []MountDef{
+			{Repo: "api", Path: "/src/api"},
+			{Repo: "sdk", Path: "/src/sdk", Mode: "rw"},
+		}}, "/src/sdk"},
+		{AgentDef{Workspace: "ro", Mounts: []MountDef{{Repo: "sdk", Path: "/src/sdk"}}}, "/src/sdk"},
+	}
+	for _, tt := range tests {
+		if got := tt.agent.WorkDir(p); got != tt.want {
+			t.Errorf("WorkDir(%+v) = %q, want %q", tt.agent.Mounts, got, tt.want)
+		}
+	}
+}
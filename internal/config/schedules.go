@@ -0,0 +1,48 @@
+package config
+
+import "time"
+
+// Schedule overlap policies: what happens when an activation comes while
+// the schedule's previous run is still going.
+const (
+	OverlapSkip  = "skip"  // the activation is skipped
+	OverlapAllow = "allow" // the runs go concurrently
+)
+
+// Missed-run policies: what happens to activations that passed while no
+// scheduler was running.
+const (
+	MissedSkip    = "skip"     // they are recorded as missed
+	MissedRunOnce = "run_once" // one run catches up for all of them
+)
+
+// OverlapPolicy returns the schedule's overlap policy, defaulting to
+// OverlapSkip.
+func (s ScheduleDef) OverlapPolicy() string {
+	if s.Overlap == "" {
+		return OverlapSkip
+	}
+	return s.Overlap
+}
+
+// MissedPolicy returns the schedule's missed-run policy, defaulting to
+// MissedSkip.
+func (s ScheduleDef) MissedPolicy() string {
+	if s.Missed == "" {
+		return MissedSkip
+	}
+	return s.Missed
+}
+
+// Location returns the time zone the schedule's cron is read in, UTC when
+// unset. Validate rejects unknown zones.
+func (s ScheduleDef) Location() *time.Location {
+	if s.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Step kinds.
+const (
+	StepAgent     = "agent"     // runs an agent container
+	StepApproval  = "approval"  // waits for a human decision
+	StepShell     = "shell"     // runs a command on the host
+	StepContainer = "container" // runs a command in the project image
+	StepPipeline  = "pipeline"  // runs a named sub-pipeline
+)
+
+// Run policies: when a step runs, given how its dependencies ended.
+const (
+	RunIfSuccess = "success" // no dependency failed
+	RunIfFailure = "failure" // some dependency failed
+	RunIfAlways  = "always"  // regardless of dependencies
+)
+
+// DefaultApprovalTimeout is how long an approval step waits when its
+// timeout is not set.
+const DefaultApprovalTimeout = 24 * time.Hour
+
+// StepKind returns the step's kind, defaulting to StepAgent.
+func (s StepDef) StepKind() string {
+	if s.Kind == "" {
+		return StepAgent
+	}
+	return s.Kind
+}
+
+// RunPolicy returns the step's run_if policy, defaulting to RunIfSuccess.
+func (s StepDef) RunPolicy() string {
+	if s.RunIf == "" {
+		return RunIfSuccess
+	}
+	return s.RunIf
+}
+
+// Cacheable reports whether the step's results may be cached and reused:
+// agent and container steps unless cache is false. For steps that also
+// write to the workspace, a hit replays their changes; see ReplayedSteps.
+func (s StepDef) Cacheable() bool {
+	kind := s.StepKind()
+	return (kind == StepAgent || kind == StepContainer) && (s.Cache == nil || *s.Cache)
+}
+
+// ReplayedSteps returns the cacheable steps that mount a repository
+// read-write. A cached result of theirs replays their changes to the
+// workspace as uncommitted changes; their commits are not remade, nor their
+// pushes repeated. Steps of sub-pipelines are named <pipeline>.<step>.
+func (c *Config) ReplayedSteps() []string {
+	var names []string
+	add := func(prefix string, steps []StepDef) {
+		for _, s := range steps {
+			if s.Cacheable() && s.WritesRepos(c) {
+				names = append(names, prefix+s.Name)
+			}
+		}
+	}
+	add("", c.Pipeline)
+	for _, name := range slices.Sorted(maps.Keys(c.Pipelines)) {
+		add(name+".", c.Pipelines[name].Steps)
+	}
+	return names
+}
+
+// RepoMounts returns the repositories mounted into the step's container:
+// those of its agent, or for container steps the default layout in the
+// step's workspace mode. Other kinds mount none.
+func (s StepDef) RepoMounts(c *Config) []MountDef {
+	switch s.StepKind() {
+	case StepAgent:
+		return c.Agents[s.Agent].WorkspaceMounts(c.Project)
+	case StepContainer:
+		def := AgentDef{Workspace: s.Workspace}
+		if def.Workspace == "" {
+			def.Workspace = "ro"
+		}
+		return def.WorkspaceMounts(c.Project)
+	}
+	return nil
+}
+
+// WritesRepos reports whether any repository is mounted read-write into the
+// step's container.
+func (s StepDef) WritesRepos(c *Config) bool {
+	for _, m := range s.RepoMounts(c) {
+		if m.Mode == "rw" {
+			return true
+		}
+	}
+	return false
+}
+
+// writesReposIn is WritesRepos extended to kind pipeline steps, which write
+// to the workspace when any step of their sub-pipeline does. seen holds the
+// sub-pipelines already being checked, so an include cycle ends the walk.
+func (s StepDef) writesReposIn(c *Config, seen map[string]bool) bool {
+	if s.StepKind() != StepPipeline {
+		return s.WritesRepos(c)
+	}
+	if seen[s.Pipeline] {
+		return false
+	}
+	seen = maps.Clone(seen)
+	if seen == nil {
+		seen = map[string]bool{}
+	}
+	seen[s.Pipeline] = true
+	for _, sub := range c.Pipelines[s.Pipeline].Steps {
+		if sub.writesReposIn(c, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApprovalTimeout returns the parsed approval timeout, or
+// DefaultApprovalTimeout when unset. Validate rejects unparsable values.
+func (s StepDef) ApprovalTimeout() time.Duration {
+	d, err := time.ParseDuration(s.Approval.Timeout)
+	if err != nil || d <= 0 {
+		return DefaultApprovalTimeout
+	}
+	return d
+}
+
+// HasStepKind reports whether any step of the pipeline or of a named
+// sub-pipeline is of the given kind.
+func (c *Config) HasStepKind(kind string) bool {
+	steps := c.Pipeline
+	for _, p := range c.Pipelines {
+		steps = append(steps[:len(steps):len(steps)], p.Steps...)
+	}
+	for _, s := range steps {
+		if s.StepKind() == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// ForPipeline returns the config of a run executing the named sub-pipeline
+// on its own, as triggers and schedules do: a copy whose pipeline is a
+// single step running it with each param bound to the same-named run input.
+// An empty name returns c itself.
+func (c *Config) ForPipeline(name string) *Config {
+	if name == "" {
+		return c
+	}
+	with := map[string]string{}
+	for _, param := range c.Pipelines[name].Params {
+		with[param] = fmt.Sprintf("{{index .Inputs %q}}", param)
+	}
+	cfg := *c
+	cfg.Pipeline = []StepDef{{Name: name, Kind: StepPipeline, Pipeline: name, With: with}}
+	return &cfg
+}
@@ -0,0 +1,51 @@
+package config
+
+import "sort"
+
+// Tool is an entry of the tool catalog that AgentDef.Tools names refer to.
+type Tool struct {
+	Allowed []string // Claude Code --allowedTools entries granted by the tool
+	Bins    []string // CLIs kept on PATH when the agent sets restrict_path
+}
+
+// toolCatalog maps the tool names accepted in AgentDef.Tools to what they
+// grant. CLI tools are allowed as Bash command prefixes.
+var toolCatalog = map[string]Tool{
+	"bash":   {Allowed: []string{"Bash"}},
+	"web":    {Allowed: []string{"WebFetch", "WebSearch"}},
+	"git":    cliTool("git"),
+	"gh":     cliTool("gh"),
+	"go":     cliTool("go", "gofmt"),
+	"make":   cliTool("make"),
+	"cargo":  cliTool("cargo", "rustc"),
+	"zig":    cliTool("zig"),
+	"node":   cliTool("node", "npm", "npx"),
+	"python": cliTool("python3", "pip3"),
+	"curl":   cliTool("curl"),
+	"jq":     cliTool("jq"),
+}
+
+// cliTool grants Bash access to each of bins and keeps them on PATH.
+func cliTool(bins ...string) Tool {
+	t := Tool{Bins: bins}
+	for _, b := range bins {
+		t.Allowed = append(t.Allowed, "Bash("+b+":*)")
+	}
+	return t
+}
+
+// LookupTool returns the catalog entry for name.
+func LookupTool(name string) (Tool, bool) {
+	t, ok := toolCatalog[name]
+	return t, ok
+}
+
+// ToolNames returns the catalog's tool names in sorted order.
+func ToolNames() []string {
+	names := make([]string, 0, len(toolCatalog))
+	for name := range toolCatalog {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
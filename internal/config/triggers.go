@@ -0,0 +1,47 @@
+package config
+
+import "slices"
+
+// GitHub events a trigger rule can match.
+const (
+	EventIssues       = "issues"
+	EventPullRequest  = "pull_request"
+	EventIssueComment = "issue_comment"
+)
+
+// TriggerInputs are the run inputs a triggered run gets from the event
+// payload; inputs the payload lacks are left unset.
+var TriggerInputs = []string{
+	"event",       // the GitHub event name
+	"action",      // the payload action, e.g. opened or labeled
+	"repo",        // owner/name of the repository
+	"sender",      // login of the user who caused the event
+	"issue",       // issue number, also for comments on issues
+	"pr",          // pull request number, also for comments on pull requests
+	"label",       // the label just added
+	"comment",     // comment body
+	"association", // commenter's author_association, e.g. MEMBER
+	"args",        // comment text after the rule's command
+}
+
+// AuthorAssociations are GitHub's relations of a commenter to a repository.
+var AuthorAssociations = []string{"OWNER", "MEMBER", "COLLABORATOR", "CONTRIBUTOR",
+	"FIRST_TIME_CONTRIBUTOR", "FIRST_TIMER", "MANNEQUIN", "NONE"}
+
+// DefaultAuthorAssociations may invoke commands of rules that set no
+// author_associations: those with write access to the repository or
+// membership in its organization.
+var DefaultAuthorAssociations = []string{"OWNER", "MEMBER", "COLLABORATOR"}
+
+// Allows reports whether a comment by sender, related to the repository by
+// association, may invoke the rule's command.
+func (r TriggerRule) Allows(sender, association string) bool {
+	if sender != "" && slices.Contains(r.AllowedSenders, sender) {
+		return true
+	}
+	allowed := r.AuthorAssociations
+	if len(allowed) == 0 {
+		allowed = DefaultAuthorAssociations
+	}
+	return association != "" && slices.Contains(allowed, association)
+}
@@ -2,17 +2,78 @@ package config
 
 // Config is the top-level structure mapping to orchestrator.yaml.
 type Config struct {
-	Project     Project              `yaml:"project"`
-	Credentials Credentials          `yaml:"credentials"`
-	Docker      Docker               `yaml:"docker"`
-	Agents      map[string]AgentDef  `yaml:"agents"`
-	Pipeline    []StepDef            `yaml:"pipeline"`
+	Project     Project                `yaml:"project"`
+	Credentials Credentials            `yaml:"credentials"`
+	Docker      Docker                 `yaml:"docker"`
+	Agents      map[string]AgentDef    `yaml:"agents"`
+	Pipeline    []StepDef              `yaml:"pipeline"`
+	Pipelines   map[string]PipelineDef `yaml:"pipelines"` // reusable sub-pipelines for kind pipeline steps
+	Budget      Budget                 `yaml:"budget"`    // limits for a whole run
+	Concurrency Concurrency            `yaml:"concurrency"`
+	Triggers    Triggers               `yaml:"triggers"`  // GitHub events that start runs under conductor serve
+	Schedules   map[string]ScheduleDef `yaml:"schedules"` // runs started on a timetable by conductor scheduler
+	API         API                    `yaml:"api"`       // HTTP API served by conductor api
 }
 
-// Project identifies the target repository.
+// API configures the HTTP API served by conductor api. Like the webhook
+// secret, the token is read from the credential store directly. Configs sent
+// by clients run with the served config's credentials, and can run shell
+// steps on the host, so they are refused unless allow_configs is set.
+type API struct {
+	Token        string `yaml:"token"`         // credential store key of the bearer token clients present
+	AllowConfigs bool   `yaml:"allow_configs"` // clients may start runs of configs they send
+}
+
+// ScheduleDef is a recurring run started by conductor scheduler.
+type ScheduleDef struct {
+	Cron     string            `yaml:"cron"`     // minute hour day-of-month month day-of-week, or @daily etc.
+	Timezone string            `yaml:"timezone"` // IANA name the cron is read in, default UTC
+	Pipeline string            `yaml:"pipeline"` // name in pipelines, empty = the main pipeline
+	Inputs   map[string]string `yaml:"inputs"`   // run inputs, also binding the pipeline's params
+	Overlap  string            `yaml:"overlap"`  // skip (default) | allow: start while the previous run is going
+	Missed   string            `yaml:"missed"`   // skip (default) | run_once: catch up on activations missed while down
+}
+
+// Triggers maps GitHub events received by conductor serve to pipeline runs.
+// The webhook secret is read from the credential store directly rather than
+// from credentials.secrets, so agents never see it.
+type Triggers struct {
+	Secret string        `yaml:"secret"` // credential store key of the webhook HMAC secret
+	Rules  []TriggerRule `yaml:"rules"`  // the first matching rule starts a run
+}
+
+// TriggerRule selects the events that start a run, and what the run executes.
+// A command starts a run only when its commenter is allowed by association
+// or login.
+type TriggerRule struct {
+	Event              string   `yaml:"event"`               // issues | pull_request | issue_comment
+	Actions            []string `yaml:"actions"`             // payload actions, empty = any
+	Label              string   `yaml:"label"`               // only when this label is added
+	Command            string   `yaml:"command"`             // issue_comment: comment starts with it, e.g. /conductor fix
+	AuthorAssociations []string `yaml:"author_associations"` // command: commenter's relation to the repo, empty = OWNER, MEMBER, COLLABORATOR
+	AllowedSenders     []string `yaml:"allowed_senders"`     // command: logins allowed whatever their association
+	Pipeline           string   `yaml:"pipeline"`            // name in pipelines, empty = the main pipeline
+}
+
+// Concurrency limits the agent containers running at once across all runs
+// of one conductor process.
+type Concurrency struct {
+	MaxAgents int            `yaml:"max_agents"` // total, 0 = unlimited
+	Agents    map[string]int `yaml:"agents"`     // agent name → limit, absent = unlimited
+}
+
+// Project identifies the target repository, or the named repositories of a
+// multi-repository project.
 type Project struct {
-	Name       string `yaml:"name"`
-	Repository string `yaml:"repository"`
+	Name         string                `yaml:"name"`
+	Repository   string                `yaml:"repository"`   // single repo, exposed as DefaultRepo
+	Repositories map[string]Repository `yaml:"repositories"` // named repos, e.g. api, sdk
+}
+
+// Repository is one named repository of a multi-repository project.
+type Repository struct {
+	URL   string `yaml:"url"`
+	Token string `yaml:"token"` // credentials.secrets key of its PAT, for clones and agents' git; default github_pat
 }
 
 // Credentials configures the secret backend and its entries.
@@ -36,10 +97,71 @@ type Docker struct {
 
 // AgentDef defines a single agent's prompt, workspace mode, and capabilities.
 type AgentDef struct {
-	Prompt       PromptDef      `yaml:"prompt"`
-	Workspace    string         `yaml:"workspace"` // rw | ro
-	OutputSchema map[string]any `yaml:"output_schema"`
-	Tools        []string       `yaml:"tools"`
+	Prompt       PromptDef            `yaml:"prompt"`
+	Workspace    string               `yaml:"workspace"` // rw | ro
+	Mounts       []MountDef           `yaml:"mounts"`    // empty = default layout, see WorkspaceMounts
+	Docker       Docker               `yaml:"docker"`    // overrides of the project docker config
+	Resources    Resources            `yaml:"resources"` // empty fields use runner defaults
+	Sandbox      Sandbox              `yaml:"sandbox"`
+	Network      NetworkDef           `yaml:"network"`
+	OutputSchema map[string]any       `yaml:"output_schema"`
+	Tools        []string             `yaml:"tools"`         // catalog names, empty = unrestricted
+	RestrictPath bool                 `yaml:"restrict_path"` // PATH holds only the tools' CLIs and base shell utilities
+	MCPServers   map[string]MCPServer `yaml:"mcp_servers"`
+	Budget       Budget               `yaml:"budget"`    // limits for one session of this agent
+	Artifacts    []string             `yaml:"artifacts"` // files or directories the agent writes to /artifacts
+}
+
+// Budget caps what an agent session or a whole run may spend. Zero fields
+// are unlimited. Tokens count input, output and cache tokens alike. While a
+// session runs, its cost is estimated from the list price of the model
+// serving each message; Claude's reported cost replaces the estimate at the
+// end.
+type Budget struct {
+	MaxCostUSD float64 `yaml:"max_cost_usd"`
+	MaxTokens  int     `yaml:"max_tokens"`
+	MaxTurns   int     `yaml:"max_turns"`
+}
+
+// MCPServer configures an MCP server available to an agent: either a stdio
+// command run inside the container or a remote URL. Command, Args, URL, Env
+// and Headers values may reference credentials with ${secrets.<key>}; see
+// SecretRefs.
+type MCPServer struct {
+	Command   string            `yaml:"command"`
+	Args      []string          `yaml:"args"`
+	URL       string            `yaml:"url"`
+	Transport string            `yaml:"transport"` // http | sse, url servers only, empty = http
+	Env       map[string]string `yaml:"env"`
+	Headers   map[string]string `yaml:"headers"`
+}
+
+// MountDef places a named project repository inside an agent's container.
+type MountDef struct {
+	Repo string `yaml:"repo"` // key into project repositories
+	Path string `yaml:"path"` // container path, e.g. /workspace/api
+	Mode string `yaml:"mode"` // rw | ro, empty = agent workspace mode
+}
+
+// Resources caps the compute an agent container may use.
+type Resources struct {
+	CPUs   string `yaml:"cpus"`   // fractional CPUs, e.g. "1.5"
+	Memory string `yaml:"memory"` // docker size, e.g. "4g"
+	PIDs   int    `yaml:"pids"`   // max processes, 0 = default
+}
+
+// Sandbox hardens an agent container beyond the always-on capability drop
+// and no-new-privileges.
+type Sandbox struct {
+	ReadOnlyRootFS bool              `yaml:"read_only_rootfs"`
+	Tmpfs          map[string]string `yaml:"tmpfs"`   // container path → size, e.g. /tmp: 512m
+	Seccomp        string            `yaml:"seccomp"` // host path to a seccomp profile JSON
+}
+
+// NetworkDef is an agent's network egress policy.
+type NetworkDef struct {
+	Mode  string   `yaml:"mode"`  // open | none | allowlist, empty = open
+	Allow []string `yaml:"allow"` // allowlist hosts, e.g. github.com or *.githubusercontent.com
 }
 
 // PromptDef holds the system and task prompt templates for an agent.
@@ -50,8 +172,34 @@ type PromptDef struct {
 
 // StepDef defines a single pipeline step.
 type StepDef struct {
-	Name      string   `yaml:"name"`
-	Agent     string   `yaml:"agent"`
-	DependsOn []string `yaml:"depends_on"`
-	Condition string   `yaml:"condition"` // CEL expression, empty = always
+	Name            string            `yaml:"name"`
+	Kind            string            `yaml:"kind"` // agent | approval | shell | container | pipeline, empty = agent
+	Agent           string            `yaml:"agent"`
+	Command         string            `yaml:"command"`        // shell and container: template run by the shell; values must go through shellquote
+	Workspace       string            `yaml:"workspace"`      // container: rw | ro, empty = ro
+	WorkspaceFrom   string            `yaml:"workspace_from"` // run against the repositories as this dependency left them
+	DependsOn       []string          `yaml:"depends_on"`
+	Condition       string            `yaml:"condition"`         // CEL expression, empty = always
+	RunIf           string            `yaml:"run_if"`            // success | failure | always, empty = success
+	ContinueOnError bool              `yaml:"continue_on_error"` // a failure neither skips dependents nor fails the run
+	Priority        int               `yaml:"priority"`          // higher gets a free agent slot first, default 0
+	Cache           *bool             `yaml:"cache"`             // agent and container: reuse cached results, default true; never with a rw mount
+	ForEach         string            `yaml:"for_each"`          // CEL list expression; runs one instance per element, read-only steps only
+	Approval        ApprovalDef       `yaml:"approval"`          // kind approval only
+	Pipeline        string            `yaml:"pipeline"`          // kind pipeline: name in Config.Pipelines
+	With            map[string]string `yaml:"with"`              // kind pipeline: param → value template
+}
+
+// PipelineDef is a named sub-pipeline. Its steps see the bound params as
+// .Params in templates; its outputs become the invoking step's output.
+type PipelineDef struct {
+	Params  []string          `yaml:"params"`
+	Steps   []StepDef         `yaml:"steps"`
+	Outputs map[string]string `yaml:"outputs"` // output name → CEL expression over the sub-pipeline's steps
+}
+
+// ApprovalDef configures a human approval gate.
+type ApprovalDef struct {
+	Message string `yaml:"message"` // shown to the approver
+	Timeout string `yaml:"timeout"` // Go duration, empty = DefaultApprovalTimeout
 }
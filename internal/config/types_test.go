@@ -22,14 +22,29 @@ func TestFR1_StructYAMLTags(t *testing.T) {
 				"Docker":      "docker",
 				"Agents":      "agents",
 				"Pipeline":    "pipeline",
+				"Pipelines":   "pipelines",
+				"Budget":      "budget",
+				"Concurrency": "concurrency",
+				"Triggers":    "triggers",
+				"Schedules":   "schedules",
+				"API":         "api",
 			},
 		},
 		{
 			"Project",
 			reflect.TypeOf(Project{}),
 			map[string]string{
-				"Name":       "name",
-				"Repository": "repository",
+				"Name":         "name",
+				"Repository":   "repository",
+				"Repositories": "repositories",
+			},
+		},
+		{
+			"Repository",
+			reflect.TypeOf(Repository{}),
+			map[string]string{
+				"URL":   "url",
+				"Token": "token",
 			},
 		},
 		{
@@ -63,8 +78,73 @@ func TestFR1_StructYAMLTags(t *testing.T) {
 			map[string]string{
 				"Prompt":       "prompt",
 				"Workspace":    "workspace",
+				"Mounts":       "mounts",
+				"Docker":       "docker",
+				"Resources":    "resources",
+				"Sandbox":      "sandbox",
+				"Network":      "network",
 				"OutputSchema": "output_schema",
 				"Tools":        "tools",
+				"RestrictPath": "restrict_path",
+				"MCPServers":   "mcp_servers",
+				"Budget":       "budget",
+				"Artifacts":    "artifacts",
+			},
+		},
+		{
+			"Budget",
+			reflect.TypeOf(Budget{}),
+			map[string]string{
+				"MaxCostUSD": "max_cost_usd",
+				"MaxTokens":  "max_tokens",
+				"MaxTurns":   "max_turns",
+			},
+		},
+		{
+			"MountDef",
+			reflect.TypeOf(MountDef{}),
+			map[string]string{
+				"Repo": "repo",
+				"Path": "path",
+				"Mode": "mode",
+			},
+		},
+		{
+			"Resources",
+			reflect.TypeOf(Resources{}),
+			map[string]string{
+				"CPUs":   "cpus",
+				"Memory": "memory",
+				"PIDs":   "pids",
+			},
+		},
+		{
+			"Sandbox",
+			reflect.TypeOf(Sandbox{}),
+			map[string]string{
+				"ReadOnlyRootFS": "read_only_rootfs",
+				"Tmpfs":          "tmpfs",
+				"Seccomp":        "seccomp",
+			},
+		},
+		{
+			"NetworkDef",
+			reflect.TypeOf(NetworkDef{}),
+			map[string]string{
+				"Mode":  "mode",
+				"Allow": "allow",
+			},
+		},
+		{
+			"MCPServer",
+			reflect.TypeOf(MCPServer{}),
+			map[string]string{
+				"Command":   "command",
+				"Args":      "args",
+				"URL":       "url",
+				"Transport": "transport",
+				"Env":       "env",
+				"Headers":   "headers",
 			},
 		},
 		{
@@ -79,10 +159,88 @@ func TestFR1_StructYAMLTags(t *testing.T) {
 			"StepDef",
 			reflect.TypeOf(StepDef{}),
 			map[string]string{
-				"Name":      "name",
-				"Agent":     "agent",
-				"DependsOn": "depends_on",
-				"Condition": "condition",
+				"Name":            "name",
+				"Kind":            "kind",
+				"Agent":           "agent",
+				"Command":         "command",
+				"Workspace":       "workspace",
+				"WorkspaceFrom":   "workspace_from",
+				"DependsOn":       "depends_on",
+				"Condition":       "condition",
+				"ForEach":         "for_each",
+				"RunIf":           "run_if",
+				"Priority":        "priority",
+				"Cache":           "cache",
+				"ContinueOnError": "continue_on_error",
+				"Approval":        "approval",
+				"Pipeline":        "pipeline",
+				"With":            "with",
+			},
+		},
+		{
+			"Concurrency",
+			reflect.TypeOf(Concurrency{}),
+			map[string]string{
+				"MaxAgents": "max_agents",
+				"Agents":    "agents",
+			},
+		},
+		{
+			"Triggers",
+			reflect.TypeOf(Triggers{}),
+			map[string]string{
+				"Secret": "secret",
+				"Rules":  "rules",
+			},
+		},
+		{
+			"TriggerRule",
+			reflect.TypeOf(TriggerRule{}),
+			map[string]string{
+				"Event":              "event",
+				"Actions":            "actions",
+				"Label":              "label",
+				"Command":            "command",
+				"AuthorAssociations": "author_associations",
+				"AllowedSenders":     "allowed_senders",
+				"Pipeline":           "pipeline",
+			},
+		},
+		{
+			"API",
+			reflect.TypeOf(API{}),
+			map[string]string{
+				"Token":        "token",
+				"AllowConfigs": "allow_configs",
+			},
+		},
+		{
+			"ScheduleDef",
+			reflect.TypeOf(ScheduleDef{}),
+			map[string]string{
+				"Cron":     "cron",
+				"Timezone": "timezone",
+				"Pipeline": "pipeline",
+				"Inputs":   "inputs",
+				"Overlap":  "overlap",
+				"Missed":   "missed",
+			},
+		},
+		{
+			"PipelineDef",
+			reflect.TypeOf(PipelineDef{}),
+			map[string]string{
+				"Params":  "params",
+				"Steps":   "steps",
+				"Outputs": "outputs",
+			},
+		},
+		{
+			"ApprovalDef",
+			reflect.TypeOf(ApprovalDef{}),
+			map[string]string{
+				"Message": "message",
+				"Timeout": "timeout",
 			},
 		},
 	}
@@ -108,17 +266,25 @@ func TestFR1_StructYAMLTags(t *testing.T) {
 // Go types (maps for named collections, slices for ordered lists).
 func TestFR1_StructFieldTypes(t *testing.T) {
 	tests := []struct {
-		name      string
-		typ       reflect.Type
-		field     string
-		wantKind  reflect.Kind
-		wantElem  string // element type name for maps/slices
+		name     string
+		typ      reflect.Type
+		field    string
+		wantKind reflect.Kind
+		wantElem string // element type name for maps/slices
 	}{
 		{"Config.Agents", reflect.TypeOf(Config{}), "Agents", reflect.Map, "AgentDef"},
 		{"Config.Pipeline", reflect.TypeOf(Config{}), "Pipeline", reflect.Slice, "StepDef"},
+		{"Config.Pipelines", reflect.TypeOf(Config{}), "Pipelines", reflect.Map, "PipelineDef"},
+		{"Triggers.Rules", reflect.TypeOf(Triggers{}), "Rules", reflect.Slice, "TriggerRule"},
+		{"Config.Schedules", reflect.TypeOf(Config{}), "Schedules", reflect.Map, "ScheduleDef"},
+		{"ScheduleDef.Inputs", reflect.TypeOf(ScheduleDef{}), "Inputs", reflect.Map, "string"},
+		{"PipelineDef.Steps", reflect.TypeOf(PipelineDef{}), "Steps", reflect.Slice, "StepDef"},
+		{"Project.Repositories", reflect.TypeOf(Project{}), "Repositories", reflect.Map, "Repository"},
 		{"Credentials.Secrets", reflect.TypeOf(Credentials{}), "Secrets", reflect.Map, "SecretRef"},
 		{"Docker.BuildArgs", reflect.TypeOf(Docker{}), "BuildArgs", reflect.Map, "string"},
 		{"AgentDef.Tools", reflect.TypeOf(AgentDef{}), "Tools", reflect.Slice, "string"},
+		{"AgentDef.Mounts", reflect.TypeOf(AgentDef{}), "Mounts", reflect.Slice, "MountDef"},
+		{"AgentDef.Artifacts", reflect.TypeOf(AgentDef{}), "Artifacts", reflect.Slice, "string"},
 		{"StepDef.DependsOn", reflect.TypeOf(StepDef{}), "DependsOn", reflect.Slice, "string"},
 		{"AgentDef.OutputSchema", reflect.TypeOf(AgentDef{}), "OutputSchema", reflect.Map, ""},
 	}
@@ -154,14 +320,28 @@ func TestFR1_StructFieldCompleteness(t *testing.T) {
 		typ       reflect.Type
 		wantCount int
 	}{
-		{"Config", reflect.TypeOf(Config{}), 5},
-		{"Project", reflect.TypeOf(Project{}), 2},
+		{"Config", reflect.TypeOf(Config{}), 11},
+		{"Concurrency", reflect.TypeOf(Concurrency{}), 2},
+		{"Triggers", reflect.TypeOf(Triggers{}), 2},
+		{"TriggerRule", reflect.TypeOf(TriggerRule{}), 7},
+		{"ScheduleDef", reflect.TypeOf(ScheduleDef{}), 6},
+		{"API", reflect.TypeOf(API{}), 2},
+		{"Project", reflect.TypeOf(Project{}), 3},
+		{"Repository", reflect.TypeOf(Repository{}), 2},
 		{"Credentials", reflect.TypeOf(Credentials{}), 2},
 		{"SecretRef", reflect.TypeOf(SecretRef{}), 2},
 		{"Docker", reflect.TypeOf(Docker{}), 3},
-		{"AgentDef", reflect.TypeOf(AgentDef{}), 4},
+		{"AgentDef", reflect.TypeOf(AgentDef{}), 13},
+		{"Budget", reflect.TypeOf(Budget{}), 3},
+		{"MCPServer", reflect.TypeOf(MCPServer{}), 6},
+		{"NetworkDef", reflect.TypeOf(NetworkDef{}), 2},
+		{"Resources", reflect.TypeOf(Resources{}), 3},
+		{"Sandbox", reflect.TypeOf(Sandbox{}), 3},
+		{"MountDef", reflect.TypeOf(MountDef{}), 3},
 		{"PromptDef", reflect.TypeOf(PromptDef{}), 2},
-		{"StepDef", reflect.TypeOf(StepDef{}), 4},
+		{"StepDef", reflect.TypeOf(StepDef{}), 16},
+		{"PipelineDef", reflect.TypeOf(PipelineDef{}), 3},
+		{"ApprovalDef", reflect.TypeOf(ApprovalDef{}), 2},
 	}
 
 	for _, tt := range tests {
@@ -3,6 +3,15 @@ package config
 import (
 	"errors"
 	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dmitriyb/conductor/internal/cron"
 )
 
 // Validate checks all Config fields for completeness and consistency.
@@ -16,7 +25,21 @@ func Validate(cfg *Config) error {
 	}
 
 	check(cfg.Project.Name != "", "project.name", "required")
-	check(cfg.Project.Repository != "", "project.repository", "required")
+	check(cfg.Project.Repository != "" || len(cfg.Project.Repositories) > 0,
+		"project.repository", "required (or project.repositories)")
+	if cfg.Project.Repository != "" {
+		_, clash := cfg.Project.Repositories[DefaultRepo]
+		check(!clash, "project.repositories."+DefaultRepo,
+			"name is reserved for project.repository")
+	}
+	for name, repo := range cfg.Project.Repositories {
+		p := "project.repositories." + name
+		check(repo.URL != "", p+".url", "required")
+		if repo.Token != "" {
+			_, ok := cfg.Credentials.Secrets[repo.Token]
+			check(ok, p+".token", fmt.Sprintf("references undefined secret %q", repo.Token))
+		}
+	}
 
 	validBackends := map[string]bool{"rbw": true, "env": true, "file": true}
 	check(validBackends[cfg.Credentials.Backend], "credentials.backend",
@@ -24,28 +47,345 @@ func Validate(cfg *Config) error {
 	check(cfg.Docker.BaseImage != "", "docker.base_image", "required")
 	check(len(cfg.Agents) > 0, "agents", "at least one agent must be defined")
 
+	repos := cfg.Project.Repos()
 	for name, agent := range cfg.Agents {
 		p := "agents." + name
 		check(agent.Prompt.System != "", p+".prompt.system", "required")
 		check(agent.Prompt.Task != "", p+".prompt.task", "required")
 		check(agent.Workspace == "rw" || agent.Workspace == "ro",
 			p+".workspace", fmt.Sprintf("must be rw or ro (got %q)", agent.Workspace))
+		paths := map[string]bool{}
+		for i, m := range agent.Mounts {
+			mp := fmt.Sprintf("%s.mounts[%d]", p, i)
+			_, ok := repos[m.Repo]
+			check(ok, mp+".repo", fmt.Sprintf("references undefined repository %q", m.Repo))
+			check(path.IsAbs(m.Path), mp+".path", fmt.Sprintf("must be an absolute path (got %q)", m.Path))
+			check(!paths[path.Clean(m.Path)], mp+".path", fmt.Sprintf("duplicate mount path %q", m.Path))
+			paths[path.Clean(m.Path)] = true
+			check(m.Mode == "" || m.Mode == "rw" || m.Mode == "ro",
+				mp+".mode", fmt.Sprintf("must be rw or ro (got %q)", m.Mode))
+		}
+
+		for i, tool := range agent.Tools {
+			_, ok := LookupTool(tool)
+			check(ok, fmt.Sprintf("%s.tools[%d]", p, i), fmt.Sprintf("unknown tool %q (known: %s)",
+				tool, strings.Join(ToolNames(), ", ")))
+		}
+		check(!agent.RestrictPath || len(agent.Tools) > 0, p+".restrict_path",
+			"requires at least one tool")
+
+		for srvName, srv := range agent.MCPServers {
+			sp := p + ".mcp_servers." + srvName
+			check((srv.Command != "") != (srv.URL != ""), sp,
+				"exactly one of command or url required")
+			check(srv.URL != "" || srv.Transport == "", sp+".transport", "only valid with url")
+			check(srv.Transport == "" || srv.Transport == "http" || srv.Transport == "sse",
+				sp+".transport", fmt.Sprintf("must be http or sse (got %q)", srv.Transport))
+			check(srv.Command != "" || len(srv.Env) == 0, sp+".env", "only valid with command")
+			check(srv.URL != "" || len(srv.Headers) == 0, sp+".headers", "only valid with url")
+			for _, key := range srv.SecretRefs() {
+				_, ok := cfg.Credentials.Secrets[key]
+				check(ok, sp, fmt.Sprintf("references undefined secret %q", key))
+			}
+		}
+
+		res := agent.Resources
+		if res.CPUs != "" {
+			cpus, err := strconv.ParseFloat(res.CPUs, 64)
+			check(err == nil && cpus > 0, p+".resources.cpus",
+				fmt.Sprintf("must be a positive number (got %q)", res.CPUs))
+		}
+		check(res.Memory == "" || validSize(res.Memory), p+".resources.memory",
+			fmt.Sprintf("must be a size like 512m or 4g (got %q)", res.Memory))
+		check(res.PIDs >= 0, p+".resources.pids",
+			fmt.Sprintf("must not be negative (got %d)", res.PIDs))
+		for dir, size := range agent.Sandbox.Tmpfs {
+			tp := p + ".sandbox.tmpfs." + dir
+			check(path.IsAbs(dir), tp, "must be an absolute container path")
+			check(validSize(size), tp, fmt.Sprintf("must be a size like 512m or 4g (got %q)", size))
+		}
+		if seccomp := agent.Sandbox.Seccomp; seccomp == "unconfined" {
+			check(false, p+".sandbox.seccomp", "must be a profile path; unconfined is not allowed")
+		} else if seccomp != "" {
+			info, err := os.Stat(seccomp)
+			check(err == nil && info.Mode().IsRegular(), p+".sandbox.seccomp",
+				fmt.Sprintf("profile %s is not a readable file", seccomp))
+		}
+
+		validateBudget(check, p+".budget", agent.Budget)
+		names := map[string]bool{}
+		for i, name := range agent.Artifacts {
+			ap := fmt.Sprintf("%s.artifacts[%d]", p, i)
+			check(fileName.MatchString(name), ap,
+				fmt.Sprintf("must be a file name like plan.md (got %q)", name))
+			check(!names[name], ap, fmt.Sprintf("duplicate artifact %q", name))
+			names[name] = true
+		}
+
+		net := agent.Network
+		switch net.Mode {
+		case "", "open", "none":
+			check(len(net.Allow) == 0, p+".network.allow",
+				fmt.Sprintf("only valid with mode allowlist (got mode %q)", net.Mode))
+		case "allowlist":
+			check(len(net.Allow) > 0, p+".network.allow", "at least one host required")
+			for i, host := range net.Allow {
+				check(hostPattern.MatchString(host), fmt.Sprintf("%s.network.allow[%d]", p, i),
+					fmt.Sprintf("must be a hostname or *.domain (got %q)", host))
+			}
+		default:
+			check(false, p+".network.mode",
+				fmt.Sprintf("must be one of: open, none, allowlist (got %q)", net.Mode))
+		}
+	}
+
+	validateBudget(check, "budget", cfg.Budget)
+	check(cfg.Concurrency.MaxAgents >= 0, "concurrency.max_agents",
+		fmt.Sprintf("must not be negative (got %d)", cfg.Concurrency.MaxAgents))
+	for name, limit := range cfg.Concurrency.Agents {
+		p := "concurrency.agents." + name
+		_, ok := cfg.Agents[name]
+		check(ok, p, fmt.Sprintf("references undefined agent %q", name))
+		check(limit > 0, p, fmt.Sprintf("must be positive (got %d)", limit))
 	}
 
+	validateTriggers(check, cfg)
+	validateSchedules(check, cfg)
+
 	check(len(cfg.Pipeline) > 0, "pipeline", "at least one step required")
+	validateSteps(check, cfg, "pipeline", cfg.Pipeline)
+	for name, sub := range cfg.Pipelines {
+		p := "pipelines." + name
+		check(len(sub.Steps) > 0, p+".steps", "at least one step required")
+		validateSteps(check, cfg, p+".steps", sub.Steps)
+		params := map[string]bool{}
+		for _, param := range sub.Params {
+			check(!params[param], p+".params", fmt.Sprintf("duplicate param %q", param))
+			params[param] = true
+		}
+		for out, expr := range sub.Outputs {
+			check(expr != "", p+".outputs."+out, "required")
+		}
+		if cycle := includeCycle(cfg, name, nil); cycle != nil {
+			check(false, p, "includes itself: "+strings.Join(cycle, " → "))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateSteps checks the steps of the pipeline or of a named sub-pipeline;
+// p is the path of the step list.
+func validateSteps(check func(bool, string, string), cfg *Config, p string, steps []StepDef) {
 	stepNames := map[string]bool{}
-	for i, step := range cfg.Pipeline {
-		p := fmt.Sprintf("pipeline[%d]", i)
+	earlier := map[string]StepDef{}
+	for i, step := range steps {
+		p := fmt.Sprintf("%s[%d]", p, i)
 		check(step.Name != "", p+".name", "required")
-		check(step.Agent != "", p+".agent", "required")
-		if step.Agent != "" {
-			_, ok := cfg.Agents[step.Agent]
-			check(ok, p+".agent", fmt.Sprintf("references undefined agent %q", step.Agent))
+		switch step.StepKind() {
+		case StepAgent:
+			check(step.Agent != "", p+".agent", "required")
+			if step.Agent != "" {
+				_, ok := cfg.Agents[step.Agent]
+				check(ok, p+".agent", fmt.Sprintf("references undefined agent %q", step.Agent))
+			}
+		case StepApproval:
+			check(step.Agent == "", p+".agent", "not valid with kind approval")
+			if step.Approval.Timeout != "" {
+				d, err := time.ParseDuration(step.Approval.Timeout)
+				check(err == nil && d > 0, p+".approval.timeout",
+					fmt.Sprintf("must be a positive duration like 30m or 4h (got %q)", step.Approval.Timeout))
+			}
+		case StepShell, StepContainer:
+			check(step.Agent == "", p+".agent", "not valid with kind "+step.Kind)
+			check(step.Command != "", p+".command", "required for kind "+step.Kind)
+			if err := CheckCommand(step.Command); err != nil {
+				check(false, p+".command", err.Error())
+			}
+		case StepPipeline:
+			check(step.Agent == "", p+".agent", "not valid with kind pipeline")
+			check(step.Pipeline != "", p+".pipeline", "required for kind pipeline")
+			if sub, ok := cfg.Pipelines[step.Pipeline]; ok {
+				for _, param := range sub.Params {
+					_, bound := step.With[param]
+					check(bound, p+".with", fmt.Sprintf("missing param %q of pipeline %q", param, step.Pipeline))
+				}
+				for param := range step.With {
+					check(slices.Contains(sub.Params, param), p+".with."+param,
+						fmt.Sprintf("pipeline %q has no such param", step.Pipeline))
+				}
+			} else if step.Pipeline != "" {
+				check(false, p+".pipeline", fmt.Sprintf("references undefined pipeline %q", step.Pipeline))
+			}
+		default:
+			check(false, p+".kind",
+				fmt.Sprintf("must be one of: agent, approval, shell, container, pipeline (got %q)", step.Kind))
+		}
+		kind := step.StepKind()
+		check(kind == StepApproval || step.Approval == (ApprovalDef{}), p+".approval",
+			"only valid with kind approval")
+		check(kind != StepApproval || step.ForEach == "", p+".for_each", "not valid with kind approval")
+		check(kind == StepApproval || step.ForEach == "" || !step.writesReposIn(cfg, nil), p+".for_each",
+			"not valid on a step that writes to the workspace, as its instances would share one checkout")
+		check(kind == StepShell || kind == StepContainer || step.Command == "", p+".command",
+			"only valid with kind shell or container")
+		check(kind == StepAgent || kind == StepContainer || step.Cache == nil, p+".cache",
+			"only valid with kind agent or container")
+		check(kind == StepPipeline || (step.Pipeline == "" && len(step.With) == 0), p+".pipeline",
+			"pipeline and with are only valid with kind pipeline")
+		if kind == StepContainer {
+			check(step.Workspace == "" || step.Workspace == "rw" || step.Workspace == "ro",
+				p+".workspace", fmt.Sprintf("must be rw or ro (got %q)", step.Workspace))
+		} else {
+			check(step.Workspace == "", p+".workspace", "only valid with kind container")
+		}
+		switch step.RunPolicy() {
+		case RunIfSuccess, RunIfAlways:
+		case RunIfFailure:
+			check(len(step.DependsOn) > 0, p+".run_if", "failure requires depends_on")
+		default:
+			check(false, p+".run_if", fmt.Sprintf("must be one of: success, failure, always (got %q)", step.RunIf))
 		}
 		for _, dep := range step.DependsOn {
 			check(stepNames[dep], p+".depends_on", fmt.Sprintf("unknown step %q", dep))
 		}
+		if from := step.WorkspaceFrom; from != "" {
+			src, known := earlier[from]
+			switch {
+			case kind != StepAgent && kind != StepContainer:
+				check(false, p+".workspace_from", "only valid with kind agent or container")
+			case !slices.Contains(step.DependsOn, from):
+				check(false, p+".workspace_from", fmt.Sprintf("step %q must be in depends_on", from))
+			case !known:
+				// reported under depends_on
+			case !src.WritesRepos(cfg):
+				check(false, p+".workspace_from", fmt.Sprintf("step %q does not write to the workspace", from))
+			case src.ForEach != "":
+				check(false, p+".workspace_from", fmt.Sprintf("step %q runs for_each instances", from))
+			}
+			check(!step.WritesRepos(cfg), p+".workspace_from", "requires a read-only workspace")
+		}
 		stepNames[step.Name] = true
+		earlier[step.Name] = step
 	}
-	return errors.Join(errs...)
 }
+
+// validateTriggers checks the trigger rules and that a secret verifies the
+// webhooks they respond to.
+func validateTriggers(check func(bool, string, string), cfg *Config) {
+	t := cfg.Triggers
+	check(len(t.Rules) == 0 || t.Secret != "", "triggers.secret", "required with trigger rules")
+	for i, rule := range t.Rules {
+		p := fmt.Sprintf("triggers.rules[%d]", i)
+		switch rule.Event {
+		case EventIssues, EventPullRequest, EventIssueComment:
+		default:
+			check(false, p+".event", fmt.Sprintf("must be one of: issues, pull_request, issue_comment (got %q)", rule.Event))
+		}
+		check(rule.Command == "" || rule.Event == EventIssueComment, p+".command", "only valid with event issue_comment")
+		check(rule.Label == "" || rule.Event != EventIssueComment, p+".label", "not valid with event issue_comment")
+		check(rule.Command != "" || len(rule.AuthorAssociations) == 0, p+".author_associations", "only valid with a command")
+		check(rule.Command != "" || len(rule.AllowedSenders) == 0, p+".allowed_senders", "only valid with a command")
+		for j, a := range rule.AuthorAssociations {
+			check(slices.Contains(AuthorAssociations, a), fmt.Sprintf("%s.author_associations[%d]", p, j),
+				fmt.Sprintf("must be one of: %s (got %q)", strings.Join(AuthorAssociations, ", "), a))
+		}
+		if rule.Pipeline == "" {
+			continue
+		}
+		sub, ok := cfg.Pipelines[rule.Pipeline]
+		if !ok {
+			check(false, p+".pipeline", fmt.Sprintf("references undefined pipeline %q", rule.Pipeline))
+			continue
+		}
+		for _, param := range sub.Params {
+			check(slices.Contains(TriggerInputs, param), p+".pipeline", fmt.Sprintf(
+				"param %q of pipeline %q is not a trigger input (known: %s)",
+				param, rule.Pipeline, strings.Join(TriggerInputs, ", ")))
+		}
+	}
+}
+
+// validateSchedules checks the schedules' timetables and policies, and that
+// their inputs bind the params of the pipeline they run.
+func validateSchedules(check func(bool, string, string), cfg *Config) {
+	for name, s := range cfg.Schedules {
+		p := "schedules." + name
+		check(fileName.MatchString(name), p, "name must be letters, digits, '.', '_' or '-', not starting with '.'")
+		if s.Cron == "" {
+			check(false, p+".cron", "required")
+		} else if _, err := cron.Parse(s.Cron); err != nil {
+			check(false, p+".cron", err.Error())
+		}
+		if s.Timezone != "" {
+			_, err := time.LoadLocation(s.Timezone)
+			check(err == nil, p+".timezone", fmt.Sprintf("unknown time zone %q", s.Timezone))
+		}
+		switch s.OverlapPolicy() {
+		case OverlapSkip, OverlapAllow:
+		default:
+			check(false, p+".overlap", fmt.Sprintf("must be one of: skip, allow (got %q)", s.Overlap))
+		}
+		switch s.MissedPolicy() {
+		case MissedSkip, MissedRunOnce:
+		default:
+			check(false, p+".missed", fmt.Sprintf("must be one of: skip, run_once (got %q)", s.Missed))
+		}
+		if s.Pipeline == "" {
+			continue
+		}
+		sub, ok := cfg.Pipelines[s.Pipeline]
+		if !ok {
+			check(false, p+".pipeline", fmt.Sprintf("references undefined pipeline %q", s.Pipeline))
+			continue
+		}
+		for _, param := range sub.Params {
+			_, bound := s.Inputs[param]
+			check(bound, p+".inputs", fmt.Sprintf("missing param %q of pipeline %q", param, s.Pipeline))
+		}
+	}
+}
+
+// includeCycle returns the chain of sub-pipelines by which the named
+// pipeline includes itself, or nil. path holds the pipelines entered so far.
+func includeCycle(cfg *Config, name string, path []string) []string {
+	path = append(path, name)
+	for _, step := range cfg.Pipelines[name].Steps {
+		if step.StepKind() != StepPipeline {
+			continue
+		}
+		if step.Pipeline == path[0] {
+			return append(path, step.Pipeline)
+		}
+		if slices.Contains(path, step.Pipeline) {
+			continue // a cycle not through path[0]; reported for its own members
+		}
+		if cycle := includeCycle(cfg, step.Pipeline, path); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// validateBudget checks that budget limits are not negative.
+func validateBudget(check func(bool, string, string), p string, b Budget) {
+	check(b.MaxCostUSD >= 0, p+".max_cost_usd", fmt.Sprintf("must not be negative (got %g)", b.MaxCostUSD))
+	check(b.MaxTokens >= 0, p+".max_tokens", fmt.Sprintf("must not be negative (got %d)", b.MaxTokens))
+	check(b.MaxTurns >= 0, p+".max_turns", fmt.Sprintf("must not be negative (got %d)", b.MaxTurns))
+}
+
+// sizePattern matches docker size values: a byte count with an optional
+// b, k, m, or g unit.
+var sizePattern = regexp.MustCompile(`^[0-9]+[bkmgBKMG]?$`)
+
+// validSize reports whether s is a docker size value.
+func validSize(s string) bool {
+	return sizePattern.MatchString(s) && s[0] != '0'
+}
+
+// hostPattern matches allowlist entries: a hostname, optionally prefixed by
+// a *. wildcard label.
+var hostPattern = regexp.MustCompile(`^(\*\.)?[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*$`)
+
+// fileName matches names used as a single path element, such as artifact and
+// schedule names, that do not start with a dot.
+var fileName = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._-]*$`)
@@ -1,8 +1,12 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
 // validConfig returns a minimal Config that passes Validate.
@@ -260,3 +264,902 @@ func TestFR3_ValidDependsOnChain(t *testing.T) {
 		t.Fatalf("Validate returned unexpected error: %v", err)
 	}
 }
+
+// TestFR3_RepositoryErrors verifies validation of named repositories and
+// per-agent mounts.
+func TestFR3_RepositoryErrors(t *testing.T) {
+	multi := func(c *Config) {
+		c.Project.Repository = ""
+		c.Project.Repositories = map[string]Repository{
+			"api": {URL: "https://github.com/test/api.git"},
+		}
+	}
+	tests := []struct {
+		name    string
+		mutate  func(*Config)
+		wantErr string
+	}{
+		{
+			name: "repository url missing",
+			mutate: func(c *Config) {
+				multi(c)
+				c.Project.Repositories["sdk"] = Repository{}
+			},
+			wantErr: "project.repositories.sdk.url: required",
+		},
+		{
+			name: "repository token undefined",
+			mutate: func(c *Config) {
+				multi(c)
+				c.Project.Repositories["api"] = Repository{URL: "https://github.com/test/api.git", Token: "nope"}
+			},
+			wantErr: `project.repositories.api.token: references undefined secret "nope"`,
+		},
+		{
+			name: "default name reserved",
+			mutate: func(c *Config) {
+				c.Project.Repositories = map[string]Repository{DefaultRepo: {URL: "x"}}
+			},
+			wantErr: "project.repositories.default: name is reserved",
+		},
+		{
+			name: "mount references undefined repository",
+			mutate: func(c *Config) {
+				multi(c)
+				c.Agents["worker"] = AgentDef{
+					Prompt:    PromptDef{System: "s.md", Task: "do"},
+					Workspace: "rw",
+					Mounts:    []MountDef{{Repo: "sdk", Path: "/workspace/sdk"}},
+				}
+			},
+			wantErr: `agents.worker.mounts[0].repo: references undefined repository "sdk"`,
+		},
+		{
+			name: "mount path relative",
+			mutate: func(c *Config) {
+				multi(c)
+				c.Agents["worker"] = AgentDef{
+					Prompt:    PromptDef{System: "s.md", Task: "do"},
+					Workspace: "rw",
+					Mounts:    []MountDef{{Repo: "api", Path: "api"}},
+				}
+			},
+			wantErr: "agents.worker.mounts[0].path: must be an absolute path",
+		},
+		{
+			name: "mount path duplicated",
+			mutate: func(c *Config) {
+				multi(c)
+				c.Agents["worker"] = AgentDef{
+					Prompt:    PromptDef{System: "s.md", Task: "do"},
+					Workspace: "rw",
+					Mounts: []MountDef{
+						{Repo: "api", Path: "/workspace"},
+						{Repo: "api", Path: "/workspace/"},
+					},
+				}
+			},
+			wantErr: "agents.worker.mounts[1].path: duplicate mount path",
+		},
+		{
+			name: "mount mode invalid",
+			mutate: func(c *Config) {
+				multi(c)
+				c.Agents["worker"] = AgentDef{
+					Prompt:    PromptDef{System: "s.md", Task: "do"},
+					Workspace: "rw",
+					Mounts:    []MountDef{{Repo: "api", Path: "/workspace", Mode: "wo"}},
+				}
+			},
+			wantErr: "agents.worker.mounts[0].mode: must be rw or ro",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.mutate(&cfg)
+			err := Validate(&cfg)
+			if err == nil {
+				t.Fatalf("Validate returned nil, want error containing %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %q, want it to contain %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestFR3_RepositoriesWithoutRepository verifies that named repositories
+// satisfy the project repository requirement on their own.
+func TestFR3_RepositoriesWithoutRepository(t *testing.T) {
+	cfg := validConfig()
+	cfg.Project.Repository = ""
+	cfg.Project.Repositories = map[string]Repository{
+		"api": {URL: "https://github.com/test/api.git"},
+	}
+	if err := Validate(&cfg); err != nil {
+		t.Fatalf("Validate returned unexpected error: %v", err)
+	}
+}
+
+// TestFR3_SandboxErrors verifies validation of agent resources and sandbox
+// settings.
+func TestFR3_SandboxErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(*AgentDef)
+		wantErr string
+	}{
+		{
+			name:    "cpus not a number",
+			mutate:  func(a *AgentDef) { a.Resources.CPUs = "two" },
+			wantErr: "agents.worker.resources.cpus: must be a positive number",
+		},
+		{
+			name:    "cpus zero",
+			mutate:  func(a *AgentDef) { a.Resources.CPUs = "0" },
+			wantErr: "agents.worker.resources.cpus: must be a positive number",
+		},
+		{
+			name:    "memory malformed",
+			mutate:  func(a *AgentDef) { a.Resources.Memory = "4 GB" },
+			wantErr: "agents.worker.resources.memory: must be a size",
+		},
+		{
+			name:    "pids negative",
+			mutate:  func(a *AgentDef) { a.Resources.PIDs = -1 },
+			wantErr: "agents.worker.resources.pids: must not be negative",
+		},
+		{
+			name:    "tmpfs relative path",
+			mutate:  func(a *AgentDef) { a.Sandbox.Tmpfs = map[string]string{"tmp": "1g"} },
+			wantErr: "agents.worker.sandbox.tmpfs.tmp: must be an absolute container path",
+		},
+		{
+			name:    "tmpfs size malformed",
+			mutate:  func(a *AgentDef) { a.Sandbox.Tmpfs = map[string]string{"/tmp": "lots"} },
+			wantErr: "agents.worker.sandbox.tmpfs./tmp: must be a size",
+		},
+		{
+			name:    "seccomp unconfined",
+			mutate:  func(a *AgentDef) { a.Sandbox.Seccomp = "unconfined" },
+			wantErr: "agents.worker.sandbox.seccomp: must be a profile path",
+		},
+		{
+			name:    "seccomp profile missing",
+			mutate:  func(a *AgentDef) { a.Sandbox.Seccomp = "/nonexistent/seccomp.json" },
+			wantErr: "agents.worker.sandbox.seccomp: profile /nonexistent/seccomp.json is not a readable file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			agent := cfg.Agents["worker"]
+			tt.mutate(&agent)
+			cfg.Agents["worker"] = agent
+			err := Validate(&cfg)
+			if err == nil {
+				t.Fatalf("Validate returned nil, want error containing %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %q, want it to contain %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestFR3_ValidSandbox verifies that well-formed limits pass validation.
+func TestFR3_ValidSandbox(t *testing.T) {
+	profile := filepath.Join(t.TempDir(), "agent.json")
+	if err := os.WriteFile(profile, []byte(`{"defaultAction":"SCMP_ACT_ERRNO"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := validConfig()
+	agent := cfg.Agents["worker"]
+	agent.Resources = Resources{CPUs: "0.5", Memory: "512m", PIDs: 128}
+	agent.Sandbox = Sandbox{
+		ReadOnlyRootFS: true,
+		Tmpfs:          map[string]string{"/tmp": "1g"},
+		Seccomp:        profile,
+	}
+	cfg.Agents["worker"] = agent
+	if err := Validate(&cfg); err != nil {
+		t.Fatalf("Validate returned unexpected error: %v", err)
+	}
+}
+
+// TestFR3_NetworkErrors verifies validation of agent network policies.
+func TestFR3_NetworkErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		network NetworkDef
+		wantErr string
+	}{
+		{
+			name:    "unknown mode",
+			network: NetworkDef{Mode: "firewalled"},
+			wantErr: "agents.worker.network.mode: must be one of: open, none, allowlist",
+		},
+		{
+			name:    "allowlist without hosts",
+			network: NetworkDef{Mode: "allowlist"},
+			wantErr: "agents.worker.network.allow: at least one host required",
+		},
+		{
+			name:    "allowlist with malformed host",
+			network: NetworkDef{Mode: "allowlist", Allow: []string{"https://github.com"}},
+			wantErr: "agents.worker.network.allow[0]: must be a hostname",
+		},
+		{
+			name:    "hosts without allowlist mode",
+			network: NetworkDef{Mode: "none", Allow: []string{"github.com"}},
+			wantErr: "agents.worker.network.allow: only valid with mode allowlist",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			agent := cfg.Agents["worker"]
+			agent.Network = tt.network
+			cfg.Agents["worker"] = agent
+			err := Validate(&cfg)
+			if err == nil {
+				t.Fatalf("Validate returned nil, want error containing %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %q, want it to contain %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestFR3_ValidNetwork verifies that well-formed policies pass validation.
+func TestFR3_ValidNetwork(t *testing.T) {
+	for _, network := range []NetworkDef{
+		{},
+		{Mode: "none"},
+		{Mode: "allowlist", Allow: []string{"api.anthropic.com", "github.com", "*.githubusercontent.com"}},
+	} {
+		cfg := validConfig()
+		agent := cfg.Agents["worker"]
+		agent.Network = network
+		cfg.Agents["worker"] = agent
+		if err := Validate(&cfg); err != nil {
+			t.Errorf("Validate(%+v) returned unexpected error: %v", network, err)
+		}
+	}
+}
+
+// TestFR3_UnknownTool verifies that tool names are checked against the
+// catalog and that restrict_path requires tools.
+func TestFR3_UnknownTool(t *testing.T) {
+	cfg := validConfig()
+	agent := cfg.Agents["worker"]
+	agent.Tools = []string{"git", "kubectl"}
+	cfg.Agents["worker"] = agent
+	err := Validate(&cfg)
+	if err == nil || !strings.Contains(err.Error(), `agents.worker.tools[1]: unknown tool "kubectl"`) {
+		t.Fatalf("error = %v, want unknown tool error", err)
+	}
+	if !strings.Contains(err.Error(), "gh, git") {
+		t.Errorf("error = %q, want it to list known tools", err.Error())
+	}
+
+	cfg = validConfig()
+	agent = cfg.Agents["worker"]
+	agent.RestrictPath = true
+	cfg.Agents["worker"] = agent
+	err = Validate(&cfg)
+	if err == nil || !strings.Contains(err.Error(), "agents.worker.restrict_path: requires at least one tool") {
+		t.Fatalf("error = %v, want restrict_path error", err)
+	}
+}
+
+// TestFR3_MCPServerErrors verifies validation of agent MCP servers.
+func TestFR3_MCPServerErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  MCPServer
+		wantErr string
+	}{
+		{
+			name:    "neither command nor url",
+			server:  MCPServer{},
+			wantErr: "agents.worker.mcp_servers.tracker: exactly one of command or url required",
+		},
+		{
+			name:    "both command and url",
+			server:  MCPServer{Command: "tracker-mcp", URL: "https://tracker/mcp"},
+			wantErr: "agents.worker.mcp_servers.tracker: exactly one of command or url required",
+		},
+		{
+			name:    "unknown transport",
+			server:  MCPServer{URL: "https://tracker/mcp", Transport: "websocket"},
+			wantErr: "agents.worker.mcp_servers.tracker.transport: must be http or sse",
+		},
+		{
+			name:    "headers on stdio server",
+			server:  MCPServer{Command: "tracker-mcp", Headers: map[string]string{"X": "y"}},
+			wantErr: "agents.worker.mcp_servers.tracker.headers: only valid with url",
+		},
+		{
+			name:    "undefined secret",
+			server:  MCPServer{Command: "tracker-mcp", Env: map[string]string{"T": "${secrets.tracker_token}"}},
+			wantErr: `agents.worker.mcp_servers.tracker: references undefined secret "tracker_token"`,
+		},
+		{
+			name:    "undefined secret in command",
+			server:  MCPServer{Command: "tracker-mcp --token=${secrets.tracker_token}"},
+			wantErr: `agents.worker.mcp_servers.tracker: references undefined secret "tracker_token"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			agent := cfg.Agents["worker"]
+			agent.MCPServers = map[string]MCPServer{"tracker": tt.server}
+			cfg.Agents["worker"] = agent
+			err := Validate(&cfg)
+			if err == nil {
+				t.Fatalf("Validate returned nil, want error containing %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %q, want it to contain %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestFR3_ValidMCPServer verifies that a server referencing a defined
+// secret passes validation.
+func TestFR3_ValidMCPServer(t *testing.T) {
+	cfg := validConfig()
+	cfg.Credentials.Secrets = map[string]SecretRef{"tracker_token": {Name: "TRACKER", Env: "TRACKER_TOKEN"}}
+	agent := cfg.Agents["worker"]
+	agent.MCPServers = map[string]MCPServer{
+		"tracker": {Command: "tracker-mcp", Env: map[string]string{"T": "${secrets.tracker_token}"}},
+		"docs":    {URL: "https://docs/mcp", Transport: "sse"},
+	}
+	cfg.Agents["worker"] = agent
+	if err := Validate(&cfg); err != nil {
+		t.Fatalf("Validate returned unexpected error: %v", err)
+	}
+}
+
+// TestFR3_BudgetErrors verifies that negative budget limits are rejected for
+// agents and for the run.
+func TestFR3_BudgetErrors(t *testing.T) {
+	cfg := validConfig()
+	agent := cfg.Agents["worker"]
+	agent.Budget = Budget{MaxCostUSD: -1, MaxTurns: -3}
+	cfg.Agents["worker"] = agent
+	cfg.Budget = Budget{MaxTokens: -5}
+
+	err := Validate(&cfg)
+	if err == nil {
+		t.Fatal("Validate returned nil, want budget errors")
+	}
+	for _, want := range []string{
+		"agents.worker.budget.max_cost_usd: must not be negative",
+		"agents.worker.budget.max_turns: must not be negative",
+		"budget.max_tokens: must not be negative",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error = %q, want it to contain %q", err.Error(), want)
+		}
+	}
+}
+
+// TestFR3_ValidBudget verifies that positive budget limits pass validation.
+func TestFR3_ValidBudget(t *testing.T) {
+	cfg := validConfig()
+	agent := cfg.Agents["worker"]
+	agent.Budget = Budget{MaxCostUSD: 2.5, MaxTurns: 40}
+	cfg.Agents["worker"] = agent
+	cfg.Budget = Budget{MaxCostUSD: 10, MaxTokens: 5_000_000}
+	if err := Validate(&cfg); err != nil {
+		t.Fatalf("Validate returned unexpected error: %v", err)
+	}
+}
+
+// TestFR3_ConcurrencyErrors verifies that concurrency limits must be
+// positive and name defined agents.
+func TestFR3_ConcurrencyErrors(t *testing.T) {
+	cfg := validConfig()
+	cfg.Concurrency = Concurrency{MaxAgents: -1, Agents: map[string]int{"worker": 0, "ghost": 2}}
+
+	err := Validate(&cfg)
+	if err == nil {
+		t.Fatal("Validate returned nil, want concurrency errors")
+	}
+	for _, want := range []string{
+		"concurrency.max_agents: must not be negative",
+		"concurrency.agents.worker: must be positive (got 0)",
+		`concurrency.agents.ghost: references undefined agent "ghost"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error = %q, want it to contain %q", err.Error(), want)
+		}
+	}
+
+	cfg.Concurrency = Concurrency{MaxAgents: 4, Agents: map[string]int{"worker": 2}}
+	if err := Validate(&cfg); err != nil {
+		t.Fatalf("Validate returned unexpected error: %v", err)
+	}
+}
+
+// TestFR3_ArtifactErrors verifies that artifact names are single, unique
+// path elements.
+func TestFR3_ArtifactErrors(t *testing.T) {
+	cfg := validConfig()
+	worker := cfg.Agents["worker"]
+	worker.Artifacts = []string{"plan.md", "../etc", "plan.md", ".hidden", "reports"}
+	cfg.Agents["worker"] = worker
+
+	err := Validate(&cfg)
+	if err == nil {
+		t.Fatal("Validate returned nil, want artifact errors")
+	}
+	for _, want := range []string{
+		`agents.worker.artifacts[1]: must be a file name like plan.md (got "../etc")`,
+		`agents.worker.artifacts[2]: duplicate artifact "plan.md"`,
+		`agents.worker.artifacts[3]: must be a file name like plan.md (got ".hidden")`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error = %q, want it to contain %q", err.Error(), want)
+		}
+	}
+	if strings.Contains(err.Error(), "artifacts[0]") || strings.Contains(err.Error(), "artifacts[4]") {
+		t.Errorf("valid artifact names rejected: %v", err)
+	}
+}
+
+// TestFR3_WorkspaceFrom verifies that workspace_from names a read-write
+// dependency and is used by read-only agent and container steps.
+func TestFR3_WorkspaceFrom(t *testing.T) {
+	tests := []struct {
+		name    string
+		step    StepDef
+		wantErr string // empty = valid
+	}{
+		{
+			name: "reviewer on build",
+			step: StepDef{Name: "review", Agent: "reviewer", DependsOn: []string{"build"}, WorkspaceFrom: "build"},
+		},
+		{
+			name: "container on build",
+			step: StepDef{Name: "test", Kind: StepContainer, Command: "make test",
+				DependsOn: []string{"build"}, WorkspaceFrom: "build"},
+		},
+		{
+			name:    "not a dependency",
+			step:    StepDef{Name: "review", Agent: "reviewer", WorkspaceFrom: "build"},
+			wantErr: `pipeline[2].workspace_from: step "build" must be in depends_on`,
+		},
+		{
+			name:    "read-only source",
+			step:    StepDef{Name: "review", Agent: "reviewer", DependsOn: []string{"lint"}, WorkspaceFrom: "lint"},
+			wantErr: `pipeline[2].workspace_from: step "lint" does not write to the workspace`,
+		},
+		{
+			name:    "read-write step",
+			step:    StepDef{Name: "fix", Agent: "worker", DependsOn: []string{"build"}, WorkspaceFrom: "build"},
+			wantErr: "pipeline[2].workspace_from: requires a read-only workspace",
+		},
+		{
+			name: "shell step",
+			step: StepDef{Name: "check", Kind: StepShell, Command: "true",
+				DependsOn: []string{"build"}, WorkspaceFrom: "build"},
+			wantErr: "pipeline[2].workspace_from: only valid with kind agent or container",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Agents["reviewer"] = AgentDef{Prompt: PromptDef{System: "review.md", Task: "review"}, Workspace: "ro"}
+			cfg.Pipeline = append(cfg.Pipeline, StepDef{Name: "lint", Agent: "reviewer"}, tt.step)
+			err := Validate(&cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate returned unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestFR3_TriggerErrors verifies trigger rules and the webhook secret they
+// require.
+func TestFR3_TriggerErrors(t *testing.T) {
+	cfg := validConfig()
+	cfg.Pipelines = map[string]PipelineDef{
+		"fix":   {Params: []string{"issue"}, Steps: []StepDef{{Name: "fix", Agent: "worker"}}},
+		"other": {Params: []string{"branch"}, Steps: []StepDef{{Name: "x", Agent: "worker"}}},
+	}
+	cfg.Triggers = Triggers{Rules: []TriggerRule{
+		{Event: "push"},
+		{Event: EventIssues, Command: "/conductor fix"},
+		{Event: EventIssueComment, Label: "bug"},
+		{Event: EventIssues, Pipeline: "missing"},
+		{Event: EventIssues, Pipeline: "other"},
+		{Event: EventIssues, Label: "bug", AuthorAssociations: []string{"MEMBER"}, AllowedSenders: []string{"octocat"}},
+		{Event: EventIssueComment, Command: "/conductor fix", AuthorAssociations: []string{"member"}},
+	}}
+
+	err := Validate(&cfg)
+	if err == nil {
+		t.Fatal("Validate returned nil, want trigger errors")
+	}
+	for _, want := range []string{
+		"triggers.secret: required with trigger rules",
+		`triggers.rules[0].event: must be one of: issues, pull_request, issue_comment (got "push")`,
+		"triggers.rules[1].command: only valid with event issue_comment",
+		"triggers.rules[2].label: not valid with event issue_comment",
+		`triggers.rules[3].pipeline: references undefined pipeline "missing"`,
+		`triggers.rules[4].pipeline: param "branch" of pipeline "other" is not a trigger input`,
+		"triggers.rules[5].author_associations: only valid with a command",
+		"triggers.rules[5].allowed_senders: only valid with a command",
+		`triggers.rules[6].author_associations[0]: must be one of: OWNER, MEMBER`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error = %q, want it to contain %q", err.Error(), want)
+		}
+	}
+
+	cfg.Triggers = Triggers{Secret: "github-webhook", Rules: []TriggerRule{
+		{Event: EventIssues, Actions: []string{"labeled"}, Label: "conductor"},
+		{Event: EventIssueComment, Command: "/conductor fix", Pipeline: "fix",
+			AuthorAssociations: []string{"OWNER", "CONTRIBUTOR"}, AllowedSenders: []string{"octocat"}},
+	}}
+	delete(cfg.Pipelines, "other")
+	if err := Validate(&cfg); err != nil {
+		t.Fatalf("Validate returned unexpected error: %v", err)
+	}
+}
+
+// TestFR3_ScheduleErrors verifies schedule timetables, policies and the
+// inputs they must bind.
+func TestFR3_ScheduleErrors(t *testing.T) {
+	cfg := validConfig()
+	cfg.Pipelines = map[string]PipelineDef{
+		"triage": {Params: []string{"label"}, Steps: []StepDef{{Name: "triage", Agent: "worker"}}},
+	}
+	cfg.Schedules = map[string]ScheduleDef{
+		".hidden":  {Cron: "@daily"},
+		"nocron":   {},
+		"badcron":  {Cron: "61 * * * *"},
+		"badzone":  {Cron: "@daily", Timezone: "Mars/Olympus"},
+		"policies": {Cron: "@daily", Overlap: "queue", Missed: "all"},
+		"missing":  {Cron: "@daily", Pipeline: "missing"},
+		"unbound":  {Cron: "@daily", Pipeline: "triage"},
+	}
+
+	err := Validate(&cfg)
+	if err == nil {
+		t.Fatal("Validate returned nil, want schedule errors")
+	}
+	for _, want := range []string{
+		"schedules..hidden: name must be",
+		"schedules.nocron.cron: required",
+		"schedules.badcron.cron: cron \"61 * * * *\": minute",
+		`schedules.badzone.timezone: unknown time zone "Mars/Olympus"`,
+		`schedules.policies.overlap: must be one of: skip, allow (got "queue")`,
+		`schedules.policies.missed: must be one of: skip, run_once (got "all")`,
+		`schedules.missing.pipeline: references undefined pipeline "missing"`,
+		`schedules.unbound.inputs: missing param "label" of pipeline "triage"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error = %q, want it to contain %q", err.Error(), want)
+		}
+	}
+
+	cfg.Schedules = map[string]ScheduleDef{
+		"nightly-triage": {Cron: "0 3 * * *", Timezone: "UTC", Pipeline: "triage",
+			Inputs: map[string]string{"label": "stale"}, Missed: MissedRunOnce},
+		"weekly-deps": {Cron: "0 6 * * mon", Overlap: OverlapAllow},
+	}
+	if err := Validate(&cfg); err != nil {
+		t.Fatalf("Validate returned unexpected error: %v", err)
+	}
+}
+
+// TestForPipeline verifies that a run of a named sub-pipeline executes it as
+// its single step with each param bound to the same-named input.
+func TestForPipeline(t *testing.T) {
+	cfg := &Config{
+		Pipeline:  []StepDef{{Name: "main", Agent: "worker"}},
+		Pipelines: map[string]PipelineDef{"fix": {Params: []string{"pr"}}},
+	}
+	if got := cfg.ForPipeline(""); got != cfg {
+		t.Error("empty name did not return the config itself")
+	}
+	got := cfg.ForPipeline("fix")
+	if len(got.Pipeline) != 1 || got.Pipeline[0].Pipeline != "fix" || got.Pipeline[0].With["pr"] != `{{index .Inputs "pr"}}` {
+		t.Errorf("pipeline = %+v", got.Pipeline)
+	}
+	if cfg.Pipeline[0].Name != "main" {
+		t.Error("ForPipeline modified the config")
+	}
+}
+
+// TestReplayedSteps verifies that cacheable steps mounting a repository
+// read-write are listed, those of sub-pipelines under the pipeline name.
+func TestReplayedSteps(t *testing.T) {
+	off := false
+	cfg := &Config{
+		Project: Project{Repository: "https://github.com/acme/widgets.git"},
+		Agents:  map[string]AgentDef{"coder": {Workspace: "rw"}, "reviewer": {Workspace: "ro"}},
+		Pipeline: []StepDef{
+			{Name: "fix", Agent: "coder"},
+			{Name: "review", Agent: "reviewer"},
+			{Name: "again", Agent: "coder", Cache: &off},
+			{Name: "format", Kind: StepContainer, Command: "gofmt -w .", Workspace: "rw"},
+			{Name: "lint", Kind: StepContainer, Command: "go vet ./..."},
+			{Name: "notify", Kind: StepShell, Command: "true"},
+		},
+		Pipelines: map[string]PipelineDef{"verify": {Steps: []StepDef{{Name: "patch", Agent: "coder"}}}},
+	}
+	want := []string{"fix", "format", "verify.patch"}
+	if got := cfg.ReplayedSteps(); !slices.Equal(got, want) {
+		t.Errorf("ReplayedSteps = %v, want %v", got, want)
+	}
+}
+
+// TestFR3_StepKindErrors verifies the fields required and forbidden per
+// step kind.
+func TestFR3_StepKindErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		step    StepDef
+		wantErr string
+	}{
+		{
+			name:    "unknown kind",
+			step:    StepDef{Name: "gate", Kind: "manual"},
+			wantErr: "pipeline[1].kind: must be one of",
+		},
+		{
+			name:    "shell command interpolating unquoted",
+			step:    StepDef{Name: "merge", Kind: StepShell, Command: "gh pr merge {{.PRNumber}}"},
+			wantErr: "pipeline[1].command: {{.PRNumber}} must be quoted",
+		},
+		{
+			name:    "approval with agent",
+			step:    StepDef{Name: "gate", Kind: StepApproval, Agent: "worker"},
+			wantErr: "pipeline[1].agent: not valid with kind approval",
+		},
+		{
+			name:    "approval bad timeout",
+			step:    StepDef{Name: "gate", Kind: StepApproval, Approval: ApprovalDef{Timeout: "soon"}},
+			wantErr: "pipeline[1].approval.timeout: must be a positive duration",
+		},
+		{
+			name:    "approval settings on agent step",
+			step:    StepDef{Name: "gate", Agent: "worker", Approval: ApprovalDef{Message: "ok?"}},
+			wantErr: "pipeline[1].approval: only valid with kind approval",
+		},
+		{
+			name:    "approval for_each",
+			step:    StepDef{Name: "gate", Kind: StepApproval, ForEach: "[1, 2]"},
+			wantErr: "pipeline[1].for_each: not valid with kind approval",
+		},
+		{
+			name:    "for_each on read-write agent",
+			step:    StepDef{Name: "fix", Agent: "worker", ForEach: "[1, 2]"},
+			wantErr: "pipeline[1].for_each: not valid on a step that writes to the workspace",
+		},
+		{
+			name:    "for_each on read-write container",
+			step:    StepDef{Name: "fmt", Kind: StepContainer, Command: "gofmt -w .", Workspace: "rw", ForEach: "[1, 2]"},
+			wantErr: "pipeline[1].for_each: not valid on a step that writes to the workspace",
+		},
+		{
+			name:    "unknown run_if",
+			step:    StepDef{Name: "gate", Agent: "worker", RunIf: "sometimes"},
+			wantErr: `pipeline[1].run_if: must be one of: success, failure, always (got "sometimes")`,
+		},
+		{
+			name:    "run_if failure without dependencies",
+			step:    StepDef{Name: "notify", Agent: "worker", RunIf: RunIfFailure},
+			wantErr: "pipeline[1].run_if: failure requires depends_on",
+		},
+		{
+			name:    "cache on shell step",
+			step:    StepDef{Name: "merge", Kind: StepShell, Command: "gh pr merge", Cache: new(bool)},
+			wantErr: "pipeline[1].cache: only valid with kind agent or container",
+		},
+		{
+			name:    "shell without command",
+			step:    StepDef{Name: "test", Kind: StepShell},
+			wantErr: "pipeline[1].command: required for kind shell",
+		},
+		{
+			name:    "container with agent",
+			step:    StepDef{Name: "test", Kind: StepContainer, Agent: "worker", Command: "make test"},
+			wantErr: "pipeline[1].agent: not valid with kind container",
+		},
+		{
+			name:    "container bad workspace",
+			step:    StepDef{Name: "test", Kind: StepContainer, Command: "make test", Workspace: "rwx"},
+			wantErr: "pipeline[1].workspace: must be rw or ro",
+		},
+		{
+			name:    "command on agent step",
+			step:    StepDef{Name: "test", Agent: "worker", Command: "make test"},
+			wantErr: "pipeline[1].command: only valid with kind shell or container",
+		},
+		{
+			name:    "workspace on shell step",
+			step:    StepDef{Name: "test", Kind: StepShell, Command: "make test", Workspace: "rw"},
+			wantErr: "pipeline[1].workspace: only valid with kind container",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Pipeline = append(cfg.Pipeline, tt.step)
+			err := Validate(&cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestFR3_ValidApprovalStep verifies that an approval step without an agent
+// passes validation and that its timeout defaults.
+func TestFR3_ValidApprovalStep(t *testing.T) {
+	cfg := validConfig()
+	cfg.Pipeline = append(cfg.Pipeline,
+		StepDef{Name: "gate", Kind: StepApproval, DependsOn: []string{"build"},
+			Approval: ApprovalDef{Message: "Push the fix?", Timeout: "4h"}},
+		StepDef{Name: "later", Kind: StepApproval})
+	if err := Validate(&cfg); err != nil {
+		t.Fatalf("Validate returned unexpected error: %v", err)
+	}
+	if got := cfg.Pipeline[1].ApprovalTimeout(); got != 4*time.Hour {
+		t.Errorf("ApprovalTimeout = %v, want 4h", got)
+	}
+	if got := cfg.Pipeline[2].ApprovalTimeout(); got != DefaultApprovalTimeout {
+		t.Errorf("ApprovalTimeout = %v, want default", got)
+	}
+}
+
+// TestFR3_ValidCommandSteps verifies that shell and container steps with a
+// command pass validation.
+func TestFR3_ValidCommandSteps(t *testing.T) {
+	cfg := validConfig()
+	cfg.Pipeline = append(cfg.Pipeline,
+		StepDef{Name: "lint", Kind: StepShell, Command: "make lint", DependsOn: []string{"build"}},
+		StepDef{Name: "test", Kind: StepContainer, Command: "go test ./...", Workspace: "rw"},
+		StepDef{Name: "vet", Kind: StepContainer, Command: "go vet {{.Item | shellquote}}", ForEach: "['./a', './b']"})
+	if err := Validate(&cfg); err != nil {
+		t.Fatalf("Validate returned unexpected error: %v", err)
+	}
+	if !cfg.HasStepKind(StepContainer) || cfg.HasStepKind(StepApproval) {
+		t.Error("HasStepKind does not match the pipeline")
+	}
+}
+
+// subPipelineConfig returns validConfig with a named "review" pipeline
+// taking a pr param, invoked by a second step.
+func subPipelineConfig() Config {
+	cfg := validConfig()
+	cfg.Pipelines = map[string]PipelineDef{"review": {
+		Params:  []string{"pr"},
+		Steps:   []StepDef{{Name: "check", Kind: StepShell, Command: "gh pr checks {{.Params.pr | shellquote}}"}},
+		Outputs: map[string]string{"passed": "steps.check.status == 'success'"},
+	}}
+	cfg.Pipeline = append(cfg.Pipeline, StepDef{Name: "review", Kind: StepPipeline, Pipeline: "review",
+		With: map[string]string{"pr": "{{.PRNumber}}"}, DependsOn: []string{"build"}})
+	return cfg
+}
+
+// TestFR3_ValidSubPipeline verifies that a pipeline step with all params
+// bound passes validation.
+func TestFR3_ValidSubPipeline(t *testing.T) {
+	cfg := subPipelineConfig()
+	if err := Validate(&cfg); err != nil {
+		t.Fatalf("Validate returned unexpected error: %v", err)
+	}
+	cfg.Pipelines["review"].Steps[0].Kind = StepContainer
+	if !cfg.HasStepKind(StepContainer) {
+		t.Error("HasStepKind ignores sub-pipeline steps")
+	}
+}
+
+// TestFR3_SubPipelineErrors verifies pipeline step bindings and sub-pipeline
+// checks.
+func TestFR3_SubPipelineErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(*Config)
+		wantErr string
+	}{
+		{
+			name:    "undefined pipeline",
+			mutate:  func(c *Config) { c.Pipeline[1].Pipeline = "nope" },
+			wantErr: `pipeline[1].pipeline: references undefined pipeline "nope"`,
+		},
+		{
+			name:    "missing pipeline",
+			mutate:  func(c *Config) { c.Pipeline[1].Pipeline = "" },
+			wantErr: "pipeline[1].pipeline: required for kind pipeline",
+		},
+		{
+			name:    "unbound param",
+			mutate:  func(c *Config) { c.Pipeline[1].With = nil },
+			wantErr: `pipeline[1].with: missing param "pr" of pipeline "review"`,
+		},
+		{
+			name:    "unknown param",
+			mutate:  func(c *Config) { c.Pipeline[1].With["issue"] = "1" },
+			wantErr: `pipeline[1].with.issue: pipeline "review" has no such param`,
+		},
+		{
+			name:    "with on agent step",
+			mutate:  func(c *Config) { c.Pipeline[0].With = map[string]string{"pr": "1"} },
+			wantErr: "pipeline[0].pipeline: pipeline and with are only valid with kind pipeline",
+		},
+		{
+			name: "invalid sub-pipeline step",
+			mutate: func(c *Config) {
+				c.Pipelines["review"].Steps[0].Command = ""
+			},
+			wantErr: "pipelines.review.steps[0].command: required for kind shell",
+		},
+		{
+			name: "empty output expression",
+			mutate: func(c *Config) {
+				c.Pipelines["review"].Outputs["passed"] = ""
+			},
+			wantErr: "pipelines.review.outputs.passed: required",
+		},
+		{
+			name: "self inclusion",
+			mutate: func(c *Config) {
+				sub := c.Pipelines["review"]
+				sub.Steps = append(sub.Steps, StepDef{Name: "again", Kind: StepPipeline, Pipeline: "review",
+					With: map[string]string{"pr": "{{.Params.pr}}"}})
+				c.Pipelines["review"] = sub
+			},
+			wantErr: "pipelines.review: includes itself: review → review",
+		},
+		{
+			name: "mutual inclusion",
+			mutate: func(c *Config) {
+				sub := c.Pipelines["review"]
+				sub.Steps = append(sub.Steps, StepDef{Name: "fix", Kind: StepPipeline, Pipeline: "fix",
+					With: map[string]string{}})
+				c.Pipelines["review"] = sub
+				c.Pipelines["fix"] = PipelineDef{Steps: []StepDef{{Name: "review", Kind: StepPipeline,
+					Pipeline: "review", With: map[string]string{"pr": "1"}}}}
+			},
+			wantErr: "pipelines.fix: includes itself: fix → review → fix",
+		},
+		{
+			name: "for_each over a sub-pipeline writing to the workspace",
+			mutate: func(c *Config) {
+				sub := c.Pipelines["review"]
+				sub.Steps = append(sub.Steps, StepDef{Name: "fix", Agent: "worker"})
+				c.Pipelines["review"] = sub
+				c.Pipeline[1].ForEach = "[1, 2]"
+			},
+			wantErr: "pipeline[1].for_each: not valid on a step that writes to the workspace",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := subPipelineConfig()
+			tt.mutate(&cfg)
+			err := Validate(&cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
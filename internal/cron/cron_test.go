@@ -0,0 +1,88 @@
+package cron
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestNext verifies activation times for the field syntaxes, the macros and
+// the day-of-month/day-of-week rule.
+func TestNext(t *testing.T) {
+	// Wednesday 2026-03-04 10:17.
+	from := time.Date(2026, 3, 4, 10, 17, 30, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"* * * * *", "2026-03-04 10:18"},
+		{"*/15 * * * *", "2026-03-04 10:30"},
+		{"17 * * * *", "2026-03-04 11:17"},
+		{"0 2 * * *", "2026-03-05 02:00"},
+		{"30 9-17/4 * * *", "2026-03-04 13:30"},
+		{"0 0 * * mon", "2026-03-09 00:00"},
+		{"0 0 * * 7", "2026-03-08 00:00"},
+		{"0 0 1,15 * *", "2026-03-15 00:00"},
+		{"0 0 1 jan *", "2027-01-01 00:00"},
+		{"0 0 13 * fri", "2026-03-06 00:00"}, // either day field matches
+		{"0 0 29 2 *", "2028-02-29 00:00"},
+		{"@daily", "2026-03-05 00:00"},
+		{"@weekly", "2026-03-08 00:00"},
+		{"@hourly", "2026-03-04 11:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := e.Next(from).Format("2006-01-02 15:04"); got != tt.want {
+				t.Errorf("Next = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestNextLocation verifies that activations follow the location of the
+// time passed in.
+func TestNextLocation(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	e, _ := Parse("0 2 * * *")
+	got := e.Next(time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC).In(loc))
+	if want := time.Date(2026, 1, 11, 7, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next = %s, want %s", got, want)
+	}
+	if never := (&Expr{}).Next(time.Now()); !never.IsZero() {
+		t.Errorf("empty expression activates at %s", never)
+	}
+}
+
+// TestParseErrors verifies that malformed expressions are rejected with the
+// offending field named.
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"* * * *", "want 5 fields"},
+		{"60 * * * *", "minute"},
+		{"* 24 * * *", "hour"},
+		{"* * 0 * *", "day of month"},
+		{"* * * foo *", "month"},
+		{"* * * * 8", "day of week"},
+		{"*/0 * * * *", "invalid step"},
+		{"5-1 * * * *", "backwards"},
+		{"@often", "want 5 fields"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse(%q) error = %v, want %q", tt.expr, err, tt.want)
+			}
+		})
+	}
+}
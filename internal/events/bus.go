@@ -0,0 +1,80 @@
+// Package events carries structured progress events from running agents and
+// pipelines to in-process consumers.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event kinds published during a run.
+const (
+	KindInit       = "init"        // agent session started
+	KindMessage    = "message"     // assistant text
+	KindToolUse    = "tool_use"    // agent invoked a tool
+	KindToolResult = "tool_result" // tool returned
+	KindResult     = "result"      // agent finished its session
+	KindOutput     = "output"      // raw line outside the structured stream
+	KindApproval   = "approval"    // step waits for a human decision
+	KindStep       = "step"        // step started or finished; Text is its status
+)
+
+// Event is one progress record of a pipeline run.
+type Event struct {
+	Time  time.Time      `json:"time"`
+	Step  string         `json:"step,omitempty"`
+	Agent string         `json:"agent,omitempty"`
+	Kind  string         `json:"kind"`
+	Text  string         `json:"text,omitempty"` // message text, tool result, or raw output line
+	Tool  string         `json:"tool,omitempty"` // tool name for tool_use events
+	Data  map[string]any `json:"data,omitempty"` // kind-specific details, e.g. tool input
+}
+
+// Bus fans events out to subscribers. Publishing never blocks: events for a
+// subscriber whose buffer is full are dropped. A nil *Bus discards events.
+type Bus struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+// NewBus returns an empty bus.
+func NewBus() *Bus {
+	return &Bus{subs: map[chan Event]struct{}{}}
+}
+
+// Publish delivers e to every subscriber, stamping it with the current time
+// if it has none.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel receiving events published from now on, and a
+// function that unsubscribes and closes the channel.
+func (b *Bus) Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+// TestBusFanOut verifies that every subscriber receives published events
+// and that unsubscribing closes the channel.
+func TestBusFanOut(t *testing.T) {
+	b := NewBus()
+	a, cancelA := b.Subscribe(4)
+	c, cancelC := b.Subscribe(4)
+
+	b.Publish(Event{Step: "implement", Kind: KindMessage, Text: "hello"})
+	for _, ch := range []<-chan Event{a, c} {
+		e := <-ch
+		if e.Step != "implement" || e.Text != "hello" || e.Time.IsZero() {
+			t.Errorf("event = %+v", e)
+		}
+	}
+
+	cancelA()
+	if _, ok := <-a; ok {
+		t.Error("channel still open after unsubscribe")
+	}
+	cancelA() // idempotent
+	b.Publish(Event{Kind: KindResult})
+	if e := <-c; e.Kind != KindResult {
+		t.Errorf("event = %+v", e)
+	}
+	cancelC()
+}
+
+// TestBusDropsWhenFull verifies that a slow subscriber never blocks Publish.
+func TestBusDropsWhenFull(t *testing.T) {
+	b := NewBus()
+	ch, cancel := b.Subscribe(1)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			b.Publish(Event{Kind: KindOutput})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a full subscriber")
+	}
+	if len(ch) != 1 {
+		t.Errorf("buffered %d events, want 1", len(ch))
+	}
+}
+
+// TestNilBus verifies that publishing to a nil bus is a no-op.
+func TestNilBus(t *testing.T) {
+	var b *Bus
+	b.Publish(Event{Kind: KindOutput})
+}
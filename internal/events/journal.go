@@ -0,0 +1,69 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// journalBuffer is how many events a journal holds back before the bus
+// drops them for it.
+const journalBuffer = 4096
+
+// Record appends every event published on b to the JSON-lines journal at
+// path, so other processes can follow the run. The returned function stops
+// recording once the events received so far are written.
+func Record(b *Bus, path string) (stop func(), err error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open event journal: %w", err)
+	}
+	ch, unsubscribe := b.Subscribe(journalBuffer)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		enc := json.NewEncoder(f)
+		for e := range ch {
+			enc.Encode(e)
+		}
+		f.Close()
+	}()
+	return func() {
+		unsubscribe()
+		<-done
+	}, nil
+}
+
+// ReadJournal returns the events of the journal at path from byte offset
+// on, and the offset following the last complete line read. A journal that
+// does not exist yet holds no events.
+func ReadJournal(path string, offset int64) ([]Event, int64, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, offset, nil
+	}
+	if err != nil {
+		return nil, offset, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, err
+	}
+	var evs []Event
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil { // EOF, possibly within a line still being written
+			return evs, offset, nil
+		}
+		offset += int64(len(line))
+		var e Event
+		if json.Unmarshal(bytes.TrimSpace(line), &e) == nil {
+			evs = append(evs, e)
+		}
+	}
+}
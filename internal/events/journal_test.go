@@ -0,0 +1,45 @@
+package events
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestJournal verifies that recorded events read back in order, that a
+// reader resumes from its offset, and that an unfinished line is left for
+// the next read.
+func TestJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	if evs, off, err := ReadJournal(path, 0); err != nil || evs != nil || off != 0 {
+		t.Fatalf("ReadJournal of missing journal = %v, %d, %v", evs, off, err)
+	}
+
+	b := NewBus()
+	stop, err := Record(b, path)
+	if err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	b.Publish(Event{Step: "plan", Kind: KindStep, Text: "running"})
+	b.Publish(Event{Step: "plan", Kind: KindToolUse, Tool: "Bash", Data: map[string]any{"command": "ls"}})
+	stop()
+
+	evs, off, err := ReadJournal(path, 0)
+	if err != nil {
+		t.Fatalf("ReadJournal: %v", err)
+	}
+	if len(evs) != 2 || evs[0].Text != "running" || evs[1].Tool != "Bash" || evs[1].Data["command"] != "ls" || evs[0].Time.IsZero() {
+		t.Fatalf("events = %+v", evs)
+	}
+
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	f.WriteString(`{"step":"plan","kind":"step","text":"succ`)
+	if evs, off2, _ := ReadJournal(path, off); len(evs) != 0 || off2 != off {
+		t.Errorf("partial line read as %+v, offset %d → %d", evs, off, off2)
+	}
+	f.WriteString(`ess"}` + "\n")
+	f.Close()
+	if evs, _, _ := ReadJournal(path, off); len(evs) != 1 || evs[0].Text != "success" {
+		t.Errorf("completed line read as %+v", evs)
+	}
+}
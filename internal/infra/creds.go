@@ -0,0 +1,27 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+)
+
+// CredentialStore retrieves secrets by backend-specific name.
+type CredentialStore interface {
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// NewCredentialStore returns the store for the given credentials.backend value.
+// Config validation already rejects unknown backends; the factory checks again
+// as defense-in-depth.
+func NewCredentialStore(backend string) (CredentialStore, error) {
+	switch backend {
+	case "rbw":
+		return &rbwStore{}, nil
+	case "env":
+		return &envStore{}, nil
+	case "file":
+		return &fileStore{}, nil
+	default:
+		return nil, fmt.Errorf("unknown credential backend: %q", backend)
+	}
+}
@@ -0,0 +1,18 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// envStore reads secrets from the host environment.
+type envStore struct{}
+
+func (s *envStore) Get(_ context.Context, name string) (string, error) {
+	val, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("env var %q not set", name)
+	}
+	return val, nil
+}
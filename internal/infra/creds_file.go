@@ -0,0 +1,19 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// fileStore reads secrets from files, one secret per file.
+type fileStore struct{}
+
+func (s *fileStore) Get(_ context.Context, name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", fmt.Errorf("read secret file %q: %w", name, err)
+	}
+	return strings.TrimRight(string(data), "\n"), nil
+}
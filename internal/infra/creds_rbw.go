@@ -0,0 +1,19 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// rbwStore reads secrets from the Bitwarden CLI (rbw).
+type rbwStore struct{}
+
+func (s *rbwStore) Get(ctx context.Context, name string) (string, error) {
+	out, err := exec.CommandContext(ctx, "rbw", "get", name).Output()
+	if err != nil {
+		return "", fmt.Errorf("rbw get %q: %w", name, err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
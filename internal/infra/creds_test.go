@@ -0,0 +1,71 @@
+package infra
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestFR5_NewCredentialStore verifies backend selection and rejection of
+// unknown backends.
+func TestFR5_NewCredentialStore(t *testing.T) {
+	for _, backend := range []string{"rbw", "env", "file"} {
+		if _, err := NewCredentialStore(backend); err != nil {
+			t.Errorf("NewCredentialStore(%q) returned error: %v", backend, err)
+		}
+	}
+	_, err := NewCredentialStore("vault")
+	if err == nil || !strings.Contains(err.Error(), `"vault"`) {
+		t.Fatalf("NewCredentialStore(vault) error = %v, want unknown backend error", err)
+	}
+}
+
+// TestFR3_EnvStore verifies that the env backend reads set variables and
+// errors on unset ones.
+func TestFR3_EnvStore(t *testing.T) {
+	t.Setenv("CONDUCTOR_TEST_SECRET", "s3cret")
+	s := &envStore{}
+	got, err := s.Get(context.Background(), "CONDUCTOR_TEST_SECRET")
+	if err != nil || got != "s3cret" {
+		t.Fatalf("Get = %q, %v; want %q, nil", got, err, "s3cret")
+	}
+	if _, err := s.Get(context.Background(), "CONDUCTOR_TEST_UNSET"); err == nil {
+		t.Fatal("Get of unset var returned nil error")
+	}
+}
+
+// TestFR4_FileStore verifies that the file backend trims the trailing newline.
+func TestFR4_FileStore(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(p, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := (&fileStore{}).Get(context.Background(), p)
+	if err != nil || got != "s3cret" {
+		t.Fatalf("Get = %q, %v; want %q, nil", got, err, "s3cret")
+	}
+}
+
+// TestFR2_RBWStore verifies that the rbw backend shells out to `rbw get`
+// and trims its output, using a fake rbw on PATH.
+func TestFR2_RBWStore(t *testing.T) {
+	fakeBin(t, "rbw", `[ "$1" = get ] && echo "secret-for-$2"`)
+	got, err := (&rbwStore{}).Get(context.Background(), "pat")
+	if err != nil || got != "secret-for-pat" {
+		t.Fatalf("Get = %q, %v; want %q, nil", got, err, "secret-for-pat")
+	}
+}
+
+// fakeBin installs an executable shell script named name at the front of
+// PATH for the duration of the test.
+func fakeBin(t *testing.T, name, script string) {
+	t.Helper()
+	dir := t.TempDir()
+	body := "#!/bin/sh\n" + script + "\n"
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
@@ -0,0 +1,269 @@
+package infra
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+const (
+	// hashLen is the number of hex digits of the content hash used in image tags.
+	hashLen = 12
+
+	// buildTimeout bounds a single docker build.
+	buildTimeout = 10 * time.Minute
+)
+
+// ImageTag returns the content-addressed tag for an image built from d:
+// conductor-<project>:<hash>, where the hash covers the Dockerfile (given or
+// generated), the base image, and the sorted build args. Any change to those
+// inputs yields a new tag, so a stale image is never mistaken for current.
+func ImageTag(project string, d config.Docker) (string, error) {
+	dockerfile, err := dockerfileContent(d)
+	if err != nil {
+		return "", err
+	}
+	return "conductor-" + project + ":" + imageHash(d, dockerfile), nil
+}
+
+// AgentImages returns the image tag of every agent, keyed by agent name.
+// Agents without docker overrides share the project image.
+func AgentImages(cfg *config.Config) (map[string]string, error) {
+	images := make(map[string]string, len(cfg.Agents))
+	for name := range cfg.Agents {
+		tag, err := ImageTag(cfg.Project.Name, cfg.AgentDocker(name))
+		if err != nil {
+			return nil, fmt.Errorf("agent %q: %w", name, err)
+		}
+		images[name] = tag
+	}
+	return images, nil
+}
+
+// ProjectImage returns the tag of the project image, which container steps
+// run in.
+func ProjectImage(cfg *config.Config) (string, error) {
+	return ImageTag(cfg.Project.Name, cfg.Docker)
+}
+
+// requiredImages returns the docker config of every distinct image the
+// pipeline needs, keyed by tag, along with the agent image tags.
+func requiredImages(cfg *config.Config) (specs map[string]config.Docker, agents map[string]string, err error) {
+	agents, err = AgentImages(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	specs = map[string]config.Docker{}
+	for name, tag := range agents {
+		specs[tag] = cfg.AgentDocker(name)
+	}
+	if cfg.HasStepKind(config.StepContainer) {
+		tag, err := ProjectImage(cfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("project image: %w", err)
+		}
+		specs[tag] = cfg.Docker
+	}
+	return specs, agents, nil
+}
+
+// imageHash digests every input that determines the built image.
+func imageHash(d config.Docker, dockerfile []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "dockerfile %d\n", len(dockerfile))
+	h.Write(dockerfile)
+	fmt.Fprintf(h, "\nbase_image %s\n", d.BaseImage)
+	for _, k := range sortedKeys(d.BuildArgs) {
+		fmt.Fprintf(h, "build_arg %s=%s\n", k, d.BuildArgs[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:hashLen]
+}
+
+// dockerfileContent returns the configured Dockerfile, or the generated one
+// when none is specified.
+func dockerfileContent(d config.Docker) ([]byte, error) {
+	if d.Dockerfile == "" {
+		return []byte(generateDockerfile(d)), nil
+	}
+	data, err := os.ReadFile(d.Dockerfile)
+	if err != nil {
+		return nil, fmt.Errorf("read dockerfile: %w", err)
+	}
+	return data, nil
+}
+
+// ImageExists reports whether the image tag is present in the local Docker
+// image store.
+func ImageExists(ctx context.Context, tag string) (bool, error) {
+	err := exec.CommandContext(ctx, "docker", "image", "inspect", tag).Run()
+	if err == nil {
+		return true, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return false, nil
+	}
+	return false, fmt.Errorf("docker image inspect: %w", err)
+}
+
+// BuildImages builds every distinct agent image, and the project image when
+// container steps need it, in parallel. It returns the image tag of each
+// agent, keyed by agent name. Images whose tag already exists are not rebuilt.
+func BuildImages(ctx context.Context, cfg *config.Config,
+	logger *slog.Logger) (map[string]string, error) {
+	specs, images, err := requiredImages(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for tag, d := range specs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := buildImage(ctx, tag, d, logger); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("image %s: %w", tag, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// buildImage builds d under tag, skipping the build when the tag exists.
+func buildImage(ctx context.Context, tag string, d config.Docker,
+	logger *slog.Logger) error {
+	exists, err := ImageExists(ctx, tag)
+	if err != nil {
+		return err
+	}
+	if exists {
+		logger.Info("image up to date, skipping build", "image", tag)
+		return nil
+	}
+
+	dockerfile := d.Dockerfile
+	if dockerfile == "" {
+		f, err := os.CreateTemp("", "conductor-dockerfile-")
+		if err != nil {
+			return fmt.Errorf("generate dockerfile: %w", err)
+		}
+		defer os.Remove(f.Name())
+		_, err = f.WriteString(generateDockerfile(d))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("generate dockerfile: %w", err)
+		}
+		dockerfile = f.Name()
+	}
+
+	args := []string{"build", "--tag", tag, "-f", dockerfile}
+	for _, k := range sortedKeys(d.BuildArgs) {
+		args = append(args, "--build-arg", k+"="+d.BuildArgs[k])
+	}
+	args = append(args, ".")
+
+	ctx, cancel := context.WithTimeout(ctx, buildTimeout)
+	defer cancel()
+	logger.Info("building image", "image", tag)
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("docker build: %w", err)
+	}
+	return nil
+}
+
+// EnsureImages returns the image tag of every agent for the current config.
+// Missing images, including the project image needed by container steps,
+// are built if autoBuild is set, and reported as an error otherwise.
+func EnsureImages(ctx context.Context, cfg *config.Config, autoBuild bool,
+	logger *slog.Logger) (map[string]string, error) {
+	if autoBuild {
+		return BuildImages(ctx, cfg, logger)
+	}
+	specs, images, err := requiredImages(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for tag := range specs {
+		exists, err := ImageExists(ctx, tag)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			missing = append(missing, tag)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("images not found: %s; run `conductor build` first",
+			strings.Join(missing, ", "))
+	}
+	return images, nil
+}
+
+// generateDockerfile renders a Dockerfile from the base image following the
+// agent image pattern: tooling, gh, Claude Code, the agent user with baked
+// onboarding state, and git defaults. Build args are declared as ARG+ENV
+// pairs so they are visible to every RUN instruction.
+func generateDockerfile(d config.Docker) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "FROM %s\n", d.BaseImage)
+	for _, k := range sortedKeys(d.BuildArgs) {
+		fmt.Fprintf(&b, "ARG %s\nENV %s=${%s}\n", k, k, k)
+	}
+	b.WriteString(`RUN apt-get update && apt-get install -y --no-install-recommends \
+    ca-certificates curl git bash jq openssh-client \
+    && rm -rf /var/lib/apt/lists/*
+RUN curl -fsSL https://cli.github.com/packages/githubcli-archive-keyring.gpg \
+    -o /usr/share/keyrings/githubcli-archive-keyring.gpg \
+    && echo "deb [signed-by=/usr/share/keyrings/githubcli-archive-keyring.gpg] https://cli.github.com/packages stable main" \
+    > /etc/apt/sources.list.d/github-cli.list \
+    && apt-get update && apt-get install -y gh && rm -rf /var/lib/apt/lists/*
+RUN useradd -m -s /bin/bash -u 1000 agent
+USER agent
+RUN curl -fsSL https://claude.ai/install.sh | bash
+ENV PATH=/home/agent/.local/bin:$PATH
+RUN mkdir -p /home/agent/.claude \
+    && echo '{"hasCompletedOnboarding":true}' > /home/agent/.claude.json \
+    && git config --global init.defaultBranch main \
+    && git config --global pull.rebase true
+WORKDIR /workspace
+ENTRYPOINT ["/bin/bash", "-c"]
+`)
+	return b.String()
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
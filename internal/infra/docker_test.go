@@ -0,0 +1,237 @@
+package infra
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// fakeDocker installs a docker stand-in that logs its arguments and tracks
+// built images as files in a state directory. It returns the log path.
+func fakeDocker(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	log := filepath.Join(dir, "docker.log")
+	state := filepath.Join(dir, "images")
+	if err := os.Mkdir(state, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FAKE_DOCKER_LOG", log)
+	t.Setenv("FAKE_DOCKER_STATE", state)
+	fakeBin(t, "docker", `echo "$*" >> "$FAKE_DOCKER_LOG"
+case "$1" in
+  image) test -e "$FAKE_DOCKER_STATE/$3" ;;
+  build) touch "$FAKE_DOCKER_STATE/$3" ;;
+esac`)
+	return log
+}
+
+func dockerLog(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func testDockerConfig() *config.Config {
+	return &config.Config{
+		Project: config.Project{Name: "test"},
+		Docker: config.Docker{
+			BaseImage: "debian:bookworm-slim",
+			BuildArgs: map[string]string{"ZIG_VERSION": "0.14.1", "A": "1"},
+		},
+		Agents: map[string]config.AgentDef{"worker": {}},
+	}
+}
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// TestImageTagContentAddressed verifies that the tag is stable for equal
+// inputs and changes with the base image, build args, or Dockerfile.
+func TestImageTagContentAddressed(t *testing.T) {
+	base, err := ImageTag("test", testDockerConfig().Docker)
+	if err != nil {
+		t.Fatalf("ImageTag: %v", err)
+	}
+	if !strings.HasPrefix(base, "conductor-test:") || len(base) != len("conductor-test:")+hashLen {
+		t.Fatalf("ImageTag = %q, want conductor-test:<%d hex>", base, hashLen)
+	}
+	if again, _ := ImageTag("test", testDockerConfig().Docker); again != base {
+		t.Errorf("ImageTag not deterministic: %q vs %q", again, base)
+	}
+
+	dockerfile := filepath.Join(t.TempDir(), "Dockerfile")
+	os.WriteFile(dockerfile, []byte("FROM scratch\n"), 0644)
+
+	mutations := map[string]func(*config.Config){
+		"base image": func(c *config.Config) { c.Docker.BaseImage = "ubuntu:24.04" },
+		"build arg":  func(c *config.Config) { c.Docker.BuildArgs["ZIG_VERSION"] = "0.15.0" },
+		"dockerfile": func(c *config.Config) { c.Docker.Dockerfile = dockerfile },
+	}
+	for name, mutate := range mutations {
+		cfg := testDockerConfig()
+		mutate(cfg)
+		tag, err := ImageTag("test", cfg.Docker)
+		if err != nil {
+			t.Fatalf("%s: ImageTag: %v", name, err)
+		}
+		if tag == base {
+			t.Errorf("%s: tag unchanged after mutation", name)
+		}
+	}
+
+	os.WriteFile(dockerfile, []byte("FROM scratch\nRUN true\n"), 0644)
+	cfg := testDockerConfig()
+	cfg.Docker.Dockerfile = dockerfile
+	edited, _ := ImageTag("test", cfg.Docker)
+	os.WriteFile(dockerfile, []byte("FROM scratch\n"), 0644)
+	original, _ := ImageTag("test", cfg.Docker)
+	if edited == original {
+		t.Error("tag unchanged after editing the Dockerfile")
+	}
+}
+
+// TestFR8_BuildImages verifies that BuildImages passes sorted build args,
+// tags the image with its content hash, and skips the build when that tag
+// already exists.
+func TestFR8_BuildImages(t *testing.T) {
+	log := fakeDocker(t)
+	cfg := testDockerConfig()
+	want, _ := ImageTag("test", cfg.Docker)
+
+	images, err := BuildImages(context.Background(), cfg, discard)
+	if err != nil {
+		t.Fatalf("BuildImages: %v", err)
+	}
+	if images["worker"] != want {
+		t.Errorf("images = %v, want worker → %q", images, want)
+	}
+	lines := dockerLog(t, log)
+	build := lines[len(lines)-1]
+	if !strings.HasPrefix(build, "build --tag "+want+" -f ") ||
+		!strings.Contains(build, "--build-arg A=1 --build-arg ZIG_VERSION=0.14.1 .") {
+		t.Errorf("docker build args = %q", build)
+	}
+
+	if _, err := BuildImages(context.Background(), cfg, discard); err != nil {
+		t.Fatalf("second BuildImages: %v", err)
+	}
+	for _, l := range dockerLog(t, log)[len(lines):] {
+		if strings.HasPrefix(l, "build ") {
+			t.Errorf("image rebuilt although tag exists: %q", l)
+		}
+	}
+}
+
+// TestBuildImagesPerAgent verifies that agent docker overrides produce
+// their own images, inheriting unset fields, and that agents sharing a
+// config share one build.
+func TestBuildImagesPerAgent(t *testing.T) {
+	log := fakeDocker(t)
+	cfg := testDockerConfig()
+	cfg.Agents = map[string]config.AgentDef{
+		"implementer": {Docker: config.Docker{BuildArgs: map[string]string{"RUST_VERSION": "1.80"}}},
+		"reviewer":    {},
+		"planner":     {},
+	}
+
+	images, err := BuildImages(context.Background(), cfg, discard)
+	if err != nil {
+		t.Fatalf("BuildImages: %v", err)
+	}
+	if images["reviewer"] != images["planner"] {
+		t.Errorf("agents without overrides should share an image: %v", images)
+	}
+	if images["implementer"] == images["reviewer"] {
+		t.Errorf("implementer override should produce its own image: %v", images)
+	}
+
+	var builds []string
+	for _, l := range dockerLog(t, log) {
+		if strings.HasPrefix(l, "build ") {
+			builds = append(builds, l)
+		}
+	}
+	if len(builds) != 2 {
+		t.Fatalf("got %d builds, want 2 distinct images: %v", len(builds), builds)
+	}
+	for _, b := range builds {
+		if strings.Contains(b, images["implementer"]) &&
+			!strings.Contains(b, "--build-arg A=1 --build-arg RUST_VERSION=1.80 --build-arg ZIG_VERSION=0.14.1") {
+			t.Errorf("implementer build does not merge build args: %q", b)
+		}
+	}
+}
+
+// TestEnsureImages verifies that missing images are an error unless
+// autoBuild is set.
+func TestEnsureImages(t *testing.T) {
+	fakeDocker(t)
+	cfg := testDockerConfig()
+
+	_, err := EnsureImages(context.Background(), cfg, false, discard)
+	if err == nil || !strings.Contains(err.Error(), "conductor build") {
+		t.Fatalf("EnsureImages error = %v, want hint to run conductor build", err)
+	}
+	built, err := EnsureImages(context.Background(), cfg, true, discard)
+	if err != nil {
+		t.Fatalf("EnsureImages(autoBuild): %v", err)
+	}
+	got, err := EnsureImages(context.Background(), cfg, false, discard)
+	if err != nil || got["worker"] != built["worker"] {
+		t.Fatalf("EnsureImages after build = %v, %v; want %v, nil", got, err, built)
+	}
+}
+
+// TestBuildImagesProjectImage verifies that the project image is built for
+// container steps even when every agent overrides its image.
+func TestBuildImagesProjectImage(t *testing.T) {
+	log := fakeDocker(t)
+	cfg := testDockerConfig()
+	cfg.Agents = map[string]config.AgentDef{
+		"worker": {Docker: config.Docker{BaseImage: "golang:1.25"}},
+	}
+	cfg.Pipeline = []config.StepDef{{Name: "test", Kind: config.StepContainer, Command: "make test"}}
+
+	if _, err := EnsureImages(context.Background(), cfg, false, discard); err == nil {
+		t.Fatal("EnsureImages returned nil error with the project image missing")
+	}
+	if _, err := BuildImages(context.Background(), cfg, discard); err != nil {
+		t.Fatalf("BuildImages: %v", err)
+	}
+	project, err := ProjectImage(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var built bool
+	for _, l := range dockerLog(t, log) {
+		built = built || strings.HasPrefix(l, "build --tag "+project)
+	}
+	if !built {
+		t.Errorf("project image %s was not built: %v", project, dockerLog(t, log))
+	}
+}
+
+// TestFR9_GenerateDockerfile verifies the generated Dockerfile structure.
+func TestFR9_GenerateDockerfile(t *testing.T) {
+	df := generateDockerfile(testDockerConfig().Docker)
+	for _, want := range []string{
+		"FROM debian:bookworm-slim\n",
+		"ARG ZIG_VERSION\nENV ZIG_VERSION=${ZIG_VERSION}\n",
+		"useradd -m -s /bin/bash -u 1000 agent",
+		"hasCompletedOnboarding",
+		`ENTRYPOINT ["/bin/bash", "-c"]`,
+	} {
+		if !strings.Contains(df, want) {
+			t.Errorf("generated Dockerfile missing %q", want)
+		}
+	}
+}
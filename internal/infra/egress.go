@@ -0,0 +1,93 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// Egress is the network plumbing for agents with an allowlist policy: an
+// internal docker network with no route to the outside, and one EgressProxy
+// per agent listening on that network's gateway address.
+type Egress struct {
+	Network string            // internal docker network, empty if no agent needs one
+	Proxies map[string]string // agent name → proxy host:port
+
+	proxies []*EgressProxy
+}
+
+// StartEgress creates the internal network named name and starts a proxy for
+// every agent whose network mode is allowlist. It does nothing when no agent
+// uses an allowlist. On failure, anything already started is torn down.
+func StartEgress(ctx context.Context, cfg *config.Config, name string,
+	logger *slog.Logger) (*Egress, error) {
+	var agents []string
+	for agentName, def := range cfg.Agents {
+		if def.Network.Mode == "allowlist" {
+			agents = append(agents, agentName)
+		}
+	}
+	e := &Egress{Proxies: map[string]string{}}
+	if len(agents) == 0 {
+		return e, nil
+	}
+	sort.Strings(agents)
+
+	gateway, err := createInternalNetwork(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	e.Network = name
+	for _, agentName := range agents {
+		proxy := NewEgressProxy(cfg.Agents[agentName].Network.Allow,
+			logger.With("component", "egress", "agent", agentName))
+		if err := proxy.Start(net.JoinHostPort(gateway, "0")); err != nil {
+			e.Close(ctx)
+			return nil, fmt.Errorf("start egress proxy for agent %q: %w", agentName, err)
+		}
+		e.proxies = append(e.proxies, proxy)
+		e.Proxies[agentName] = proxy.Addr()
+	}
+	return e, nil
+}
+
+// Close stops the proxies and removes the internal network.
+func (e *Egress) Close(ctx context.Context) {
+	if e == nil {
+		return
+	}
+	for _, p := range e.proxies {
+		p.Close()
+	}
+	if e.Network != "" {
+		exec.CommandContext(ctx, "docker", "network", "rm", e.Network).Run()
+	}
+}
+
+// createInternalNetwork creates a docker bridge network without external
+// connectivity and returns its gateway address, which is the host side of
+// the bridge and the only place containers on it can reach.
+func createInternalNetwork(ctx context.Context, name string) (string, error) {
+	if out, err := exec.CommandContext(ctx, "docker", "network", "create",
+		"--internal", name).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("docker network create: %w\n%s", err, out)
+	}
+	out, err := exec.CommandContext(ctx, "docker", "network", "inspect", "--format",
+		"{{range .IPAM.Config}}{{.Gateway}} {{end}}", name).Output()
+	if err != nil {
+		exec.CommandContext(ctx, "docker", "network", "rm", name).Run()
+		return "", fmt.Errorf("docker network inspect: %w", err)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		exec.CommandContext(ctx, "docker", "network", "rm", name).Run()
+		return "", fmt.Errorf("docker network %s has no gateway", name)
+	}
+	return fields[0], nil
+}
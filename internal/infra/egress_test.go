@@ -0,0 +1,60 @@
+package infra
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// TestStartEgress verifies that an internal network is created and one
+// proxy started per allowlisted agent, and that Close removes the network.
+func TestStartEgress(t *testing.T) {
+	log := fakeDocker(t)
+	fakeBin(t, "docker", `echo "$*" >> "$FAKE_DOCKER_LOG"
+[ "$2" = inspect ] && echo "127.0.0.1"
+true`)
+	cfg := &config.Config{Agents: map[string]config.AgentDef{
+		"implementer": {Network: config.NetworkDef{Mode: "allowlist", Allow: []string{"github.com"}}},
+		"reviewer":    {Network: config.NetworkDef{Mode: "none"}},
+		"planner":     {},
+	}}
+
+	e, err := StartEgress(context.Background(), cfg, "conductor-test-net", discard)
+	if err != nil {
+		t.Fatalf("StartEgress: %v", err)
+	}
+	if e.Network != "conductor-test-net" {
+		t.Errorf("Network = %q", e.Network)
+	}
+	if len(e.Proxies) != 1 || e.Proxies["implementer"] == "" {
+		t.Fatalf("Proxies = %v, want one for implementer", e.Proxies)
+	}
+	conn, err := net.Dial("tcp", e.Proxies["implementer"])
+	if err != nil {
+		t.Fatalf("proxy not listening: %v", err)
+	}
+	conn.Close()
+
+	e.Close(context.Background())
+	lines := dockerLog(t, log)
+	if lines[0] != "network create --internal conductor-test-net" {
+		t.Errorf("first docker call = %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[len(lines)-1], "network rm conductor-test-net") {
+		t.Errorf("last docker call = %q, want network rm", lines[len(lines)-1])
+	}
+}
+
+// TestStartEgressUnused verifies that no network is created when no agent
+// has an allowlist.
+func TestStartEgressUnused(t *testing.T) {
+	fakeBin(t, "docker", "exit 1")
+	cfg := &config.Config{Agents: map[string]config.AgentDef{"worker": {}}}
+	e, err := StartEgress(context.Background(), cfg, "unused", discard)
+	if err != nil || e.Network != "" {
+		t.Fatalf("StartEgress = %+v, %v; want empty egress", e, err)
+	}
+}
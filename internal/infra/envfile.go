@@ -0,0 +1,58 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// envFileDir is where env files are created: RAM-backed, so secrets never
+// touch disk.
+var envFileDir = "/dev/shm"
+
+// EnvFile is a docker --env-file holding the resolved secrets of a run.
+type EnvFile struct{ Path string }
+
+// WriteEnvFile resolves every secret from store and writes them as ENV=value
+// lines to a new 0600 file in envFileDir.
+func WriteEnvFile(ctx context.Context, store CredentialStore,
+	secrets map[string]config.SecretRef) (*EnvFile, error) {
+	f, err := os.CreateTemp(envFileDir, ".conductor-env-")
+	if err != nil {
+		return nil, fmt.Errorf("create env file: %w", err)
+	}
+	defer f.Close()
+	if err := f.Chmod(0600); err != nil {
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("chmod env file: %w", err)
+	}
+
+	keys := make([]string, 0, len(secrets))
+	for k := range secrets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		ref := secrets[k]
+		secret, err := store.Get(ctx, ref.Name)
+		if err != nil {
+			os.Remove(f.Name())
+			return nil, fmt.Errorf("secret %q: %w", k, err)
+		}
+		if _, err := fmt.Fprintf(f, "%s=%s\n", ref.Env, secret); err != nil {
+			os.Remove(f.Name())
+			return nil, fmt.Errorf("write env file: %w", err)
+		}
+	}
+	return &EnvFile{Path: f.Name()}, nil
+}
+
+// Remove deletes the env file.
+func (e *EnvFile) Remove() {
+	if e != nil {
+		os.Remove(e.Path)
+	}
+}
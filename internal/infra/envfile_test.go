@@ -0,0 +1,60 @@
+package infra
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// TestNFR1_WriteEnvFile verifies that secrets are written as ENV=value lines
+// to a 0600 file, and that Remove deletes it.
+func TestNFR1_WriteEnvFile(t *testing.T) {
+	envFileDir = t.TempDir()
+	t.Cleanup(func() { envFileDir = "/dev/shm" })
+	t.Setenv("CONDUCTOR_TEST_A", "alpha")
+	t.Setenv("CONDUCTOR_TEST_B", "beta")
+
+	ef, err := WriteEnvFile(context.Background(), &envStore{}, map[string]config.SecretRef{
+		"b": {Name: "CONDUCTOR_TEST_B", Env: "B_TOKEN"},
+		"a": {Name: "CONDUCTOR_TEST_A", Env: "A_TOKEN"},
+	})
+	if err != nil {
+		t.Fatalf("WriteEnvFile: %v", err)
+	}
+	info, err := os.Stat(ef.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+	data, _ := os.ReadFile(ef.Path)
+	if string(data) != "A_TOKEN=alpha\nB_TOKEN=beta\n" {
+		t.Errorf("content = %q", data)
+	}
+
+	ef.Remove()
+	if _, err := os.Stat(ef.Path); !os.IsNotExist(err) {
+		t.Errorf("env file still exists after Remove: %v", err)
+	}
+}
+
+// TestNFR1_WriteEnvFileMissingSecret verifies that a missing secret fails
+// without leaving a file behind.
+func TestNFR1_WriteEnvFileMissingSecret(t *testing.T) {
+	envFileDir = t.TempDir()
+	t.Cleanup(func() { envFileDir = "/dev/shm" })
+
+	_, err := WriteEnvFile(context.Background(), &envStore{}, map[string]config.SecretRef{
+		"x": {Name: "CONDUCTOR_TEST_UNSET", Env: "X"},
+	})
+	if err == nil {
+		t.Fatal("WriteEnvFile returned nil error for missing secret")
+	}
+	entries, _ := os.ReadDir(envFileDir)
+	if len(entries) != 0 {
+		t.Errorf("env dir has %d entries, want none", len(entries))
+	}
+}
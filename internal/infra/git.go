@@ -0,0 +1,158 @@
+package infra
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// CloneResult is a repository checkout in its own temporary directory.
+type CloneResult struct {
+	Dir      string // tmpdir root
+	RepoPath string // Dir + "/repo"
+}
+
+// Remove deletes the clone and its temporary directory.
+func (c *CloneResult) Remove() {
+	if c != nil {
+		os.RemoveAll(c.Dir)
+	}
+}
+
+// Clone clones repoURL into a fresh temporary directory. When patSecretName
+// is non-empty, the PAT is fetched from store and injected into the HTTPS
+// clone URL, then stripped from the remote once the clone completes.
+func Clone(ctx context.Context, repoURL string,
+	store CredentialStore, patSecretName string) (*CloneResult, error) {
+	httpsURL := toHTTPS(repoURL)
+
+	cloneURL := httpsURL
+	if patSecretName != "" {
+		token, err := store.Get(ctx, patSecretName)
+		if err != nil {
+			return nil, fmt.Errorf("clone: get PAT: %w", err)
+		}
+		cloneURL = injectToken(httpsURL, token)
+	}
+
+	dir, err := os.MkdirTemp("", "conductor-")
+	if err != nil {
+		return nil, fmt.Errorf("clone: %w", err)
+	}
+	repoPath := filepath.Join(dir, "repo")
+
+	if out, err := exec.CommandContext(ctx, "git", "clone", "--quiet",
+		cloneURL, repoPath).CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("git clone %s: %w\n%s", httpsURL, err, out)
+	}
+
+	// Strip token from remote
+	if out, err := exec.CommandContext(ctx, "git", "-C", repoPath,
+		"remote", "set-url", "origin", httpsURL).CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("git remote set-url: %w\n%s", err, out)
+	}
+
+	return &CloneResult{Dir: dir, RepoPath: repoPath}, nil
+}
+
+// toHTTPS rewrites GitHub SSH URLs to their HTTPS form.
+func toHTTPS(url string) string {
+	return config.Repository{URL: url}.HTTPSURL()
+}
+
+// injectToken embeds token as the userinfo of an HTTPS URL. Other URLs
+// (local paths, file://) are returned unchanged.
+func injectToken(url, token string) string {
+	if !strings.HasPrefix(url, "https://") {
+		return url
+	}
+	return strings.Replace(url, "https://", "https://"+token+"@", 1)
+}
+
+// Workspace holds one clone per project repository.
+type Workspace struct {
+	Repos map[string]*CloneResult // keyed by repository name
+}
+
+// CloneRepos clones every repository of the project. Each repository is
+// authenticated with the secret named by its token (see
+// config.Repository.TokenSecret); repositories whose token is not among the
+// configured secrets are cloned anonymously. On failure, clones made so far
+// are removed.
+func CloneRepos(ctx context.Context, cfg *config.Config,
+	store CredentialStore) (*Workspace, error) {
+	repos := cfg.Project.Repos()
+	names := make([]string, 0, len(repos))
+	for name := range repos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ws := &Workspace{Repos: make(map[string]*CloneResult, len(repos))}
+	for _, name := range names {
+		repo := repos[name]
+		var pat string
+		if ref, ok := cfg.Credentials.Secrets[repo.TokenSecret()]; ok {
+			pat = ref.Name
+		}
+		clone, err := Clone(ctx, repo.URL, store, pat)
+		if err != nil {
+			ws.Cleanup()
+			return nil, fmt.Errorf("repository %q: %w", name, err)
+		}
+		ws.Repos[name] = clone
+	}
+	return ws, nil
+}
+
+// Paths returns the host checkout path of each repository, keyed by name.
+func (w *Workspace) Paths() map[string]string {
+	paths := make(map[string]string, len(w.Repos))
+	for name, c := range w.Repos {
+		paths[name] = c.RepoPath
+	}
+	return paths
+}
+
+// Cleanup removes every clone in the workspace.
+func (w *Workspace) Cleanup() {
+	if w == nil {
+		return
+	}
+	for _, c := range w.Repos {
+		c.Remove()
+	}
+}
+
+// RepoState identifies the content of the checkout at dir: its HEAD commit,
+// followed by a hash of the uncommitted changes when the tree is dirty.
+func RepoState(ctx context.Context, dir string) (string, error) {
+	head, err := exec.CommandContext(ctx, "git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse %s: %w", dir, err)
+	}
+	status, err := exec.CommandContext(ctx, "git", "-C", dir, "status", "--porcelain").Output()
+	if err != nil {
+		return "", fmt.Errorf("git status %s: %w", dir, err)
+	}
+	state := strings.TrimSpace(string(head))
+	if len(status) == 0 {
+		return state, nil
+	}
+	diff, err := exec.CommandContext(ctx, "git", "-C", dir, "diff", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("git diff %s: %w", dir, err)
+	}
+	sum := sha256.Sum256(append(status, diff...))
+	return state + "+" + hex.EncodeToString(sum[:8]), nil
+}
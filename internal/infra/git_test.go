@@ -0,0 +1,178 @@
+package infra
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// initRepo creates a local git repository with a single commit and returns
+// its path.
+func initRepo(t *testing.T, file string) string {
+	t.Helper()
+	dir := t.TempDir()
+	gitRun(t, dir, "init", "--quiet")
+	if err := os.WriteFile(filepath.Join(dir, file), []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, dir, "add", file)
+	gitRun(t, dir, "-c", "user.name=test", "-c", "user.email=test@example.com",
+		"commit", "--quiet", "-m", "init")
+	return dir
+}
+
+func gitRun(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// TestFR6_ToHTTPS verifies SSH-to-HTTPS URL conversion.
+func TestFR6_ToHTTPS(t *testing.T) {
+	tests := map[string]string{
+		"git@github.com:owner/repo.git":     "https://github.com/owner/repo.git",
+		"https://github.com/owner/repo.git": "https://github.com/owner/repo.git",
+		"/srv/git/repo":                     "/srv/git/repo",
+	}
+	for in, want := range tests {
+		if got := toHTTPS(in); got != want {
+			t.Errorf("toHTTPS(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// TestFR6_InjectToken verifies that tokens are only embedded in HTTPS URLs.
+func TestFR6_InjectToken(t *testing.T) {
+	if got := injectToken("https://github.com/o/r.git", "tok"); got != "https://tok@github.com/o/r.git" {
+		t.Errorf("injectToken(https) = %q", got)
+	}
+	if got := injectToken("/srv/git/repo", "tok"); got != "/srv/git/repo" {
+		t.Errorf("injectToken(local) = %q, want unchanged", got)
+	}
+}
+
+// TestFR7_CloneLeavesCleanRemote verifies that Clone checks out the repo
+// and leaves the plain URL as origin.
+func TestFR7_CloneLeavesCleanRemote(t *testing.T) {
+	src := initRepo(t, "README.md")
+	t.Setenv("CONDUCTOR_TEST_PAT", "tok")
+
+	res, err := Clone(context.Background(), src, &envStore{}, "CONDUCTOR_TEST_PAT")
+	if err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	defer res.Remove()
+
+	if _, err := os.Stat(filepath.Join(res.RepoPath, "README.md")); err != nil {
+		t.Errorf("cloned file missing: %v", err)
+	}
+	if got := gitRun(t, res.RepoPath, "remote", "get-url", "origin"); got != src {
+		t.Errorf("origin = %q, want %q", got, src)
+	}
+}
+
+// TestNFR2_CloneFailureCleansUp verifies that a failed clone reports the
+// error and leaves no temporary directory behind.
+func TestNFR2_CloneFailureCleansUp(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	_, err := Clone(context.Background(), filepath.Join(tmp, "missing"), &envStore{}, "")
+	if err == nil {
+		t.Fatal("Clone of missing repo returned nil error")
+	}
+	entries, _ := os.ReadDir(tmp)
+	if len(entries) != 0 {
+		t.Errorf("temp dir not cleaned up: %v", entries)
+	}
+}
+
+// TestCloneRepos verifies that every named repository is cloned, that each
+// uses its own token secret, and that Cleanup removes all clones.
+func TestCloneRepos(t *testing.T) {
+	api := initRepo(t, "api.go")
+	sdk := initRepo(t, "sdk.go")
+	t.Setenv("SDK_PAT", "tok")
+
+	cfg := &config.Config{
+		Project: config.Project{Repositories: map[string]config.Repository{
+			"api": {URL: api},
+			"sdk": {URL: sdk, Token: "sdk_pat"},
+		}},
+		Credentials: config.Credentials{Secrets: map[string]config.SecretRef{
+			"sdk_pat": {Name: "SDK_PAT", Env: "AGENT_SDK_TOKEN"},
+		}},
+	}
+	ws, err := CloneRepos(context.Background(), cfg, &envStore{})
+	if err != nil {
+		t.Fatalf("CloneRepos: %v", err)
+	}
+
+	paths := ws.Paths()
+	for name, file := range map[string]string{"api": "api.go", "sdk": "sdk.go"} {
+		if _, err := os.Stat(filepath.Join(paths[name], file)); err != nil {
+			t.Errorf("repository %q: %v", name, err)
+		}
+	}
+
+	ws.Cleanup()
+	for name, p := range paths {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("repository %q not removed after Cleanup", name)
+		}
+	}
+}
+
+// TestCloneReposMissingToken verifies that a repository whose token secret
+// cannot be read fails the whole workspace and names the repository.
+func TestCloneReposMissingToken(t *testing.T) {
+	cfg := &config.Config{
+		Project: config.Project{Repository: initRepo(t, "main.go")},
+		Credentials: config.Credentials{Secrets: map[string]config.SecretRef{
+			config.DefaultTokenSecret: {Name: "CONDUCTOR_TEST_UNSET", Env: "AGENT_GH_TOKEN"},
+		}},
+	}
+	_, err := CloneRepos(context.Background(), cfg, &envStore{})
+	if err == nil || !strings.Contains(err.Error(), `repository "default"`) {
+		t.Fatalf("CloneRepos error = %v, want error naming the default repository", err)
+	}
+}
+
+// TestRepoState verifies that the state follows commits and uncommitted
+// changes, and is stable otherwise.
+func TestRepoState(t *testing.T) {
+	ctx := context.Background()
+	dir := initRepo(t, "main.go")
+	clean, err := RepoState(ctx, dir)
+	if err != nil {
+		t.Fatalf("RepoState: %v", err)
+	}
+	if clean != gitRun(t, dir, "rev-parse", "HEAD") {
+		t.Errorf("clean state = %q, want the HEAD commit", clean)
+	}
+
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("changed\n"), 0644)
+	dirty, _ := RepoState(ctx, dir)
+	if !strings.HasPrefix(dirty, clean+"+") {
+		t.Errorf("dirty state = %q, want %q plus a change hash", dirty, clean)
+	}
+	if again, _ := RepoState(ctx, dir); again != dirty {
+		t.Errorf("state changed without edits: %q vs %q", again, dirty)
+	}
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("changed again\n"), 0644)
+	if other, _ := RepoState(ctx, dir); other == dirty {
+		t.Error("state unchanged after a further edit")
+	}
+
+	if _, err := RepoState(ctx, t.TempDir()); err == nil {
+		t.Error("RepoState of a non-repository returned nil error")
+	}
+}
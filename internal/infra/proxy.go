@@ -0,0 +1,213 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dialTimeout bounds the proxy's connection attempts to upstream hosts.
+const dialTimeout = 10 * time.Second
+
+// EgressProxy is an HTTP proxy that only lets traffic through to allowlisted
+// hosts. It tunnels CONNECT requests (HTTPS) and forwards plain HTTP
+// requests, logging every allowed and denied destination.
+type EgressProxy struct {
+	allow     []string
+	logger    *slog.Logger
+	transport *http.Transport
+	ln        net.Listener
+	srv       *http.Server
+
+	mu      sync.Mutex
+	tunnels map[net.Conn]struct{}
+}
+
+// NewEgressProxy returns a proxy admitting the given hosts. An entry
+// "*.example.com" admits every subdomain of example.com but not example.com
+// itself.
+func NewEgressProxy(allow []string, logger *slog.Logger) *EgressProxy {
+	return &EgressProxy{
+		allow:     allow,
+		logger:    logger,
+		transport: &http.Transport{Proxy: nil, DialContext: (&net.Dialer{Timeout: dialTimeout}).DialContext},
+		tunnels:   map[net.Conn]struct{}{},
+	}
+}
+
+// Start listens on addr (e.g. "172.18.0.1:0") and serves in the background.
+func (p *EgressProxy) Start(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	p.ln = ln
+	p.srv = &http.Server{Handler: p, ReadHeaderTimeout: dialTimeout}
+	go p.srv.Serve(ln)
+	return nil
+}
+
+// Addr returns the proxy's listen address as host:port.
+func (p *EgressProxy) Addr() string {
+	return p.ln.Addr().String()
+}
+
+// Close stops the proxy and tears down any open tunnels.
+func (p *EgressProxy) Close() error {
+	if p.srv == nil {
+		return nil
+	}
+	err := p.srv.Close()
+	p.mu.Lock()
+	for c := range p.tunnels {
+		c.Close()
+	}
+	p.mu.Unlock()
+	p.transport.CloseIdleConnections()
+	return err
+}
+
+// ServeHTTP enforces the allowlist and proxies the request.
+func (p *EgressProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var host string
+	if r.Method == http.MethodConnect {
+		host = hostOnly(r.Host)
+	} else {
+		host = r.URL.Hostname()
+	}
+	if host == "" {
+		http.Error(w, "proxy requires an absolute URL", http.StatusBadRequest)
+		return
+	}
+	if !p.allowed(host) {
+		p.logger.Warn("egress denied", "host", host, "method", r.Method)
+		http.Error(w, "egress to "+host+" denied by conductor network policy", http.StatusForbidden)
+		return
+	}
+	p.logger.Info("egress allowed", "host", host, "method", r.Method)
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+	p.forward(w, r)
+}
+
+// allowed reports whether host matches an allowlist entry.
+func (p *EgressProxy) allowed(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, entry := range p.allow {
+		entry = strings.ToLower(entry)
+		if suffix, ok := strings.CutPrefix(entry, "*"); ok {
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return true
+			}
+			continue
+		}
+		if host == entry {
+			return true
+		}
+	}
+	return false
+}
+
+// tunnel connects to the CONNECT target and splices the client connection
+// to it until either side closes.
+func (p *EgressProxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	dst, err := net.DialTimeout("tcp", r.Host, dialTimeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		dst.Close()
+		http.Error(w, "tunneling not supported", http.StatusInternalServerError)
+		return
+	}
+	src, buf, err := hj.Hijack()
+	if err != nil {
+		dst.Close()
+		return
+	}
+	p.track(src, dst)
+	defer p.untrack(src, dst)
+
+	src.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(dst, buf)
+		closeWrite(dst)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(src, dst)
+		closeWrite(src)
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+}
+
+// forward relays a plain HTTP request to its destination.
+func (p *EgressProxy) forward(w http.ResponseWriter, r *http.Request) {
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	out.Header.Del("Proxy-Connection")
+	out.Header.Del("Proxy-Authorization")
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, context.Canceled) {
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	defer resp.Body.Close()
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+func (p *EgressProxy) track(conns ...net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range conns {
+		p.tunnels[c] = struct{}{}
+	}
+}
+
+func (p *EgressProxy) untrack(conns ...net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range conns {
+		c.Close()
+		delete(p.tunnels, c)
+	}
+}
+
+// closeWrite half-closes c when supported so the peer sees EOF.
+func closeWrite(c net.Conn) {
+	if cw, ok := c.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+	}
+}
+
+// hostOnly strips the port from a host:port authority.
+func hostOnly(hostport string) string {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return hostport
+	}
+	return host
+}
@@ -0,0 +1,133 @@
+package infra
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// startProxy starts an EgressProxy on loopback and returns it with a client
+// that routes every request through it, plus the proxy's log output.
+func startProxy(t *testing.T, allow ...string) (*http.Client, *bytes.Buffer) {
+	t.Helper()
+	var logs bytes.Buffer
+	p := NewEgressProxy(allow, slog.New(slog.NewTextHandler(&logs, nil)))
+	if err := p.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { p.Close() })
+	proxyURL, _ := url.Parse("http://" + p.Addr())
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(proxyURL),
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+	t.Cleanup(client.CloseIdleConnections)
+	return client, &logs
+}
+
+// localURL rewrites a test server URL to use the hostname localhost, so the
+// proxy sees a name rather than an IP literal.
+func localURL(srv *httptest.Server) string {
+	return strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+}
+
+func upstream(t *testing.T, tlsServer bool) *httptest.Server {
+	t.Helper()
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello from upstream")
+	})
+	var srv *httptest.Server
+	if tlsServer {
+		srv = httptest.NewTLSServer(h)
+	} else {
+		srv = httptest.NewServer(h)
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestEgressProxyAllowsHTTP verifies that plain HTTP to an allowlisted host
+// is forwarded and logged.
+func TestEgressProxyAllowsHTTP(t *testing.T) {
+	srv := upstream(t, false)
+	client, logs := startProxy(t, "localhost")
+
+	resp, err := client.Get(localURL(srv))
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "hello from upstream" {
+		t.Fatalf("response = %d %q", resp.StatusCode, body)
+	}
+	if !strings.Contains(logs.String(), "egress allowed") {
+		t.Errorf("logs = %q, want allowed entry", logs.String())
+	}
+}
+
+// TestEgressProxyAllowsConnect verifies that HTTPS to an allowlisted host is
+// tunneled end to end.
+func TestEgressProxyAllowsConnect(t *testing.T) {
+	srv := upstream(t, true)
+	client, _ := startProxy(t, "localhost")
+
+	resp, err := client.Get(localURL(srv))
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hello from upstream" {
+		t.Fatalf("body = %q", body)
+	}
+}
+
+// TestEgressProxyDenies verifies that hosts outside the allowlist are
+// refused for both HTTP and CONNECT, and that denials are logged.
+func TestEgressProxyDenies(t *testing.T) {
+	plain := upstream(t, false)
+	secure := upstream(t, true)
+	client, logs := startProxy(t, "api.anthropic.com")
+
+	resp, err := client.Get(localURL(plain))
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("HTTP status = %d, want 403", resp.StatusCode)
+	}
+
+	if _, err := client.Get(localURL(secure)); err == nil {
+		t.Error("CONNECT to denied host succeeded")
+	}
+	if got := strings.Count(logs.String(), "egress denied"); got != 2 {
+		t.Errorf("logged %d denials, want 2: %s", got, logs.String())
+	}
+}
+
+// TestEgressProxyAllowedMatching verifies exact and wildcard matching.
+func TestEgressProxyAllowedMatching(t *testing.T) {
+	p := NewEgressProxy([]string{"github.com", "*.githubusercontent.com"}, discard)
+	tests := map[string]bool{
+		"github.com":                         true,
+		"GitHub.com.":                        true,
+		"api.github.com":                     false,
+		"raw.githubusercontent.com":          true,
+		"githubusercontent.com":              false,
+		"evilgithubusercontent.com":          false,
+		"objects.githubusercontent.com.evil": false,
+	}
+	for host, want := range tests {
+		if got := p.allowed(host); got != want {
+			t.Errorf("allowed(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
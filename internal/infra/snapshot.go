@@ -0,0 +1,114 @@
+package infra
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/dmitriyb/conductor/internal/state"
+)
+
+// Snapshot records the working tree of the checkout at dir, uncommitted and
+// untracked changes included, as a commit whose parent is HEAD. The index,
+// HEAD and refs are left untouched, so the step that owns the checkout does
+// not notice. It returns the snapshot commit.
+func Snapshot(ctx context.Context, dir string) (string, error) {
+	index, err := os.CreateTemp("", "conductor-index-")
+	if err != nil {
+		return "", fmt.Errorf("snapshot: %w", err)
+	}
+	index.Close()
+	defer os.Remove(index.Name())
+
+	env := append(os.Environ(), "GIT_INDEX_FILE="+index.Name(),
+		"GIT_AUTHOR_NAME=conductor", "GIT_AUTHOR_EMAIL=conductor@localhost",
+		"GIT_COMMITTER_NAME=conductor", "GIT_COMMITTER_EMAIL=conductor@localhost")
+	git := func(args ...string) (string, error) {
+		cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = env
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("snapshot %s: git %s: %w", dir, args[0], err)
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	if _, err := git("read-tree", "HEAD"); err != nil {
+		return "", err
+	}
+	if _, err := git("add", "--all"); err != nil {
+		return "", err
+	}
+	tree, err := git("write-tree")
+	if err != nil {
+		return "", err
+	}
+	return git("commit-tree", tree, "-p", "HEAD", "-m", "conductor snapshot")
+}
+
+// Commits returns the commits reachable from head but not from base, oldest
+// first.
+func Commits(ctx context.Context, dir, base, head string) ([]state.Commit, error) {
+	out, err := exec.CommandContext(ctx, "git", "-C", dir, "log", "--reverse",
+		"--format=%H %s", base+".."+head).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log %s: %w", dir, err)
+	}
+	var commits []state.Commit
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line == "" {
+			continue
+		}
+		sha, subject, _ := strings.Cut(line, " ")
+		commits = append(commits, state.Commit{SHA: sha, Subject: subject})
+	}
+	return commits, nil
+}
+
+// Diff returns the binary patch that turns commit from into commit to.
+func Diff(ctx context.Context, dir, from, to string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, "git", "-C", dir, "diff", "--binary", from, to).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff %s: %w", dir, err)
+	}
+	return out, nil
+}
+
+// Apply applies patch, as made by Diff, to the working tree of the checkout
+// at dir, or with check only tests that it would apply cleanly. An empty
+// patch applies trivially.
+func Apply(ctx context.Context, dir string, patch []byte, check bool) error {
+	if len(patch) == 0 {
+		return nil
+	}
+	args := []string{"-C", dir, "apply", "--binary"}
+	if check {
+		args = append(args, "--check")
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Stdin = bytes.NewReader(patch)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git apply %s: %w\n%s", dir, err, out)
+	}
+	return nil
+}
+
+// CheckoutCopy copies the checkout at src to dst and checks out rev there,
+// detached and without untracked files, leaving src untouched.
+func CheckoutCopy(ctx context.Context, src, dst, rev string) error {
+	if err := os.CopyFS(dst, os.DirFS(src)); err != nil {
+		return fmt.Errorf("copy %s: %w", src, err)
+	}
+	for _, args := range [][]string{
+		{"checkout", "--quiet", "--force", "--detach", rev},
+		{"clean", "--quiet", "-ffdx"},
+	} {
+		if out, err := exec.CommandContext(ctx, "git", append([]string{"-C", dst}, args...)...).CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s %s: %w\n%s", args[0], filepath.Base(dst), err, out)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package infra
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSnapshotChanges verifies that snapshots capture committed, modified
+// and untracked files without touching the checkout, that the range
+// between two snapshots yields its commits and patch, and that the patch
+// applies to another checkout at the base.
+func TestSnapshotChanges(t *testing.T) {
+	ctx := context.Background()
+	dir := initRepo(t, "main.go")
+	base, err := Snapshot(ctx, dir)
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "lib.go"), []byte("package lib\n"), 0644)
+	gitRun(t, dir, "add", "lib.go")
+	gitRun(t, dir, "-c", "user.name=test", "-c", "user.email=test@example.com",
+		"commit", "--quiet", "-m", "add lib")
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("changed\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("untracked\n"), 0644)
+	status := gitRun(t, dir, "status", "--porcelain")
+
+	after, err := Snapshot(ctx, dir)
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if got := gitRun(t, dir, "status", "--porcelain"); got != status {
+		t.Errorf("status after snapshot = %q, want %q", got, status)
+	}
+
+	commits, err := Commits(ctx, dir, base+"^", after+"^")
+	if err != nil {
+		t.Fatalf("Commits: %v", err)
+	}
+	if len(commits) != 1 || commits[0].Subject != "add lib" {
+		t.Errorf("commits = %+v, want the lib commit", commits)
+	}
+	patch, err := Diff(ctx, dir, base, after)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	for _, want := range []string{"+package lib", "-hello", "+changed", "+untracked"} {
+		if !strings.Contains(string(patch), want) {
+			t.Errorf("patch missing %q:\n%s", want, patch)
+		}
+	}
+
+	copyDir := filepath.Join(t.TempDir(), "repo")
+	if err := CheckoutCopy(ctx, dir, copyDir, after); err != nil {
+		t.Fatalf("CheckoutCopy: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(copyDir, "notes.txt")); string(got) != "untracked\n" {
+		t.Errorf("copy notes.txt = %q", got)
+	}
+	if got := gitRun(t, copyDir, "status", "--porcelain"); got != "" {
+		t.Errorf("copy is not clean: %q", got)
+	}
+	if got := gitRun(t, dir, "status", "--porcelain"); got != status {
+		t.Errorf("source status after copy = %q, want %q", got, status)
+	}
+
+	replay := filepath.Join(t.TempDir(), "replay")
+	if err := CheckoutCopy(ctx, dir, replay, base); err != nil {
+		t.Fatalf("CheckoutCopy: %v", err)
+	}
+	if err := Apply(ctx, replay, patch, true); err != nil {
+		t.Fatalf("Apply check: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(replay, "lib.go")); !os.IsNotExist(err) {
+		t.Errorf("checking the patch changed the checkout: %v", err)
+	}
+	if err := Apply(ctx, replay, patch, false); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	for file, want := range map[string]string{"lib.go": "package lib\n", "main.go": "changed\n", "notes.txt": "untracked\n"} {
+		if got, _ := os.ReadFile(filepath.Join(replay, file)); string(got) != want {
+			t.Errorf("replayed %s = %q, want %q", file, got, want)
+		}
+	}
+	if err := Apply(ctx, replay, patch, true); err == nil {
+		t.Error("patch applies twice")
+	}
+}
@@ -0,0 +1,118 @@
+package pipeline
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dmitriyb/conductor/internal/agent"
+)
+
+// ReadBatch reads the run inputs of a batch file, one run per line. A .jsonl
+// file holds a JSON object of inputs per line; any other file holds one
+// issue number per line. Blank lines and lines starting with # are skipped.
+func ReadBatch(path string) ([]map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open batch: %w", err)
+	}
+	defer f.Close()
+
+	jsonl := strings.HasSuffix(path, ".jsonl")
+	var batch []map[string]string
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !jsonl {
+			batch = append(batch, map[string]string{"issue": line})
+			continue
+		}
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		inputs := make(map[string]string, len(obj))
+		for k, v := range obj {
+			inputs[k] = formatValue(v)
+		}
+		batch = append(batch, inputs)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read batch: %w", err)
+	}
+	if len(batch) == 0 {
+		return nil, fmt.Errorf("batch %s has no entries", path)
+	}
+	return batch, nil
+}
+
+// BatchRun is the outcome of one pipeline run of a batch.
+type BatchRun struct {
+	Inputs map[string]string
+	RunID  string
+	Result *PipelineResult // nil if the run failed to start
+	Error  string          // why the run failed to start
+}
+
+// BatchResult aggregates the runs of a batch.
+type BatchResult struct {
+	Runs     []BatchRun // in batch order
+	Duration time.Duration
+}
+
+// Status returns "success" if every run succeeded, else "failure".
+func (b *BatchResult) Status() string {
+	for _, r := range b.Runs {
+		if r.Result == nil || r.Result.Status != "success" {
+			return "failure"
+		}
+	}
+	return "success"
+}
+
+// Print writes a summary line per run and the batch totals to w.
+func (b *BatchResult) Print(w io.Writer) {
+	fmt.Fprintln(w, "\n============================================")
+	fmt.Fprintln(w, "  Batch Summary")
+	fmt.Fprintln(w, "============================================")
+	var usage agent.Usage
+	succeeded := 0
+	for _, r := range b.Runs {
+		if r.Result == nil {
+			fmt.Fprintf(w, "  %-20s %-8s  %s  %s\n", InputLabel(r.Inputs), "error", r.RunID, r.Error)
+			continue
+		}
+		usage.Add(r.Result.Usage)
+		if r.Result.Status == "success" {
+			succeeded++
+		}
+		fmt.Fprintf(w, "  %-20s %-8s  %s  %s\n", InputLabel(r.Inputs), r.Result.Status, r.RunID,
+			formatUsage(r.Result.Usage))
+	}
+	fmt.Fprintf(w, "\n  Runs:     %d succeeded, %d failed\n", succeeded, len(b.Runs)-succeeded)
+	fmt.Fprintf(w, "  Duration: %s\n", b.Duration.Round(time.Second))
+	fmt.Fprintf(w, "  Usage:    %s\n", formatUsage(usage))
+	fmt.Fprintln(w, "============================================")
+}
+
+// InputLabel names a run by its inputs: the issue number when given,
+// otherwise the sorted key=value pairs.
+func InputLabel(inputs map[string]string) string {
+	if issue, ok := inputs["issue"]; ok {
+		return "#" + issue
+	}
+	var pairs []string
+	for _, k := range slices.Sorted(maps.Keys(inputs)) {
+		pairs = append(pairs, k+"="+inputs[k])
+	}
+	return strings.Join(pairs, " ")
+}
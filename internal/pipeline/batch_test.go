@@ -0,0 +1,84 @@
+package pipeline
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dmitriyb/conductor/internal/agent"
+)
+
+// writeBatch writes content to a batch file with the given name.
+func writeBatch(t *testing.T, name, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// TestReadBatch verifies both batch formats and their error cases.
+func TestReadBatch(t *testing.T) {
+	batch, err := ReadBatch(writeBatch(t, "issues.txt", "# backlog\n123\n\n 124 \n"))
+	if err != nil {
+		t.Fatalf("ReadBatch(txt): %v", err)
+	}
+	if len(batch) != 2 || batch[0]["issue"] != "123" || batch[1]["issue"] != "124" {
+		t.Errorf("txt batch = %v", batch)
+	}
+
+	batch, err = ReadBatch(writeBatch(t, "issues.jsonl", `{"issue": 7, "label": "bug"}`+"\n"+`{"pr": "9"}`+"\n"))
+	if err != nil {
+		t.Fatalf("ReadBatch(jsonl): %v", err)
+	}
+	if len(batch) != 2 || batch[0]["issue"] != "7" || batch[0]["label"] != "bug" || batch[1]["pr"] != "9" {
+		t.Errorf("jsonl batch = %v", batch)
+	}
+
+	for name, content := range map[string]string{
+		"bad.jsonl": "{\"issue\": 1}\nnot json\n",
+		"empty.txt": "# nothing yet\n",
+	} {
+		if _, err := ReadBatch(writeBatch(t, name, content)); err == nil {
+			t.Errorf("ReadBatch(%s) returned nil error", name)
+		}
+	}
+	if _, err := ReadBatch(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("ReadBatch of a missing file returned nil error")
+	}
+}
+
+// TestBatchSummary verifies the per-run lines, totals and batch status.
+func TestBatchSummary(t *testing.T) {
+	b := &BatchResult{Runs: []BatchRun{
+		{Inputs: map[string]string{"issue": "123"}, RunID: "run-a",
+			Result: &PipelineResult{Status: "success", Usage: agent.Usage{InputTokens: 100, CostUSD: 0.5, Turns: 2}}},
+		{Inputs: map[string]string{"pr": "9", "label": "bug"}, RunID: "run-b",
+			Result: &PipelineResult{Status: "failure"}},
+		{Inputs: map[string]string{"issue": "125"}, RunID: "run-c", Error: "clone failed"},
+	}}
+	var buf bytes.Buffer
+	b.Print(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		"#123                 success   run-a  100 tokens  $0.5000  2 turns",
+		"label=bug pr=9       failure   run-b",
+		"#125                 error     run-c  clone failed",
+		"Runs:     1 succeeded, 2 failed",
+		"Usage:    100 tokens  $0.5000  2 turns",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("summary missing %q:\n%s", want, out)
+		}
+	}
+	if b.Status() != "failure" {
+		t.Errorf("Status = %q, want failure", b.Status())
+	}
+	b.Runs = b.Runs[:1]
+	if b.Status() != "success" {
+		t.Errorf("Status = %q, want success", b.Status())
+	}
+}
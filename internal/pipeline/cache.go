@@ -0,0 +1,194 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/dmitriyb/conductor/internal/agent"
+	"github.com/dmitriyb/conductor/internal/config"
+	"github.com/dmitriyb/conductor/internal/infra"
+)
+
+// cacheKey returns the key of the step's cached result: a hash of what
+// determines its outcome. For agents that is the rendered prompts, the agent
+// definition and its image; for containers the rendered command, workspace
+// mode and project image; for both the state of the mounted repositories and
+// the outputs and artifacts of the step's dependencies. It returns "" when
+// the step is not to be cached or the key cannot be computed.
+func (e *executor) cacheKey(ctx context.Context, node *Node, data agent.TemplateData, runCfg agent.RunConfig) string {
+	if runCfg.Cache == nil || !node.Step.Cacheable() {
+		return ""
+	}
+	key, err := e.computeKey(ctx, node, data, runCfg)
+	if err != nil {
+		e.logger.Warn("not caching step", "step", e.prefix+node.Name, "error", err)
+		return ""
+	}
+	return key
+}
+
+func (e *executor) computeKey(ctx context.Context, node *Node, data agent.TemplateData,
+	runCfg agent.RunConfig) (string, error) {
+	parts := []any{node.Step.StepKind()}
+	mounts := node.Step.RepoMounts(e.cfg)
+	if node.Step.StepKind() == config.StepContainer {
+		command, err := agent.RenderTemplate("command", node.Step.Command, data)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, command, mounts, runCfg.ProjectImage)
+	} else {
+		def := e.cfg.Agents[node.Step.Agent]
+		def.Budget = config.Budget{} // limits do not change what a session produces
+		var repo string
+		if len(mounts) > 0 {
+			repo = runCfg.Repos[mounts[0].Repo]
+		}
+		system, task, err := agent.RenderPrompts(def, repo, data)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, node.Step.Agent, def, runCfg.Images[node.Step.Agent], system, task)
+	}
+
+	repos := make(map[string]string, len(mounts))
+	for _, m := range mounts {
+		dir, ok := runCfg.Repos[m.Repo]
+		if !ok {
+			return "", fmt.Errorf("repository %q was not cloned", m.Repo)
+		}
+		s, err := infra.RepoState(ctx, dir)
+		if err != nil {
+			return "", err
+		}
+		repos[m.Repo] = s
+	}
+	upstream := make(map[string]map[string]any, len(node.Deps))
+	for _, dep := range node.Deps {
+		upstream[dep.Name] = data.Steps[dep.Name].Output
+	}
+	// Artifacts are keyed by content, as every run collects them anew.
+	artifacts := map[string]string{}
+	for step, names := range data.Artifacts {
+		for name := range names {
+			sum, err := hashPath(data.Steps[step].Artifacts[name])
+			if err != nil {
+				return "", fmt.Errorf("hash artifact %s of %s: %w", name, step, err)
+			}
+			artifacts[step+"/"+name] = sum
+		}
+	}
+	parts = append(parts, repos, upstream, artifacts)
+
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, p := range parts {
+		if err := enc.Encode(p); err != nil {
+			return "", fmt.Errorf("cache key: %w", err)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cacheEntry is a cached step result with the patches the step made to the
+// repositories it mounts read-write, by repository.
+type cacheEntry struct {
+	agent.StepResult
+	Patches map[string][]byte `json:"patches,omitempty"`
+}
+
+// cacheGet returns the cached result of key renamed to name, marked cached
+// and without usage, since reusing it cost nothing. The step's patches are
+// applied to its read-write repositories and recorded as its changes, so
+// the workspace ends up as if the step had run; commits it made come back
+// as uncommitted changes, and pushes are not repeated. Results whose
+// artifacts have since been deleted or whose patches no longer apply are not
+// reused.
+func (e *executor) cacheGet(ctx context.Context, key string, node *Node, name string,
+	runCfg agent.RunConfig) (agent.StepResult, bool) {
+	var c cacheEntry
+	ok, err := e.runCfg.Cache.Get(key, &c)
+	if err != nil {
+		e.logger.Warn("ignoring cache entry", "step", name, "error", err)
+		return c.StepResult, false
+	}
+	if !ok {
+		return c.StepResult, false
+	}
+	for artifact, p := range c.Artifacts {
+		if _, err := os.Stat(p); err != nil {
+			e.logger.Info("cached artifact is gone, rerunning", "step", name, "artifact", artifact)
+			return c.StepResult, false
+		}
+	}
+	for repo, patch := range c.Patches {
+		if err := infra.Apply(ctx, runCfg.Repos[repo], patch, true); err != nil {
+			e.logger.Info("cached changes do not apply, rerunning", "step", name, "repo", repo, "error", err)
+			return c.StepResult, false
+		}
+	}
+	if len(c.Patches) > 0 {
+		bases := e.snapshotRepos(ctx, node, runCfg)
+		for repo, patch := range c.Patches {
+			if err := infra.Apply(ctx, runCfg.Repos[repo], patch, false); err != nil {
+				e.logger.Error("step failed", "step", name, "error", err)
+				return agent.StepResult{Name: name, Agent: c.Agent, Status: "failure",
+					Error: fmt.Sprintf("replay cached changes: %v", err)}, true
+			}
+		}
+		e.recordChanges(ctx, name, bases, runCfg)
+	}
+	e.logger.Info("reusing cached result", "step", name, "key", key[:12])
+	r := c.StepResult
+	r.Name, r.Cached, r.Usage, r.Queued = name, true, agent.Usage{}, 0
+	return r, true
+}
+
+// cachePut stores a successful result under key, with the patches of the
+// step's changes. A step writing to repositories whose changes were not all
+// captured is not cached, as a hit could not replay them.
+func (e *executor) cachePut(key string, node *Node, r agent.StepResult, patches map[string][]byte) {
+	if r.Status != "success" {
+		return
+	}
+	for _, m := range node.Step.RepoMounts(e.cfg) {
+		if _, ok := patches[m.Repo]; m.Mode == "rw" && !ok {
+			e.logger.Warn("not caching result without its changes", "step", r.Name, "repo", m.Repo)
+			return
+		}
+	}
+	if err := e.runCfg.Cache.Put(key, cacheEntry{StepResult: r, Patches: patches}); err != nil {
+		e.logger.Warn("caching result failed", "step", r.Name, "error", err)
+	}
+}
+
+// hashPath returns the sha256 of the file at p, or of the relative paths and
+// contents of the files below the directory p.
+func hashPath(p string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(p, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, _ := filepath.Rel(p, file)
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		fmt.Fprintf(h, "%s\x00", rel)
+		_, err = io.Copy(h, f)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
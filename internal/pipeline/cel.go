@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/dmitriyb/conductor/internal/agent"
+)
+
+// newCELEnv declares the variables conditions may reference.
+func newCELEnv() (*cel.Env, error) {
+	return cel.NewEnv(cel.Variable("steps", cel.MapType(cel.StringType, cel.DynType)))
+}
+
+// compileCondition parses and type-checks a condition or for_each
+// expression.
+func compileCondition(expr string) (*cel.Ast, *cel.Env, error) {
+	env, err := newCELEnv()
+	if err != nil {
+		return nil, nil, fmt.Errorf("cel env: %w", err)
+	}
+	ast, iss := env.Parse(expr)
+	if iss.Err() != nil {
+		return nil, nil, fmt.Errorf("cel parse: %w", iss.Err())
+	}
+	checked, iss := env.Check(ast)
+	if iss.Err() != nil {
+		return nil, nil, fmt.Errorf("cel check: %w", iss.Err())
+	}
+	return checked, env, nil
+}
+
+// EvalCondition evaluates a step condition against the results of completed
+// steps. Each step is visible as steps.<name> with status and output fields.
+// An empty condition is true.
+func EvalCondition(expr string, results map[string]agent.StepResult) (bool, error) {
+	if expr == "" {
+		return true, nil
+	}
+	out, err := evalExpr(expr, results)
+	if err != nil {
+		return false, err
+	}
+	val, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("condition must be bool, got %T", out.Value())
+	}
+	return val, nil
+}
+
+// EvalForEach evaluates a for_each expression against the results of
+// completed steps, as EvalCondition does, and returns the list it yields.
+func EvalForEach(expr string, results map[string]agent.StepResult) ([]any, error) {
+	out, err := evalExpr(expr, results)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := out.(traits.Lister); !ok {
+		return nil, fmt.Errorf("for_each must yield a list, got %s", out.Type().TypeName())
+	}
+	// Converting through structpb yields JSON-shaped values, like step outputs.
+	native, err := out.ConvertToNative(reflect.TypeOf(&structpb.ListValue{}))
+	if err != nil {
+		return nil, fmt.Errorf("for_each: %w", err)
+	}
+	return native.(*structpb.ListValue).AsSlice(), nil
+}
+
+// EvalOutput evaluates a sub-pipeline output expression against the results
+// of its steps and returns a JSON-shaped value.
+func EvalOutput(expr string, results map[string]agent.StepResult) (any, error) {
+	out, err := evalExpr(expr, results)
+	if err != nil {
+		return nil, err
+	}
+	native, err := out.ConvertToNative(reflect.TypeOf(&structpb.Value{}))
+	if err != nil {
+		return nil, fmt.Errorf("output: %w", err)
+	}
+	return native.(*structpb.Value).AsInterface(), nil
+}
+
+// evalExpr compiles and evaluates expr with the steps variable bound to
+// results.
+func evalExpr(expr string, results map[string]agent.StepResult) (ref.Val, error) {
+	checked, env, err := compileCondition(expr)
+	if err != nil {
+		return nil, err
+	}
+	prg, err := env.Program(checked)
+	if err != nil {
+		return nil, fmt.Errorf("cel program: %w", err)
+	}
+
+	stepsMap := make(map[string]any, len(results))
+	for name, r := range results {
+		stepsMap[name] = map[string]any{"status": r.Status, "output": r.Output}
+	}
+	out, _, err := prg.Eval(map[string]any{"steps": stepsMap})
+	if err != nil {
+		return nil, fmt.Errorf("cel eval: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,97 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/dmitriyb/conductor/internal/agent"
+)
+
+// TestFR5_EvalCondition verifies field access, comparisons and boolean logic
+// over step results.
+func TestFR5_EvalCondition(t *testing.T) {
+	results := map[string]agent.StepResult{
+		"review":    {Status: "success", Output: map[string]any{"status": "approved", "comment_count": float64(3)}},
+		"implement": {Status: "failure"},
+	}
+	tests := []struct {
+		expr    string
+		want    bool
+		wantErr bool
+	}{
+		{"", true, false},
+		{"steps.review.output.status == 'approved'", true, false},
+		{"steps.review.output.status == 'changes_requested'", false, false},
+		{"steps.review.output.comment_count > 2.0 && steps.implement.status == 'failure'", true, false},
+		{"steps.review.status", false, true},
+		{"steps.review.output.status ==", false, true},
+		{"nosuchvar == 1", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := EvalCondition(tt.expr, results)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("EvalCondition = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEvalForEach verifies that for_each expressions yield native lists and
+// that other results are errors.
+func TestEvalForEach(t *testing.T) {
+	results := map[string]agent.StepResult{
+		"plan": {Status: "success", Output: map[string]any{
+			"tasks": []any{map[string]any{"title": "a"}, map[string]any{"title": "b"}},
+			"count": float64(2),
+		}},
+	}
+	tests := []struct {
+		expr    string
+		want    int
+		wantErr bool
+	}{
+		{"steps.plan.output.tasks", 2, false},
+		{"steps.plan.output.tasks.filter(t, t.title == 'b')", 1, false},
+		{"['x', 'y', 'z']", 3, false},
+		{"[]", 0, false},
+		{"steps.plan.output.count", 0, true},
+		{"steps.plan.output.missing", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := EvalForEach(tt.expr, results)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Errorf("EvalForEach = %v, want %d items", got, tt.want)
+			}
+		})
+	}
+	items, _ := EvalForEach("steps.plan.output.tasks", results)
+	if m, ok := items[1].(map[string]any); !ok || m["title"] != "b" {
+		t.Errorf("items[1] = %#v, want the plan's second task", items[1])
+	}
+}
+
+// TestEvalOutput verifies that sub-pipeline outputs evaluate to JSON-shaped
+// values.
+func TestEvalOutput(t *testing.T) {
+	results := map[string]agent.StepResult{
+		"test": {Status: "success", Output: map[string]any{"passed": float64(12), "suite": "unit"}},
+	}
+	got, err := EvalOutput("{'total': steps.test.output.passed, 'ok': steps.test.status == 'success'}", results)
+	if err != nil {
+		t.Fatalf("EvalOutput: %v", err)
+	}
+	m, ok := got.(map[string]any)
+	if !ok || m["total"] != float64(12) || m["ok"] != true {
+		t.Errorf("EvalOutput = %#v", got)
+	}
+	if _, err := EvalOutput("steps.missing.output", results); err == nil {
+		t.Error("EvalOutput of a missing step returned nil error")
+	}
+}
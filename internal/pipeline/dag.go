@@ -0,0 +1,126 @@
+// Package pipeline builds the step DAG from the config, runs its steps with
+// maximum parallelism, and aggregates their results.
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// Graph is the step DAG of a pipeline.
+type Graph struct {
+	Nodes map[string]*Node
+	Order []string // topological order
+}
+
+// Node is one step of the graph with its edges.
+type Node struct {
+	Name       string
+	Step       config.StepDef
+	Deps       []*Node // steps this one depends on
+	Dependents []*Node // steps depending on this one
+	InDegree   int
+}
+
+// BuildGraph builds the DAG of steps and sorts it topologically. It fails on
+// unknown dependencies and on cycles, reporting the cycle path.
+func BuildGraph(steps []config.StepDef) (*Graph, error) {
+	g := &Graph{Nodes: make(map[string]*Node, len(steps))}
+	for _, s := range steps {
+		g.Nodes[s.Name] = &Node{Name: s.Name, Step: s}
+	}
+	for _, s := range steps {
+		node := g.Nodes[s.Name]
+		for _, dep := range s.DependsOn {
+			d, ok := g.Nodes[dep]
+			if !ok {
+				return nil, fmt.Errorf("step %q: unknown dependency %q", s.Name, dep)
+			}
+			node.Deps = append(node.Deps, d)
+			d.Dependents = append(d.Dependents, node)
+			node.InDegree++
+		}
+	}
+	order, err := topoSort(g)
+	if err != nil {
+		return nil, err
+	}
+	g.Order = order
+	return g, nil
+}
+
+// topoSort orders the graph with Kahn's algorithm, breaking ties
+// alphabetically so the order is deterministic.
+func topoSort(g *Graph) ([]string, error) {
+	inDeg := make(map[string]int, len(g.Nodes))
+	var queue []string
+	for name, node := range g.Nodes {
+		inDeg[name] = node.InDegree
+		if node.InDegree == 0 {
+			queue = append(queue, name)
+		}
+	}
+	sort.Strings(queue)
+
+	var order []string
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		order = append(order, name)
+		for _, dep := range g.Nodes[name].Dependents {
+			inDeg[dep.Name]--
+			if inDeg[dep.Name] == 0 {
+				queue = append(queue, dep.Name)
+				sort.Strings(queue)
+			}
+		}
+	}
+	if len(order) != len(g.Nodes) {
+		return nil, fmt.Errorf("pipeline has a cycle: %s", strings.Join(findCycle(g), " → "))
+	}
+	return order, nil
+}
+
+// findCycle returns one cycle of g as a closed path, e.g. [A B A], found by
+// a depth-first search over dependency edges.
+func findCycle(g *Graph) []string {
+	names := make([]string, 0, len(g.Nodes))
+	for name := range g.Nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	visited := map[string]bool{}
+	onStack := map[string]int{} // name → index in stack
+	var stack []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		visited[name] = true
+		onStack[name] = len(stack)
+		stack = append(stack, name)
+		for _, next := range g.Nodes[name].Dependents {
+			if i, ok := onStack[next.Name]; ok {
+				return append(append([]string{}, stack[i:]...), next.Name)
+			}
+			if !visited[next.Name] {
+				if cycle := visit(next.Name); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		delete(onStack, name)
+		stack = stack[:len(stack)-1]
+		return nil
+	}
+	for _, name := range names {
+		if !visited[name] {
+			if cycle := visit(name); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// TestFR1_BuildGraph verifies nodes and edges of a linear chain.
+func TestFR1_BuildGraph(t *testing.T) {
+	g, err := BuildGraph([]config.StepDef{
+		{Name: "A"},
+		{Name: "B", DependsOn: []string{"A"}},
+		{Name: "C", DependsOn: []string{"B"}},
+	})
+	if err != nil {
+		t.Fatalf("BuildGraph: %v", err)
+	}
+	if len(g.Nodes) != 3 {
+		t.Fatalf("len(Nodes) = %d, want 3", len(g.Nodes))
+	}
+	b := g.Nodes["B"]
+	if b.InDegree != 1 || b.Deps[0].Name != "A" || b.Dependents[0].Name != "C" {
+		t.Errorf("node B = %+v, want dep A and dependent C", b)
+	}
+}
+
+// TestFR1_BuildGraphUnknownDep verifies that a missing dependency is an error.
+func TestFR1_BuildGraphUnknownDep(t *testing.T) {
+	_, err := BuildGraph([]config.StepDef{{Name: "A", DependsOn: []string{"X"}}})
+	if err == nil || !strings.Contains(err.Error(), `"X"`) {
+		t.Fatalf("err = %v, want unknown dependency X", err)
+	}
+}
+
+// TestFR3_TopoSort verifies deterministic ordering with alphabetical
+// tie-breaking.
+func TestFR3_TopoSort(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []config.StepDef
+		want  []string
+	}{
+		{"chain", []config.StepDef{
+			{Name: "A"}, {Name: "B", DependsOn: []string{"A"}}, {Name: "C", DependsOn: []string{"B"}},
+		}, []string{"A", "B", "C"}},
+		{"fan-out", []config.StepDef{
+			{Name: "A"}, {Name: "C", DependsOn: []string{"A"}}, {Name: "B", DependsOn: []string{"A"}},
+		}, []string{"A", "B", "C"}},
+		{"fan-in", []config.StepDef{
+			{Name: "Z"}, {Name: "Y"}, {Name: "M", DependsOn: []string{"Z", "Y"}},
+		}, []string{"Y", "Z", "M"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := BuildGraph(tt.steps)
+			if err != nil {
+				t.Fatalf("BuildGraph: %v", err)
+			}
+			if !reflect.DeepEqual(g.Order, tt.want) {
+				t.Errorf("Order = %v, want %v", g.Order, tt.want)
+			}
+		})
+	}
+}
+
+// TestFR2_CycleDetection verifies that cycles are reported with their path.
+func TestFR2_CycleDetection(t *testing.T) {
+	_, err := BuildGraph([]config.StepDef{
+		{Name: "A", DependsOn: []string{"B"}},
+		{Name: "B", DependsOn: []string{"A"}},
+	})
+	if err == nil {
+		t.Fatal("BuildGraph returned nil error for cycle")
+	}
+	if !strings.Contains(err.Error(), "cycle") || !strings.Contains(err.Error(), "A → B → A") {
+		t.Errorf("err = %q, want cycle path A → B → A", err)
+	}
+}
@@ -0,0 +1,631 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dmitriyb/conductor/internal/agent"
+	"github.com/dmitriyb/conductor/internal/config"
+	"github.com/dmitriyb/conductor/internal/events"
+	"github.com/dmitriyb/conductor/internal/state"
+)
+
+// Execute runs the pipeline of cfg and returns the results of its steps.
+// Steps whose dependencies have all finished are launched in goroutines of
+// their own, and each finished step releases its dependents in turn, so
+// independent branches run concurrently. Cancelling ctx stops running steps
+// and returns the partial results. inputs are the run's --input values,
+// available to templates as .Inputs; the issue and pr inputs also set
+// .IssueNumber and the initial .PRNumber.
+func Execute(ctx context.Context, cfg *config.Config, run *state.Run, inputs map[string]string,
+	runCfg agent.RunConfig, logger *slog.Logger) (*PipelineResult, error) {
+	if err := compileExprs(cfg.Pipeline); err != nil {
+		return nil, err
+	}
+	for name, sub := range cfg.Pipelines {
+		if err := compileExprs(sub.Steps); err != nil {
+			return nil, fmt.Errorf("pipeline %q: %w", name, err)
+		}
+		for out, expr := range sub.Outputs {
+			if _, _, err := compileCondition(expr); err != nil {
+				return nil, fmt.Errorf("pipeline %q output %q: %w", name, out, err)
+			}
+		}
+	}
+	if runCfg.Slots == nil {
+		runCfg.Slots = agent.NewSlots(cfg.Concurrency.MaxAgents, cfg.Concurrency.Agents)
+	}
+	runCfg.Meter = agent.NewMeter(cfg.Budget)
+	e := &executor{cfg: cfg, run: run, inputs: inputs, runCfg: runCfg, logger: logger, budget: cfg.Budget}
+	pr, err := e.execute(ctx, cfg.Pipeline)
+	if err != nil {
+		return nil, err
+	}
+	pr.Concurrency = cfg.Concurrency
+	return pr, nil
+}
+
+// compileExprs checks the condition and for_each expressions of steps.
+func compileExprs(steps []config.StepDef) error {
+	for _, step := range steps {
+		if step.Condition != "" {
+			if _, _, err := compileCondition(step.Condition); err != nil {
+				return fmt.Errorf("step %q condition: %w", step.Name, err)
+			}
+		}
+		if step.ForEach != "" {
+			if _, _, err := compileCondition(step.ForEach); err != nil {
+				return fmt.Errorf("step %q for_each: %w", step.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// executor holds the shared state of one pipeline run, or of one invocation
+// of a sub-pipeline. mu guards results, inDeg, failed and spent.
+type executor struct {
+	cfg    *config.Config
+	run    *state.Run
+	inputs map[string]string
+	runCfg agent.RunConfig
+	logger *slog.Logger
+	budget config.Budget // limits for all steps of this executor
+
+	// Sub-pipeline invocations qualify their step names with prefix, see
+	// the bound params as .Params, and track the named pipelines they are
+	// nested in.
+	prefix string
+	params map[string]string
+	stack  []string
+
+	steps []config.StepDef
+	wg    sync.WaitGroup
+
+	mu      sync.Mutex
+	results map[string]agent.StepResult
+	inDeg   map[string]int    // unfinished dependencies per step
+	failed  map[string]string // step → failed step whose failure it passes on
+	spent   agent.Usage       // usage of finished steps, for the budget
+}
+
+// execute runs steps to completion and aggregates their results.
+func (e *executor) execute(ctx context.Context, steps []config.StepDef) (*PipelineResult, error) {
+	start := time.Now()
+	graph, err := BuildGraph(steps)
+	if err != nil {
+		return nil, err
+	}
+	e.steps = steps
+	e.results = make(map[string]agent.StepResult, len(graph.Nodes))
+	e.inDeg = make(map[string]int, len(graph.Nodes))
+	e.failed = map[string]string{}
+	for name, node := range graph.Nodes {
+		e.inDeg[name] = node.InDegree
+	}
+	e.mu.Lock()
+	for _, name := range graph.Order {
+		if e.inDeg[name] == 0 {
+			e.launch(ctx, graph.Nodes[name])
+		}
+	}
+	e.mu.Unlock()
+	e.wg.Wait()
+
+	pr := &PipelineResult{Status: "success", Duration: time.Since(start)}
+	for _, step := range steps {
+		r, ok := e.results[step.Name]
+		if !ok {
+			continue
+		}
+		pr.Steps = append(pr.Steps, r)
+		pr.Usage.Add(r.Usage)
+		if r.Status == "failure" && !step.ContinueOnError {
+			pr.Status = "failure"
+		}
+	}
+	if ctx.Err() != nil {
+		pr.Status = "cancelled" // steps after the cancellation were skipped, not passed
+	}
+	return pr, nil
+}
+
+// launch starts node in a new goroutine and records it running, then
+// finished. Agent steps then wait for a slot of runCfg.Slots, made from
+// cfg.Concurrency unless the caller shares its own, before their container
+// starts. The caller holds e.mu.
+func (e *executor) launch(ctx context.Context, node *Node) {
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		started := time.Now()
+		e.recordStep(agent.StepResult{Name: e.prefix + node.Name, Agent: node.Step.Agent,
+			Status: state.StatusRunning}, started, time.Time{})
+		r := e.runStep(ctx, node)
+		r.Duration = time.Since(started)
+		e.recordStep(r, started, started.Add(r.Duration))
+		e.finish(ctx, node, r)
+	}()
+}
+
+// recordStep announces the status of a step on the event bus and keeps it
+// in the run record, if there is one. Failures to record are logged.
+func (e *executor) recordStep(r agent.StepResult, started, finished time.Time) {
+	e.runCfg.Events.Publish(events.Event{Step: r.Name, Agent: r.Agent, Kind: events.KindStep, Text: r.Status})
+	if e.run == nil {
+		return
+	}
+	err := e.run.RecordStep(state.StepRecord{Name: r.Name, Agent: r.Agent, Status: r.Status,
+		Output: r.Output, Error: r.Error, Cached: r.Cached, Started: started, Finished: finished})
+	if err != nil {
+		e.logger.Warn("recording step status failed", "step", r.Name, "error", err)
+	}
+}
+
+// runStep evaluates the step's condition and runs it according to its kind.
+func (e *executor) runStep(ctx context.Context, node *Node) agent.StepResult {
+	name := e.prefix + node.Name
+	if ctx.Err() != nil {
+		return agent.StepResult{Name: name, Agent: node.Step.Agent, Status: "skipped", Error: "pipeline cancelled"}
+	}
+	e.mu.Lock()
+	snap := maps.Clone(e.results)
+	e.mu.Unlock()
+
+	if node.Step.Condition != "" {
+		pass, err := EvalCondition(node.Step.Condition, snap)
+		if err != nil || !pass {
+			reason := "condition is false"
+			if err != nil {
+				reason = err.Error()
+			}
+			e.logger.Info("skipping step", "step", name, "reason", reason)
+			return agent.StepResult{Name: name, Agent: node.Step.Agent, Status: "skipped", Error: reason}
+		}
+	}
+
+	if node.Step.StepKind() == config.StepApproval {
+		return e.runApproval(ctx, node, snap)
+	}
+	if node.Step.ForEach != "" {
+		return e.runForEach(ctx, node, snap)
+	}
+	return e.runInstance(ctx, node, name, e.templateData(snap))
+}
+
+// runForEach expands the step into one instance per element of its for_each
+// list and runs them concurrently. The collected result fails if any
+// instance failed; its output lists the instance outputs in order as items,
+// and its artifacts are the instances' artifacts under <index>/<artifact>.
+func (e *executor) runForEach(ctx context.Context, node *Node, snap map[string]agent.StepResult) agent.StepResult {
+	name := e.prefix + node.Name
+	items, err := EvalForEach(node.Step.ForEach, snap)
+	if err != nil {
+		e.logger.Error("step failed", "step", name, "error", err)
+		return agent.StepResult{Name: name, Agent: node.Step.Agent, Status: "failure", Error: err.Error()}
+	}
+	e.logger.Info("expanding step", "step", name, "instances", len(items))
+
+	children := make([]agent.StepResult, len(items))
+	var wg sync.WaitGroup
+	for i, item := range items {
+		data := e.templateData(snap)
+		data.Item, data.Index = item, i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			started := time.Now()
+			children[i] = e.runInstance(ctx, node, fmt.Sprintf("%s[%d]", name, i), data)
+			children[i].Duration = time.Since(started)
+		}()
+	}
+	wg.Wait()
+
+	r := agent.StepResult{Name: name, Agent: node.Step.Agent, Status: "success", Children: children}
+	outputs := make([]any, len(children))
+	var failed []string
+	for i, c := range children {
+		outputs[i] = c.Output
+		r.Usage.Add(c.Usage)
+		// Dependents find instance i's artifacts as <i>/<artifact>.
+		for artifact, p := range c.Artifacts {
+			if r.Artifacts == nil {
+				r.Artifacts = map[string]string{}
+			}
+			r.Artifacts[path.Join(strconv.Itoa(i), artifact)] = p
+		}
+		if c.Status == "failure" {
+			failed = append(failed, c.Name)
+		}
+	}
+	r.Output = map[string]any{"items": outputs}
+	if len(failed) > 0 {
+		r.Status = "failure"
+		r.Error = fmt.Sprintf("%d of %d instances failed: %s", len(failed), len(children), strings.Join(failed, ", "))
+	}
+	return r
+}
+
+// runInstance runs the step under the given result name, rendering its task
+// or command with data. Agent and container steps reuse a cached result when
+// their inputs are unchanged, replaying what they changed in read-write
+// repositories, and run in a reconstructed workspace when the step sets
+// workspace_from.
+func (e *executor) runInstance(ctx context.Context, node *Node, name string, data agent.TemplateData) agent.StepResult {
+	kind := node.Step.StepKind()
+	if kind == config.StepPipeline {
+		return e.runSubPipeline(ctx, node, name, data)
+	}
+	if kind == config.StepAgent || kind == config.StepContainer {
+		deps := make([]string, len(node.Deps))
+		for i, dep := range node.Deps {
+			deps[i] = dep.Name
+		}
+		data.Artifacts = agent.UpstreamArtifacts(deps, data.Steps)
+	}
+	runCfg, cleanup, err := e.stepConfig(ctx, node)
+	if err != nil {
+		e.logger.Error("step failed", "step", name, "error", err)
+		return agent.StepResult{Name: name, Agent: node.Step.Agent, Status: "failure", Error: err.Error()}
+	}
+	defer cleanup()
+	key := e.cacheKey(ctx, node, data, runCfg)
+	if key != "" {
+		if r, ok := e.cacheGet(ctx, key, node, name, runCfg); ok {
+			return r
+		}
+	}
+	var r agent.StepResult
+	var patches map[string][]byte
+	if kind == config.StepShell || kind == config.StepContainer {
+		r, patches = e.runCommand(ctx, node, name, data, runCfg)
+	} else {
+		r, patches = e.runAgent(ctx, node, name, data, runCfg)
+	}
+	if key != "" {
+		e.cachePut(key, node, r, patches)
+	}
+	return r
+}
+
+// runAgent runs an agent step once an agent slot is free, within what is
+// left of the run budget, recording what it changed in read-write
+// repositories. It returns the result and the patches of those changes.
+func (e *executor) runAgent(ctx context.Context, node *Node, name string, data agent.TemplateData,
+	runCfg agent.RunConfig) (agent.StepResult, map[string][]byte) {
+	def := e.cfg.Agents[node.Step.Agent]
+	budget, err := e.stepBudget(def.Budget)
+	if err != nil {
+		return agent.StepResult{Name: name, Agent: node.Step.Agent, Status: "failure", Error: err.Error()}, nil
+	}
+	def.Budget = budget
+
+	queued := time.Now()
+	release, err := runCfg.Slots.Acquire(ctx, node.Step.Agent, node.Step.Priority)
+	if err != nil {
+		return agent.StepResult{Name: name, Agent: node.Step.Agent, Status: "skipped", Error: "pipeline cancelled"}, nil
+	}
+	defer release()
+	wait := time.Since(queued)
+	if wait >= time.Second {
+		e.logger.Info("got agent slot", "step", name, "queued", wait.Round(time.Second))
+	}
+
+	bases := e.snapshotRepos(ctx, node, runCfg)
+	result, err := agent.RunAgent(ctx, name, node.Step.Agent, def, data, runCfg, e.logger)
+	patches := e.recordChanges(ctx, name, bases, runCfg)
+	if err != nil {
+		if result == nil {
+			result = &agent.StepResult{Name: name, Agent: node.Step.Agent, Status: "failure", Error: err.Error()}
+		}
+		e.logger.Error("step failed", "step", name, "error", err)
+	}
+	result.Queued = wait
+	return *result, patches
+}
+
+// runSubPipeline runs the step's named sub-pipeline with its params rendered
+// from data. The sub-pipeline's step results become the step's children, its
+// outputs the step's output, and its steps' artifacts the step's artifacts
+// under <sub-step>/<artifact>; it fails if any of its steps failed.
+func (e *executor) runSubPipeline(ctx context.Context, node *Node, name string, data agent.TemplateData) agent.StepResult {
+	fail := func(err error) agent.StepResult {
+		e.logger.Error("step failed", "step", name, "error", err)
+		return agent.StepResult{Name: name, Status: "failure", Error: err.Error()}
+	}
+	pipelineName := node.Step.Pipeline
+	def, ok := e.cfg.Pipelines[pipelineName]
+	if !ok {
+		return fail(fmt.Errorf("undefined pipeline %q", pipelineName))
+	}
+	if slices.Contains(e.stack, pipelineName) {
+		return fail(fmt.Errorf("pipeline %q includes itself", pipelineName))
+	}
+	params := make(map[string]string, len(node.Step.With))
+	for param, text := range node.Step.With {
+		v, err := agent.RenderTemplate("with."+param, text, data)
+		if err != nil {
+			return fail(err)
+		}
+		params[param] = v
+	}
+	budget, err := e.stepBudget(config.Budget{})
+	if err != nil {
+		return fail(err)
+	}
+
+	sub := &executor{cfg: e.cfg, run: e.run, inputs: e.inputs, runCfg: e.runCfg, budget: budget,
+		logger: e.logger.With("pipeline", pipelineName),
+		prefix: name + ".", params: params, stack: append(slices.Clone(e.stack), pipelineName)}
+	pr, err := sub.execute(ctx, def.Steps)
+	if err != nil {
+		return fail(fmt.Errorf("pipeline %q: %w", pipelineName, err))
+	}
+
+	r := agent.StepResult{Name: name, Status: pr.Status, Usage: pr.Usage, Children: pr.Steps,
+		Output: make(map[string]any, len(def.Outputs))}
+	var failed []string
+	for _, c := range pr.Steps {
+		if c.Status == "failure" {
+			failed = append(failed, c.Name)
+		}
+		// Dependents find a sub-step's artifacts as <sub-step>/<artifact>.
+		for artifact, p := range c.Artifacts {
+			if r.Artifacts == nil {
+				r.Artifacts = map[string]string{}
+			}
+			r.Artifacts[path.Join(strings.TrimPrefix(c.Name, sub.prefix), artifact)] = p
+		}
+	}
+	switch pr.Status {
+	case "failure":
+		r.Error = fmt.Sprintf("pipeline %s failed: %s", pipelineName, strings.Join(failed, ", "))
+	case "cancelled":
+		r.Status, r.Error = "failure", fmt.Sprintf("pipeline %s cancelled", pipelineName)
+	}
+	for out, expr := range def.Outputs {
+		v, err := EvalOutput(expr, sub.results)
+		if err != nil && r.Status == "success" {
+			r.Status = "failure"
+			r.Error = fmt.Sprintf("output %s: %v", out, err)
+		}
+		r.Output[out] = v
+	}
+	return r
+}
+
+// runCommand renders a shell or container step's command with data and
+// runs it. Commands interpolating values without shellquote are refused,
+// even if the config skipped validation. Like runAgent, it returns the
+// patches of what a container step changed in read-write repositories.
+func (e *executor) runCommand(ctx context.Context, node *Node, name string, data agent.TemplateData,
+	runCfg agent.RunConfig) (agent.StepResult, map[string][]byte) {
+	command := ""
+	err := config.CheckCommand(node.Step.Command)
+	if err == nil {
+		command, err = agent.RenderTemplate("command", node.Step.Command, data)
+	}
+	var result *agent.StepResult
+	var patches map[string][]byte
+	if err == nil {
+		if node.Step.StepKind() == config.StepShell {
+			result, err = agent.RunShell(ctx, name, command, runCfg, e.logger)
+		} else {
+			bases := e.snapshotRepos(ctx, node, runCfg)
+			result, err = agent.RunContainer(ctx, name, command, node.Step.Workspace, data, runCfg, e.logger)
+			patches = e.recordChanges(ctx, name, bases, runCfg)
+		}
+	}
+	if err != nil {
+		if result == nil {
+			result = &agent.StepResult{Name: name, Status: "failure", Error: err.Error()}
+		}
+		e.logger.Error("step failed", "step", name, "error", err)
+	}
+	return *result, patches
+}
+
+// runApproval records an approval request showing the outputs of the step's
+// dependencies and waits for a human decision, recorded in the run by
+// conductor approve or reject, so approval steps need a run record.
+// Rejection, timeout and cancellation fail the step; other branches keep
+// running meanwhile.
+func (e *executor) runApproval(ctx context.Context, node *Node, snap map[string]agent.StepResult) agent.StepResult {
+	name := e.prefix + node.Name
+	fail := func(msg string) agent.StepResult {
+		return agent.StepResult{Name: name, Status: "failure", Error: msg}
+	}
+	if e.run == nil {
+		return fail("approval steps need a run record")
+	}
+	upstream := make(map[string]map[string]any, len(node.Deps))
+	for _, dep := range node.Deps {
+		upstream[dep.Name] = snap[dep.Name].Output
+	}
+	timeout := node.Step.ApprovalTimeout()
+	now := time.Now()
+	req := state.ApprovalRequest{Step: name, Message: node.Step.Approval.Message,
+		Upstream: upstream, RequestedAt: now, Deadline: now.Add(timeout)}
+	if err := e.run.RequestApproval(req); err != nil {
+		return fail(fmt.Sprintf("request approval: %v", err))
+	}
+	e.runCfg.Events.Publish(events.Event{Step: name, Kind: events.KindApproval,
+		Text: req.Message, Data: map[string]any{"run_id": e.run.ID, "upstream": upstream}})
+	e.logger.Warn("approval required", "step", name, "run_id", e.run.ID,
+		"message", req.Message, "upstream", upstream, "deadline", req.Deadline.Format(time.RFC3339),
+		"approve", fmt.Sprintf("conductor approve %s %s", e.run.ID, name),
+		"reject", fmt.Sprintf("conductor reject %s %s", e.run.ID, name))
+
+	d, err := e.run.WaitDecision(ctx, name, timeout)
+	if err != nil {
+		return fail(fmt.Sprintf("approval: %v", err))
+	}
+	output := map[string]any{"approved": d.Approved, "by": d.By, "comment": d.Comment}
+	e.logger.Info("approval decided", "step", name, "approved", d.Approved, "by", d.By)
+	if !d.Approved {
+		r := fail(fmt.Sprintf("rejected by %s: %s", d.By, d.Comment))
+		r.Output = output
+		return r
+	}
+	return agent.StepResult{Name: name, Status: "success", Output: output}
+}
+
+// finish records r and releases the step's dependents. A failure is passed
+// on to them unless the step is continue_on_error, skipping those with
+// run_if success and running those with run_if failure or always. The caller
+// must not hold e.mu.
+func (e *executor) finish(ctx context.Context, node *Node, r agent.StepResult) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.results[node.Name] = r
+	e.spent.Add(r.Usage)
+	if r.Status == "failure" && !node.Step.ContinueOnError {
+		e.failed[node.Name] = node.Name
+	}
+	e.release(ctx, node)
+}
+
+// release counts node as finished for its dependents. A dependent whose
+// dependencies have all finished is launched, or skipped if its run_if
+// policy does not match how they ended; skips are released in turn. The
+// caller holds e.mu.
+func (e *executor) release(ctx context.Context, node *Node) {
+	for _, dep := range node.Dependents {
+		e.inDeg[dep.Name]--
+		if e.inDeg[dep.Name] > 0 {
+			continue
+		}
+		var cause string // failed step upstream of dep, if any
+		for _, d := range dep.Deps {
+			if cause = e.failed[d.Name]; cause != "" {
+				break
+			}
+		}
+		skip := ""
+		switch dep.Step.RunPolicy() {
+		case config.RunIfSuccess:
+			if cause != "" {
+				skip = fmt.Sprintf("dependency %s failed", cause)
+				e.failed[dep.Name] = cause
+			}
+		case config.RunIfFailure:
+			if cause == "" {
+				skip = "no dependency failed"
+			}
+		}
+		if skip == "" {
+			e.launch(ctx, dep)
+			continue
+		}
+		e.logger.Info("skipping step", "step", e.prefix+dep.Name, "reason", skip)
+		e.results[dep.Name] = agent.StepResult{Name: e.prefix + dep.Name, Agent: dep.Step.Agent,
+			Status: "skipped", Error: skip}
+		e.recordStep(e.results[dep.Name], time.Time{}, time.Now())
+		e.release(ctx, dep)
+	}
+}
+
+// stepBudget returns the agent's budget tightened to what is left of the run
+// budget, or an error if the run budget is already used up. Steps running
+// concurrently each get the same remainder; the run's meter stops them once
+// together they spend more. In a sub-pipeline the run budget is what was
+// left when it started.
+func (e *executor) stepBudget(b config.Budget) (config.Budget, error) {
+	run := e.budget
+	e.mu.Lock()
+	spent := e.spent
+	e.mu.Unlock()
+
+	if run.MaxCostUSD > 0 {
+		left := run.MaxCostUSD - spent.CostUSD
+		if left <= 0 {
+			return b, fmt.Errorf("run budget exhausted: cost $%.4f of $%.4f", spent.CostUSD, run.MaxCostUSD)
+		}
+		if b.MaxCostUSD == 0 || left < b.MaxCostUSD {
+			b.MaxCostUSD = left
+		}
+	}
+	if run.MaxTokens > 0 {
+		left := run.MaxTokens - spent.Tokens()
+		if left <= 0 {
+			return b, fmt.Errorf("run budget exhausted: %d of %d tokens", spent.Tokens(), run.MaxTokens)
+		}
+		if b.MaxTokens == 0 || left < b.MaxTokens {
+			b.MaxTokens = left
+		}
+	}
+	if run.MaxTurns > 0 {
+		left := run.MaxTurns - spent.Turns
+		if left <= 0 {
+			return b, fmt.Errorf("run budget exhausted: %d of %d turns", spent.Turns, run.MaxTurns)
+		}
+		if b.MaxTurns == 0 || left < b.MaxTurns {
+			b.MaxTurns = left
+		}
+	}
+	return b, nil
+}
+
+// templateData returns the template context for a step of this executor.
+func (e *executor) templateData(results map[string]agent.StepResult) agent.TemplateData {
+	data := buildTemplateData(e.cfg, e.steps, results)
+	data.Inputs = e.inputs
+	data.IssueNumber = e.inputs["issue"]
+	if data.PRNumber == "" {
+		data.PRNumber = e.inputs["pr"]
+	}
+	data.Params = e.params
+	return data
+}
+
+// buildTemplateData assembles the task template context from the project and
+// the results of finished steps. PRNumber is taken from the last of steps, in
+// order, whose output has a pr_number.
+func buildTemplateData(cfg *config.Config, steps []config.StepDef, results map[string]agent.StepResult) agent.TemplateData {
+	data := agent.TemplateData{Steps: results}
+	if repo, ok := cfg.Project.Repos()[config.DefaultRepo]; ok {
+		data.RepoURL = repo.URL
+		data.RepoOwner, data.RepoName = splitRepoURL(repo.URL)
+	}
+	for _, step := range steps {
+		if v, ok := results[step.Name].Output["pr_number"]; ok {
+			data.PRNumber = formatValue(v)
+		}
+	}
+	return data
+}
+
+// splitRepoURL returns the owner and name of an https or scp-style git URL.
+func splitRepoURL(url string) (owner, name string) {
+	path := strings.TrimSuffix(url, ".git")
+	if i := strings.Index(path, "://"); i >= 0 {
+		path = path[i+3:]
+		if j := strings.Index(path, "/"); j >= 0 {
+			path = path[j+1:]
+		}
+	} else if i := strings.Index(path, ":"); i >= 0 {
+		path = path[i+1:]
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 2 {
+		return "", ""
+	}
+	return parts[len(parts)-2], parts[len(parts)-1]
+}
+
+// formatValue renders a JSON output value for a template; whole numbers
+// print without a fraction.
+func formatValue(v any) string {
+	if f, ok := v.(float64); ok && f == float64(int64(f)) {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,1160 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmitriyb/conductor/internal/agent"
+	"github.com/dmitriyb/conductor/internal/config"
+	"github.com/dmitriyb/conductor/internal/events"
+	"github.com/dmitriyb/conductor/internal/state"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// fakeStep describes what the fake agent container of one step does.
+type fakeStep struct {
+	output    map[string]any // marker payload; nil exits non-zero
+	sleep     string         // seconds to sleep before printing
+	cost      float64
+	edit      bool     // writes <step>.txt into its read-write workspace
+	artifacts []string // files written to its /artifacts mount
+}
+
+// fakeAgents installs a docker stand-in that replays each step's canned
+// session, or container step, found by the step name in the container name. It returns the
+// path of a file listing the steps that ran; beside it, <step>.ws holds the
+// host directory mounted as the step's workspace and the files in it, and
+// <step>.up the upstream artifact mounts it was given.
+func fakeAgents(t *testing.T, steps map[string]fakeStep) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, s := range steps {
+		if s.output != nil {
+			payload, _ := json.Marshal(s.output)
+			line, _ := json.Marshal(map[string]any{
+				"type": "result", "subtype": "success", "num_turns": 1, "total_cost_usd": s.cost,
+				"usage":  map[string]any{"input_tokens": 100, "output_tokens": 10},
+				"result": "###PIPELINE_OUTPUT###" + string(payload),
+			})
+			if err := os.WriteFile(filepath.Join(dir, name+".out"), append(line, '\n'), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if s.sleep != "" {
+			if err := os.WriteFile(filepath.Join(dir, name+".sleep"), []byte(s.sleep), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if s.edit {
+			if err := os.WriteFile(filepath.Join(dir, name+".edit"), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(s.artifacts) > 0 {
+			if err := os.WriteFile(filepath.Join(dir, name+".artifacts"), []byte(strings.Join(s.artifacts, "\n")+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	ran := filepath.Join(dir, "ran")
+	script := `#!/bin/sh
+state="` + dir + `"
+[ "$1" = run ] || exit 0
+prev=; for a; do [ "$prev" = --name ] && name="$a"; prev="$a"; done
+step=$(echo "$name" | sed 's/^conductor-//; s/-[0-9]*-[0-9]*-[0-9a-f]*$//')
+echo "$step" >> "$state/ran"
+for a; do case "$a" in
+  *:/workspace|*:/workspace:ro) ws="${a%:ro}"; ws="${ws%:/workspace}" ;;
+  *:/artifacts) out="${a%:/artifacts}" ;;
+  *:/upstream/*) echo "$a" >> "$state/$step.up" ;;
+esac; done
+{ echo "$ws"; ls "$ws"; } > "$state/$step.ws"
+[ -f "$state/$step.artifacts" ] && while read -r f; do echo "$step" > "$out/$f"; done < "$state/$step.artifacts"
+[ -f "$state/$step.edit" ] && echo "$step" > "$ws/$step.txt"
+[ -f "$state/$step.sleep" ] && sleep "$(cat "$state/$step.sleep")"
+[ -f "$state/$step.out" ] || exit 1
+cat "$state/$step.out"
+`
+	if err := os.WriteFile(filepath.Join(dir, "docker"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return ran
+}
+
+// ranSteps returns the steps the fake docker was asked to run.
+func ranSteps(t *testing.T, ran string) []string {
+	t.Helper()
+	data, err := os.ReadFile(ran)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return strings.Fields(string(data))
+}
+
+// testPipeline returns a config running steps with a single worker agent,
+// and a matching RunConfig.
+func testPipeline(t *testing.T, steps ...config.StepDef) (*config.Config, agent.RunConfig) {
+	cfg := &config.Config{
+		Project: config.Project{Name: "test", Repository: "https://github.com/acme/widgets.git"},
+		Agents: map[string]config.AgentDef{"worker": {
+			Prompt:    config.PromptDef{System: "Work.\n", Task: "PR {{.PRNumber}}"},
+			Workspace: "rw",
+		}},
+		Pipeline: steps,
+	}
+	runCfg := agent.RunConfig{
+		Images:  map[string]string{"worker": "conductor-test:0123456789ab"},
+		Project: cfg.Project,
+		Repos:   map[string]string{config.DefaultRepo: t.TempDir()},
+		LogDir:  t.TempDir(),
+	}
+	return cfg, runCfg
+}
+
+// statuses maps step names to their result status.
+func statuses(pr *PipelineResult) map[string]string {
+	m := map[string]string{}
+	for _, s := range pr.Steps {
+		m[s.Name] = s.Status
+	}
+	return m
+}
+
+// TestFR6_ExecuteAggregates verifies that all steps run and results are
+// aggregated in config order with summed usage.
+func TestFR6_ExecuteAggregates(t *testing.T) {
+	ok := map[string]any{"status": "success"}
+	fakeAgents(t, map[string]fakeStep{
+		"plan":  {output: ok, cost: 0.5},
+		"build": {output: ok, cost: 0.25},
+		"docs":  {output: ok, cost: 0.25},
+	})
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "plan", Agent: "worker"},
+		config.StepDef{Name: "build", Agent: "worker", DependsOn: []string{"plan"}},
+		config.StepDef{Name: "docs", Agent: "worker", DependsOn: []string{"plan"}},
+	)
+
+	pr, err := Execute(context.Background(), cfg, nil, nil, runCfg, discard)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if pr.Status != "success" {
+		t.Errorf("Status = %q, want success", pr.Status)
+	}
+	var names []string
+	for _, s := range pr.Steps {
+		names = append(names, s.Name)
+	}
+	if strings.Join(names, ",") != "plan,build,docs" {
+		t.Errorf("steps = %v, want config order", names)
+	}
+	if pr.Usage.CostUSD != 1 || pr.Usage.Tokens() != 330 || pr.Usage.Turns != 3 {
+		t.Errorf("Usage = %+v, want summed usage", pr.Usage)
+	}
+}
+
+// TestFR7_FailurePropagation verifies that dependents of a failed step are
+// skipped while independent branches still run.
+func TestFR7_FailurePropagation(t *testing.T) {
+	ran := fakeAgents(t, map[string]fakeStep{
+		"other": {output: map[string]any{"status": "success"}},
+	})
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "implement", Agent: "worker"},
+		config.StepDef{Name: "review", Agent: "worker", DependsOn: []string{"implement"}},
+		config.StepDef{Name: "fix", Agent: "worker", DependsOn: []string{"review"}},
+		config.StepDef{Name: "other", Agent: "worker"},
+	)
+
+	pr, err := Execute(context.Background(), cfg, nil, nil, runCfg, discard)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	got := statuses(pr)
+	want := map[string]string{"implement": "failure", "review": "skipped", "fix": "skipped", "other": "success"}
+	for name, status := range want {
+		if got[name] != status {
+			t.Errorf("%s status = %q, want %q", name, got[name], status)
+		}
+	}
+	if pr.Status != "failure" {
+		t.Errorf("Status = %q, want failure", pr.Status)
+	}
+	if pr.Steps[1].Error != "dependency implement failed" {
+		t.Errorf("review error = %q", pr.Steps[1].Error)
+	}
+	if r := ranSteps(t, ran); len(r) != 2 {
+		t.Errorf("ran %v, want only implement and other", r)
+	}
+}
+
+// TestStepRecords verifies that step statuses are kept in the run record and
+// announced on the event bus as steps start and finish.
+func TestStepRecords(t *testing.T) {
+	fakeAgents(t, map[string]fakeStep{
+		"other": {output: map[string]any{"status": "success", "note": "done"}},
+	})
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "implement", Agent: "worker"},
+		config.StepDef{Name: "review", Agent: "worker", DependsOn: []string{"implement"}},
+		config.StepDef{Name: "other", Agent: "worker"},
+	)
+	runCfg.Events = events.NewBus()
+	ch, unsubscribe := runCfg.Events.Subscribe(64)
+	run := testRun(t)
+
+	if _, err := Execute(context.Background(), cfg, run, nil, runCfg, discard); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	unsubscribe()
+	announced := map[string][]string{}
+	for e := range ch {
+		if e.Kind == events.KindStep {
+			announced[e.Step] = append(announced[e.Step], e.Text)
+		}
+	}
+	if got := fmt.Sprint(announced["implement"]); got != "[running failure]" {
+		t.Errorf("implement announced %s, want running then failure", got)
+	}
+	if got := fmt.Sprint(announced["review"]); got != "[skipped]" {
+		t.Errorf("review announced %s, want skipped", got)
+	}
+
+	steps, err := run.Steps()
+	if err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+	got := map[string]state.StepRecord{}
+	for _, s := range steps {
+		got[s.Name] = s
+	}
+	if s := got["other"]; s.Status != "success" || s.Output["note"] != "done" || s.Started.IsZero() || s.Finished.IsZero() {
+		t.Errorf("other = %+v, want success with output and times", s)
+	}
+	if s := got["review"]; s.Status != "skipped" || s.Error != "dependency implement failed" {
+		t.Errorf("review = %+v, want skipped", s)
+	}
+	if s := got["implement"]; s.Status != "failure" {
+		t.Errorf("implement = %+v, want failure", s)
+	}
+}
+
+// TestRunIfPolicies verifies that failure handlers run only after a failed
+// dependency, cleanup steps always run, and both still see upstream statuses.
+func TestRunIfPolicies(t *testing.T) {
+	tests := []struct {
+		name      string
+		implement string // shell command of the implement step
+		want      map[string]string
+		wantRun   string
+	}{
+		{"implement fails", "exit 1", map[string]string{
+			"implement": "failure", "review": "skipped", "notify": "success", "cleanup": "success",
+		}, "failure"},
+		{"implement succeeds", "true", map[string]string{
+			"implement": "success", "review": "success", "notify": "skipped", "cleanup": "success",
+		}, "success"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, runCfg := testPipeline(t,
+				config.StepDef{Name: "implement", Kind: config.StepShell, Command: tt.implement},
+				config.StepDef{Name: "review", Kind: config.StepShell, DependsOn: []string{"implement"},
+					Command: "true"},
+				config.StepDef{Name: "notify", Kind: config.StepShell, DependsOn: []string{"review"},
+					RunIf: config.RunIfFailure, Condition: "steps.implement.status == 'failure'",
+					Command: `printf '{"failed": "%s"}' {{.Steps.implement.Status | shellquote}}`},
+				config.StepDef{Name: "cleanup", Kind: config.StepShell, DependsOn: []string{"notify"},
+					RunIf: config.RunIfAlways, Command: "true"},
+			)
+
+			pr, err := Execute(context.Background(), cfg, nil, nil, runCfg, discard)
+			if err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			got := statuses(pr)
+			for step, status := range tt.want {
+				if got[step] != status {
+					t.Errorf("%s: status = %q, want %q", step, got[step], status)
+				}
+			}
+			if pr.Status != tt.wantRun {
+				t.Errorf("run status = %q, want %q", pr.Status, tt.wantRun)
+			}
+		})
+	}
+}
+
+// TestContinueOnError verifies that a failed continue_on_error step lets its
+// dependents run and does not fail the run.
+func TestContinueOnError(t *testing.T) {
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "lint", Kind: config.StepShell, Command: "exit 1", ContinueOnError: true},
+		config.StepDef{Name: "test", Kind: config.StepShell, DependsOn: []string{"lint"},
+			Condition: "steps.lint.status == 'failure'", Command: "true"},
+		config.StepDef{Name: "notify", Kind: config.StepShell, DependsOn: []string{"lint"},
+			RunIf: config.RunIfFailure, Command: "true"},
+	)
+
+	pr, err := Execute(context.Background(), cfg, nil, nil, runCfg, discard)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	got := statuses(pr)
+	if got["lint"] != "failure" || got["test"] != "success" || got["notify"] != "skipped" {
+		t.Errorf("statuses = %v, want lint failed, test run, notify skipped", got)
+	}
+	if pr.Status != "success" {
+		t.Errorf("run status = %q, want success", pr.Status)
+	}
+}
+
+// TestFR5_ConditionSkipsStep verifies that a false condition skips the step
+// without failing the run, and that its dependents still run.
+func TestFR5_ConditionSkipsStep(t *testing.T) {
+	fakeAgents(t, map[string]fakeStep{
+		"review": {output: map[string]any{"status": "success", "verdict": "approved"}},
+		"report": {output: map[string]any{"status": "success"}},
+	})
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "review", Agent: "worker"},
+		config.StepDef{Name: "fix", Agent: "worker", DependsOn: []string{"review"},
+			Condition: "steps.review.output.verdict == 'changes_requested'"},
+		config.StepDef{Name: "report", Agent: "worker", DependsOn: []string{"fix"}},
+	)
+
+	pr, err := Execute(context.Background(), cfg, nil, nil, runCfg, discard)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	got := statuses(pr)
+	if got["fix"] != "skipped" || got["report"] != "success" || pr.Status != "success" {
+		t.Errorf("statuses = %v, run = %q; want fix skipped, report success", got, pr.Status)
+	}
+}
+
+// TestCommandSteps verifies that shell step output feeds conditions and the
+// command templates of later steps, and that a failing command fails its
+// dependents.
+func TestCommandSteps(t *testing.T) {
+	ran := fakeAgents(t, map[string]fakeStep{"fix": {output: map[string]any{"status": "success"}}})
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "lint", Kind: config.StepShell, Command: `echo '{"issues": 2}'`},
+		config.StepDef{Name: "fix", Agent: "worker", DependsOn: []string{"lint"},
+			Condition: "steps.lint.output.issues > 0"},
+		config.StepDef{Name: "report", Kind: config.StepShell, DependsOn: []string{"lint"},
+			Command: `test {{index .Steps.lint.Output "issues" | shellquote}} = 2 && echo reported`},
+		config.StepDef{Name: "broken", Kind: config.StepShell, Command: "exit 1"},
+		config.StepDef{Name: "after", Kind: config.StepShell, DependsOn: []string{"broken"}, Command: "true"},
+	)
+
+	pr, err := Execute(context.Background(), cfg, nil, nil, runCfg, discard)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	got := statuses(pr)
+	want := map[string]string{"lint": "success", "fix": "success", "report": "success",
+		"broken": "failure", "after": "skipped"}
+	for step, status := range want {
+		if got[step] != status {
+			t.Errorf("%s: status = %q, want %q", step, got[step], status)
+		}
+	}
+	if r := ranSteps(t, ran); len(r) != 1 || r[0] != "fix" {
+		t.Errorf("docker ran %v, want only the fix agent", r)
+	}
+}
+
+// TestExecuteCancelled verifies that a run cancelled before its steps ran
+// reports itself cancelled rather than successful.
+func TestExecuteCancelled(t *testing.T) {
+	ran := fakeAgents(t, map[string]fakeStep{"plan": {output: map[string]any{"status": "success"}}})
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "plan", Agent: "worker"},
+		config.StepDef{Name: "check", Kind: config.StepShell, Command: "true", DependsOn: []string{"plan"}},
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	pr, err := Execute(ctx, cfg, nil, nil, runCfg, discard)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if pr.Status != "cancelled" {
+		t.Errorf("status = %q, want cancelled", pr.Status)
+	}
+	if got := statuses(pr); got["plan"] != "skipped" || got["check"] != "skipped" {
+		t.Errorf("statuses = %v, want both skipped", got)
+	}
+	if r := ranSteps(t, ran); len(r) != 0 {
+		t.Errorf("docker ran %v after cancellation", r)
+	}
+}
+
+// TestForEachFanOut verifies that a for_each step runs one instance per list
+// element with Item and Index in its template data, and that dependents see
+// the collected outputs.
+func TestForEachFanOut(t *testing.T) {
+	fakeAgents(t, map[string]fakeStep{
+		"plan": {output: map[string]any{"status": "success",
+			"tasks": []any{map[string]any{"title": "parser"}, map[string]any{"title": "lexer"}}}},
+	})
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "plan", Agent: "worker"},
+		config.StepDef{Name: "implement", Kind: config.StepShell, DependsOn: []string{"plan"},
+			ForEach: "steps.plan.output.tasks",
+			Command: `printf '{"task": "%s", "index": %d}' {{.Item.title | shellquote}} {{.Index | shellquote}}`},
+		config.StepDef{Name: "merge", Kind: config.StepShell, DependsOn: []string{"implement"},
+			Condition: "steps.implement.output.items.all(i, i.exit_code == 0)",
+			Command:   `test {{index (index .Steps.implement.Output.items 1) "task" | shellquote}} = lexer`},
+		config.StepDef{Name: "none", Kind: config.StepShell, ForEach: "[]", Command: "false"},
+	)
+
+	pr, err := Execute(context.Background(), cfg, nil, nil, runCfg, discard)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	got := statuses(pr)
+	if got["implement"] != "success" || got["merge"] != "success" || got["none"] != "success" {
+		t.Fatalf("statuses = %v, want implement, merge and none to succeed", got)
+	}
+	var impl agent.StepResult
+	for _, s := range pr.Steps {
+		if s.Name == "implement" {
+			impl = s
+		}
+	}
+	if len(impl.Children) != 2 || impl.Children[1].Name != "implement[1]" {
+		t.Fatalf("instances = %+v, want implement[0] and implement[1]", impl.Children)
+	}
+	items, _ := impl.Output["items"].([]any)
+	if len(items) != 2 || items[0].(map[string]any)["task"] != "parser" || items[1].(map[string]any)["index"] != float64(1) {
+		t.Errorf("items = %v", items)
+	}
+}
+
+// TestForEachInstanceFailure verifies that one failed instance fails the
+// step and skips its dependents, while the other instances still run.
+func TestForEachInstanceFailure(t *testing.T) {
+	ok := map[string]any{"status": "success"}
+	ran := fakeAgents(t, map[string]fakeStep{"fix-0": {output: ok}, "fix-2": {output: ok}})
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "fix", Agent: "worker", ForEach: "['a', 'b', 'c']"},
+		config.StepDef{Name: "report", Agent: "worker", DependsOn: []string{"fix"}},
+	)
+	worker := cfg.Agents["worker"]
+	worker.Workspace = "ro" // instances cannot share a read-write checkout
+	cfg.Agents["worker"] = worker
+
+	pr, err := Execute(context.Background(), cfg, nil, nil, runCfg, discard)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	got := statuses(pr)
+	if got["fix"] != "failure" || got["report"] != "skipped" {
+		t.Errorf("statuses = %v, want fix failed and report skipped", got)
+	}
+	if r := ranSteps(t, ran); len(r) != 3 {
+		t.Errorf("ran %v, want all three instances", r)
+	}
+	if want := "1 of 3 instances failed: fix[1]"; pr.Steps[0].Error != want {
+		t.Errorf("error = %q, want %q", pr.Steps[0].Error, want)
+	}
+}
+
+// TestForEachArtifacts verifies that a step depending on a for_each step is
+// given every instance's artifacts, under the instance index.
+func TestForEachArtifacts(t *testing.T) {
+	ok := map[string]any{"status": "success"}
+	ran := fakeAgents(t, map[string]fakeStep{
+		"fix-0":  {output: ok, artifacts: []string{"report.md"}},
+		"fix-1":  {output: ok, artifacts: []string{"report.md"}},
+		"review": {output: ok},
+	})
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "fix", Agent: "reporter", ForEach: "['a', 'b']"},
+		config.StepDef{Name: "review", Agent: "worker", DependsOn: []string{"fix"}},
+	)
+	cfg.Agents["reporter"] = config.AgentDef{Prompt: config.PromptDef{System: "Fix.\n", Task: "Fix {{.Item}}"},
+		Workspace: "ro", Artifacts: []string{"report.md"}}
+	runCfg.Images["reporter"] = runCfg.Images["worker"]
+	runCfg.ArtifactDir = t.TempDir()
+
+	pr, err := Execute(context.Background(), cfg, nil, nil, runCfg, discard)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if got := statuses(pr); got["fix"] != "success" || got["review"] != "success" {
+		t.Fatalf("statuses = %v, want fix and review to succeed", got)
+	}
+	want := map[string]string{
+		"0/report.md": filepath.Join(runCfg.ArtifactDir, "fix[0]", "report.md"),
+		"1/report.md": filepath.Join(runCfg.ArtifactDir, "fix[1]", "report.md"),
+	}
+	if !maps.Equal(pr.Steps[0].Artifacts, want) {
+		t.Errorf("fix artifacts = %v, want %v", pr.Steps[0].Artifacts, want)
+	}
+	up, err := os.ReadFile(filepath.Join(filepath.Dir(ran), "review.up"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, host := range []string{want["0/report.md"], want["1/report.md"]} {
+		mount := fmt.Sprintf("%s:/upstream/fix/%d/report.md:ro", host, i)
+		if !strings.Contains(string(up), mount) {
+			t.Errorf("review mounts:\n%s\nwant %s", up, mount)
+		}
+	}
+}
+
+// TestContainerUpstreamArtifacts verifies that a container step is given
+// the artifacts of the steps it depends on, and can name them in its
+// command.
+func TestContainerUpstreamArtifacts(t *testing.T) {
+	ok := map[string]any{"status": "success"}
+	ran := fakeAgents(t, map[string]fakeStep{
+		"build": {output: ok, artifacts: []string{"report.md"}},
+		"check": {output: ok},
+	})
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "build", Agent: "builder"},
+		config.StepDef{Name: "check", Kind: config.StepContainer, DependsOn: []string{"build"},
+			Command: `test -f {{index .Artifacts.build "report.md" | shellquote}}`},
+	)
+	cfg.Agents["builder"] = config.AgentDef{Prompt: config.PromptDef{System: "Build.\n", Task: "build"},
+		Workspace: "ro", Artifacts: []string{"report.md"}}
+	runCfg.Images["builder"] = runCfg.Images["worker"]
+	runCfg.ProjectImage = "conductor-test-project:0123456789ab"
+	runCfg.ArtifactDir = t.TempDir()
+
+	pr, err := Execute(context.Background(), cfg, nil, nil, runCfg, discard)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if got := statuses(pr); got["build"] != "success" || got["check"] != "success" {
+		t.Fatalf("statuses = %v, want build and check to succeed", got)
+	}
+	up, err := os.ReadFile(filepath.Join(filepath.Dir(ran), "check.up"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mount := filepath.Join(runCfg.ArtifactDir, "build", "report.md") + ":/upstream/build/report.md:ro"
+	if strings.TrimSpace(string(up)) != mount {
+		t.Errorf("check mounts:\n%s\nwant %s", up, mount)
+	}
+}
+
+// TestSubPipeline verifies that pipeline steps run their named sub-pipeline
+// with bound params, expose its outputs, and nest its step results.
+func TestSubPipeline(t *testing.T) {
+	ran := fakeAgents(t, map[string]fakeStep{"api.review": {output: map[string]any{"status": "success", "verdict": "ok"}}})
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "api", Kind: config.StepPipeline, Pipeline: "verify",
+			With: map[string]string{"target": "api", "review": "yes"}},
+		config.StepDef{Name: "sdk", Kind: config.StepPipeline, Pipeline: "verify",
+			With: map[string]string{"target": "sdk", "review": "no"}},
+		config.StepDef{Name: "report", Kind: config.StepShell, DependsOn: []string{"api", "sdk"},
+			Condition: "steps.api.output.verdict == 'ok' && steps.sdk.output.verdict == 'none'",
+			Command:   "test {{.Steps.sdk.Output.tested | shellquote}} = sdk"},
+	)
+	cfg.Pipelines = map[string]config.PipelineDef{"verify": {
+		Params: []string{"target", "review"},
+		Steps: []config.StepDef{
+			{Name: "test", Kind: config.StepShell, Command: `printf '{"target": "%s"}' {{.Params.target | shellquote}}`},
+			{Name: "review", Agent: "worker", DependsOn: []string{"test"},
+				Condition: "steps.test.output.target == 'api'"},
+		},
+		Outputs: map[string]string{
+			"tested":  "steps.test.output.target",
+			"verdict": "steps.review.status == 'success' ? steps.review.output.verdict : 'none'",
+		},
+	}}
+
+	pr, err := Execute(context.Background(), cfg, nil, nil, runCfg, discard)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if got := statuses(pr); got["api"] != "success" || got["sdk"] != "success" || got["report"] != "success" {
+		t.Fatalf("statuses = %v, want all success", got)
+	}
+	if r := ranSteps(t, ran); len(r) != 1 || r[0] != "api.review" {
+		t.Errorf("docker ran %v, want only api.review", r)
+	}
+	sdk := pr.Steps[1]
+	if len(sdk.Children) != 2 || sdk.Children[0].Name != "sdk.test" || sdk.Children[1].Status != "skipped" {
+		t.Errorf("sdk children = %+v, want sdk.test and a skipped sdk.review", sdk.Children)
+	}
+}
+
+// TestSubPipelineArtifacts verifies that a step depending on a pipeline step
+// is given the artifacts of the sub-pipeline's steps, under the sub-step name.
+func TestSubPipelineArtifacts(t *testing.T) {
+	ok := map[string]any{"status": "success"}
+	ran := fakeAgents(t, map[string]fakeStep{
+		"verify.review": {output: ok, artifacts: []string{"report.md"}},
+		"summary":       {output: ok},
+	})
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "verify", Kind: config.StepPipeline, Pipeline: "checks"},
+		config.StepDef{Name: "summary", Agent: "worker", DependsOn: []string{"verify"}},
+	)
+	cfg.Pipelines = map[string]config.PipelineDef{"checks": {
+		Steps: []config.StepDef{{Name: "review", Agent: "reporter"}},
+	}}
+	cfg.Agents["reporter"] = config.AgentDef{Prompt: config.PromptDef{System: "Review.\n", Task: "Review"},
+		Workspace: "ro", Artifacts: []string{"report.md"}}
+	runCfg.Images["reporter"] = runCfg.Images["worker"]
+	runCfg.ArtifactDir = t.TempDir()
+
+	pr, err := Execute(context.Background(), cfg, nil, nil, runCfg, discard)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if got := statuses(pr); got["verify"] != "success" || got["summary"] != "success" {
+		t.Fatalf("statuses = %v, want verify and summary to succeed", got)
+	}
+	host := filepath.Join(runCfg.ArtifactDir, "verify.review", "report.md")
+	if want := map[string]string{"review/report.md": host}; !maps.Equal(pr.Steps[0].Artifacts, want) {
+		t.Errorf("verify artifacts = %v, want %v", pr.Steps[0].Artifacts, want)
+	}
+	up, err := os.ReadFile(filepath.Join(filepath.Dir(ran), "summary.up"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mount := host + ":/upstream/verify/review/report.md:ro"; !strings.Contains(string(up), mount) {
+		t.Errorf("summary mounts:\n%s\nwant %s", up, mount)
+	}
+}
+
+// TestSubPipelineFailure verifies that a failing sub-step fails the pipeline
+// step and skips the step's dependents.
+func TestSubPipelineFailure(t *testing.T) {
+	fakeAgents(t, nil)
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "verify", Kind: config.StepPipeline, Pipeline: "checks"},
+		config.StepDef{Name: "merge", Kind: config.StepShell, DependsOn: []string{"verify"}, Command: "true"},
+	)
+	cfg.Pipelines = map[string]config.PipelineDef{"checks": {Steps: []config.StepDef{
+		{Name: "lint", Kind: config.StepShell, Command: "true"},
+		{Name: "test", Kind: config.StepShell, Command: "exit 1"},
+	}}}
+
+	pr, err := Execute(context.Background(), cfg, nil, nil, runCfg, discard)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if got := statuses(pr); got["verify"] != "failure" || got["merge"] != "skipped" || pr.Status != "failure" {
+		t.Errorf("statuses = %v, run = %q; want verify failed, merge skipped", got, pr.Status)
+	}
+	if want := "pipeline checks failed: verify.test"; pr.Steps[0].Error != want {
+		t.Errorf("error = %q, want %q", pr.Steps[0].Error, want)
+	}
+}
+
+// TestExecuteInputs verifies that run inputs reach templates, with issue and
+// pr filling IssueNumber and PRNumber.
+func TestExecuteInputs(t *testing.T) {
+	cfg, runCfg := testPipeline(t, config.StepDef{Name: "check", Kind: config.StepShell,
+		Command: "test {{.IssueNumber | shellquote}}-{{.PRNumber | shellquote}}-{{.Inputs.label | shellquote}} = 42-7-bug"})
+	inputs := map[string]string{"issue": "42", "pr": "7", "label": "bug"}
+
+	pr, err := Execute(context.Background(), cfg, nil, inputs, runCfg, discard)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if pr.Status != "success" {
+		t.Errorf("check = %+v, want the inputs rendered", pr.Steps[0])
+	}
+}
+
+// TestExecuteCommandInjection verifies that a hostile input reaches a shell
+// step as a literal argument, and that an unquoted or double-quoted
+// interpolation is refused even without validation.
+func TestExecuteCommandInjection(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "pwned")
+	inputs := map[string]string{"args": "x; touch " + marker, "title": "$(touch " + marker + ")"}
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "quoted", Kind: config.StepShell,
+			Command: `test {{.Inputs.args | shellquote}} = "x; touch ` + marker + `"`},
+		config.StepDef{Name: "unquoted", Kind: config.StepShell, Command: "echo {{.Inputs.args}}"},
+		config.StepDef{Name: "doublequoted", Kind: config.StepShell, Command: `echo "{{.Inputs.title | shellquote}}"`},
+	)
+
+	pr, err := Execute(context.Background(), cfg, nil, inputs, runCfg, discard)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if pr.Steps[0].Status != "success" {
+		t.Errorf("quoted = %+v, want the input passed literally", pr.Steps[0])
+	}
+	if pr.Steps[1].Status != "failure" || !strings.Contains(pr.Steps[1].Error, "must be quoted") {
+		t.Errorf("unquoted = %+v, want refused", pr.Steps[1])
+	}
+	if pr.Steps[2].Status != "failure" || !strings.Contains(pr.Steps[2].Error, "inside shell quotes") {
+		t.Errorf("doublequoted = %+v, want refused", pr.Steps[2])
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("input was executed as a command")
+	}
+}
+
+// TestFR4_ParallelExecution verifies that independent steps run concurrently.
+func TestFR4_ParallelExecution(t *testing.T) {
+	ok := map[string]any{"status": "success"}
+	fakeAgents(t, map[string]fakeStep{
+		"a": {output: ok, sleep: "0.5"},
+		"b": {output: ok, sleep: "0.5"},
+		"c": {output: ok, sleep: "0.5"},
+	})
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "a", Agent: "worker"},
+		config.StepDef{Name: "b", Agent: "worker"},
+		config.StepDef{Name: "c", Agent: "worker"},
+	)
+
+	start := time.Now()
+	if _, err := Execute(context.Background(), cfg, nil, nil, runCfg, discard); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if d := time.Since(start); d > 1400*time.Millisecond {
+		t.Errorf("three 0.5s steps took %v, want them to overlap", d)
+	}
+}
+
+// TestExecuteAgentSlots verifies that per-agent limits serialize agent steps
+// and that queueing time is reported.
+func TestExecuteAgentSlots(t *testing.T) {
+	ok := map[string]any{"status": "success"}
+	fakeAgents(t, map[string]fakeStep{
+		"a": {output: ok, sleep: "0.4"},
+		"b": {output: ok, sleep: "0.4"},
+	})
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "a", Agent: "worker"},
+		config.StepDef{Name: "b", Agent: "worker", Priority: 1},
+	)
+	cfg.Concurrency = config.Concurrency{Agents: map[string]int{"worker": 1}}
+
+	start := time.Now()
+	pr, err := Execute(context.Background(), cfg, nil, nil, runCfg, discard)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if d := time.Since(start); d < 800*time.Millisecond {
+		t.Errorf("two 0.4s steps with one slot took %v, want them serialized", d)
+	}
+	if pr.Steps[0].Queued+pr.Steps[1].Queued < 300*time.Millisecond {
+		t.Errorf("queued = %v and %v, want one step to have waited", pr.Steps[0].Queued, pr.Steps[1].Queued)
+	}
+	if pr.Concurrency.Agents["worker"] != 1 {
+		t.Errorf("result concurrency = %+v", pr.Concurrency)
+	}
+}
+
+// TestStepCache verifies that an unchanged step reuses its cached result,
+// and that a changed repository, cache: false and a refresh rerun it.
+func TestStepCache(t *testing.T) {
+	ran := fakeAgents(t, map[string]fakeStep{
+		"review":   {output: map[string]any{"status": "success"}, cost: 0.5},
+		"uncached": {output: map[string]any{"status": "success"}},
+	})
+	noCache := false
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "review", Agent: "worker"},
+		config.StepDef{Name: "uncached", Agent: "worker", Cache: &noCache},
+	)
+	worker := cfg.Agents["worker"]
+	worker.Workspace = "ro" // see TestStepCacheReplaysChanges for read-write steps
+	cfg.Agents["worker"] = worker
+	repo := gitInit(t, runCfg)
+	runCfg.Cache = state.Open(t.TempDir()).Cache()
+
+	execute := func() *PipelineResult {
+		t.Helper()
+		os.Remove(ran)
+		pr, err := Execute(context.Background(), cfg, nil, nil, runCfg, discard)
+		if err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+		return pr
+	}
+
+	execute()
+	pr := execute()
+	if r := ranSteps(t, ran); len(r) != 1 || r[0] != "uncached" {
+		t.Errorf("second run ran %v, want only the uncached step", r)
+	}
+	if !pr.Steps[0].Cached || pr.Steps[0].Usage.CostUSD != 0 || pr.Steps[0].Output["status"] != "success" {
+		t.Errorf("cached step = %+v", pr.Steps[0])
+	}
+	if pr.Steps[1].Cached {
+		t.Error("step with cache: false was marked cached")
+	}
+
+	os.WriteFile(filepath.Join(repo, "new.go"), []byte("package x\n"), 0644)
+	execute()
+	if r := ranSteps(t, ran); len(r) != 2 {
+		t.Errorf("after a repository change ran %v, want both steps", r)
+	}
+
+	runCfg.Cache.Refresh = true
+	if pr := execute(); pr.Steps[0].Cached {
+		t.Error("refresh returned a cached result")
+	}
+}
+
+// TestStepCacheReplaysChanges verifies that a cached step writing to the
+// workspace has its changes replayed into the checkout and recorded, so that
+// a workspace_from step still sees them.
+func TestStepCacheReplaysChanges(t *testing.T) {
+	ok := map[string]any{"status": "success"}
+	ran := fakeAgents(t, map[string]fakeStep{
+		"implement": {output: ok, edit: true},
+		"review":    {output: ok},
+	})
+	noCache := false
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "implement", Agent: "worker"},
+		config.StepDef{Name: "review", Agent: "reviewer", DependsOn: []string{"implement"}, WorkspaceFrom: "implement",
+			Cache: &noCache},
+	)
+	cfg.Agents["reviewer"] = config.AgentDef{Prompt: config.PromptDef{System: "Review.\n", Task: "review"}, Workspace: "ro"}
+	runCfg.Images["reviewer"] = runCfg.Images["worker"]
+	repo := gitInit(t, runCfg)
+	runCfg.Cache = state.Open(t.TempDir()).Cache()
+
+	for i := range 2 {
+		os.Remove(ran)
+		os.Remove(filepath.Join(repo, "implement.txt"))
+		run := testRun(t)
+		pr, err := Execute(context.Background(), cfg, run, nil, runCfg, discard)
+		if err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+		if pr.Status != "success" || pr.Steps[0].Cached != (i == 1) {
+			t.Fatalf("run %d: steps = %+v", i, pr.Steps)
+		}
+		if got, _ := os.ReadFile(filepath.Join(repo, "implement.txt")); string(got) != "implement\n" {
+			t.Errorf("run %d: implement.txt = %q, want the step's change", i, got)
+		}
+		if _, err := run.Changes("implement"); err != nil {
+			t.Errorf("run %d: %v", i, err)
+		}
+	}
+	if r := ranSteps(t, ran); !slices.Equal(r, []string{"review"}) {
+		t.Errorf("second run ran %v, want only review", r)
+	}
+	ws, _ := os.ReadFile(filepath.Join(filepath.Dir(ran), "review.ws"))
+	if !strings.Contains(string(ws), "implement.txt") {
+		t.Errorf("review workspace = %q, want the replayed implement.txt", ws)
+	}
+}
+
+// gitInit makes the checkout of runCfg's default repository a git
+// repository with one commit, and returns its path.
+func gitInit(t *testing.T, runCfg agent.RunConfig) string {
+	t.Helper()
+	repo := runCfg.Repos[config.DefaultRepo]
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	return repo
+}
+
+// TestWorkspaceFrom verifies that the changes of a read-write step are
+// recorded and that a workspace_from step sees a copy of the repository as
+// that step left it.
+func TestWorkspaceFrom(t *testing.T) {
+	ok := map[string]any{"status": "success"}
+	ran := fakeAgents(t, map[string]fakeStep{
+		"implement": {output: ok, edit: true},
+		"review":    {output: ok},
+	})
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "implement", Agent: "worker"},
+		config.StepDef{Name: "review", Agent: "reviewer", DependsOn: []string{"implement"}, WorkspaceFrom: "implement"},
+	)
+	cfg.Agents["reviewer"] = config.AgentDef{Prompt: config.PromptDef{System: "Review.\n", Task: "review"}, Workspace: "ro"}
+	runCfg.Images["reviewer"] = "conductor-test:0123456789ab"
+	repo := gitInit(t, runCfg)
+	run := testRun(t)
+
+	pr, err := Execute(context.Background(), cfg, run, nil, runCfg, discard)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if pr.Status != "success" {
+		t.Fatalf("status = %q, steps = %+v", pr.Status, pr.Steps)
+	}
+
+	changes, err := run.Changes("implement")
+	if err != nil {
+		t.Fatalf("Changes: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Repo != config.DefaultRepo {
+		t.Fatalf("changes = %+v", changes)
+	}
+	patch, _ := run.Patch("implement", config.DefaultRepo)
+	if !strings.Contains(string(patch), "+++ b/implement.txt") {
+		t.Errorf("patch = %q, want implement.txt added", patch)
+	}
+
+	ws, err := os.ReadFile(filepath.Join(filepath.Dir(ran), "review.ws"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(ws)), "\n")
+	reviewWS := lines[0]
+	if reviewWS == repo {
+		t.Error("review got the live checkout, want a reconstructed copy")
+	}
+	if !slices.Contains(lines[1:], "implement.txt") {
+		t.Errorf("review workspace holds %v, want implement.txt", lines[1:])
+	}
+	if _, err := os.Stat(reviewWS); !os.IsNotExist(err) {
+		t.Errorf("reconstructed workspace %s not removed: %v", reviewWS, err)
+	}
+	if _, err := run.Changes("review"); err == nil {
+		t.Error("read-only review recorded changes")
+	}
+}
+
+// TestExecuteInvalidCondition verifies that a malformed condition fails the
+// run before any step starts.
+func TestExecuteInvalidCondition(t *testing.T) {
+	ran := fakeAgents(t, nil)
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "a", Agent: "worker"},
+		config.StepDef{Name: "b", Agent: "worker", DependsOn: []string{"a"}, Condition: "steps.a.status =="},
+	)
+	if _, err := Execute(context.Background(), cfg, nil, nil, runCfg, discard); err == nil {
+		t.Fatal("Execute returned nil error for malformed condition")
+	}
+	if r := ranSteps(t, ran); len(r) != 0 {
+		t.Errorf("ran %v, want nothing", r)
+	}
+}
+
+// TestExecuteRunBudget verifies that steps fail once the run budget is used
+// up by earlier steps.
+func TestExecuteRunBudget(t *testing.T) {
+	ok := map[string]any{"status": "success"}
+	ran := fakeAgents(t, map[string]fakeStep{
+		"first":  {output: ok, cost: 1.5},
+		"second": {output: ok, cost: 1.5},
+	})
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "first", Agent: "worker"},
+		config.StepDef{Name: "second", Agent: "worker", DependsOn: []string{"first"}},
+	)
+	cfg.Budget = config.Budget{MaxCostUSD: 1}
+
+	pr, err := Execute(context.Background(), cfg, nil, nil, runCfg, discard)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if pr.Steps[0].Status != "failure" || !strings.Contains(pr.Steps[0].Error, "budget exceeded") {
+		t.Errorf("first = %+v, want budget exceeded", pr.Steps[0])
+	}
+	if pr.Steps[1].Status != "skipped" {
+		t.Errorf("second = %+v, want skipped", pr.Steps[1])
+	}
+	if r := ranSteps(t, ran); len(r) != 1 {
+		t.Errorf("ran %v, want only first", r)
+	}
+}
+
+// TestExecuteRunBudgetConcurrent verifies that steps running concurrently,
+// each within what was left of the run budget when it started, are stopped
+// once together they go over it.
+func TestExecuteRunBudgetConcurrent(t *testing.T) {
+	ok := map[string]any{"status": "success"}
+	fakeAgents(t, map[string]fakeStep{
+		"first":  {output: ok, cost: 0.75, sleep: "0.2"},
+		"second": {output: ok, cost: 0.75, sleep: "0.6"},
+	})
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "first", Agent: "worker"},
+		config.StepDef{Name: "second", Agent: "worker"},
+	)
+	cfg.Budget = config.Budget{MaxCostUSD: 1}
+
+	pr, err := Execute(context.Background(), cfg, nil, nil, runCfg, discard)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if pr.Steps[0].Status != "success" {
+		t.Errorf("first = %+v, want success", pr.Steps[0])
+	}
+	if pr.Steps[1].Status != "failure" || !strings.Contains(pr.Steps[1].Error, "run budget exceeded") {
+		t.Errorf("second = %+v, want run budget exceeded", pr.Steps[1])
+	}
+}
+
+// TestStepBudget verifies that an agent budget is tightened to the run's
+// remainder and that an exhausted run budget is an error.
+func TestStepBudget(t *testing.T) {
+	e := &executor{budget: config.Budget{MaxCostUSD: 5, MaxTurns: 10}}
+	e.spent = agent.Usage{CostUSD: 4, Turns: 2}
+
+	got, err := e.stepBudget(config.Budget{MaxCostUSD: 3, MaxTokens: 1000})
+	if err != nil {
+		t.Fatalf("stepBudget: %v", err)
+	}
+	want := config.Budget{MaxCostUSD: 1, MaxTokens: 1000, MaxTurns: 8}
+	if got != want {
+		t.Errorf("stepBudget = %+v, want %+v", got, want)
+	}
+
+	e.spent.CostUSD = 5
+	if _, err := e.stepBudget(config.Budget{}); err == nil || !strings.Contains(err.Error(), "run budget exhausted") {
+		t.Errorf("err = %v, want run budget exhausted", err)
+	}
+}
+
+// TestBuildTemplateData verifies repository fields and PR number extraction.
+func TestBuildTemplateData(t *testing.T) {
+	cfg := &config.Config{
+		Project:  config.Project{Repository: "git@github.com:acme/widgets.git"},
+		Pipeline: []config.StepDef{{Name: "implement"}, {Name: "review"}},
+	}
+	data := buildTemplateData(cfg, cfg.Pipeline, map[string]agent.StepResult{
+		"implement": {Output: map[string]any{"pr_number": float64(42)}},
+	})
+	if data.RepoOwner != "acme" || data.RepoName != "widgets" || data.PRNumber != "42" {
+		t.Errorf("data = %+v, want acme/widgets PR 42", data)
+	}
+
+	owner, name := splitRepoURL("https://github.com/acme/widgets.git")
+	if owner != "acme" || name != "widgets" {
+		t.Errorf("splitRepoURL = %q, %q", owner, name)
+	}
+}
+
+// testRun returns a run record in a temporary state dir with fast polling.
+func testRun(t *testing.T) *state.Run {
+	t.Helper()
+	old := state.PollInterval
+	state.PollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { state.PollInterval = old })
+	run, err := state.Open(t.TempDir()).NewRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return run
+}
+
+// decideWhenRequested waits for the approval request of step and records d.
+func decideWhenRequested(t *testing.T, run *state.Run, step string, d state.Decision) <-chan *state.ApprovalRequest {
+	t.Helper()
+	seen := make(chan *state.ApprovalRequest, 1)
+	go func() {
+		for i := 0; i < 500; i++ {
+			if req, err := run.Approval(step); err == nil {
+				run.Decide(step, d)
+				seen <- req
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		close(seen)
+	}()
+	return seen
+}
+
+// TestApprovalGate verifies that an approval step shows upstream outputs,
+// waits for the decision, and unblocks its dependents when approved, while
+// independent branches run without waiting.
+func TestApprovalGate(t *testing.T) {
+	ok := map[string]any{"status": "success"}
+	ran := fakeAgents(t, map[string]fakeStep{
+		"review": {output: map[string]any{"status": "success", "verdict": "changes_requested"}},
+		"fix":    {output: ok},
+		"docs":   {output: ok},
+	})
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "review", Agent: "worker"},
+		config.StepDef{Name: "gate", Kind: config.StepApproval, DependsOn: []string{"review"},
+			Approval: config.ApprovalDef{Message: "Push the fix?"}},
+		config.StepDef{Name: "fix", Agent: "worker", DependsOn: []string{"gate"},
+			Condition: "steps.gate.output.approved == true"},
+		config.StepDef{Name: "docs", Agent: "worker"},
+	)
+	run := testRun(t)
+	seen := decideWhenRequested(t, run, "gate", state.Decision{Approved: true, By: "alice"})
+
+	pr, err := Execute(context.Background(), cfg, run, nil, runCfg, discard)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	req := <-seen
+	if req == nil || req.Message != "Push the fix?" || req.Upstream["review"]["verdict"] != "changes_requested" {
+		t.Errorf("approval request = %+v, want message and review output", req)
+	}
+	got := statuses(pr)
+	if got["gate"] != "success" || got["fix"] != "success" || pr.Status != "success" {
+		t.Errorf("statuses = %v, want gate and fix success", got)
+	}
+	if r := ranSteps(t, ran); len(r) != 3 {
+		t.Errorf("ran %v, want review, fix and docs", r)
+	}
+}
+
+// TestApprovalGateRejected verifies that a rejection fails the gate and
+// skips its dependents.
+func TestApprovalGateRejected(t *testing.T) {
+	fakeAgents(t, map[string]fakeStep{"review": {output: map[string]any{"status": "success"}}})
+	cfg, runCfg := testPipeline(t,
+		config.StepDef{Name: "review", Agent: "worker"},
+		config.StepDef{Name: "gate", Kind: config.StepApproval, DependsOn: []string{"review"}},
+		config.StepDef{Name: "fix", Agent: "worker", DependsOn: []string{"gate"}},
+	)
+	run := testRun(t)
+	decideWhenRequested(t, run, "gate", state.Decision{Approved: false, By: "bob", Comment: "not yet"})
+
+	pr, err := Execute(context.Background(), cfg, run, nil, runCfg, discard)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	gate := pr.Steps[1]
+	if gate.Status != "failure" || gate.Error != "rejected by bob: not yet" {
+		t.Errorf("gate = %+v, want rejection", gate)
+	}
+	if pr.Steps[2].Status != "skipped" {
+		t.Errorf("fix = %+v, want skipped", pr.Steps[2])
+	}
+}
+
+// TestApprovalGateTimeout verifies that an undecided gate fails after its
+// timeout.
+func TestApprovalGateTimeout(t *testing.T) {
+	fakeAgents(t, nil)
+	cfg, runCfg := testPipeline(t, config.StepDef{Name: "gate", Kind: config.StepApproval,
+		Approval: config.ApprovalDef{Timeout: "50ms"}})
+
+	pr, err := Execute(context.Background(), cfg, testRun(t), nil, runCfg, discard)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if pr.Steps[0].Status != "failure" || !strings.Contains(pr.Steps[0].Error, "no decision within 50ms") {
+		t.Errorf("gate = %+v, want timeout failure", pr.Steps[0])
+	}
+}
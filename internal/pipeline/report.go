@@ -0,0 +1,366 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dmitriyb/conductor/internal/agent"
+)
+
+// Report formats written by Report.Write.
+const (
+	ReportJSON     = "json"     // versioned JSON document, see Report
+	ReportJUnit    = "junit"    // JUnit XML, one testcase per step
+	ReportMarkdown = "markdown" // summary for a pull request comment
+)
+
+// ReportFormats lists the report formats.
+var ReportFormats = []string{ReportJSON, ReportJUnit, ReportMarkdown}
+
+// ReportVersion is the version of the JSON report schema. Fields may be
+// added within a version; removing or changing one bumps it.
+const ReportVersion = 1
+
+// excerptLines and excerptBytes bound the log text quoted for a failed
+// step.
+const (
+	excerptLines = 40
+	excerptBytes = 4 << 10
+)
+
+// markdownLimit is the most a Markdown report may hold, so that it fits in a
+// GitHub comment, whose body is limited to 65,536 characters.
+const markdownLimit = 65536
+
+// Report is the machine-readable record of a run, encoded as the JSON
+// report.
+type Report struct {
+	Version         int               `json:"version"`
+	RunID           string            `json:"run_id,omitempty"`
+	Inputs          map[string]string `json:"inputs,omitempty"`
+	Status          string            `json:"status"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	Usage           ReportUsage       `json:"usage"`
+	Steps           []ReportStep      `json:"steps"`
+}
+
+// ReportStep is the result of one step in a Report.
+type ReportStep struct {
+	Name            string            `json:"name"`
+	Agent           string            `json:"agent,omitempty"`
+	Status          string            `json:"status"`
+	Error           string            `json:"error,omitempty"`
+	Output          map[string]any    `json:"output,omitempty"`
+	Log             string            `json:"log,omitempty"` // path of the full log
+	DurationSeconds float64           `json:"duration_seconds"`
+	QueuedSeconds   float64           `json:"queued_seconds,omitempty"`
+	Cached          bool              `json:"cached,omitempty"`
+	Usage           ReportUsage       `json:"usage"`
+	Artifacts       map[string]string `json:"artifacts,omitempty"`
+	Instances       []ReportStep      `json:"instances,omitempty"` // for_each instances or sub-pipeline steps
+}
+
+// ReportUsage is the token and cost accounting in a Report.
+type ReportUsage struct {
+	InputTokens         int     `json:"input_tokens"`
+	OutputTokens        int     `json:"output_tokens"`
+	CacheCreationTokens int     `json:"cache_creation_tokens"`
+	CacheReadTokens     int     `json:"cache_read_tokens"`
+	CostUSD             float64 `json:"cost_usd"`
+	Turns               int     `json:"turns"`
+}
+
+// NewReport returns the report of result, the run with the given ID and
+// inputs.
+func NewReport(result *PipelineResult, runID string, inputs map[string]string) *Report {
+	return &Report{
+		Version:         ReportVersion,
+		RunID:           runID,
+		Inputs:          inputs,
+		Status:          result.Status,
+		DurationSeconds: result.Duration.Seconds(),
+		Usage:           reportUsage(result.Usage),
+		Steps:           reportSteps(result.Steps),
+	}
+}
+
+func reportSteps(results []agent.StepResult) []ReportStep {
+	steps := make([]ReportStep, 0, len(results))
+	for _, r := range results {
+		steps = append(steps, ReportStep{
+			Name:            r.Name,
+			Agent:           r.Agent,
+			Status:          r.Status,
+			Error:           r.Error,
+			Output:          r.Output,
+			Log:             r.LogPath,
+			DurationSeconds: r.Duration.Seconds(),
+			QueuedSeconds:   r.Queued.Seconds(),
+			Cached:          r.Cached,
+			Usage:           reportUsage(r.Usage),
+			Artifacts:       r.Artifacts,
+			Instances:       reportSteps(r.Children),
+		})
+	}
+	return steps
+}
+
+func reportUsage(u agent.Usage) ReportUsage {
+	return ReportUsage{InputTokens: u.InputTokens, OutputTokens: u.OutputTokens,
+		CacheCreationTokens: u.CacheCreationTokens, CacheReadTokens: u.CacheReadTokens,
+		CostUSD: u.CostUSD, Turns: u.Turns}
+}
+
+// Tokens returns the total number of tokens processed, cache tokens included.
+func (u ReportUsage) Tokens() int {
+	return u.InputTokens + u.OutputTokens + u.CacheCreationTokens + u.CacheReadTokens
+}
+
+// Write writes the report to w in the given format.
+func (rep *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case ReportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	case ReportJUnit:
+		return rep.writeJUnit(w)
+	case ReportMarkdown:
+		return rep.writeMarkdown(w)
+	}
+	return fmt.Errorf("unknown report format %q (want %s)", format, strings.Join(ReportFormats, ", "))
+}
+
+// flatten returns steps followed, each, by their instances.
+func flatten(steps []ReportStep) []ReportStep {
+	var out []ReportStep
+	for _, s := range steps {
+		out = append(out, s)
+		out = append(out, flatten(s.Instances)...)
+	}
+	return out
+}
+
+// junitSuites is the root of a JUnit XML report.
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitCase     `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// writeJUnit writes the report as JUnit XML: one testsuite for the run and
+// one testcase per step and instance. Failures quote the end of the step's
+// log.
+func (rep *Report) writeJUnit(w io.Writer) error {
+	suite := junitSuite{Name: "conductor", Time: seconds(rep.DurationSeconds)}
+	if rep.RunID != "" {
+		suite.Name += " run " + rep.RunID
+	}
+	for _, k := range slices.Sorted(maps.Keys(rep.Inputs)) {
+		suite.Properties = append(suite.Properties, junitProperty{Name: "input." + k, Value: rep.Inputs[k]})
+	}
+	for _, s := range flatten(rep.Steps) {
+		c := junitCase{Name: s.Name, Classname: "conductor", Time: seconds(s.DurationSeconds)}
+		if s.Agent != "" {
+			c.Classname += "." + s.Agent
+		}
+		switch s.Status {
+		case "failure":
+			suite.Failures++
+			body := s.Error
+			if excerpt := logExcerpt(s.Log); excerpt != "" {
+				body += "\n\n" + excerpt
+			}
+			c.Failure = &junitMessage{Message: s.Error, Body: body}
+		case "skipped":
+			suite.Skipped++
+			c.Skipped = &junitMessage{Message: s.Error}
+		}
+		suite.Tests++
+		suite.Cases = append(suite.Cases, c)
+	}
+	doc := junitSuites{Name: "conductor", Tests: suite.Tests, Failures: suite.Failures, Skipped: suite.Skipped,
+		Time: suite.Time, Suites: []junitSuite{suite}}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// seconds formats a duration in seconds as JUnit times are written.
+func seconds(s float64) string {
+	return fmt.Sprintf("%.3f", s)
+}
+
+// logExcerpt returns the end of the log at path, or "" if there is none:
+// the last lines of the session's text for an agent's stream-json log, of
+// the output for other logs, cut to at most excerptBytes.
+func logExcerpt(path string) string {
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if text := agent.LogText(line); text != "" {
+			lines = append(lines, strings.Split(strings.TrimRight(text, "\n"), "\n")...)
+		}
+	}
+	excerpt := strings.Join(lines[max(len(lines)-excerptLines, 0):], "\n")
+	if len(excerpt) <= excerptBytes {
+		return excerpt
+	}
+	// Keep whole lines where the cut falls short of the last one, and
+	// otherwise cut the last line on a character boundary.
+	cut := len(excerpt) - excerptBytes
+	if i := strings.IndexByte(excerpt[cut:], '\n'); i >= 0 {
+		return excerpt[cut+i+1:]
+	}
+	for !utf8.RuneStart(excerpt[cut]) {
+		cut++
+	}
+	return excerpt[cut:]
+}
+
+// statusMarks marks statuses in the Markdown report.
+var statusMarks = map[string]string{"success": "✅", "failure": "❌", "skipped": "⏭️", "cancelled": "🛑"}
+
+// writeMarkdown writes the report as a Markdown summary: a heading with the
+// status, a table of the steps, and the errors and log excerpts of failed
+// steps folded away.
+func (rep *Report) writeMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s Pipeline %s", statusMarks[rep.Status], rep.Status)
+	if rep.RunID != "" {
+		fmt.Fprintf(&b, " · run `%s`", rep.RunID)
+	}
+	b.WriteString("\n\n")
+	if len(rep.Inputs) > 0 {
+		var pairs []string
+		for _, k := range slices.Sorted(maps.Keys(rep.Inputs)) {
+			pairs = append(pairs, fmt.Sprintf("`%s=%s`", k, rep.Inputs[k]))
+		}
+		fmt.Fprintf(&b, "Inputs: %s\n\n", strings.Join(pairs, " "))
+	}
+
+	b.WriteString("| Step | Status | Duration | Tokens | Cost | Note |\n")
+	b.WriteString("|---|---|---|---:|---:|---|\n")
+	var failed []ReportStep
+	var row func(s ReportStep, depth int)
+	row = func(s ReportStep, depth int) {
+		name := s.Name
+		if depth > 0 {
+			name = strings.Repeat("&nbsp;&nbsp;", depth) + "↳ " + name
+		}
+		note := s.Error
+		if s.Cached {
+			note = "cached"
+		}
+		tokens, cost := "", ""
+		if s.Usage != (ReportUsage{}) {
+			tokens, cost = fmt.Sprint(s.Usage.Tokens()), fmt.Sprintf("$%.4f", s.Usage.CostUSD)
+		}
+		fmt.Fprintf(&b, "| %s | %s %s | %s | %s | %s | %s |\n", cell(name), statusMarks[s.Status], s.Status,
+			duration(s.DurationSeconds), tokens, cost, cell(note))
+		if s.Status == "failure" && len(s.Instances) == 0 {
+			failed = append(failed, s)
+		}
+		for _, c := range s.Instances {
+			row(c, depth+1)
+		}
+	}
+	for _, s := range rep.Steps {
+		row(s, 0)
+	}
+	fmt.Fprintf(&b, "\n**Duration** %s · **Usage** %d tokens, $%.4f, %d turns\n",
+		duration(rep.DurationSeconds), rep.Usage.Tokens(), rep.Usage.CostUSD, rep.Usage.Turns)
+
+	for i, s := range failed {
+		var d strings.Builder
+		fmt.Fprintf(&d, "\n<details><summary>❌ %s</summary>\n\n", html(s.Name))
+		if s.Error != "" {
+			fmt.Fprintf(&d, "%s\n\n", s.Error)
+		}
+		if excerpt := logExcerpt(s.Log); excerpt != "" {
+			fence := "```"
+			for strings.Contains(excerpt, fence) {
+				fence += "`"
+			}
+			fmt.Fprintf(&d, "%s\n%s\n%s\n\n", fence, excerpt, fence)
+		}
+		d.WriteString("</details>\n")
+		// Leave room for the note on the failures left out.
+		if b.Len()+d.Len() > markdownLimit-100 {
+			fmt.Fprintf(&b, "\n_%d of %d failed steps shown; see the logs for the rest._\n", i, len(failed))
+			break
+		}
+		b.WriteString(d.String())
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// duration formats seconds as a rounded duration, e.g. 1m35s.
+func duration(s float64) string {
+	return (time.Duration(s * float64(time.Second))).Round(time.Second).String()
+}
+
+// cell escapes text for a Markdown table cell.
+func cell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// html escapes text for inline HTML.
+func html(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
@@ -0,0 +1,207 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dmitriyb/conductor/internal/agent"
+)
+
+// testReport returns the report of a failed run with a for_each step, a
+// skipped step and a failed step with a log.
+func testReport(t *testing.T) *Report {
+	t.Helper()
+	log := filepath.Join(t.TempDir(), "review.log")
+	var lines []string
+	for i := range 50 {
+		lines = append(lines, fmt.Sprintf("log line %d", i))
+	}
+	if err := os.WriteFile(log, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	result := &PipelineResult{
+		Steps: []agent.StepResult{
+			{Name: "implement", Agent: "coder", Status: "success", Duration: 90 * time.Second,
+				Usage: agent.Usage{InputTokens: 1000, OutputTokens: 200, CostUSD: 0.42, Turns: 7},
+				Children: []agent.StepResult{
+					{Name: "implement[0]", Agent: "coder", Status: "success", Output: map[string]any{"pr": "12"}},
+				}},
+			{Name: "review", Agent: "reviewer", Status: "failure", Error: "tests | lint failed", LogPath: log,
+				Duration: 1500 * time.Millisecond},
+			{Name: "deploy", Status: "skipped", Error: "dependency review failed"},
+		},
+		Status:   "failure",
+		Duration: 95 * time.Second,
+		Usage:    agent.Usage{InputTokens: 1000, OutputTokens: 200, CostUSD: 0.42, Turns: 7},
+	}
+	return NewReport(result, "20260301-120000-abcdef", map[string]string{"issue": "7"})
+}
+
+// TestReportJSON verifies the versioned JSON schema.
+func TestReportJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport(t).Write(&buf, ReportJSON); err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("report is not JSON: %v\n%s", err, buf.String())
+	}
+	if doc["version"] != float64(ReportVersion) || doc["run_id"] != "20260301-120000-abcdef" ||
+		doc["status"] != "failure" || doc["duration_seconds"] != 95.0 {
+		t.Errorf("report = %v", doc)
+	}
+	if usage := doc["usage"].(map[string]any); usage["input_tokens"] != 1000.0 || usage["cost_usd"] != 0.42 {
+		t.Errorf("usage = %v", usage)
+	}
+	steps := doc["steps"].([]any)
+	implement := steps[0].(map[string]any)
+	instance := implement["instances"].([]any)[0].(map[string]any)
+	if implement["duration_seconds"] != 90.0 || instance["output"].(map[string]any)["pr"] != "12" {
+		t.Errorf("implement = %v", implement)
+	}
+	if review := steps[1].(map[string]any); review["error"] != "tests | lint failed" || review["log"] == nil {
+		t.Errorf("review = %v", review)
+	}
+	if _, ok := steps[2].(map[string]any)["instances"]; ok {
+		t.Errorf("deploy lists instances: %v", steps[2])
+	}
+}
+
+// TestReportJUnit verifies one testcase per step and instance, with
+// failures quoting the end of the log.
+func TestReportJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport(t).Write(&buf, ReportJUnit); err != nil {
+		t.Fatal(err)
+	}
+	var doc junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("report is not XML: %v\n%s", err, buf.String())
+	}
+	if doc.Tests != 4 || doc.Failures != 1 || doc.Skipped != 1 || len(doc.Suites) != 1 {
+		t.Fatalf("suites = %+v", doc)
+	}
+	suite := doc.Suites[0]
+	if suite.Name != "conductor run 20260301-120000-abcdef" || suite.Time != "95.000" ||
+		len(suite.Properties) != 1 || suite.Properties[0] != (junitProperty{"input.issue", "7"}) {
+		t.Errorf("suite = %+v", suite)
+	}
+	var names []string
+	for _, c := range suite.Cases {
+		names = append(names, c.Name)
+	}
+	if fmt.Sprint(names) != "[implement implement[0] review deploy]" {
+		t.Errorf("testcases = %v", names)
+	}
+	review := suite.Cases[2]
+	if review.Classname != "conductor.reviewer" || review.Time != "1.500" || review.Failure == nil {
+		t.Fatalf("review = %+v", review)
+	}
+	if body := review.Failure.Body; !strings.HasPrefix(body, "tests | lint failed\n\nlog line 10\n") ||
+		!strings.HasSuffix(body, "log line 49") {
+		t.Errorf("failure body = %q, want the error and the last %d log lines", body, excerptLines)
+	}
+	if deploy := suite.Cases[3]; deploy.Skipped == nil || deploy.Skipped.Message != "dependency review failed" {
+		t.Errorf("deploy = %+v", deploy)
+	}
+}
+
+// TestReportMarkdown verifies the heading, the step table and the folded
+// failure details.
+func TestReportMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport(t).Write(&buf, ReportMarkdown); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"### ❌ Pipeline failure · run `20260301-120000-abcdef`\n",
+		"Inputs: `issue=7`\n",
+		"| implement | ✅ success | 1m30s | 1200 | $0.4200 |  |\n",
+		"| &nbsp;&nbsp;↳ implement[0] | ✅ success | 0s |  |  |  |\n",
+		"| review | ❌ failure | 2s |  |  | tests \\| lint failed |\n",
+		"**Duration** 1m35s · **Usage** 1200 tokens, $0.4200, 7 turns\n",
+		"<details><summary>❌ review</summary>\n\ntests | lint failed\n\n```\nlog line 10\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "log line 9\n") {
+		t.Errorf("report quotes more than %d log lines", excerptLines)
+	}
+}
+
+// TestLogExcerpt verifies that an agent's log is quoted as the session's
+// text and that excerpts are cut to excerptBytes on a character boundary.
+func TestLogExcerpt(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	session := write("session.log", strings.Join([]string{
+		`{"type":"system","subtype":"init","model":"claude"}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"running the tests"},{"type":"tool_use","name":"Bash","input":{"command":"make test"}}]}}`,
+		`{"type":"user","message":{"content":[{"type":"tool_result","content":"FAIL"}]}}`,
+		`{"type":"result","subtype":"success","result":"tests fail\nin parser_test.go"}`,
+	}, "\n")+"\n")
+	if got, want := logExcerpt(session), "running the tests\ntests fail\nin parser_test.go"; got != want {
+		t.Errorf("session excerpt = %q, want %q", got, want)
+	}
+
+	lines := write("lines.log", strings.Repeat(strings.Repeat("x", 199)+"\n", excerptLines))
+	if got := logExcerpt(lines); len(got) > excerptBytes || !strings.HasPrefix(got, "xxx") {
+		t.Errorf("excerpt of long lines is %d bytes, want at most %d in whole lines", len(got), excerptBytes)
+	}
+
+	long := write("long.log", strings.Repeat("é", excerptBytes))
+	if got := logExcerpt(long); len(got) > excerptBytes || !utf8.ValidString(got) {
+		t.Errorf("excerpt of a long line is %d bytes, valid UTF-8 %v", len(got), utf8.ValidString(got))
+	}
+}
+
+// TestReportMarkdownLimit verifies that the Markdown report of many failed
+// steps fits in a GitHub comment.
+func TestReportMarkdownLimit(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "step.log")
+	if err := os.WriteFile(log, []byte(strings.Repeat(strings.Repeat("x", 199)+"\n", excerptLines)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	result := &PipelineResult{Status: "failure"}
+	for i := range 40 {
+		result.Steps = append(result.Steps, agent.StepResult{Name: fmt.Sprintf("step%d", i), Status: "failure",
+			Error: "failed", LogPath: log})
+	}
+	var buf bytes.Buffer
+	if err := NewReport(result, "", nil).Write(&buf, ReportMarkdown); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if len(out) > markdownLimit {
+		t.Errorf("report is %d bytes, want at most %d", len(out), markdownLimit)
+	}
+	if !strings.Contains(out, "failed steps shown; see the logs for the rest.") {
+		t.Errorf("report does not note the failures left out:\n%s", out[len(out)-200:])
+	}
+}
+
+// TestReportFormat verifies that unknown formats are refused.
+func TestReportFormat(t *testing.T) {
+	if err := testReport(t).Write(&bytes.Buffer{}, "html"); err == nil {
+		t.Error("Write in format html returned nil error")
+	}
+}
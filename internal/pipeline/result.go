@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dmitriyb/conductor/internal/agent"
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// PipelineResult aggregates the step results of a run.
+type PipelineResult struct {
+	Steps    []agent.StepResult // in config order
+	Status   string             // "success", "failure" if a step failed, or "cancelled"
+	Duration time.Duration
+	Usage    agent.Usage // summed over all steps
+
+	Concurrency config.Concurrency // agent slot limits the run was held to
+}
+
+// Print writes a human-readable summary of the run to w.
+func (r *PipelineResult) Print(w io.Writer) {
+	fmt.Fprintln(w, "\n============================================")
+	fmt.Fprintln(w, "  Pipeline Summary")
+	fmt.Fprintln(w, "============================================")
+	for _, s := range r.Steps {
+		printStep(w, s, "")
+	}
+	fmt.Fprintf(w, "\n  Status:   %s\n  Duration: %s\n", r.Status, r.Duration.Round(time.Second))
+	fmt.Fprintf(w, "  Usage:    %s\n", formatUsage(r.Usage))
+	if limits := formatLimits(r.Concurrency); limits != "" {
+		fmt.Fprintf(w, "  Slots:    %s\n", limits)
+	}
+	fmt.Fprintln(w, "============================================")
+}
+
+// printStep writes one summary line for s, followed by its instances
+// indented below it.
+func printStep(w io.Writer, s agent.StepResult, indent string) {
+	var note string
+	if s.Cached {
+		note = "  cached"
+	}
+	if q := s.Queued.Round(time.Second); q > 0 {
+		note += fmt.Sprintf("  queued %s", q)
+	}
+	if s.Usage == (agent.Usage{}) {
+		fmt.Fprintf(w, "  %-20s %s%s\n", indent+s.Name, s.Status, note)
+	} else {
+		fmt.Fprintf(w, "  %-20s %-8s  %s%s\n", indent+s.Name, s.Status, formatUsage(s.Usage), note)
+	}
+	for _, c := range s.Children {
+		printStep(w, c, indent+"  ")
+	}
+}
+
+// formatLimits renders the configured agent slot limits, or "" if there are
+// none.
+func formatLimits(c config.Concurrency) string {
+	var parts []string
+	if c.MaxAgents > 0 {
+		parts = append(parts, fmt.Sprintf("%d agents", c.MaxAgents))
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Agents)) {
+		parts = append(parts, fmt.Sprintf("%s %d", name, c.Agents[name]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatUsage renders usage as tokens, cost and turns.
+func formatUsage(u agent.Usage) string {
+	return fmt.Sprintf("%d tokens  $%.4f  %d turns", u.Tokens(), u.CostUSD, u.Turns)
+}
@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmitriyb/conductor/internal/agent"
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// TestFR8_PrintSummary verifies the summary content, including usage.
+func TestFR8_PrintSummary(t *testing.T) {
+	r := &PipelineResult{
+		Steps: []agent.StepResult{
+			{Name: "implement", Status: "success", Usage: agent.Usage{InputTokens: 1000, OutputTokens: 200, CostUSD: 0.42, Turns: 7}},
+			{Name: "fix", Status: "skipped"},
+		},
+		Status:   "success",
+		Duration: 95 * time.Second,
+		Usage:    agent.Usage{InputTokens: 1000, OutputTokens: 200, CostUSD: 0.42, Turns: 7},
+	}
+	var buf bytes.Buffer
+	r.Print(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		"implement            success   1200 tokens  $0.4200  7 turns",
+		"fix                  skipped\n",
+		"Status:   success",
+		"Duration: 1m35s",
+		"Usage:    1200 tokens  $0.4200  7 turns",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("summary missing %q:\n%s", want, out)
+		}
+	}
+}
+
+// TestPrintSummaryInstances verifies that for_each instances are listed
+// indented below their step.
+func TestPrintSummaryInstances(t *testing.T) {
+	r := &PipelineResult{
+		Steps: []agent.StepResult{{Name: "implement", Status: "failure", Children: []agent.StepResult{
+			{Name: "implement[0]", Status: "success"},
+			{Name: "implement[1]", Status: "failure"},
+		}}},
+		Status: "failure",
+	}
+	var buf bytes.Buffer
+	r.Print(&buf)
+	want := "  implement            failure\n" +
+		"    implement[0]       success\n" +
+		"    implement[1]       failure\n"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("summary = %q, want it to contain %q", buf.String(), want)
+	}
+}
+
+// TestPrintSummarySlots verifies that queueing time, cached results and slot
+// limits are reported.
+func TestPrintSummarySlots(t *testing.T) {
+	r := &PipelineResult{
+		Steps: []agent.StepResult{
+			{Name: "implement", Status: "success", Queued: 75 * time.Second},
+			{Name: "review", Status: "success", Cached: true},
+		},
+		Status:      "success",
+		Concurrency: config.Concurrency{MaxAgents: 4, Agents: map[string]int{"reviewer": 1, "implementer": 2}},
+	}
+	var buf bytes.Buffer
+	r.Print(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		"implement            success  queued 1m15s\n",
+		"review               success  cached\n",
+		"Slots:    4 agents, implementer 2, reviewer 1\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("summary missing %q:\n%s", want, out)
+		}
+	}
+	r.Concurrency = config.Concurrency{}
+	buf.Reset()
+	r.Print(&buf)
+	if strings.Contains(buf.String(), "Slots:") {
+		t.Errorf("summary reports slots without limits:\n%s", buf.String())
+	}
+}
+
+// TestNFR1_PrintDeterministic verifies identical output for identical input.
+func TestNFR1_PrintDeterministic(t *testing.T) {
+	r := &PipelineResult{
+		Steps:  []agent.StepResult{{Name: "a", Status: "success"}, {Name: "b", Status: "failure"}},
+		Status: "failure",
+	}
+	var first, second bytes.Buffer
+	r.Print(&first)
+	r.Print(&second)
+	if first.String() != second.String() {
+		t.Error("Print output differs between calls")
+	}
+}
@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+
+	"github.com/dmitriyb/conductor/internal/agent"
+	"github.com/dmitriyb/conductor/internal/infra"
+	"github.com/dmitriyb/conductor/internal/state"
+)
+
+// stepConfig returns the RunConfig a step runs with. For workspace_from, the
+// repositories changed by that step are replaced by copies checked out at
+// the snapshot recorded when it finished; cleanup removes the copies.
+func (e *executor) stepConfig(ctx context.Context, node *Node) (runCfg agent.RunConfig, cleanup func(), err error) {
+	runCfg, cleanup = e.runCfg, func() {}
+	from := node.Step.WorkspaceFrom
+	if from == "" {
+		return runCfg, cleanup, nil
+	}
+	if e.run == nil {
+		return runCfg, cleanup, fmt.Errorf("workspace_from needs a run record")
+	}
+	changes, err := e.run.Changes(e.prefix + from)
+	if err != nil {
+		return runCfg, cleanup, err
+	}
+	dir, err := os.MkdirTemp("", "conductor-workspace-")
+	if err != nil {
+		return runCfg, cleanup, fmt.Errorf("create workspace: %w", err)
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+	runCfg.Repos = maps.Clone(e.runCfg.Repos)
+	for _, c := range changes {
+		dst := filepath.Join(dir, c.Repo)
+		if err := infra.CheckoutCopy(ctx, e.runCfg.Repos[c.Repo], dst, c.Snapshot); err != nil {
+			cleanup()
+			return e.runCfg, func() {}, fmt.Errorf("workspace of %s: %w", from, err)
+		}
+		runCfg.Repos[c.Repo] = dst
+	}
+	e.logger.Info("reconstructed workspace", "step", e.prefix+node.Name, "from", e.prefix+from)
+	return runCfg, cleanup, nil
+}
+
+// snapshotRepos snapshots the repositories the step mounts read-write before
+// it runs, so recordChanges can tell what it did. It returns the snapshots by
+// repository, or nil when there is neither a run record nor a cache entry to
+// keep changes in.
+func (e *executor) snapshotRepos(ctx context.Context, node *Node, runCfg agent.RunConfig) map[string]string {
+	if e.run == nil && (runCfg.Cache == nil || !node.Step.Cacheable()) {
+		return nil
+	}
+	var bases map[string]string
+	for _, m := range node.Step.RepoMounts(e.cfg) {
+		if m.Mode != "rw" {
+			continue
+		}
+		base, err := infra.Snapshot(ctx, runCfg.Repos[m.Repo])
+		if err != nil {
+			e.logger.Warn("not recording changes", "step", e.prefix+node.Name, "repo", m.Repo, "error", err)
+			continue
+		}
+		if bases == nil {
+			bases = map[string]string{}
+		}
+		bases[m.Repo] = base
+	}
+	return bases
+}
+
+// recordChanges records in the run record, if any, the commits and patch
+// step name made to each repository in bases, and returns the patches by
+// repository. Failures are logged, not returned: the step's own result
+// stands either way, though a repository missing from the patches keeps
+// the result out of the cache.
+func (e *executor) recordChanges(ctx context.Context, name string, bases map[string]string,
+	runCfg agent.RunConfig) map[string][]byte {
+	ctx = context.WithoutCancel(ctx) // a cancelled step's changes are still worth keeping
+	patches := make(map[string][]byte, len(bases))
+	for repo, base := range bases {
+		err := func() error {
+			dir := runCfg.Repos[repo]
+			after, err := infra.Snapshot(ctx, dir)
+			if err != nil {
+				return err
+			}
+			commits, err := infra.Commits(ctx, dir, base+"^", after+"^")
+			if err != nil {
+				return err
+			}
+			patch, err := infra.Diff(ctx, dir, base, after)
+			if err != nil {
+				return err
+			}
+			patches[repo] = patch
+			if e.run == nil {
+				return nil
+			}
+			return e.run.RecordChange(name, state.Change{Repo: repo, Base: base, Snapshot: after, Commits: commits}, patch)
+		}()
+		if err != nil {
+			e.logger.Warn("recording changes failed", "step", name, "repo", repo, "error", err)
+		}
+	}
+	return patches
+}
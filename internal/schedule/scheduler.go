@@ -0,0 +1,185 @@
+// Package schedule starts pipeline runs on the timetables of the config's
+// schedules and keeps each schedule's history in the state store.
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dmitriyb/conductor/internal/config"
+	"github.com/dmitriyb/conductor/internal/cron"
+	"github.com/dmitriyb/conductor/internal/state"
+)
+
+// Executor executes the run of schedule name and returns the run's status
+// once it has finished.
+type Executor func(ctx context.Context, name string, def config.ScheduleDef, run *state.Run) (status string, err error)
+
+// Scheduler starts a run whenever the cron of one of its schedules comes
+// due, applying the schedules' overlap and missed-run policies.
+type Scheduler struct {
+	Schedules map[string]config.ScheduleDef
+	Store     *state.Store
+	Execute   Executor
+	Logger    *slog.Logger
+
+	// Now and After stand in for time.Now and time.After, which they
+	// default to.
+	Now   func() time.Time
+	After func(time.Duration) <-chan time.Time
+
+	mu      sync.Mutex
+	running map[string]int // schedule → runs in progress
+	wg      sync.WaitGroup
+}
+
+// Run handles the activations missed since each schedule's last recorded
+// one, then starts runs as the schedules come due until ctx is done. It
+// returns once the runs in progress have finished.
+func (s *Scheduler) Run(ctx context.Context) error {
+	if s.Now == nil {
+		s.Now = time.Now
+	}
+	if s.After == nil {
+		s.After = time.After
+	}
+	s.running = map[string]int{}
+	defer s.wg.Wait()
+
+	names := slices.Sorted(maps.Keys(s.Schedules))
+	exprs := map[string]*cron.Expr{}
+	next := map[string]time.Time{}
+	now := s.Now()
+	for _, name := range names {
+		def := s.Schedules[name]
+		expr, err := cron.Parse(def.Cron)
+		if err != nil {
+			return fmt.Errorf("schedule %s: %w", name, err)
+		}
+		exprs[name] = expr
+		if err := s.catchUp(ctx, name, def, expr, now); err != nil {
+			return err
+		}
+		next[name] = expr.Next(now.In(def.Location()))
+		s.Logger.Info("scheduled", "schedule", name, "cron", def.Cron, "next", next[name])
+	}
+
+	for {
+		var due time.Time
+		for _, t := range next {
+			if !t.IsZero() && (due.IsZero() || t.Before(due)) {
+				due = t
+			}
+		}
+		var wake <-chan time.Time // nil, so never, when nothing is due again
+		if !due.IsZero() {
+			wake = s.After(due.Sub(s.Now()))
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-wake:
+		}
+		now := s.Now()
+		for _, name := range names {
+			if t := next[name]; t.IsZero() || t.After(now) {
+				continue
+			}
+			def := s.Schedules[name]
+			s.fire(ctx, name, def, next[name])
+			next[name] = exprs[name].Next(now.In(def.Location()))
+		}
+	}
+}
+
+// catchUp applies the missed-run policy of the schedule to the activations
+// between its last recorded one and now. A schedule without history has
+// missed nothing.
+func (s *Scheduler) catchUp(ctx context.Context, name string, def config.ScheduleDef, expr *cron.Expr, now time.Time) error {
+	history, err := s.Store.Activations(name)
+	if err != nil {
+		return err
+	}
+	var last time.Time
+	for _, a := range history {
+		if a.Scheduled.After(last) {
+			last = a.Scheduled
+		}
+	}
+	if last.IsZero() {
+		return nil
+	}
+	var missed int
+	var latest time.Time
+	for t := expr.Next(last.In(def.Location())); !t.IsZero() && !t.After(now); t = expr.Next(t) {
+		missed++
+		latest = t
+	}
+	if missed == 0 {
+		return nil
+	}
+	s.Logger.Warn("activations missed", "schedule", name, "count", missed, "since", last, "policy", def.MissedPolicy())
+	if def.MissedPolicy() == config.MissedRunOnce {
+		s.fire(ctx, name, def, latest)
+		return nil
+	}
+	s.record(name, state.Activation{Scheduled: latest, Status: state.ActivationMissed,
+		Reason: fmt.Sprintf("%d activations since %s passed while no scheduler was running", missed, last.Format(time.RFC3339))})
+	return nil
+}
+
+// fire starts the run of an activation, unless the overlap policy skips it.
+func (s *Scheduler) fire(ctx context.Context, name string, def config.ScheduleDef, at time.Time) {
+	s.mu.Lock()
+	if s.running[name] > 0 && def.OverlapPolicy() == config.OverlapSkip {
+		s.mu.Unlock()
+		s.Logger.Warn("activation skipped, previous run still running", "schedule", name, "scheduled", at)
+		s.record(name, state.Activation{Scheduled: at, Status: state.ActivationSkipped, Reason: "previous run still running"})
+		return
+	}
+	s.running[name]++
+	s.mu.Unlock()
+	done := func() {
+		s.mu.Lock()
+		s.running[name]--
+		s.mu.Unlock()
+	}
+
+	run, err := s.Store.NewRun()
+	if err != nil {
+		done()
+		s.Logger.Error("scheduled run failed to start", "schedule", name, "error", err)
+		s.record(name, state.Activation{Scheduled: at, Status: state.ActivationError, Reason: err.Error()})
+		return
+	}
+	s.Logger.Info("scheduled run", "schedule", name, "scheduled", at, "run_id", run.ID)
+	s.record(name, state.Activation{Scheduled: at, RunID: run.ID, Status: state.ActivationRunning})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer done()
+		status, err := s.Execute(ctx, name, def, run)
+		a := state.Activation{Scheduled: at, RunID: run.ID, Status: status}
+		if err != nil {
+			s.Logger.Error("scheduled run failed", "schedule", name, "run_id", run.ID, "error", err)
+			a.Status, a.Reason = state.ActivationError, err.Error()
+		} else {
+			s.Logger.Info("scheduled run finished", "schedule", name, "run_id", run.ID, "status", status)
+		}
+		s.record(name, a)
+	}()
+}
+
+// record appends a to the schedule's history. Failures are logged: a lost
+// entry must not stop the scheduler.
+func (s *Scheduler) record(name string, a state.Activation) {
+	a.Recorded = s.Now()
+	if err := s.Store.RecordActivation(name, a); err != nil {
+		s.Logger.Error("recording activation failed", "schedule", name, "error", err)
+	}
+}
@@ -0,0 +1,206 @@
+package schedule
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dmitriyb/conductor/internal/config"
+	"github.com/dmitriyb/conductor/internal/state"
+)
+
+// fakeClock jumps to each wake-up time at once. After the given number of
+// wake-ups it never wakes again and closes idle.
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	wakes int
+	idle  chan struct{}
+}
+
+func newFakeClock(now time.Time, wakes int) *fakeClock {
+	return &fakeClock{now: now, wakes: wakes, idle: make(chan struct{})}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.wakes == 0 {
+		close(c.idle)
+		c.wakes--
+		return nil
+	}
+	if c.wakes < 0 {
+		return nil
+	}
+	c.wakes--
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// runScheduler runs a scheduler over schedules until the clock is idle and
+// the runs are done. With settle, the clock only moves on once the runs
+// started so far have finished.
+func runScheduler(t *testing.T, store *state.Store, clock *fakeClock, settle bool,
+	schedules map[string]config.ScheduleDef, execute Executor) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{Schedules: schedules, Store: store, Execute: execute,
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), Now: clock.Now, After: clock.After}
+	if settle {
+		s.After = func(d time.Duration) <-chan time.Time {
+			for s.busy() {
+				time.Sleep(time.Millisecond)
+			}
+			return clock.After(d)
+		}
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- s.Run(ctx) }()
+	<-clock.idle
+	cancel()
+	if err := <-errc; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+}
+
+// history returns the activations of a schedule as "01-02 15:04 status" strings.
+func history(t *testing.T, store *state.Store, name string) []string {
+	t.Helper()
+	h, err := store.Activations(name)
+	if err != nil {
+		t.Fatalf("Activations: %v", err)
+	}
+	var got []string
+	for _, a := range h {
+		got = append(got, a.Scheduled.UTC().Format("01-02 15:04")+" "+a.Status)
+	}
+	return got
+}
+
+// busy reports whether any run is in progress.
+func (s *Scheduler) busy() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, n := range s.running {
+		if n > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestSchedulerRuns verifies that runs start when the cron comes due, in the
+// schedule's time zone, and that their outcome is recorded.
+func TestSchedulerRuns(t *testing.T) {
+	store := state.Open(t.TempDir())
+	clock := newFakeClock(time.Date(2026, 3, 4, 10, 30, 0, 0, time.UTC), 3)
+	var mu sync.Mutex
+	var runs []string
+	runScheduler(t, store, clock, true, map[string]config.ScheduleDef{
+		"hourly": {Cron: "0 * * * *", Timezone: "Asia/Kolkata"}, // UTC+5:30
+	}, func(ctx context.Context, name string, def config.ScheduleDef, run *state.Run) (string, error) {
+		mu.Lock()
+		runs = append(runs, run.ID)
+		mu.Unlock()
+		return "success", nil
+	})
+
+	if len(runs) != 3 {
+		t.Fatalf("runs = %v, want 3", runs)
+	}
+	want := []string{"03-04 11:30 success", "03-04 12:30 success", "03-04 13:30 success"}
+	if got := history(t, store, "hourly"); !equal(got, want) {
+		t.Errorf("history = %v, want %v", got, want)
+	}
+}
+
+// TestSchedulerOverlap verifies that an activation coming while the previous
+// run is going is skipped, unless the schedule allows overlap.
+func TestSchedulerOverlap(t *testing.T) {
+	for _, tt := range []struct {
+		overlap string
+		want    []string
+	}{
+		{"", []string{"03-04 11:00 success", "03-04 12:00 skipped"}},
+		{config.OverlapAllow, []string{"03-04 11:00 success", "03-04 12:00 success"}},
+	} {
+		t.Run("overlap="+tt.overlap, func(t *testing.T) {
+			store := state.Open(t.TempDir())
+			clock := newFakeClock(time.Date(2026, 3, 4, 10, 30, 0, 0, time.UTC), 2)
+			release := make(chan struct{})
+			go func() {
+				<-clock.idle
+				close(release)
+			}()
+			runScheduler(t, store, clock, false, map[string]config.ScheduleDef{
+				"slow": {Cron: "@hourly", Overlap: tt.overlap},
+			}, func(ctx context.Context, name string, def config.ScheduleDef, run *state.Run) (string, error) {
+				<-release // every run lasts until both activations passed
+				return "success", nil
+			})
+			if got := history(t, store, "slow"); !equal(got, tt.want) {
+				t.Errorf("history = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSchedulerMissed verifies the missed-run policies for activations that
+// passed while no scheduler was running.
+func TestSchedulerMissed(t *testing.T) {
+	store := state.Open(t.TempDir())
+	last := time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC)
+	for _, name := range []string{"skipper", "catcher", "current"} {
+		store.RecordActivation(name, state.Activation{Scheduled: last, RunID: "r-" + name, Status: "success"})
+	}
+	clock := newFakeClock(time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC), 0)
+	var mu sync.Mutex
+	var ran []string
+	runScheduler(t, store, clock, true, map[string]config.ScheduleDef{
+		"skipper": {Cron: "0 3 * * *"},
+		"catcher": {Cron: "0 3 * * *", Missed: config.MissedRunOnce},
+		"current": {Cron: "0 3 1 * *", Missed: config.MissedRunOnce},
+	}, func(ctx context.Context, name string, def config.ScheduleDef, run *state.Run) (string, error) {
+		mu.Lock()
+		ran = append(ran, name)
+		mu.Unlock()
+		return "failure", nil
+	})
+
+	if len(ran) != 1 || ran[0] != "catcher" {
+		t.Errorf("ran = %v, want catcher only", ran)
+	}
+	for name, want := range map[string][]string{
+		"skipper": {"03-01 03:00 success", "03-04 03:00 missed"},
+		"catcher": {"03-01 03:00 success", "03-04 03:00 failure"},
+		"current": {"03-01 03:00 success"},
+	} {
+		if got := history(t, store, name); !equal(got, want) {
+			t.Errorf("%s history = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ApprovalRequest is written when an approval step starts waiting.
+type ApprovalRequest struct {
+	Step        string                    `json:"step"`
+	Message     string                    `json:"message,omitempty"`
+	Upstream    map[string]map[string]any `json:"upstream"` // dependency step → output
+	RequestedAt time.Time                 `json:"requested_at"`
+	Deadline    time.Time                 `json:"deadline"`
+}
+
+// Decision is a human's answer to an approval request.
+type Decision struct {
+	Approved  bool      `json:"approved"`
+	By        string    `json:"by,omitempty"`
+	Comment   string    `json:"comment,omitempty"`
+	DecidedAt time.Time `json:"decided_at"`
+}
+
+// PollInterval is how often WaitDecision checks for a decision.
+var PollInterval = time.Second
+
+func (r *Run) approvalDir() string { return filepath.Join(r.Dir, "approvals") }
+
+func (r *Run) requestPath(step string) string {
+	return filepath.Join(r.approvalDir(), step+".request.json")
+}
+
+func (r *Run) decisionPath(step string) string {
+	return filepath.Join(r.approvalDir(), step+".decision.json")
+}
+
+// RequestApproval records that step is waiting for a decision.
+func (r *Run) RequestApproval(req ApprovalRequest) error {
+	if err := checkStepName(req.Step); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(r.approvalDir(), 0700); err != nil {
+		return fmt.Errorf("create approvals dir: %w", err)
+	}
+	return writeJSON(r.requestPath(req.Step), req)
+}
+
+// Approval returns the pending request of step.
+func (r *Run) Approval(step string) (*ApprovalRequest, error) {
+	var req ApprovalRequest
+	if err := checkStepName(step); err != nil {
+		return nil, err
+	}
+	if err := readJSON(r.requestPath(step), &req); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("run %s has no approval request for step %q", r.ID, step)
+		}
+		return nil, err
+	}
+	return &req, nil
+}
+
+// Decide records a decision for the pending approval of step. A step can
+// only be decided once.
+func (r *Run) Decide(step string, d Decision) error {
+	if _, err := r.Approval(step); err != nil {
+		return err
+	}
+	if d.DecidedAt.IsZero() {
+		d.DecidedAt = time.Now()
+	}
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write aside, then hard-link into place: the link fails if a decision
+	// exists, so the first approver wins and readers never see a partial file.
+	tmp, err := os.CreateTemp(r.approvalDir(), step+".decision-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Link(tmp.Name(), r.decisionPath(step)); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("step %q of run %s was already decided", step, r.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// WaitDecision blocks until step is decided, the timeout passes, or ctx is
+// cancelled.
+func (r *Run) WaitDecision(ctx context.Context, step string, timeout time.Duration) (*Decision, error) {
+	if err := checkStepName(step); err != nil {
+		return nil, err
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	tick := time.NewTicker(PollInterval)
+	defer tick.Stop()
+	for {
+		var d Decision
+		err := readJSON(r.decisionPath(step), &d)
+		if err == nil {
+			return &d, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline.C:
+			return nil, fmt.Errorf("no decision within %s", timeout)
+		case <-tick.C:
+		}
+	}
+}
+
+// writeJSON writes v atomically, so readers never see a partial file.
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,96 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testRun returns a new run in a temporary store with fast polling.
+func testRun(t *testing.T) *Run {
+	t.Helper()
+	old := PollInterval
+	PollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { PollInterval = old })
+	r, err := Open(t.TempDir()).NewRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// TestApprovalDecision verifies the request, decide and wait round trip.
+func TestApprovalDecision(t *testing.T) {
+	r := testRun(t)
+	req := ApprovalRequest{Step: "gate", Message: "Push?",
+		Upstream: map[string]map[string]any{"review": {"verdict": "approved"}}}
+	if err := r.RequestApproval(req); err != nil {
+		t.Fatalf("RequestApproval: %v", err)
+	}
+	got, err := r.Approval("gate")
+	if err != nil || got.Message != "Push?" || got.Upstream["review"]["verdict"] != "approved" {
+		t.Fatalf("Approval = %+v, %v", got, err)
+	}
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		r.Decide("gate", Decision{Approved: true, By: "alice"})
+	}()
+	d, err := r.WaitDecision(context.Background(), "gate", 5*time.Second)
+	if err != nil {
+		t.Fatalf("WaitDecision: %v", err)
+	}
+	if !d.Approved || d.By != "alice" {
+		t.Errorf("decision = %+v", d)
+	}
+
+	if err := r.Decide("gate", Decision{Approved: false}); err == nil || !strings.Contains(err.Error(), "already decided") {
+		t.Errorf("second Decide err = %v, want already decided", err)
+	}
+}
+
+// TestDecideWithoutRequest verifies that only pending steps can be decided.
+func TestDecideWithoutRequest(t *testing.T) {
+	r := testRun(t)
+	if err := r.Decide("gate", Decision{Approved: true}); err == nil || !strings.Contains(err.Error(), "no approval request") {
+		t.Errorf("err = %v, want no approval request", err)
+	}
+}
+
+// TestApprovalStepNames verifies that step names cannot address files
+// outside the run's approvals.
+func TestApprovalStepNames(t *testing.T) {
+	r := testRun(t)
+	for _, step := range []string{"", "../info", "..", `a\b`, "../../runs/x/approvals/gate"} {
+		if _, err := r.Approval(step); !errors.Is(err, ErrInvalidStep) {
+			t.Errorf("Approval(%q) err = %v, want ErrInvalidStep", step, err)
+		}
+		if err := r.Decide(step, Decision{Approved: true}); !errors.Is(err, ErrInvalidStep) {
+			t.Errorf("Decide(%q) err = %v, want ErrInvalidStep", step, err)
+		}
+		if err := r.RequestApproval(ApprovalRequest{Step: step}); !errors.Is(err, ErrInvalidStep) {
+			t.Errorf("RequestApproval(%q) err = %v, want ErrInvalidStep", step, err)
+		}
+	}
+	if err := r.RequestApproval(ApprovalRequest{Step: "verify.gate"}); err != nil {
+		t.Errorf("RequestApproval of a sub-pipeline step: %v", err)
+	}
+}
+
+// TestWaitDecisionTimeoutAndCancel verifies that waiting ends on timeout and
+// on cancellation.
+func TestWaitDecisionTimeoutAndCancel(t *testing.T) {
+	r := testRun(t)
+	if _, err := r.WaitDecision(context.Background(), "gate", 50*time.Millisecond); err == nil ||
+		!strings.Contains(err.Error(), "no decision within") {
+		t.Errorf("err = %v, want timeout", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := r.WaitDecision(ctx, "gate", time.Hour); err != context.Canceled {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
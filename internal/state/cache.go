@@ -0,0 +1,56 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// Cache holds step results by content key, shared by all runs of a store.
+type Cache struct {
+	Dir     string
+	Refresh bool // never return hits, but keep storing results (--no-cache)
+}
+
+// Cache returns the step result cache of the store.
+func (s *Store) Cache() *Cache {
+	return &Cache{Dir: filepath.Join(s.Dir, "cache")}
+}
+
+// cacheKeyPattern matches keys: lowercase hex digests.
+var cacheKeyPattern = regexp.MustCompile(`^[0-9a-f]{16,}$`)
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.Dir, key[:2], key+".json")
+}
+
+// Get reads the entry of key into v and reports whether there was one.
+func (c *Cache) Get(key string, v any) (bool, error) {
+	if !cacheKeyPattern.MatchString(key) {
+		return false, fmt.Errorf("invalid cache key %q", key)
+	}
+	if c.Refresh {
+		return false, nil
+	}
+	if err := readJSON(c.path(key), v); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("read cache entry: %w", err)
+	}
+	return true, nil
+}
+
+// Put stores v as the entry of key, replacing any previous one.
+func (c *Cache) Put(key string, v any) error {
+	if !cacheKeyPattern.MatchString(key) {
+		return fmt.Errorf("invalid cache key %q", key)
+	}
+	p := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return fmt.Errorf("create cache dir: %w", err)
+	}
+	return writeJSON(p, v)
+}
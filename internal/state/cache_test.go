@@ -0,0 +1,32 @@
+package state
+
+import "testing"
+
+// TestCache verifies storing, reading and refreshing cache entries.
+func TestCache(t *testing.T) {
+	c := Open(t.TempDir()).Cache()
+	key := "0123456789abcdef0123456789abcdef"
+	type entry struct{ Status string }
+
+	var got entry
+	if ok, err := c.Get(key, &got); ok || err != nil {
+		t.Fatalf("Get on empty cache = %v, %v", ok, err)
+	}
+	if err := c.Put(key, entry{Status: "success"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if ok, err := c.Get(key, &got); !ok || err != nil || got.Status != "success" {
+		t.Fatalf("Get = %v, %v, %+v", ok, err, got)
+	}
+
+	c.Refresh = true
+	if ok, _ := c.Get(key, &got); ok {
+		t.Error("Get returned a hit with Refresh set")
+	}
+
+	for _, bad := range []string{"", "../../etc/passwd", "ABCDEF0123456789"} {
+		if err := c.Put(bad, entry{}); err == nil {
+			t.Errorf("Put(%q) returned nil error", bad)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Change is what a step with a read-write workspace did to one repository.
+type Change struct {
+	Repo     string   `json:"repo"`
+	Base     string   `json:"base"`     // snapshot commit of the tree before the step
+	Snapshot string   `json:"snapshot"` // snapshot commit of the tree after the step
+	Commits  []Commit `json:"commits"`  // commits the step made, oldest first
+}
+
+// Commit is one commit made by a step.
+type Commit struct {
+	SHA     string `json:"sha"`
+	Subject string `json:"subject"`
+}
+
+func (r *Run) changesDir(step string) string { return filepath.Join(r.Dir, "changes", step) }
+
+// RecordChange stores the change step made to c.Repo and its patch.
+func (r *Run) RecordChange(step string, c Change, patch []byte) error {
+	dir := r.changesDir(step)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("create changes dir: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, c.Repo+".patch"), patch, 0600); err != nil {
+		return fmt.Errorf("write patch: %w", err)
+	}
+	return writeJSON(filepath.Join(dir, c.Repo+".json"), c)
+}
+
+// Changes returns the changes step made, sorted by repository.
+func (r *Run) Changes(step string) ([]Change, error) {
+	entries, err := os.ReadDir(r.changesDir(step))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("run %s has no recorded changes for step %q", r.ID, step)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		var c Change
+		if err := readJSON(filepath.Join(r.changesDir(step), e.Name()), &c); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Repo < changes[j].Repo })
+	return changes, nil
+}
+
+// Patch returns the patch of the change step made to repo.
+func (r *Run) Patch(step, repo string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(r.changesDir(step), repo+".patch"))
+}
@@ -0,0 +1,36 @@
+package state
+
+import "testing"
+
+// TestRecordChanges verifies that recorded changes and patches read back,
+// sorted by repository.
+func TestRecordChanges(t *testing.T) {
+	r := testRun(t)
+	if _, err := r.Changes("implement"); err == nil {
+		t.Error("Changes of a step without changes returned nil error")
+	}
+
+	api := Change{Repo: "api", Base: "b1", Snapshot: "s1",
+		Commits: []Commit{{SHA: "c1", Subject: "fix handler"}}}
+	if err := r.RecordChange("implement", api, []byte("diff --git a/x b/x\n")); err != nil {
+		t.Fatalf("RecordChange: %v", err)
+	}
+	if err := r.RecordChange("implement", Change{Repo: "app", Base: "b2", Snapshot: "s2"}, nil); err != nil {
+		t.Fatalf("RecordChange: %v", err)
+	}
+
+	changes, err := r.Changes("implement")
+	if err != nil {
+		t.Fatalf("Changes: %v", err)
+	}
+	if len(changes) != 2 || changes[0].Repo != "api" || changes[1].Repo != "app" {
+		t.Fatalf("changes = %+v, want api then app", changes)
+	}
+	if changes[0].Snapshot != "s1" || len(changes[0].Commits) != 1 || changes[0].Commits[0].Subject != "fix handler" {
+		t.Errorf("api change = %+v", changes[0])
+	}
+	patch, err := r.Patch("implement", "api")
+	if err != nil || string(patch) != "diff --git a/x b/x\n" {
+		t.Errorf("Patch = %q, %v", patch, err)
+	}
+}
@@ -0,0 +1,55 @@
+package state
+
+import (
+	"slices"
+	"time"
+)
+
+// RunFilter selects runs by their summary. Zero fields match every run.
+type RunFilter struct {
+	Status string
+	Since  time.Time         // started at or after
+	Until  time.Time         // started before
+	Inputs map[string]string // inputs the run must have been given
+}
+
+// Match reports whether a run with summary info passes the filter.
+func (f RunFilter) Match(info RunInfo) bool {
+	switch {
+	case f.Status != "" && info.Status != f.Status:
+		return false
+	case !f.Since.IsZero() && info.Started.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !info.Started.Before(f.Until):
+		return false
+	}
+	for k, v := range f.Inputs {
+		if got, ok := info.Inputs[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// History returns the summaries of the runs matching f, newest first. A run
+// without a summary, because it predates summaries or its process died
+// before writing one, has an empty status and the start time of its ID.
+func (s *Store) History(f RunFilter) ([]RunInfo, error) {
+	runs, err := s.Runs()
+	if err != nil {
+		return nil, err
+	}
+	var infos []RunInfo
+	for _, r := range slices.Backward(runs) {
+		info := RunInfo{ID: r.ID}
+		if recorded, err := r.Info(); err == nil {
+			info = *recorded
+		} else if started, err := time.ParseInLocation("20060102-150405", r.ID[:15], time.Local); err == nil {
+			info.Started = started
+		}
+		if f.Match(info) {
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
@@ -0,0 +1,67 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestHistory verifies that runs are listed newest first and filtered by
+// status, start time and inputs, and that runs without a summary are
+// listed with the time of their ID.
+func TestHistory(t *testing.T) {
+	store := Open(t.TempDir())
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	for i, info := range []RunInfo{
+		{Status: "success", Inputs: map[string]string{"issue": "1"}},
+		{Status: "failure", Inputs: map[string]string{"issue": "2", "label": "bug"}},
+		{Status: "success", Inputs: map[string]string{"issue": "3", "label": "bug"}},
+	} {
+		info.ID = fmt.Sprintf("20260301-12000%d-aaaaaa", i)
+		info.Started = day.Add(time.Duration(i) * 24 * time.Hour)
+		r := &Run{ID: info.ID, Dir: filepath.Join(store.Dir, "runs", info.ID)}
+		if err := os.MkdirAll(r.Dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.WriteInfo(info); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(store.Dir, "runs", "20260228-093000-bbbbbb"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		filter RunFilter
+		want   string
+	}{
+		{"all", RunFilter{}, "[2 1 0 20260228-093000-bbbbbb]"},
+		{"status", RunFilter{Status: "success"}, "[2 0]"},
+		{"since", RunFilter{Since: day.Add(time.Hour)}, "[2 1]"},
+		{"until", RunFilter{Until: day.Add(24 * time.Hour)}, "[0 20260228-093000-bbbbbb]"},
+		{"inputs", RunFilter{Inputs: map[string]string{"label": "bug"}}, "[2 1]"},
+		{"combined", RunFilter{Status: "failure", Inputs: map[string]string{"label": "bug", "issue": "3"}}, "[]"},
+	} {
+		infos, err := store.History(tt.filter)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var got []string
+		for _, info := range infos {
+			if info.Status == "" {
+				got = append(got, info.ID)
+				if want := time.Date(2026, 2, 28, 9, 30, 0, 0, time.Local); !info.Started.Equal(want) {
+					t.Errorf("%s: run without summary started %v, want %v", tt.name, info.Started, want)
+				}
+				continue
+			}
+			got = append(got, info.ID[14:15])
+		}
+		if fmt.Sprint(got) != tt.want {
+			t.Errorf("%s: runs = %v, want %s", tt.name, got, tt.want)
+		}
+	}
+}
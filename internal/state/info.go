@@ -0,0 +1,157 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Run and step statuses besides the final statuses success, failure and
+// skipped.
+const (
+	StatusRunning   = "running"   // started and not finished
+	StatusCancelled = "cancelled" // the run was cancelled before it finished
+	StatusError     = "error"     // the run could not be executed
+)
+
+// RunInfo is the summary of a run kept in its record.
+type RunInfo struct {
+	ID       string            `json:"id"`
+	Status   string            `json:"status"`
+	Error    string            `json:"error,omitempty"`
+	Inputs   map[string]string `json:"inputs,omitempty"`
+	Started  time.Time         `json:"started"`
+	Finished time.Time         `json:"finished,omitzero"`
+}
+
+// StepRecord is the status of one step of a run, kept in its record while
+// the run goes on.
+type StepRecord struct {
+	Name     string         `json:"name"` // qualified by sub-pipeline, e.g. review.check
+	Agent    string         `json:"agent,omitempty"`
+	Status   string         `json:"status"`
+	Output   map[string]any `json:"output,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Cached   bool           `json:"cached,omitempty"`
+	Started  time.Time      `json:"started,omitzero"`
+	Finished time.Time      `json:"finished,omitzero"`
+}
+
+func (r *Run) infoPath() string { return filepath.Join(r.Dir, "run.json") }
+
+func (r *Run) stepsDir() string { return filepath.Join(r.Dir, "steps") }
+
+// LogDir returns the directory holding the logs of the run's step
+// invocations.
+func (r *Run) LogDir() string { return filepath.Join(r.Dir, "logs") }
+
+// WriteInfo replaces the summary of the run.
+func (r *Run) WriteInfo(info RunInfo) error {
+	return writeJSON(r.infoPath(), info)
+}
+
+// Info returns the summary of the run.
+func (r *Run) Info() (*RunInfo, error) {
+	var info RunInfo
+	if err := readJSON(r.infoPath(), &info); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("run %s has no summary", r.ID)
+		}
+		return nil, err
+	}
+	return &info, nil
+}
+
+// RecordStep replaces the status of step s.Name.
+func (r *Run) RecordStep(s StepRecord) error {
+	if err := os.MkdirAll(r.stepsDir(), 0700); err != nil {
+		return fmt.Errorf("create steps dir: %w", err)
+	}
+	return writeJSON(filepath.Join(r.stepsDir(), s.Name+".json"), s)
+}
+
+// Steps returns the statuses of the steps recorded so far, in the order
+// they started; steps skipped without starting come last.
+func (r *Run) Steps() ([]StepRecord, error) {
+	entries, err := os.ReadDir(r.stepsDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var steps []StepRecord
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		var s StepRecord
+		if err := readJSON(filepath.Join(r.stepsDir(), e.Name()), &s); err != nil {
+			return nil, err
+		}
+		steps = append(steps, s)
+	}
+	slices.SortStableFunc(steps, func(a, b StepRecord) int {
+		switch {
+		case a.Started.IsZero() != b.Started.IsZero():
+			if a.Started.IsZero() {
+				return 1
+			}
+			return -1
+		case !a.Started.Equal(b.Started):
+			return a.Started.Compare(b.Started)
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return steps, nil
+}
+
+// Step returns the status of the named step.
+func (r *Run) Step(name string) (*StepRecord, error) {
+	var s StepRecord
+	if err := checkStepName(name); err != nil {
+		return nil, err
+	}
+	if err := readJSON(filepath.Join(r.stepsDir(), name+".json"), &s); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("run %s has no step %q", r.ID, name)
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ErrInvalidStep is returned for step names that could address files outside
+// the run record.
+var ErrInvalidStep = errors.New("invalid step name")
+
+// checkStepName rejects step names that are empty or contain a path
+// separator or "..", as step names become file names in the run record.
+func checkStepName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+		return fmt.Errorf("%w %q", ErrInvalidStep, name)
+	}
+	return nil
+}
+
+// Runs returns the runs of the store, oldest first.
+func (s *Store) Runs() ([]*Run, error) {
+	entries, err := os.ReadDir(filepath.Join(s.Dir, "runs"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var runs []*Run
+	for _, e := range entries {
+		if e.IsDir() && runIDPattern.MatchString(e.Name()) {
+			runs = append(runs, &Run{ID: e.Name(), Dir: filepath.Join(s.Dir, "runs", e.Name())})
+		}
+	}
+	return runs, nil
+}
@@ -0,0 +1,64 @@
+package state
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestRunInfo verifies that run summaries and step statuses read back, with
+// steps in start order and later records replacing earlier ones.
+func TestRunInfo(t *testing.T) {
+	store := Open(t.TempDir())
+	r, err := store.NewRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Info(); err == nil {
+		t.Error("Info of a run without summary returned nil error")
+	}
+	started := time.Now().Truncate(time.Second)
+	if err := r.WriteInfo(RunInfo{ID: r.ID, Status: StatusRunning, Inputs: map[string]string{"issue": "7"}, Started: started}); err != nil {
+		t.Fatalf("WriteInfo: %v", err)
+	}
+	info, err := r.Info()
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if info.Status != StatusRunning || info.Inputs["issue"] != "7" || !info.Started.Equal(started) || !info.Finished.IsZero() {
+		t.Errorf("info = %+v", info)
+	}
+
+	for _, s := range []StepRecord{
+		{Name: "review", Status: StatusRunning, Started: started.Add(time.Second)},
+		{Name: "plan", Status: StatusRunning, Started: started},
+		{Name: "deploy", Status: "skipped", Error: "dependency review failed"},
+		{Name: "review", Status: "failure", Started: started.Add(time.Second), Finished: started.Add(time.Minute)},
+	} {
+		if err := r.RecordStep(s); err != nil {
+			t.Fatalf("RecordStep: %v", err)
+		}
+	}
+	steps, err := r.Steps()
+	if err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+	var got []string
+	for _, s := range steps {
+		got = append(got, s.Name+" "+s.Status)
+	}
+	if want := "[plan running review failure deploy skipped]"; fmt.Sprint(got) != want {
+		t.Errorf("steps = %v, want %s", got, want)
+	}
+	if s, err := r.Step("review"); err != nil || s.Status != "failure" {
+		t.Errorf("Step(review) = %+v, %v", s, err)
+	}
+	if _, err := r.Step("../run"); err == nil {
+		t.Error("Step accepted a path")
+	}
+
+	runs, err := store.Runs()
+	if err != nil || len(runs) != 1 || runs[0].ID != r.ID {
+		t.Errorf("Runs = %v, %v; want the one run", runs, err)
+	}
+}
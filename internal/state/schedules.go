@@ -0,0 +1,85 @@
+package state
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Activation statuses other than the final status of the run started.
+const (
+	ActivationRunning = "running" // the run started and has not finished
+	ActivationSkipped = "skipped" // no run: the previous one was still going
+	ActivationMissed  = "missed"  // no run: no scheduler was running
+	ActivationError   = "error"   // the run could not be executed
+)
+
+// Activation is one entry of a schedule's history: a time its cron came due
+// and what became of it.
+type Activation struct {
+	Scheduled time.Time `json:"scheduled"`        // the time the cron came due
+	RunID     string    `json:"run_id,omitempty"` // the run started, if any
+	Status    string    `json:"status"`           // an Activation status or the run's status
+	Reason    string    `json:"reason,omitempty"`
+	Recorded  time.Time `json:"recorded"`
+}
+
+func (s *Store) historyPath(schedule string) string {
+	return filepath.Join(s.Dir, "schedules", schedule+".jsonl")
+}
+
+// RecordActivation appends a to the history of the schedule. A later entry
+// with the same run ID supersedes an earlier one.
+func (s *Store) RecordActivation(schedule string, a Activation) error {
+	path := s.historyPath(schedule)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("create schedules dir: %w", err)
+	}
+	line, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open schedule history: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("write schedule history: %w", err)
+	}
+	return f.Close()
+}
+
+// Activations returns the history of the schedule, oldest first, with the
+// entries of each run merged into its latest. A schedule that never came due
+// has an empty history.
+func (s *Store) Activations(schedule string) ([]Activation, error) {
+	f, err := os.Open(s.historyPath(schedule))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var history []Activation
+	byRun := map[string]int{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var a Activation
+		if err := json.Unmarshal(sc.Bytes(), &a); err != nil {
+			return nil, fmt.Errorf("schedule %s history: %w", schedule, err)
+		}
+		if i, ok := byRun[a.RunID]; ok && a.RunID != "" {
+			history[i] = a
+			continue
+		}
+		byRun[a.RunID] = len(history)
+		history = append(history, a)
+	}
+	return history, sc.Err()
+}
@@ -0,0 +1,41 @@
+package state
+
+import (
+	"testing"
+	"time"
+)
+
+// TestActivations verifies that the history reads back in order with each
+// run's entries merged into its latest.
+func TestActivations(t *testing.T) {
+	s := Open(t.TempDir())
+	if h, err := s.Activations("nightly"); err != nil || h != nil {
+		t.Fatalf("Activations of a new schedule = %v, %v; want nil, nil", h, err)
+	}
+
+	t1 := time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC)
+	t2 := t1.Add(24 * time.Hour)
+	for _, a := range []Activation{
+		{Scheduled: t1, RunID: "r1", Status: ActivationRunning},
+		{Scheduled: t2, Status: ActivationSkipped, Reason: "previous run still running"},
+		{Scheduled: t1, RunID: "r1", Status: "success"},
+	} {
+		if err := s.RecordActivation("nightly", a); err != nil {
+			t.Fatalf("RecordActivation: %v", err)
+		}
+	}
+
+	h, err := s.Activations("nightly")
+	if err != nil {
+		t.Fatalf("Activations: %v", err)
+	}
+	if len(h) != 2 {
+		t.Fatalf("history = %+v, want 2 entries", h)
+	}
+	if h[0].RunID != "r1" || h[0].Status != "success" || !h[0].Scheduled.Equal(t1) {
+		t.Errorf("first entry = %+v, want r1 finished", h[0])
+	}
+	if h[1].Status != ActivationSkipped || !h[1].Scheduled.Equal(t2) {
+		t.Errorf("second entry = %+v, want skipped", h[1])
+	}
+}
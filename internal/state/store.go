@@ -0,0 +1,74 @@
+// Package state keeps the records of pipeline runs under the conductor
+// state directory, where other conductor processes can find them.
+package state
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// Store is a state directory holding one subdirectory per run.
+type Store struct {
+	Dir string
+}
+
+// DefaultDir returns $XDG_STATE_HOME/conductor, falling back to
+// ~/.local/state/conductor.
+func DefaultDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "conductor")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "state", "conductor")
+	}
+	return filepath.Join(os.TempDir(), "conductor-state")
+}
+
+// Open returns the store rooted at dir.
+func Open(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// Run is the record directory of one pipeline run.
+type Run struct {
+	ID  string
+	Dir string
+}
+
+// runIDPattern matches IDs made by NewRun: a timestamp and a random suffix.
+var runIDPattern = regexp.MustCompile(`^[0-9]{8}-[0-9]{6}-[0-9a-f]{6}$`)
+
+// NewRun creates the record directory of a new run with a fresh ID.
+func (s *Store) NewRun() (*Run, error) {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, fmt.Errorf("run id: %w", err)
+	}
+	id := time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+	r := &Run{ID: id, Dir: filepath.Join(s.Dir, "runs", id)}
+	if err := os.MkdirAll(r.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("create run dir: %w", err)
+	}
+	return r, nil
+}
+
+// Run returns the existing run with the given ID.
+func (s *Store) Run(id string) (*Run, error) {
+	if !runIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("invalid run id %q", id)
+	}
+	r := &Run{ID: id, Dir: filepath.Join(s.Dir, "runs", id)}
+	if _, err := os.Stat(r.Dir); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("run %s not found in %s", id, s.Dir)
+		}
+		return nil, err
+	}
+	return r, nil
+}
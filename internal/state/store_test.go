@@ -0,0 +1,46 @@
+package state
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestNewRunAndLookup verifies that new runs get unique IDs whose records
+// can be found again, and that bad IDs are rejected.
+func TestNewRunAndLookup(t *testing.T) {
+	s := Open(t.TempDir())
+	a, err := s.NewRun()
+	if err != nil {
+		t.Fatalf("NewRun: %v", err)
+	}
+	b, err := s.NewRun()
+	if err != nil {
+		t.Fatalf("NewRun: %v", err)
+	}
+	if a.ID == b.ID {
+		t.Errorf("run IDs collide: %s", a.ID)
+	}
+
+	got, err := s.Run(a.ID)
+	if err != nil {
+		t.Fatalf("Run(%s): %v", a.ID, err)
+	}
+	if got.Dir != a.Dir {
+		t.Errorf("Dir = %q, want %q", got.Dir, a.Dir)
+	}
+
+	if _, err := s.Run("20260101-000000-abcdef"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("err = %v, want not found", err)
+	}
+	if _, err := s.Run("../etc"); err == nil || !strings.Contains(err.Error(), "invalid run id") {
+		t.Errorf("err = %v, want invalid run id", err)
+	}
+}
+
+// TestDefaultDir verifies that XDG_STATE_HOME is honoured.
+func TestDefaultDir(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/var/state")
+	if got := DefaultDir(); got != "/var/state/conductor" {
+		t.Errorf("DefaultDir = %q", got)
+	}
+}
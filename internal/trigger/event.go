@@ -0,0 +1,124 @@
+// Package trigger turns GitHub events into pipeline runs: it verifies
+// webhook deliveries, derives run inputs from their payloads and matches
+// them against the trigger rules of the config.
+package trigger
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// Event is a GitHub event: its name, as sent in the X-GitHub-Event header,
+// and the run inputs derived from its payload (see config.TriggerInputs).
+type Event struct {
+	Name   string
+	Inputs map[string]string
+}
+
+// payload holds the fields of issue, pull request and comment events that
+// run inputs are derived from.
+type payload struct {
+	Action     string `json:"action"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+	Issue *struct {
+		Number      int             `json:"number"`
+		PullRequest json.RawMessage `json:"pull_request"` // present when the issue is a pull request
+	} `json:"issue"`
+	PullRequest *struct {
+		Number int `json:"number"`
+	} `json:"pull_request"`
+	Label *struct {
+		Name string `json:"name"`
+	} `json:"label"`
+	Comment *struct {
+		Body              string `json:"body"`
+		AuthorAssociation string `json:"author_association"`
+	} `json:"comment"`
+}
+
+// ParseEvent derives the run inputs of the named event from its JSON
+// payload.
+func ParseEvent(name string, data []byte) (*Event, error) {
+	var p payload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parse %s payload: %w", name, err)
+	}
+	inputs := map[string]string{"event": name}
+	set := func(key, value string) {
+		if value != "" {
+			inputs[key] = value
+		}
+	}
+	set("action", p.Action)
+	set("repo", p.Repository.FullName)
+	set("sender", p.Sender.Login)
+	if p.Issue != nil {
+		if len(p.Issue.PullRequest) > 0 && string(p.Issue.PullRequest) != "null" {
+			set("pr", strconv.Itoa(p.Issue.Number))
+		} else {
+			set("issue", strconv.Itoa(p.Issue.Number))
+		}
+	}
+	if p.PullRequest != nil {
+		set("pr", strconv.Itoa(p.PullRequest.Number))
+	}
+	if p.Label != nil {
+		set("label", p.Label.Name)
+	}
+	if p.Comment != nil {
+		set("comment", p.Comment.Body)
+		set("association", p.Comment.AuthorAssociation)
+	}
+	return &Event{Name: name, Inputs: inputs}, nil
+}
+
+// Match returns the index of the first rule ev satisfies and the inputs of
+// the run it starts: the event's inputs, plus args for command rules.
+// Commands from commenters the rule does not allow are ignored.
+func Match(rules []config.TriggerRule, ev *Event) (int, map[string]string, bool) {
+	for i, rule := range rules {
+		if rule.Event != ev.Name {
+			continue
+		}
+		action := ev.Inputs["action"]
+		if len(rule.Actions) > 0 && !slices.Contains(rule.Actions, action) {
+			continue
+		}
+		if rule.Label != "" && (action != "labeled" || ev.Inputs["label"] != rule.Label) {
+			continue
+		}
+		inputs := maps.Clone(ev.Inputs)
+		if rule.Command != "" {
+			args, ok := commandArgs(ev.Inputs["comment"], rule.Command)
+			if !ok || !rule.Allows(ev.Inputs["sender"], ev.Inputs["association"]) {
+				continue
+			}
+			inputs["args"] = args
+		}
+		return i, inputs, true
+	}
+	return -1, nil, false
+}
+
+// commandArgs reports whether the first line of comment invokes command,
+// and returns the text following it.
+func commandArgs(comment, command string) (string, bool) {
+	line, _, _ := strings.Cut(strings.TrimSpace(comment), "\n")
+	line = strings.TrimSpace(line)
+	rest, ok := strings.CutPrefix(line, command)
+	if !ok || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+		return "", false
+	}
+	return strings.TrimSpace(rest), true
+}
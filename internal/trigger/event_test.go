@@ -0,0 +1,149 @@
+package trigger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// fixture returns the parsed event of a payload in testdata.
+func fixture(t *testing.T, name, file string) *Event {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, err := ParseEvent(name, data)
+	if err != nil {
+		t.Fatalf("ParseEvent(%s): %v", file, err)
+	}
+	return ev
+}
+
+// TestParseEvent verifies the inputs derived from each kind of payload.
+func TestParseEvent(t *testing.T) {
+	tests := []struct {
+		event, file string
+		want        map[string]string
+	}{
+		{config.EventIssues, "issues_labeled.json", map[string]string{
+			"event": "issues", "action": "labeled", "repo": "acme/widgets", "sender": "octocat",
+			"issue": "123", "label": "conductor",
+		}},
+		{config.EventIssueComment, "issue_comment_pr.json", map[string]string{
+			"event": "issue_comment", "action": "created", "repo": "acme/widgets", "sender": "hubot",
+			"pr": "42", "comment": "/conductor fix the flaky test\n\nThanks!", "association": "MEMBER",
+		}},
+		{config.EventPullRequest, "pull_request_opened.json", map[string]string{
+			"event": "pull_request", "action": "opened", "repo": "acme/widgets", "sender": "hubot",
+			"pr": "42",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got := fixture(t, tt.event, tt.file).Inputs
+			if len(got) != len(tt.want) {
+				t.Errorf("inputs = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("inputs[%s] = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+	if _, err := ParseEvent("issues", []byte("not json")); err == nil {
+		t.Error("ParseEvent of malformed payload returned nil error")
+	}
+}
+
+// TestMatch verifies rule selection by event, action, label and command.
+func TestMatch(t *testing.T) {
+	rules := []config.TriggerRule{
+		{Event: config.EventIssues, Label: "needs-design"},
+		{Event: config.EventIssues, Label: "conductor"},
+		{Event: config.EventPullRequest, Actions: []string{"opened", "synchronize"}},
+		{Event: config.EventIssueComment, Command: "/conductor review"},
+		{Event: config.EventIssueComment, Command: "/conductor fix", Pipeline: "fix"},
+	}
+	tests := []struct {
+		event, file string
+		wantRule    int
+		wantArgs    string
+	}{
+		{config.EventIssues, "issues_labeled.json", 1, ""},
+		{config.EventPullRequest, "pull_request_opened.json", 2, ""},
+		{config.EventIssueComment, "issue_comment_pr.json", 4, "the flaky test"},
+	}
+	for _, tt := range tests {
+		i, inputs, ok := Match(rules, fixture(t, tt.event, tt.file))
+		if !ok || i != tt.wantRule {
+			t.Errorf("%s matched rule %d (%v), want %d", tt.file, i, ok, tt.wantRule)
+			continue
+		}
+		if inputs["args"] != tt.wantArgs {
+			t.Errorf("%s args = %q, want %q", tt.file, inputs["args"], tt.wantArgs)
+		}
+	}
+
+	closed := &Event{Name: config.EventPullRequest, Inputs: map[string]string{"action": "closed"}}
+	if i, _, ok := Match(rules, closed); ok {
+		t.Errorf("closed pull request matched rule %d", i)
+	}
+	unlabeled := &Event{Name: config.EventIssues, Inputs: map[string]string{"action": "unlabeled", "label": "conductor"}}
+	if i, _, ok := Match(rules, unlabeled); ok {
+		t.Errorf("label removal matched rule %d", i)
+	}
+}
+
+// TestMatchSender verifies that commands run only for commenters allowed by
+// association, by default owners, members and collaborators, or by login.
+func TestMatchSender(t *testing.T) {
+	rules := []config.TriggerRule{
+		{Event: config.EventIssueComment, Command: "/conductor fix"},
+		{Event: config.EventIssueComment, Command: "/conductor review",
+			AuthorAssociations: []string{"OWNER"}, AllowedSenders: []string{"renovate[bot]"}},
+	}
+	tests := []struct {
+		comment, sender, association string
+		wantOK                       bool
+	}{
+		{"/conductor fix", "octocat", "COLLABORATOR", true},
+		{"/conductor fix", "mallory", "NONE", false},
+		{"/conductor fix", "newcomer", "FIRST_TIME_CONTRIBUTOR", false},
+		{"/conductor fix", "ghost", "", false},
+		{"/conductor review", "octocat", "MEMBER", false},
+		{"/conductor review", "octocat", "OWNER", true},
+		{"/conductor review", "renovate[bot]", "NONE", true},
+	}
+	for _, tt := range tests {
+		ev := &Event{Name: config.EventIssueComment, Inputs: map[string]string{"action": "created",
+			"comment": tt.comment, "sender": tt.sender, "association": tt.association}}
+		if _, _, ok := Match(rules, ev); ok != tt.wantOK {
+			t.Errorf("%q by %s (%s) matched = %v, want %v", tt.comment, tt.sender, tt.association, ok, tt.wantOK)
+		}
+	}
+}
+
+// TestCommandArgs verifies that commands match whole words on the first line.
+func TestCommandArgs(t *testing.T) {
+	tests := []struct {
+		comment  string
+		wantArgs string
+		wantOK   bool
+	}{
+		{"/conductor fix", "", true},
+		{"  /conductor fix  now please \nmore", "now please", true},
+		{"/conductor fixes", "", false},
+		{"please /conductor fix", "", false},
+		{"hello\n/conductor fix", "", false},
+	}
+	for _, tt := range tests {
+		args, ok := commandArgs(tt.comment, "/conductor fix")
+		if ok != tt.wantOK || args != tt.wantArgs {
+			t.Errorf("commandArgs(%q) = %q, %v; want %q, %v", tt.comment, args, ok, tt.wantArgs, tt.wantOK)
+		}
+	}
+}
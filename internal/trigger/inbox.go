@@ -0,0 +1,96 @@
+package trigger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Inbox is a local directory of GitHub events, for triggering runs without
+// a webhook: every *.json file holds {"event": name, "payload": {...}}.
+// Files are trusted as they are, so the directory must only be writable by
+// the user running conductor.
+type Inbox struct {
+	Dir string
+}
+
+// inboxEvent is the content of an inbox file.
+type inboxEvent struct {
+	Event   string          `json:"event"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Process dispatches every event file in the inbox in name order, moving
+// each to done/ once handled or to failed/ when it could not be.
+func (in *Inbox) Process(d *Dispatcher) error {
+	entries, err := os.ReadDir(in.Dir)
+	if err != nil {
+		return fmt.Errorf("read inbox: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, e.Name())
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		dest := "done"
+		if err := in.dispatch(d, name); err != nil {
+			d.Logger.Error("inbox event failed", "file", name, "error", err)
+			dest = "failed"
+		}
+		if err := os.MkdirAll(filepath.Join(in.Dir, dest), 0700); err != nil {
+			return err
+		}
+		if err := os.Rename(filepath.Join(in.Dir, name), filepath.Join(in.Dir, dest, name)); err != nil {
+			return fmt.Errorf("move inbox event: %w", err)
+		}
+	}
+	return nil
+}
+
+func (in *Inbox) dispatch(d *Dispatcher, name string) error {
+	data, err := os.ReadFile(filepath.Join(in.Dir, name))
+	if err != nil {
+		return err
+	}
+	var ie inboxEvent
+	if err := json.Unmarshal(data, &ie); err != nil {
+		return err
+	}
+	if ie.Event == "" {
+		return fmt.Errorf("no event name")
+	}
+	ev, err := ParseEvent(ie.Event, ie.Payload)
+	if err != nil {
+		return err
+	}
+	_, err = d.Dispatch(ev)
+	return err
+}
+
+// Watch processes the inbox every interval until ctx is done.
+func (in *Inbox) Watch(ctx context.Context, d *Dispatcher, interval time.Duration) {
+	if err := os.MkdirAll(in.Dir, 0700); err != nil {
+		d.Logger.Error("inbox unavailable", "dir", in.Dir, "error", err)
+		return
+	}
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		if err := in.Process(d); err != nil {
+			d.Logger.Error("processing inbox failed", "dir", in.Dir, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
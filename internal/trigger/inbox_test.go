@@ -0,0 +1,52 @@
+package trigger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// TestInbox verifies that inbox events are dispatched in name order and
+// moved to done/ or failed/.
+func TestInbox(t *testing.T) {
+	dir := t.TempDir()
+	payload, err := os.ReadFile(filepath.Join("testdata", "issue_comment_pr.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"1-comment.json": `{"event": "issue_comment", "payload": ` + string(payload) + `}`,
+		"2-broken.json":  `{"payload": {}}`,
+		"notes.txt":      "ignored",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec := &recorder{}
+	d := &Dispatcher{
+		Rules:  []config.TriggerRule{{Event: config.EventIssueComment, Command: "/conductor fix"}},
+		Start:  rec.start,
+		Logger: discard,
+	}
+
+	in := &Inbox{Dir: dir}
+	if err := in.Process(d); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if len(rec.inputs) != 1 || rec.inputs[0]["pr"] != "42" || rec.inputs[0]["args"] != "the flaky test" {
+		t.Errorf("started runs = %v", rec.inputs)
+	}
+	for _, p := range []string{"done/1-comment.json", "failed/2-broken.json", "notes.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, p)); err != nil {
+			t.Errorf("want %s: %v", p, err)
+		}
+	}
+
+	if err := in.Process(d); err != nil || len(rec.inputs) != 1 {
+		t.Errorf("second Process: err = %v, runs = %d, want nothing new", err, len(rec.inputs))
+	}
+}
@@ -0,0 +1,98 @@
+package trigger
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+// maxPayload bounds a webhook delivery; GitHub caps payloads at 25 MB.
+const maxPayload = 25 << 20
+
+// Starter starts a run for rule with inputs and returns its ID without
+// waiting for the run to finish.
+type Starter func(rule config.TriggerRule, inputs map[string]string) (runID string, err error)
+
+// Dispatcher starts a run for every event matching one of its rules.
+type Dispatcher struct {
+	Rules  []config.TriggerRule
+	Start  Starter
+	Logger *slog.Logger
+}
+
+// Dispatch matches ev against the rules and starts a run for the first
+// match. It returns the run ID, or "" when no rule matched.
+func (d *Dispatcher) Dispatch(ev *Event) (string, error) {
+	i, inputs, ok := Match(d.Rules, ev)
+	if !ok {
+		d.Logger.Info("no trigger matched", "event", ev.Name, "action", ev.Inputs["action"])
+		return "", nil
+	}
+	runID, err := d.Start(d.Rules[i], inputs)
+	if err != nil {
+		d.Logger.Error("triggered run failed to start", "event", ev.Name, "rule", i, "error", err)
+		return "", err
+	}
+	d.Logger.Info("triggered run", "event", ev.Name, "rule", i, "run_id", runID)
+	return runID, nil
+}
+
+// Handler serves the webhook endpoint: it accepts POSTed GitHub deliveries
+// signed with Secret and dispatches them.
+type Handler struct {
+	Secret     []byte
+	Dispatcher *Dispatcher
+}
+
+// webhookResponse is the JSON body answering a delivery.
+type webhookResponse struct {
+	RunID string `json:"run_id,omitempty"` // empty when no rule matched
+	Error string `json:"error,omitempty"`
+}
+
+// ServeHTTP answers 202 when a run was started, 200 when no rule matched,
+// and 4xx for unsigned, oversized or malformed deliveries.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reply := func(code int, resp webhookResponse) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(resp)
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		reply(http.StatusMethodNotAllowed, webhookResponse{Error: "POST only"})
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPayload))
+	if err != nil {
+		reply(http.StatusRequestEntityTooLarge, webhookResponse{Error: err.Error()})
+		return
+	}
+	if err := Verify(h.Secret, body, r.Header.Get(SignatureHeader)); err != nil {
+		h.Dispatcher.Logger.Warn("rejected webhook delivery", "remote", r.RemoteAddr, "error", err)
+		reply(http.StatusUnauthorized, webhookResponse{Error: err.Error()})
+		return
+	}
+	name := r.Header.Get("X-GitHub-Event")
+	if name == "" {
+		reply(http.StatusBadRequest, webhookResponse{Error: "missing X-GitHub-Event header"})
+		return
+	}
+	ev, err := ParseEvent(name, body)
+	if err != nil {
+		reply(http.StatusBadRequest, webhookResponse{Error: err.Error()})
+		return
+	}
+	runID, err := h.Dispatcher.Dispatch(ev)
+	switch {
+	case err != nil:
+		reply(http.StatusInternalServerError, webhookResponse{Error: err.Error()})
+	case runID == "":
+		reply(http.StatusOK, webhookResponse{})
+	default:
+		reply(http.StatusAccepted, webhookResponse{RunID: runID})
+	}
+}
@@ -0,0 +1,102 @@
+package trigger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dmitriyb/conductor/internal/config"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// recorder is a Starter that records the runs it was asked to start.
+type recorder struct {
+	rules  []config.TriggerRule
+	inputs []map[string]string
+	err    error
+}
+
+func (r *recorder) start(rule config.TriggerRule, inputs map[string]string) (string, error) {
+	if r.err != nil {
+		return "", r.err
+	}
+	r.rules = append(r.rules, rule)
+	r.inputs = append(r.inputs, inputs)
+	return "run-1", nil
+}
+
+// TestWebhook verifies signature checks, dispatch and response codes for
+// fixture deliveries.
+func TestWebhook(t *testing.T) {
+	secret := []byte("s3cret")
+	rec := &recorder{}
+	h := &Handler{Secret: secret, Dispatcher: &Dispatcher{
+		Rules:  []config.TriggerRule{{Event: config.EventIssues, Label: "conductor"}},
+		Start:  rec.start,
+		Logger: discard,
+	}}
+	labeled, err := os.ReadFile(filepath.Join("testdata", "issues_labeled.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, _ := os.ReadFile(filepath.Join("testdata", "pull_request_opened.json"))
+
+	post := func(event string, body []byte, signature string) (int, webhookResponse) {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body))
+		req.Header.Set("X-GitHub-Event", event)
+		if signature != "" {
+			req.Header.Set(SignatureHeader, signature)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		var resp webhookResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		return w.Code, resp
+	}
+
+	if code, resp := post("issues", labeled, Sign(secret, labeled)); code != http.StatusAccepted || resp.RunID != "run-1" {
+		t.Errorf("labeled issue: %d %+v, want 202 with the run", code, resp)
+	}
+	if len(rec.inputs) != 1 || rec.inputs[0]["issue"] != "123" {
+		t.Errorf("started runs = %v", rec.inputs)
+	}
+	if code, resp := post("pull_request", opened, Sign(secret, opened)); code != http.StatusOK || resp.RunID != "" {
+		t.Errorf("unmatched event: %d %+v, want 200 without a run", code, resp)
+	}
+	for name, sig := range map[string]string{
+		"unsigned":   "",
+		"wrong key":  Sign([]byte("other"), labeled),
+		"malformed":  "sha256=zz",
+		"wrong algo": "sha1=abc",
+	} {
+		if code, _ := post("issues", labeled, sig); code != http.StatusUnauthorized {
+			t.Errorf("%s delivery: %d, want 401", name, code)
+		}
+	}
+	if len(rec.inputs) != 1 {
+		t.Errorf("rejected deliveries started runs: %v", rec.inputs)
+	}
+	bad := []byte("{")
+	if code, _ := post("issues", bad, Sign(secret, bad)); code != http.StatusBadRequest {
+		t.Errorf("malformed payload: %d, want 400", code)
+	}
+
+	rec.err = errors.New("clone failed")
+	if code, resp := post("issues", labeled, Sign(secret, labeled)); code != http.StatusInternalServerError || resp.Error != "clone failed" {
+		t.Errorf("failed start: %d %+v, want 500", code, resp)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/webhook", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: %d, want 405", w.Code)
+	}
+}
@@ -0,0 +1,191 @@
+package tui
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/dmitriyb/conductor/internal/events"
+)
+
+// refresh is how often the dashboard redraws while events arrive, and so
+// how often elapsed times tick.
+const refresh = 200 * time.Millisecond
+
+// Dashboard draws a Model on a terminal as the events of a run arrive.
+type Dashboard struct {
+	Model *Model
+	Out   io.Writer
+	Keys  <-chan Key                 // key presses, nil for none
+	Size  func() (width, height int) // current size of the terminal
+	Quit  func()                     // called when the user presses q
+}
+
+// Run shows the dashboard on the alternate screen until feed is closed,
+// applying its events and the key presses. It restores the screen when it
+// returns.
+func (d *Dashboard) Run(feed <-chan events.Event) {
+	io.WriteString(d.Out, "\x1b[?1049h\x1b[?25l")
+	defer io.WriteString(d.Out, "\x1b[?25h\x1b[?1049l")
+	tick := time.NewTicker(refresh)
+	defer tick.Stop()
+	keys := d.Keys
+	dirty := true
+	for {
+		select {
+		case e, ok := <-feed:
+			if !ok {
+				d.draw()
+				return
+			}
+			d.Model.Apply(e)
+			dirty = true
+			continue // apply a burst of events before drawing
+		case k, ok := <-keys:
+			if !ok {
+				keys = nil
+				continue
+			}
+			if d.Model.Press(k) && d.Quit != nil {
+				d.Quit()
+				keys = nil
+			}
+			d.draw()
+			dirty = false
+			continue
+		case <-tick.C:
+		}
+		if dirty || !d.Model.started.IsZero() {
+			d.draw()
+			dirty = false
+		}
+	}
+}
+
+// draw redraws the whole screen in place.
+func (d *Dashboard) draw() {
+	width, height := d.Size()
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, l := range d.Model.Render(width, height, time.Now()) {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(l)
+		b.WriteString("\x1b[K")
+	}
+	io.WriteString(d.Out, b.String())
+}
+
+// ReadKeys returns the key presses read from r, a terminal in raw mode.
+// The channel is closed when r fails.
+func ReadKeys(r io.Reader) <-chan Key {
+	ch := make(chan Key)
+	go func() {
+		defer close(ch)
+		br := bufio.NewReader(r)
+		for {
+			b, err := br.ReadByte()
+			if err != nil {
+				return
+			}
+			var k Key
+			switch b {
+			case 'k':
+				k = KeyUp
+			case 'j':
+				k = KeyDown
+			case 'b':
+				k = KeyPgUp
+			case ' ', 'f':
+				k = KeyPgDn
+			case 'G':
+				k = KeyEnd
+			case 'q', 3: // Ctrl-C
+				k = KeyQuit
+			case 0x1b:
+				var ok bool
+				if k, ok = escape(br); !ok {
+					continue
+				}
+			default:
+				continue
+			}
+			ch <- k
+		}
+	}()
+	return ch
+}
+
+// escape decodes the rest of an escape sequence of a cursor key.
+func escape(br *bufio.Reader) (Key, bool) {
+	if b, err := br.ReadByte(); err != nil || b != '[' {
+		return 0, false
+	}
+	var seq []byte
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, false
+		}
+		seq = append(seq, b)
+		if b >= 0x40 && b <= 0x7e { // final byte
+			break
+		}
+	}
+	switch string(seq) {
+	case "A":
+		return KeyUp, true
+	case "B":
+		return KeyDown, true
+	case "5~":
+		return KeyPgUp, true
+	case "6~":
+		return KeyPgDn, true
+	case "F", "4~":
+		return KeyEnd, true
+	}
+	return 0, false
+}
+
+// Terminal is a terminal the dashboard runs on.
+type Terminal struct {
+	Keys    <-chan Key
+	out     *os.File
+	restore func()
+}
+
+// OpenTerminal returns out as a Terminal if it is one, reading keys from
+// in when that is a terminal too, which it puts in raw mode until Close.
+func OpenTerminal(in io.Reader, out io.Writer) (*Terminal, bool) {
+	f, ok := out.(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return nil, false
+	}
+	t := &Terminal{out: f, restore: func() {}}
+	if inf, ok := in.(*os.File); ok && term.IsTerminal(int(inf.Fd())) {
+		if old, err := term.MakeRaw(int(inf.Fd())); err == nil {
+			t.restore = func() { term.Restore(int(inf.Fd()), old) }
+			t.Keys = ReadKeys(inf)
+		}
+	}
+	return t, true
+}
+
+// Size returns the size of the terminal, or 80x24 if it cannot tell.
+func (t *Terminal) Size() (width, height int) {
+	w, h, err := term.GetSize(int(t.out.Fd()))
+	if err != nil {
+		return 80, 24
+	}
+	return w, h
+}
+
+// Close restores the terminal's input mode.
+func (t *Terminal) Close() {
+	t.restore()
+}
@@ -0,0 +1,56 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dmitriyb/conductor/internal/events"
+)
+
+// TestReadKeys verifies the keys decoded from terminal input, including
+// cursor key escape sequences, and that other input is ignored.
+func TestReadKeys(t *testing.T) {
+	in := "jk\x1b[A\x1b[B\x1b[5~\x1b[6~\x1b[F\x1b[1;5Cxq\x03"
+	var got []Key
+	for k := range ReadKeys(strings.NewReader(in)) {
+		got = append(got, k)
+	}
+	want := []Key{KeyDown, KeyUp, KeyUp, KeyDown, KeyPgUp, KeyPgDn, KeyEnd, KeyQuit, KeyQuit}
+	if len(got) != len(want) {
+		t.Fatalf("keys = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("keys = %v, want %v", got, want)
+		}
+	}
+}
+
+// TestDashboard verifies that the dashboard draws on the alternate screen
+// until its feed closes, and calls Quit for q.
+func TestDashboard(t *testing.T) {
+	var out strings.Builder
+	keys := make(chan Key, 1)
+	quit := make(chan struct{})
+	feed := make(chan events.Event)
+	d := &Dashboard{Model: NewModel("run 1", testSteps), Out: &out, Keys: keys,
+		Size: func() (int, int) { return 40, 12 }, Quit: func() { close(quit) }}
+	done := make(chan struct{})
+	go func() {
+		d.Run(feed)
+		close(done)
+	}()
+	feed <- events.Event{Step: "plan", Kind: events.KindStep, Text: "running"}
+	keys <- KeyQuit
+	<-quit
+	close(feed)
+	<-done
+
+	s := out.String()
+	if !strings.HasPrefix(s, "\x1b[?1049h") || !strings.HasSuffix(s, "\x1b[?1049l") {
+		t.Errorf("output does not switch to and from the alternate screen: %q", s)
+	}
+	if !strings.Contains(s, "\x1b[H") || !strings.Contains(s, "● running") {
+		t.Errorf("output lacks a frame showing plan running: %q", s)
+	}
+}
@@ -0,0 +1,213 @@
+// Package tui shows the live state of a pipeline run: a dashboard of the
+// step DAG on terminals, plain progress lines elsewhere.
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dmitriyb/conductor/internal/config"
+	"github.com/dmitriyb/conductor/internal/events"
+	"github.com/dmitriyb/conductor/internal/pipeline"
+)
+
+// maxLog bounds the log lines kept per step.
+const maxLog = 2000
+
+// Step is the displayed state of one step.
+type Step struct {
+	Name     string
+	Deps     []string
+	Depth    int    // DAG level, or nesting below the step that started it
+	Status   string // empty until the step starts
+	Started  time.Time
+	Finished time.Time
+	Activity string // what the step is doing right now
+	Log      []string
+}
+
+// Model is the state of a run as shown by the dashboard, built from the
+// run's progress events.
+type Model struct {
+	Title string
+
+	started  time.Time
+	steps    []*Step
+	byName   map[string]*Step
+	selected int
+	scroll   int // log lines scrolled back from the newest
+	page     int // log lines shown by the last Render
+}
+
+// NewModel returns the model of a run of steps, laid out as their DAG:
+// each step indented by its level, after the steps it depends on.
+func NewModel(title string, steps []config.StepDef) *Model {
+	m := &Model{Title: title, byName: map[string]*Step{}}
+	graph, err := pipeline.BuildGraph(steps)
+	if err != nil { // not for validated configs; show the steps as listed
+		for _, s := range steps {
+			m.insert(len(m.steps), &Step{Name: s.Name})
+		}
+		return m
+	}
+	depth := map[string]int{}
+	for _, name := range graph.Order {
+		st := &Step{Name: name}
+		for _, d := range graph.Nodes[name].Deps {
+			st.Deps = append(st.Deps, d.Name)
+			depth[name] = max(depth[name], depth[d.Name]+1)
+		}
+		st.Depth = depth[name]
+		m.insert(len(m.steps), st)
+	}
+	return m
+}
+
+// Steps returns the steps in display order.
+func (m *Model) Steps() []*Step {
+	return m.steps
+}
+
+func (m *Model) insert(i int, st *Step) {
+	m.steps = append(m.steps[:i], append([]*Step{st}, m.steps[i:]...)...)
+	m.byName[st.Name] = st
+}
+
+// step returns the step with the given name. Steps missing from the layout,
+// such as sub-pipeline steps (review.check) and for_each instances (fix[0]),
+// are added below the step they belong to.
+func (m *Model) step(name string) *Step {
+	if st, ok := m.byName[name]; ok {
+		return st
+	}
+	st := &Step{Name: name}
+	var parent *Step
+	for _, p := range m.steps {
+		if isChild(name, p.Name) && (parent == nil || len(p.Name) > len(parent.Name)) {
+			parent = p
+		}
+	}
+	if parent == nil {
+		m.insert(len(m.steps), st)
+		return st
+	}
+	st.Depth = parent.Depth + 1
+	i := 0
+	for j, p := range m.steps {
+		if p == parent || isChild(p.Name, parent.Name) {
+			i = j + 1
+		}
+	}
+	m.insert(i, st)
+	return st
+}
+
+// isChild reports whether name is a step nested below parent.
+func isChild(name, parent string) bool {
+	rest, ok := strings.CutPrefix(name, parent)
+	return ok && (strings.HasPrefix(rest, ".") || strings.HasPrefix(rest, "["))
+}
+
+// Apply updates the model with a progress event.
+func (m *Model) Apply(e events.Event) {
+	if m.started.IsZero() || e.Time.Before(m.started) {
+		m.started = e.Time
+	}
+	if e.Step == "" {
+		return
+	}
+	st := m.step(e.Step)
+	if e.Kind == events.KindStep {
+		st.Status = e.Text
+		if e.Text == "running" {
+			st.Started, st.Finished = e.Time, time.Time{}
+		} else {
+			st.Finished = e.Time
+		}
+	}
+	activity, lines := Describe(e)
+	if activity != "" {
+		st.Activity = activity
+	}
+	st.Log = append(st.Log, lines...)
+	if over := len(st.Log) - maxLog; over > 0 {
+		st.Log = st.Log[over:]
+	}
+}
+
+// Describe returns a one-line summary of what an event says the step is
+// doing, if anything, and the lines it adds to the step's log.
+func Describe(e events.Event) (activity string, lines []string) {
+	switch e.Kind {
+	case events.KindStep:
+		return "", []string{"── " + e.Text}
+	case events.KindInit:
+		return "session started", []string{fmt.Sprintf("── session started (%v)", e.Data["model"])}
+	case events.KindMessage:
+		text := strings.TrimSpace(e.Text)
+		first, _, _ := strings.Cut(text, "\n")
+		return first, strings.Split(text, "\n")
+	case events.KindToolUse:
+		call := strings.TrimSpace(e.Tool + " " + toolSummary(e.Data))
+		return call, []string{"→ " + call}
+	case events.KindToolResult:
+		first, _, _ := strings.Cut(strings.TrimSpace(e.Text), "\n")
+		prefix := "← "
+		if e.Data["is_error"] == true {
+			prefix = "← error: "
+		}
+		return "", []string{prefix + first}
+	case events.KindResult:
+		return "session " + e.Text, []string{fmt.Sprintf("── session %s, %v turns, $%v", e.Text, e.Data["turns"], e.Data["cost_usd"])}
+	case events.KindApproval:
+		return "waiting for approval", []string{"── waiting for approval: " + e.Text}
+	case events.KindOutput:
+		return e.Text, []string{e.Text}
+	}
+	return "", nil
+}
+
+// toolSummary picks the most telling argument of a tool call.
+func toolSummary(input map[string]any) string {
+	for _, key := range []string{"command", "file_path", "path", "pattern", "url", "description"} {
+		if v, ok := input[key].(string); ok {
+			first, _, _ := strings.Cut(v, "\n")
+			return first
+		}
+	}
+	return ""
+}
+
+// Key is a key press the dashboard acts on.
+type Key int
+
+// Keys of the dashboard.
+const (
+	KeyUp   Key = iota // select the previous step
+	KeyDown            // select the next step
+	KeyPgUp            // scroll the log back
+	KeyPgDn            // scroll the log forward
+	KeyEnd             // follow the newest log lines
+	KeyQuit            // q or Ctrl-C
+)
+
+// Press updates the selection and log scroll for a key, paging by the log
+// pane of the last Render. It reports whether the key asks to quit.
+func (m *Model) Press(k Key) bool {
+	switch k {
+	case KeyUp:
+		m.selected, m.scroll = max(m.selected-1, 0), 0
+	case KeyDown:
+		m.selected, m.scroll = min(m.selected+1, len(m.steps)-1), 0
+	case KeyPgUp:
+		m.scroll += max(m.page-1, 1)
+	case KeyPgDn:
+		m.scroll = max(m.scroll-max(m.page-1, 1), 0)
+	case KeyEnd:
+		m.scroll = 0
+	case KeyQuit:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package tui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dmitriyb/conductor/internal/config"
+	"github.com/dmitriyb/conductor/internal/events"
+)
+
+// testSteps is a diamond: plan, then implement and docs, then review.
+var testSteps = []config.StepDef{
+	{Name: "plan"},
+	{Name: "implement", DependsOn: []string{"plan"}},
+	{Name: "docs", DependsOn: []string{"plan"}},
+	{Name: "review", DependsOn: []string{"implement", "docs"}},
+}
+
+func names(m *Model) []string {
+	var out []string
+	for _, st := range m.Steps() {
+		out = append(out, st.Name)
+	}
+	return out
+}
+
+// TestLayout verifies that steps are laid out in DAG order with their
+// levels, and that steps only known from events nest below their parent.
+func TestLayout(t *testing.T) {
+	m := NewModel("run", testSteps)
+	for _, st := range m.Steps() {
+		want := map[string]int{"plan": 0, "implement": 1, "docs": 1, "review": 2}[st.Name]
+		if st.Depth != want {
+			t.Errorf("%s depth = %d, want %d", st.Name, st.Depth, want)
+		}
+	}
+	if review := m.byName["review"]; len(review.Deps) != 2 {
+		t.Errorf("review deps = %v", review.Deps)
+	}
+
+	for _, name := range []string{"implement[0]", "implement[1]", "implement[0].test", "other"} {
+		m.Apply(events.Event{Step: name, Kind: events.KindStep, Text: "running"})
+	}
+	got := names(m)
+	want := []string{"plan", "docs", "implement", "implement[0]", "implement[0].test", "implement[1]", "review", "other"}
+	if len(got) != len(want) {
+		t.Fatalf("steps = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("steps = %v, want %v", got, want)
+		}
+	}
+	if d := m.byName["implement[0].test"].Depth; d != 3 {
+		t.Errorf("implement[0].test depth = %d, want 3", d)
+	}
+	if d := m.byName["other"].Depth; d != 0 {
+		t.Errorf("other depth = %d, want 0", d)
+	}
+}
+
+// TestApply verifies that events set a step's status, times, activity and
+// log.
+func TestApply(t *testing.T) {
+	m := NewModel("run", testSteps)
+	t0 := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, e := range []events.Event{
+		{Step: "plan", Kind: events.KindStep, Text: "running"},
+		{Step: "plan", Kind: events.KindInit, Data: map[string]any{"model": "m1"}},
+		{Step: "plan", Kind: events.KindMessage, Text: "Reading the issue.\nIt is short."},
+		{Step: "plan", Kind: events.KindToolUse, Tool: "Bash", Data: map[string]any{"command": "go test ./...\necho"}},
+		{Step: "plan", Kind: events.KindToolResult, Text: "FAIL\nmore", Data: map[string]any{"is_error": true}},
+		{Step: "plan", Kind: events.KindStep, Text: "failure"},
+	} {
+		e.Time = t0.Add(time.Duration(i) * time.Second)
+		m.Apply(e)
+	}
+	st := m.byName["plan"]
+	if st.Status != "failure" || !st.Started.Equal(t0) || !st.Finished.Equal(t0.Add(5*time.Second)) {
+		t.Errorf("plan = %s %v–%v", st.Status, st.Started, st.Finished)
+	}
+	if st.Activity != "Bash go test ./..." {
+		t.Errorf("activity = %q", st.Activity)
+	}
+	want := []string{"── running", "── session started (m1)", "Reading the issue.", "It is short.",
+		"→ Bash go test ./...", "← error: FAIL", "── failure"}
+	if len(st.Log) != len(want) {
+		t.Fatalf("log = %q, want %q", st.Log, want)
+	}
+	for i := range want {
+		if st.Log[i] != want[i] {
+			t.Errorf("log[%d] = %q, want %q", i, st.Log[i], want[i])
+		}
+	}
+
+	for range maxLog + 10 {
+		m.Apply(events.Event{Step: "docs", Kind: events.KindOutput, Text: "x"})
+	}
+	if n := len(m.byName["docs"].Log); n != maxLog {
+		t.Errorf("log holds %d lines, want %d", n, maxLog)
+	}
+}
+
+// TestPress verifies that keys move the selection within the steps and
+// scroll the log by pages.
+func TestPress(t *testing.T) {
+	m := NewModel("run", testSteps)
+	m.page = 5
+	for _, tt := range []struct {
+		key              Key
+		selected, scroll int
+	}{
+		{KeyUp, 0, 0},
+		{KeyDown, 1, 0},
+		{KeyPgUp, 1, 4},
+		{KeyPgUp, 1, 8},
+		{KeyPgDn, 1, 4},
+		{KeyEnd, 1, 0},
+		{KeyDown, 2, 0},
+		{KeyDown, 3, 0},
+		{KeyDown, 3, 0},
+	} {
+		if m.Press(tt.key) {
+			t.Fatalf("key %d quits", tt.key)
+		}
+		if m.selected != tt.selected || m.scroll != tt.scroll {
+			t.Fatalf("after key %d: selected %d scroll %d, want %d %d", tt.key, m.selected, m.scroll, tt.selected, tt.scroll)
+		}
+	}
+	if !m.Press(KeyQuit) {
+		t.Error("KeyQuit does not quit")
+	}
+}
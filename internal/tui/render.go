@@ -0,0 +1,151 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/dmitriyb/conductor/internal/events"
+)
+
+// statusIcons marks step statuses in the step list.
+var statusIcons = map[string]string{
+	"running":   "●",
+	"success":   "✓",
+	"failure":   "✗",
+	"skipped":   "–",
+	"cancelled": "⊘",
+}
+
+// Render returns the dashboard as lines of at most width runes, filling
+// height lines: a header, the step list, and the log of the selected step.
+func (m *Model) Render(width, height int, now time.Time) []string {
+	width, height = max(width, 20), max(height, 6)
+	var lines []string
+	add := func(s string) { lines = append(lines, clip(s, width)) }
+
+	header := "conductor · " + m.Title
+	if !m.started.IsZero() {
+		header += " · " + elapsed(m.started, m.finished(), now)
+	}
+	add(header)
+	add(strings.Repeat("─", width))
+
+	// The step list takes up to half the screen, scrolled to the selection.
+	m.selected = min(max(m.selected, 0), max(len(m.steps)-1, 0))
+	rows := min(len(m.steps), (height-4)/2)
+	first := min(max(m.selected-rows+1, 0), len(m.steps)-rows)
+	nameWidth := 4
+	for _, st := range m.steps {
+		nameWidth = max(nameWidth, 2*st.Depth+len([]rune(st.Name)))
+	}
+	for i, st := range m.steps[first : first+rows] {
+		cursor := "  "
+		if first+i == m.selected {
+			cursor = "> "
+		}
+		icon, ok := statusIcons[st.Status]
+		if !ok {
+			icon = "·"
+		}
+		status := st.Status
+		if status == "" {
+			status = "pending"
+		}
+		name := strings.Repeat("  ", st.Depth) + st.Name
+		row := fmt.Sprintf("%s%-*s  %s %-9s %7s  %s", cursor, nameWidth, name, icon, status,
+			elapsed(st.Started, st.Finished, now), st.Activity)
+		if st.Status == "" && len(st.Deps) > 0 {
+			row += "after " + strings.Join(st.Deps, ", ")
+		}
+		add(row)
+	}
+
+	// The log pane fills the rest.
+	page := height - len(lines) - 1
+	m.page = page
+	title := "── log"
+	var log []string
+	if len(m.steps) > 0 {
+		st := m.steps[m.selected]
+		title += ": " + st.Name
+		log = st.Log
+	}
+	m.scroll = min(m.scroll, max(len(log)-page, 0))
+	end := len(log) - m.scroll
+	if m.scroll > 0 {
+		title += fmt.Sprintf(" (%d more below)", m.scroll)
+	}
+	add(title + " " + strings.Repeat("─", max(width-len([]rune(title))-1, 0)))
+	for _, l := range log[max(end-page, 0):end] {
+		add(l)
+	}
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+// finished returns when the last step finished, or zero while any step
+// still runs.
+func (m *Model) finished() time.Time {
+	var last time.Time
+	for _, st := range m.steps {
+		switch {
+		case st.Status == "running":
+			return time.Time{}
+		case st.Finished.After(last):
+			last = st.Finished
+		}
+	}
+	return last
+}
+
+// elapsed formats the time from start to end, or to now if end is zero.
+func elapsed(start, end, now time.Time) string {
+	if start.IsZero() {
+		return ""
+	}
+	if end.IsZero() {
+		end = now
+	}
+	return end.Sub(start).Round(time.Second).String()
+}
+
+// clip cuts s to at most width runes, replacing tabs and control
+// characters that would disturb the screen.
+func clip(s string, width int) string {
+	out := make([]rune, 0, min(len(s), width))
+	for _, r := range s {
+		if len(out) == width {
+			break
+		}
+		switch {
+		case r == '\t':
+			r = ' '
+		case r < ' ' || r == 0x7f:
+			r = '?'
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
+
+// Plain writes one line per event of feed to w until feed is closed: the
+// step's status changes, tool calls, and what agents say, in the order
+// they happen. It is the dashboard for output that is not a terminal.
+func Plain(w io.Writer, feed <-chan events.Event) {
+	for e := range feed {
+		if e.Step == "" || e.Kind == events.KindToolResult {
+			continue
+		}
+		_, lines := Describe(e)
+		if e.Kind == events.KindMessage {
+			lines = lines[:1]
+		}
+		for _, l := range lines {
+			fmt.Fprintf(w, "%s %s %s\n", e.Time.Format("15:04:05"), e.Step, l)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmitriyb/conductor/internal/events"
+)
+
+// TestRender verifies the header, step rows and log pane of the dashboard,
+// and that every line fits the screen.
+func TestRender(t *testing.T) {
+	m := NewModel("run 1", testSteps)
+	t0 := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	m.Apply(events.Event{Time: t0, Step: "plan", Kind: events.KindStep, Text: "running"})
+	m.Apply(events.Event{Time: t0.Add(2 * time.Second), Step: "plan", Kind: events.KindToolUse, Tool: "Read",
+		Data: map[string]any{"file_path": "main.go"}})
+	for i := range 30 {
+		m.Apply(events.Event{Time: t0, Step: "plan", Kind: events.KindOutput, Text: fmt.Sprintf("line %d\tend", i)})
+	}
+
+	lines := m.Render(60, 20, t0.Add(75*time.Second))
+	if len(lines) != 20 {
+		t.Fatalf("got %d lines, want 20", len(lines))
+	}
+	for _, l := range lines {
+		if n := len([]rune(l)); n > 60 {
+			t.Errorf("line %q is %d wide", l, n)
+		}
+	}
+	if !strings.HasPrefix(lines[0], "conductor · run 1 · 1m15s") {
+		t.Errorf("header = %q", lines[0])
+	}
+	plan := lines[2]
+	for _, want := range []string{"> plan", "● running", "1m15s", "line 29 end"} {
+		if !strings.Contains(plan, want) {
+			t.Errorf("plan row %q lacks %q", plan, want)
+		}
+	}
+	if review := lines[5]; !strings.Contains(review, "    review") || !strings.Contains(review, "pending") ||
+		!strings.Contains(review, "after implement, docs") {
+		t.Errorf("review row = %q", review)
+	}
+	if !strings.HasPrefix(lines[6], "── log: plan ──") {
+		t.Errorf("log title = %q", lines[6])
+	}
+	if last := lines[19]; last != "line 29 end" {
+		t.Errorf("last log line = %q, want the newest", last)
+	}
+
+	m.Press(KeyPgUp)
+	lines = m.Render(60, 20, t0)
+	if !strings.Contains(lines[6], "more below") || lines[19] == "line 29 end" {
+		t.Errorf("scrolled log: title %q, last %q", lines[6], lines[19])
+	}
+}
+
+// TestPlain verifies the progress lines written for events.
+func TestPlain(t *testing.T) {
+	t0 := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	feed := make(chan events.Event, 10)
+	for _, e := range []events.Event{
+		{Step: "plan", Kind: events.KindStep, Text: "running"},
+		{Step: "plan", Kind: events.KindMessage, Text: "First.\nSecond."},
+		{Step: "plan", Kind: events.KindToolUse, Tool: "Bash", Data: map[string]any{"command": "make"}},
+		{Step: "plan", Kind: events.KindToolResult, Text: "ok"},
+		{Step: "plan", Kind: events.KindStep, Text: "success"},
+	} {
+		e.Time = t0
+		feed <- e
+	}
+	close(feed)
+	var b strings.Builder
+	Plain(&b, feed)
+	want := "03:04:05 plan ── running\n03:04:05 plan First.\n03:04:05 plan → Bash make\n03:04:05 plan ── success\n"
+	if b.String() != want {
+		t.Errorf("output = %q, want %q", b.String(), want)
+	}
+}
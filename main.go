@@ -1,18 +1,173 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
 	"os"
+	"os/exec"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
+	"github.com/dmitriyb/conductor/internal/agent"
+	"github.com/dmitriyb/conductor/internal/api"
 	"github.com/dmitriyb/conductor/internal/config"
+	"github.com/dmitriyb/conductor/internal/events"
+	"github.com/dmitriyb/conductor/internal/infra"
+	"github.com/dmitriyb/conductor/internal/pipeline"
+	"github.com/dmitriyb/conductor/internal/schedule"
+	"github.com/dmitriyb/conductor/internal/state"
+	"github.com/dmitriyb/conductor/internal/trigger"
+	"github.com/dmitriyb/conductor/internal/tui"
 )
 
 func main() {
 	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
 }
 
+// runOptions holds the flags of the run subcommand.
+type runOptions struct {
+	autoBuild bool
+	inputs    map[string]string
+	batch     string
+	parallel  int
+	noCache   bool
+	tui       bool
+
+	reportFormat string
+	reportFile   string
+}
+
+// reportExtensions maps report file extensions to the format they imply.
+var reportExtensions = map[string]string{
+	".json": pipeline.ReportJSON,
+	".xml":  pipeline.ReportJUnit,
+	".md":   pipeline.ReportMarkdown,
+}
+
+// parseRunFlags parses the flags that follow the run subcommand.
+func parseRunFlags(args []string, stderr io.Writer) (*runOptions, error) {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	opts := &runOptions{inputs: map[string]string{}}
+	fs.BoolVar(&opts.autoBuild, "auto-build", false, "build agent images that are missing for the current config")
+	fs.Func("input", "run input `key=value`, e.g. issue=123 (repeatable)", func(s string) error {
+		k, v, ok := strings.Cut(s, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("want key=value, got %q", s)
+		}
+		opts.inputs[k] = v
+		return nil
+	})
+	fs.StringVar(&opts.batch, "batch", "", "run once per line of a .txt (issue numbers) or .jsonl (inputs) `file`")
+	fs.IntVar(&opts.parallel, "parallel", 4, "maximum concurrent runs of a batch")
+	fs.BoolVar(&opts.noCache, "no-cache", false, "rerun cacheable steps instead of reusing cached results")
+	fs.BoolVar(&opts.tui, "tui", false, "show a live dashboard of the run, or progress lines when stdout is not a terminal")
+	fs.StringVar(&opts.reportFormat, "report-format", "",
+		"write a report of the run as `format`: "+strings.Join(pipeline.ReportFormats, ", ")+"; to stdout unless --report-file is set")
+	fs.StringVar(&opts.reportFile, "report-file", "", "write the report to `path`; the format defaults from its extension (.json, .xml, .md)")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if opts.parallel < 1 {
+		fmt.Fprintln(stderr, "--parallel must be at least 1")
+		return nil, errors.New("invalid --parallel")
+	}
+	if opts.tui && opts.batch != "" {
+		fmt.Fprintln(stderr, "--tui cannot be combined with --batch")
+		return nil, errors.New("invalid --tui")
+	}
+	if opts.reportFile != "" && opts.reportFormat == "" {
+		opts.reportFormat = reportExtensions[strings.ToLower(filepath.Ext(opts.reportFile))]
+		if opts.reportFormat == "" {
+			fmt.Fprintf(stderr, "cannot tell the report format of %s; set --report-format\n", opts.reportFile)
+			return nil, errors.New("invalid --report-file")
+		}
+	}
+	if opts.reportFormat != "" {
+		if !slices.Contains(pipeline.ReportFormats, opts.reportFormat) {
+			fmt.Fprintf(stderr, "--report-format must be one of %s\n", strings.Join(pipeline.ReportFormats, ", "))
+			return nil, errors.New("invalid --report-format")
+		}
+		if opts.batch != "" {
+			fmt.Fprintln(stderr, "reports cannot be combined with --batch")
+			return nil, errors.New("invalid --report-format")
+		}
+	}
+	return opts, nil
+}
+
+// serveOptions holds the flags of the serve subcommand.
+type serveOptions struct {
+	autoBuild bool
+	addr      string
+	inbox     string
+}
+
+// parseServeFlags parses the flags that follow the serve subcommand.
+func parseServeFlags(args []string, stateDir string, stderr io.Writer) (*serveOptions, error) {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	opts := &serveOptions{}
+	fs.BoolVar(&opts.autoBuild, "auto-build", false, "build agent images that are missing for the current config")
+	fs.StringVar(&opts.addr, "addr", "127.0.0.1:8080", "`address` the webhook endpoint /webhook listens on")
+	fs.StringVar(&opts.inbox, "inbox", filepath.Join(stateDir, "inbox"), "`directory` polled for local event files")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// schedulerOptions holds the flags of the scheduler subcommand.
+type schedulerOptions struct {
+	autoBuild bool
+}
+
+// parseSchedulerFlags parses the flags that follow the scheduler subcommand.
+func parseSchedulerFlags(args []string, stderr io.Writer) (*schedulerOptions, error) {
+	fs := flag.NewFlagSet("scheduler", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	opts := &schedulerOptions{}
+	fs.BoolVar(&opts.autoBuild, "auto-build", false, "build agent images that are missing for the current config")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// apiOptions holds the flags of the api subcommand.
+type apiOptions struct {
+	autoBuild bool
+	addr      string
+}
+
+// parseAPIFlags parses the flags that follow the api subcommand.
+func parseAPIFlags(args []string, stderr io.Writer) (*apiOptions, error) {
+	fs := flag.NewFlagSet("api", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	opts := &apiOptions{}
+	fs.BoolVar(&opts.autoBuild, "auto-build", false, "build agent images that are missing for the current config")
+	fs.StringVar(&opts.addr, "addr", "127.0.0.1:8081", "`address` the API listens on")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
 // run parses flags and dispatches to the appropriate subcommand.
 // It returns the exit code. Extracted from main() for testability.
 func run(args []string, stdout, stderr io.Writer) int {
@@ -20,6 +175,7 @@ func run(args []string, stdout, stderr io.Writer) int {
 	fs.SetOutput(stderr)
 	cfgPath := fs.String("config", "orchestrator.yaml", "config file path")
 	logLevel := fs.String("log-level", "info", "log level")
+	stateDir := fs.String("state-dir", state.DefaultDir(), "directory holding run records")
 
 	if err := fs.Parse(args); err != nil {
 		return 1
@@ -28,16 +184,52 @@ func run(args []string, stdout, stderr io.Writer) int {
 	subcmds := fs.Args()
 	if len(subcmds) == 0 {
 		fmt.Fprintln(stderr, "usage: conductor [flags] <subcommand>")
-		fmt.Fprintln(stderr, "subcommands: validate, build, run")
+		fmt.Fprintln(stderr, "subcommands: validate, build, run, serve, scheduler, api, approve, reject, diff, watch, runs")
 		return 1
 	}
 
+	var runOpts *runOptions
+	var serveOpts *serveOptions
+	var schedulerOpts *schedulerOptions
+	var apiOpts *apiOptions
 	switch subcmds[0] {
-	case "validate", "build", "run":
+	case "approve", "reject":
+		return decide(subcmds, *stateDir, stdout, stderr)
+	case "diff":
+		return showDiff(subcmds, *stateDir, stdout, stderr)
+	case "watch":
+		return watch(subcmds, *cfgPath, *stateDir, stdout, stderr)
+	case "runs":
+		return browseRuns(subcmds, *stateDir, stdout, stderr)
+	case "validate", "build":
 		// valid subcommand — continue below
+	case "run":
+		opts, err := parseRunFlags(subcmds[1:], stderr)
+		if err != nil {
+			return 1
+		}
+		runOpts = opts
+	case "serve":
+		opts, err := parseServeFlags(subcmds[1:], *stateDir, stderr)
+		if err != nil {
+			return 1
+		}
+		serveOpts = opts
+	case "scheduler":
+		opts, err := parseSchedulerFlags(subcmds[1:], stderr)
+		if err != nil {
+			return 1
+		}
+		schedulerOpts = opts
+	case "api":
+		opts, err := parseAPIFlags(subcmds[1:], stderr)
+		if err != nil {
+			return 1
+		}
+		apiOpts = opts
 	default:
 		fmt.Fprintf(stderr, "unknown subcommand: %q\n", subcmds[0])
-		fmt.Fprintln(stderr, "subcommands: validate, build, run")
+		fmt.Fprintln(stderr, "subcommands: validate, build, run, serve, scheduler, api, approve, reject, diff, watch, runs")
 		return 1
 	}
 
@@ -54,16 +246,892 @@ func run(args []string, stdout, stderr io.Writer) int {
 		return 1
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	switch subcmds[0] {
 	case "validate":
 		fmt.Fprintln(stdout, "configuration is valid")
+		for _, step := range cfg.ReplayedSteps() {
+			fmt.Fprintf(stdout, "step %s writes to the workspace; a cached result replays its changes uncommitted and does not push\n", step)
+		}
 	case "build":
-		fmt.Fprintln(stderr, "build: not yet implemented")
-		return 1
+		images, err := infra.BuildImages(ctx, cfg, logger)
+		if err != nil {
+			logger.Error("build failed", "error", err)
+			return 1
+		}
+		for _, name := range slices.Sorted(maps.Keys(images)) {
+			fmt.Fprintf(stdout, "%s\t%s\n", name, images[name])
+		}
 	case "run":
-		fmt.Fprintln(stderr, "run: not yet implemented")
+		images, err := infra.EnsureImages(ctx, cfg, runOpts.autoBuild, logger)
+		if err != nil {
+			logger.Error("image check failed", "error", err)
+			return 1
+		}
+		runs := state.Open(*stateDir)
+		cache := runs.Cache()
+		cache.Refresh = runOpts.noCache
+		if runOpts.batch != "" {
+			return runBatch(ctx, cfg, runs, runOpts, images, cache, stdout, logger)
+		}
+		r, err := runs.NewRun()
+		if err != nil {
+			logger.Error("run failed", "error", err)
+			return 1
+		}
+		env := runEnv{images: images, cache: cache}
+		if runOpts.tui {
+			return runWithDashboard(ctx, cfg, r, runOpts, env, *logLevel, stdout, logger)
+		}
+		result, err := runPipeline(ctx, cfg, r, runOpts.inputs, env, logger)
+		if err != nil {
+			logger.Error("run failed", "error", err)
+			return 1
+		}
+		return writeResult(result, r, runOpts, stdout, logger)
+	case "serve":
+		images, err := infra.EnsureImages(ctx, cfg, serveOpts.autoBuild, logger)
+		if err != nil {
+			logger.Error("image check failed", "error", err)
+			return 1
+		}
+		return serve(ctx, cfg, state.Open(*stateDir), serveOpts, images, logger)
+	case "scheduler":
+		images, err := infra.EnsureImages(ctx, cfg, schedulerOpts.autoBuild, logger)
+		if err != nil {
+			logger.Error("image check failed", "error", err)
+			return 1
+		}
+		return runScheduler(ctx, cfg, state.Open(*stateDir), images, logger)
+	case "api":
+		images, err := infra.EnsureImages(ctx, cfg, apiOpts.autoBuild, logger)
+		if err != nil {
+			logger.Error("image check failed", "error", err)
+			return 1
+		}
+		return serveAPI(ctx, cfg, state.Open(*stateDir), apiOpts, images, logger)
+	}
+
+	return 0
+}
+
+// runBatch executes the pipeline once per entry of the batch file, at most
+// opts.parallel at a time, each as its own run with the entry's inputs over
+// the --input values. It prints the batch summary and returns the exit code.
+func runBatch(ctx context.Context, cfg *config.Config, runs *state.Store, opts *runOptions,
+	images map[string]string, cache *state.Cache, stdout io.Writer, logger *slog.Logger) int {
+	batch, err := pipeline.ReadBatch(opts.batch)
+	if err != nil {
+		logger.Error("batch failed", "error", err)
 		return 1
 	}
+	logger.Info("starting batch", "runs", len(batch), "parallel", opts.parallel)
+
+	// The runs share one slot pool, so agent limits hold across the batch.
+	env := runEnv{images: images, cache: cache,
+		slots: agent.NewSlots(cfg.Concurrency.MaxAgents, cfg.Concurrency.Agents)}
+	start := time.Now()
+	result := &pipeline.BatchResult{Runs: make([]pipeline.BatchRun, len(batch))}
+	sem := make(chan struct{}, opts.parallel)
+	var wg sync.WaitGroup
+	for i, entry := range batch {
+		inputs := maps.Clone(opts.inputs)
+		maps.Copy(inputs, entry)
+		br := &result.Runs[i]
+		br.Inputs = inputs
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			r, err := runs.NewRun()
+			if err != nil {
+				br.Error = err.Error()
+				return
+			}
+			br.RunID = r.ID
+			br.Result, err = runPipeline(ctx, cfg, r, inputs, env,
+				logger.With("input", pipeline.InputLabel(inputs)))
+			if err != nil {
+				logger.Error("run failed", "run_id", r.ID, "error", err)
+				br.Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+	result.Duration = time.Since(start)
 
+	result.Print(stdout)
+	if result.Status() != "success" {
+		return 1
+	}
 	return 0
 }
+
+// serve starts a run for every GitHub event that matches a trigger rule,
+// received on the webhook endpoint or found in the inbox, until ctx is done.
+// Triggered runs share one slot pool and the result cache; serve waits for
+// them to wind down before returning.
+func serve(ctx context.Context, cfg *config.Config, runs *state.Store, opts *serveOptions,
+	images map[string]string, logger *slog.Logger) int {
+	if len(cfg.Triggers.Rules) == 0 {
+		logger.Error("serve needs trigger rules", "config", "triggers.rules")
+		return 1
+	}
+	creds, err := infra.NewCredentialStore(cfg.Credentials.Backend)
+	if err != nil {
+		logger.Error("serve failed", "error", err)
+		return 1
+	}
+	secret, err := creds.Get(ctx, cfg.Triggers.Secret)
+	if err != nil {
+		logger.Error("webhook secret unavailable", "error", err)
+		return 1
+	}
+	ln, err := net.Listen("tcp", opts.addr)
+	if err != nil {
+		logger.Error("serve failed", "error", err)
+		return 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	env := runEnv{images: images, cache: runs.Cache(),
+		slots: agent.NewSlots(cfg.Concurrency.MaxAgents, cfg.Concurrency.Agents)}
+	var wg sync.WaitGroup
+	start := func(rule config.TriggerRule, inputs map[string]string) (string, error) {
+		r, err := runs.NewRun()
+		if err != nil {
+			return "", err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger := logger.With("input", pipeline.InputLabel(inputs))
+			result, err := runPipeline(ctx, cfg.ForPipeline(rule.Pipeline), r, inputs, env, logger)
+			if err != nil {
+				logger.Error("run failed", "run_id", r.ID, "error", err)
+				return
+			}
+			logger.Info("run finished", "run_id", r.ID, "status", result.Status,
+				"tokens", result.Usage.Tokens(), "cost_usd", result.Usage.CostUSD)
+		}()
+		return r.ID, nil
+	}
+	d := &trigger.Dispatcher{Rules: cfg.Triggers.Rules, Start: start, Logger: logger}
+
+	mux := http.NewServeMux()
+	mux.Handle("/webhook", &trigger.Handler{Secret: []byte(secret), Dispatcher: d})
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+	inbox := &trigger.Inbox{Dir: opts.inbox}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		inbox.Watch(ctx, d, 2*time.Second)
+	}()
+
+	logger.Info("serving webhooks", "addr", ln.Addr().String(), "inbox", opts.inbox)
+	err = srv.Serve(ln)
+	cancel() // stops the inbox, and runs too if the server failed
+	wg.Wait()
+	if !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("serve failed", "error", err)
+		return 1
+	}
+	return 0
+}
+
+// runScheduler starts the runs of the config's schedules as they come due,
+// sharing agent slots and the step cache between them, until ctx is done.
+func runScheduler(ctx context.Context, cfg *config.Config, runs *state.Store,
+	images map[string]string, logger *slog.Logger) int {
+	if len(cfg.Schedules) == 0 {
+		logger.Error("scheduler needs schedules", "config", "schedules")
+		return 1
+	}
+	env := runEnv{images: images, cache: runs.Cache(),
+		slots: agent.NewSlots(cfg.Concurrency.MaxAgents, cfg.Concurrency.Agents)}
+	s := &schedule.Scheduler{
+		Schedules: cfg.Schedules,
+		Store:     runs,
+		Logger:    logger,
+		Execute: func(ctx context.Context, name string, def config.ScheduleDef, r *state.Run) (string, error) {
+			result, err := runPipeline(ctx, cfg.ForPipeline(def.Pipeline), r, def.Inputs, env,
+				logger.With("schedule", name))
+			if err != nil {
+				return "", err
+			}
+			return result.Status, nil
+		},
+	}
+	if err := s.Run(ctx); err != nil {
+		logger.Error("scheduler failed", "error", err)
+		return 1
+	}
+	return 0
+}
+
+// serveAPI serves the HTTP API until ctx is done. Runs started through it
+// share one slot pool and the result cache; serveAPI cancels them and waits
+// for them to wind down before returning. Runs of configs sent by clients
+// use the images of those configs, built first with --build.
+func serveAPI(ctx context.Context, cfg *config.Config, runs *state.Store, opts *apiOptions,
+	images map[string]string, logger *slog.Logger) int {
+	if cfg.API.Token == "" {
+		logger.Error("api needs a token", "config", "api.token")
+		return 1
+	}
+	creds, err := infra.NewCredentialStore(cfg.Credentials.Backend)
+	if err != nil {
+		logger.Error("api failed", "error", err)
+		return 1
+	}
+	token, err := creds.Get(ctx, cfg.API.Token)
+	if err != nil {
+		logger.Error("api token unavailable", "error", err)
+		return 1
+	}
+	ln, err := net.Listen("tcp", opts.addr)
+	if err != nil {
+		logger.Error("api failed", "error", err)
+		return 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	env := runEnv{images: images, cache: runs.Cache(),
+		slots: agent.NewSlots(cfg.Concurrency.MaxAgents, cfg.Concurrency.Agents)}
+	s := &api.Server{
+		Config: cfg,
+		Runs:   runs,
+		Token:  token,
+		Logger: logger,
+		Execute: func(ctx context.Context, runCfg *config.Config, req api.StartRequest, r *state.Run, bus *events.Bus) {
+			env := env
+			env.events = bus
+			if runCfg != cfg {
+				images, err := infra.EnsureImages(ctx, runCfg, opts.autoBuild, logger)
+				if err != nil {
+					logger.Error("image check failed", "run_id", r.ID, "error", err)
+					r.WriteInfo(state.RunInfo{ID: r.ID, Status: state.StatusError, Error: err.Error(),
+						Inputs: req.Inputs, Started: time.Now(), Finished: time.Now()})
+					return
+				}
+				env.images = images
+			}
+			result, err := runPipeline(ctx, runCfg.ForPipeline(req.Pipeline), r, req.Inputs, env, logger)
+			if err != nil {
+				logger.Error("run failed", "run_id", r.ID, "error", err)
+				return
+			}
+			logger.Info("run finished", "run_id", r.ID, "status", result.Status,
+				"tokens", result.Usage.Tokens(), "cost_usd", result.Usage.CostUSD)
+		},
+	}
+	srv := &http.Server{Handler: s.Handler(ctx), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+
+	logger.Info("serving api", "addr", ln.Addr().String())
+	err = srv.Serve(ln)
+	cancel() // cancels runs too if the server failed
+	s.Wait()
+	if !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("api failed", "error", err)
+		return 1
+	}
+	return 0
+}
+
+// runEnv is what the runs started by one conductor process share.
+type runEnv struct {
+	images map[string]string // agent name → image tag
+	slots  *agent.Slots      // nil gives each run a pool of its own
+	cache  *state.Cache
+	events *events.Bus // receives the run's progress; may be nil
+}
+
+// runPipeline executes the pipeline of cfg with inputs as run, keeping the
+// run's summary in its record: running until it finishes, then its status.
+func runPipeline(ctx context.Context, cfg *config.Config, run *state.Run, inputs map[string]string,
+	env runEnv, logger *slog.Logger) (*pipeline.PipelineResult, error) {
+	logger = logger.With("run_id", run.ID)
+	info := state.RunInfo{ID: run.ID, Status: state.StatusRunning, Inputs: inputs, Started: time.Now()}
+	record := func() {
+		if err := run.WriteInfo(info); err != nil {
+			logger.Warn("recording run summary failed", "error", err)
+		}
+	}
+	record()
+	if env.events == nil {
+		env.events = events.NewBus()
+	}
+	stopJournal, err := events.Record(env.events, filepath.Join(run.Dir, "events.jsonl"))
+	if err != nil {
+		logger.Warn("recording run events failed", "error", err)
+	} else {
+		defer stopJournal()
+	}
+	result, err := executeRun(ctx, cfg, run, inputs, env, logger)
+	info.Finished = time.Now()
+	switch {
+	case err != nil:
+		info.Status, info.Error = state.StatusError, err.Error()
+	case ctx.Err() != nil:
+		info.Status = state.StatusCancelled
+	default:
+		info.Status = result.Status
+	}
+	record()
+	return result, err
+}
+
+// runWithDashboard executes run like runPipeline while showing its
+// progress: a dashboard when stdout is a terminal, where quitting cancels
+// the run, and plain progress lines otherwise. It writes the result as
+// writeResult does and returns the exit code.
+func runWithDashboard(ctx context.Context, cfg *config.Config, run *state.Run, opts *runOptions,
+	env runEnv, logLevel string, stdout io.Writer, logger *slog.Logger) int {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	env.events = events.NewBus()
+	feed, unsubscribe := env.events.Subscribe(1024)
+	screen, isTerminal := tui.OpenTerminal(os.Stdin, stdout)
+	runLogger := logger
+	if isTerminal {
+		// Log lines would tear through the dashboard; keep them with the run.
+		if f, err := os.Create(filepath.Join(run.Dir, "conductor.log")); err == nil {
+			defer f.Close()
+			runLogger = config.InitLogging(logLevel, f)
+		}
+	}
+
+	var result *pipeline.PipelineResult
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer unsubscribe()
+		result, err = runPipeline(ctx, cfg, run, opts.inputs, env, runLogger)
+	}()
+	if isTerminal {
+		d := &tui.Dashboard{Model: tui.NewModel("run "+run.ID, cfg.Pipeline), Out: stdout,
+			Keys: screen.Keys, Size: screen.Size, Quit: cancel}
+		d.Run(feed)
+		screen.Close()
+	} else {
+		tui.Plain(stdout, feed)
+	}
+	<-done
+
+	if err != nil {
+		logger.Error("run failed", "error", err)
+		return 1
+	}
+	return writeResult(result, run, opts, stdout, logger)
+}
+
+// writeResult prints the summary of a run to stdout, or its report when
+// opts asks for one without a report file, and writes the report file. It
+// returns the exit code: 0 if the run succeeded.
+func writeResult(result *pipeline.PipelineResult, run *state.Run, opts *runOptions, stdout io.Writer,
+	logger *slog.Logger) int {
+	code := 0
+	if result.Status != "success" {
+		code = 1
+	}
+	if opts.reportFormat == "" {
+		result.Print(stdout)
+		return code
+	}
+	report := pipeline.NewReport(result, run.ID, opts.inputs)
+	if opts.reportFile == "" {
+		if err := report.Write(stdout, opts.reportFormat); err != nil {
+			logger.Error("writing report failed", "error", err)
+			return 1
+		}
+		return code
+	}
+	result.Print(stdout)
+	f, err := os.Create(opts.reportFile)
+	if err == nil {
+		err = report.Write(f, opts.reportFormat)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		logger.Error("writing report failed", "file", opts.reportFile, "error", err)
+		return 1
+	}
+	return code
+}
+
+// executeRun sets up the host-side resources of run — secrets env file,
+// repository clones and egress proxies — executes the pipeline with inputs,
+// and tears the resources down again. Agent steps take slots from
+// env.slots, or from a pool of their own when nil. Cacheable steps reuse
+// results from env.cache.
+func executeRun(ctx context.Context, cfg *config.Config, run *state.Run, inputs map[string]string,
+	env runEnv, logger *slog.Logger) (*pipeline.PipelineResult, error) {
+	logger.Info("starting run", "state", run.Dir)
+
+	store, err := infra.NewCredentialStore(cfg.Credentials.Backend)
+	if err != nil {
+		return nil, err
+	}
+	envFile, err := infra.WriteEnvFile(ctx, store, cfg.Credentials.Secrets)
+	if err != nil {
+		return nil, err
+	}
+	defer envFile.Remove()
+
+	ws, err := infra.CloneRepos(ctx, cfg, store)
+	if err != nil {
+		return nil, err
+	}
+	defer ws.Cleanup()
+
+	network := fmt.Sprintf("conductor-%s-%s", cfg.Project.Name, run.ID)
+	egress, err := infra.StartEgress(ctx, cfg, network, logger)
+	if err != nil {
+		return nil, err
+	}
+	defer egress.Close(context.Background())
+
+	if err := os.MkdirAll(run.LogDir(), 0700); err != nil {
+		return nil, fmt.Errorf("create log dir: %w", err)
+	}
+	runCfg := agent.RunConfig{
+		Images:      env.images,
+		Slots:       env.slots,
+		Cache:       env.cache,
+		Events:      env.events,
+		ArtifactDir: filepath.Join(run.Dir, "artifacts"),
+		LogDir:      run.LogDir(),
+		EnvFilePath: envFile.Path,
+		Secrets:     cfg.Credentials.Secrets,
+		Project:     cfg.Project,
+		Repos:       ws.Paths(),
+		Network:     egress.Network,
+		Proxies:     egress.Proxies,
+		SSHSock:     os.Getenv("SSH_AUTH_SOCK"),
+		GitName:     hostGitConfig("user.name"),
+		GitEmail:    hostGitConfig("user.email"),
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		skills := filepath.Join(home, ".claude", "skills")
+		if info, err := os.Stat(skills); err == nil && info.IsDir() {
+			runCfg.SkillsDir = skills
+		}
+	}
+	if cfg.HasStepKind(config.StepContainer) {
+		if runCfg.ProjectImage, err = infra.ProjectImage(cfg); err != nil {
+			return nil, err
+		}
+	}
+	return pipeline.Execute(ctx, cfg, run, inputs, runCfg, logger)
+}
+
+// decide handles `approve|reject [--comment text] <run-id> <step>`, recording
+// the decision for a waiting approval step of a run.
+func decide(args []string, stateDir string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	comment := fs.String("comment", "", "comment recorded with the decision")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 1
+	}
+	if fs.NArg() != 2 {
+		fmt.Fprintf(stderr, "usage: conductor %s [--comment text] <run-id> <step>\n", args[0])
+		return 1
+	}
+	runID, step := fs.Arg(0), fs.Arg(1)
+	run, err := state.Open(stateDir).Run(runID)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	approved := args[0] == "approve"
+	if err := run.Decide(step, state.Decision{Approved: approved, By: currentUser(), Comment: *comment}); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	verb := "rejected"
+	if approved {
+		verb = "approved"
+	}
+	fmt.Fprintf(stdout, "%s step %s of run %s\n", verb, step, runID)
+	return 0
+}
+
+// showDiff prints what a step changed in each repository during a run: the
+// commits it made, then the patch from the tree before it to the tree after.
+func showDiff(args []string, stateDir string, stdout, stderr io.Writer) int {
+	if len(args) != 3 {
+		fmt.Fprintln(stderr, "usage: conductor diff <run-id> <step>")
+		return 1
+	}
+	runID, step := args[1], args[2]
+	run, err := state.Open(stateDir).Run(runID)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	changes, err := run.Changes(step)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	for _, c := range changes {
+		patch, err := run.Patch(step, c.Repo)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "# repository %s: %d commits\n", c.Repo, len(c.Commits))
+		for _, commit := range c.Commits {
+			fmt.Fprintf(stdout, "#   %.12s %s\n", commit.SHA, commit.Subject)
+		}
+		stdout.Write(patch)
+	}
+	return 0
+}
+
+// browseRuns handles `runs list|show|logs`, reading the records of past
+// and running runs.
+func browseRuns(args []string, stateDir string, stdout, stderr io.Writer) int {
+	usage := "usage: conductor runs list [flags] | show <run-id> | logs [-f] <run-id> <step>"
+	if len(args) < 2 {
+		fmt.Fprintln(stderr, usage)
+		return 1
+	}
+	store := state.Open(stateDir)
+	switch args[1] {
+	case "list":
+		return listRuns(args[2:], store, stdout, stderr)
+	case "show":
+		if len(args) != 3 {
+			fmt.Fprintln(stderr, "usage: conductor runs show <run-id>")
+			return 1
+		}
+		return showRun(args[2], store, stdout, stderr)
+	case "logs":
+		return stepLogs(args[2:], store, stdout, stderr)
+	}
+	fmt.Fprintln(stderr, usage)
+	return 1
+}
+
+// listRuns prints the runs matching the flags in args, newest first.
+func listRuns(args []string, store *state.Store, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("runs list", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var filter state.RunFilter
+	fs.StringVar(&filter.Status, "status", "", "only runs with this `status`, e.g. success, failure, running")
+	fs.Func("since", "only runs started at or after `time` (2006-01-02 or RFC 3339)", func(s string) (err error) {
+		filter.Since, err = parseTime(s)
+		return err
+	})
+	fs.Func("until", "only runs started before `time` (2006-01-02 or RFC 3339)", func(s string) (err error) {
+		filter.Until, err = parseTime(s)
+		return err
+	})
+	fs.Func("input", "only runs given input `key=value` (repeatable)", func(s string) error {
+		k, v, ok := strings.Cut(s, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("want key=value, got %q", s)
+		}
+		if filter.Inputs == nil {
+			filter.Inputs = map[string]string{}
+		}
+		filter.Inputs[k] = v
+		return nil
+	})
+	limit := fs.Int("limit", 0, "show at most `n` runs (0 for all)")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	infos, err := store.History(filter)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if *limit > 0 && len(infos) > *limit {
+		infos = infos[:*limit]
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tSTARTED\tDURATION\tINPUTS")
+	for _, info := range infos {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", info.ID, runStatus(info.Status),
+			info.Started.Format(time.DateTime), runDuration(info.Started, info.Finished), formatInputs(info.Inputs))
+	}
+	tw.Flush()
+	return 0
+}
+
+// showRun prints the summary of a run and the result and output of each
+// of its steps.
+func showRun(id string, store *state.Store, stdout, stderr io.Writer) int {
+	run, err := store.Run(id)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	info, err := run.Info()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	steps, err := run.Steps()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Run:      %s\n", info.ID)
+	fmt.Fprintf(stdout, "Status:   %s\n", runStatus(info.Status))
+	fmt.Fprintf(stdout, "Started:  %s\n", info.Started.Format(time.DateTime))
+	if !info.Finished.IsZero() {
+		fmt.Fprintf(stdout, "Duration: %s\n", runDuration(info.Started, info.Finished))
+	}
+	if len(info.Inputs) > 0 {
+		fmt.Fprintf(stdout, "Inputs:   %s\n", formatInputs(info.Inputs))
+	}
+	if info.Error != "" {
+		fmt.Fprintf(stdout, "Error:    %s\n", info.Error)
+	}
+
+	fmt.Fprintln(stdout)
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tSTATUS\tDURATION\tAGENT\tNOTE")
+	for _, s := range steps {
+		note := s.Error
+		if s.Cached {
+			note = "cached"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", s.Name, s.Status, runDuration(s.Started, s.Finished), s.Agent, note)
+	}
+	tw.Flush()
+
+	for _, s := range steps {
+		if len(s.Output) == 0 {
+			continue
+		}
+		out, err := json.MarshalIndent(s.Output, "  ", "  ")
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(stdout, "\nOutput of %s:\n  %s\n", s.Name, out)
+	}
+	return 0
+}
+
+// stepLogs prints the logs of a step of a run, oldest invocation first.
+// With -f it keeps printing what is appended until the run finishes.
+func stepLogs(args []string, store *state.Store, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("runs logs", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	follow := fs.Bool("f", false, "follow the logs until the run finishes")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 2 {
+		fmt.Fprintln(stderr, "usage: conductor runs logs [-f] <run-id> <step>")
+		return 1
+	}
+	run, err := store.Run(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	step := fs.Arg(1)
+	if _, err := run.Step(step); err != nil && !*follow {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	offsets := map[string]int64{}
+	for {
+		// The summary is read first, so the logs of a run that has just
+		// finished are printed in full.
+		info, infoErr := run.Info()
+		logs, err := agent.StepLogs(run.LogDir(), step)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		for _, path := range logs {
+			n, err := copyFrom(stdout, path, offsets[path])
+			offsets[path] += n
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				return 1
+			}
+		}
+		if !*follow || infoErr != nil || info.Status != state.StatusRunning {
+			if len(offsets) == 0 && !*follow {
+				fmt.Fprintf(stderr, "no logs of step %s in %s\n", step, run.LogDir())
+				return 1
+			}
+			return 0
+		}
+		select {
+		case <-ctx.Done():
+			return 0
+		case <-time.After(watchPoll):
+		}
+	}
+}
+
+// copyFrom copies the file at path from byte offset on to w, returning the
+// number of bytes copied.
+func copyFrom(w io.Writer, path string, offset int64) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.Copy(w, f)
+}
+
+// parseTime parses a date or an RFC 3339 time; dates are local midnight.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("want 2006-01-02 or RFC 3339 time, got %q", s)
+	}
+	return t, nil
+}
+
+// runStatus names a recorded status, unknown for runs without a summary.
+func runStatus(status string) string {
+	if status == "" {
+		return "unknown"
+	}
+	return status
+}
+
+// runDuration formats the time from start to end, or "-" while unfinished.
+func runDuration(start, end time.Time) string {
+	if start.IsZero() || end.IsZero() {
+		return "-"
+	}
+	return end.Sub(start).Round(time.Second).String()
+}
+
+// formatInputs formats inputs as sorted key=value pairs.
+func formatInputs(inputs map[string]string) string {
+	var pairs []string
+	for _, k := range slices.Sorted(maps.Keys(inputs)) {
+		pairs = append(pairs, k+"="+inputs[k])
+	}
+	return strings.Join(pairs, " ")
+}
+
+// watchPoll is how often watch and runs logs -f look for new output of a
+// run.
+const watchPoll = 250 * time.Millisecond
+
+// watch handles `watch <run-id>`, following a run started by any conductor
+// process until it finishes: on a dashboard when stdout is a terminal,
+// where quitting stops watching, and as plain progress lines otherwise.
+// The steps of the config at cfgPath lay out the dashboard if it loads.
+func watch(args []string, cfgPath, stateDir string, stdout, stderr io.Writer) int {
+	if len(args) != 2 {
+		fmt.Fprintln(stderr, "usage: conductor watch <run-id>")
+		return 1
+	}
+	run, err := state.Open(stateDir).Run(args[1])
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	var steps []config.StepDef
+	if cfg, err := config.Load(cfgPath); err == nil {
+		steps = cfg.Pipeline
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	feed := make(chan events.Event, 256)
+	go followRun(ctx, run, feed)
+	if screen, ok := tui.OpenTerminal(os.Stdin, stdout); ok {
+		d := &tui.Dashboard{Model: tui.NewModel("run "+run.ID, steps), Out: stdout,
+			Keys: screen.Keys, Size: screen.Size, Quit: cancel}
+		d.Run(feed)
+		screen.Close()
+	} else {
+		tui.Plain(stdout, feed)
+	}
+
+	if info, err := run.Info(); err == nil && info.Status != state.StatusRunning {
+		fmt.Fprintf(stdout, "run %s: %s\n", run.ID, info.Status)
+	}
+	return 0
+}
+
+// followRun sends the events of run's journal to feed as they are written,
+// and closes feed once the run has finished, has no summary to tell, or ctx
+// is done.
+func followRun(ctx context.Context, run *state.Run, feed chan<- events.Event) {
+	defer close(feed)
+	path := filepath.Join(run.Dir, "events.jsonl")
+	var offset int64
+	for {
+		// The summary is read first, so the events of a run that has just
+		// finished are read in full.
+		info, infoErr := run.Info()
+		evs, next, err := events.ReadJournal(path, offset)
+		for _, e := range evs {
+			select {
+			case feed <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+		offset = next
+		if err != nil || infoErr != nil || info.Status != state.StatusRunning {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watchPoll):
+		}
+	}
+}
+
+// currentUser names the human recording a decision.
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// hostGitConfig returns a value of the host's git config, or "" if unset.
+func hostGitConfig(key string) string {
+	out, err := exec.Command("git", "config", "--get", key).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -2,10 +2,25 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/dmitriyb/conductor/internal/api"
+	"github.com/dmitriyb/conductor/internal/config"
+	"github.com/dmitriyb/conductor/internal/infra"
+	"github.com/dmitriyb/conductor/internal/pipeline"
+	"github.com/dmitriyb/conductor/internal/state"
 )
 
 // validYAML is a minimal orchestrator.yaml that passes Validate.
@@ -56,6 +71,9 @@ func TestFR4_ValidateSubcommandSuccess(t *testing.T) {
 	if !strings.Contains(stdout.String(), "configuration is valid") {
 		t.Fatalf("stdout = %q, want it to contain %q", stdout.String(), "configuration is valid")
 	}
+	if want := "step build writes to the workspace; a cached result replays its changes uncommitted"; !strings.Contains(stdout.String(), want) {
+		t.Errorf("stdout = %q, want it to note %q", stdout.String(), want)
+	}
 }
 
 // TestFR4_ValidateDefaultConfig verifies that `conductor validate` defaults
@@ -122,35 +140,396 @@ func TestFR4_ValidateMissingConfig(t *testing.T) {
 	}
 }
 
-// TestFR4_RunSubcommandRecognized verifies that the `run` subcommand is
-// recognized and loads config before reporting unimplemented.
+// fakeDocker puts a docker stand-in on PATH that tracks built images as
+// files, so `image inspect` succeeds only after `build`. It returns the dir
+// whose builds and runs files list the tag of every build and the image of
+// every run; see dockerLog.
+func fakeDocker(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	state := filepath.Join(dir, "images")
+	if err := os.Mkdir(state, 0755); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\ncase \"$1\" in\n" +
+		"  image) test -e \"" + state + "/$3\" ;;\n" +
+		"  build) touch \"" + state + "/$3\"; echo \"$3\" >> \"" + dir + "/builds\" ;;\n" +
+		"  run) for a; do image=$prev; prev=$a; done; echo \"$image\" >> \"" + dir + "/runs\"\n" +
+		"    echo '" + `{"type":"result","subtype":"success","total_cost_usd":0.5,"num_turns":2,` +
+		`"result":"###PIPELINE_OUTPUT###{\"status\":\"success\"}"}` + "' ;;\n" +
+		"esac\n"
+	if err := os.WriteFile(filepath.Join(dir, "docker"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dir
+}
+
+// dockerLog returns the lines of fakeDocker's builds or runs file.
+func dockerLog(t *testing.T, dir, name string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return strings.Fields(string(data))
+}
+
+// localRepoConfig returns validYAML pointed at a fresh local repository
+// holding the worker's system prompt, so runs need no network.
+func localRepoConfig(t *testing.T) string {
+	t.Helper()
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, "system.md"), []byte("Be brief."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "."},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "--quiet", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	return strings.Replace(validYAML, "https://github.com/test/repo.git", repo, 1)
+}
+
+// TestFR4_RunSubcommandRecognized verifies that the `run` subcommand executes
+// the pipeline and prints the summary with usage.
 func TestFR4_RunSubcommandRecognized(t *testing.T) {
+	fakeDocker(t)
+	cfgPath := writeConfig(t, localRepoConfig(t))
+	var stdout, stderr bytes.Buffer
+
+	code := run([]string{"--config", cfgPath, "--state-dir", t.TempDir(), "run", "--auto-build"}, &stdout, &stderr)
+
+	if code != 0 {
+		t.Fatalf("want exit 0, got %d; stderr: %s", code, stderr.String())
+	}
+	for _, want := range []string{"Pipeline Summary", "build", "Usage:    0 tokens  $0.5000  2 turns"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("stdout = %q, want it to contain %q", stdout.String(), want)
+		}
+	}
+}
+
+// TestRunContentTaggedImage verifies that agents run in the image tagged by
+// the content hash of their docker config, that an unchanged config reuses
+// it without rebuilding, and that a changed one needs a new image.
+func TestRunContentTaggedImage(t *testing.T) {
+	docker := fakeDocker(t)
+	yaml := localRepoConfig(t)
+	cfgPath := writeConfig(t, yaml)
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	images, err := infra.AgentImages(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+
+	for _, args := range [][]string{{"run", "--auto-build"}, {"run"}} {
+		if code := run(append([]string{"--config", cfgPath, "--state-dir", t.TempDir()}, args...), &stdout, &stderr); code != 0 {
+			t.Fatalf("%v: want exit 0, got %d; stderr: %s", args, code, stderr.String())
+		}
+	}
+	if runs := dockerLog(t, docker, "runs"); len(runs) != 2 || runs[0] != images["worker"] || runs[1] != images["worker"] {
+		t.Errorf("ran images %v, want %s twice", runs, images["worker"])
+	}
+	if builds := dockerLog(t, docker, "builds"); len(builds) != 1 || builds[0] != images["worker"] {
+		t.Errorf("built %v, want %s once", builds, images["worker"])
+	}
+
+	changed := writeConfig(t, strings.Replace(yaml, "debian:bookworm-slim", "debian:trixie-slim", 1))
+	if code := run([]string{"--config", changed, "--state-dir", t.TempDir(), "run"}, &stdout, &stderr); code == 0 {
+		t.Error("run with a changed docker config reused the old image")
+	}
+}
+
+// TestRunPerAgentImage verifies that an agent with docker overrides runs in
+// its own image while the others share the project image.
+func TestRunPerAgentImage(t *testing.T) {
+	docker := fakeDocker(t)
+	yaml := strings.Replace(localRepoConfig(t), `    workspace: rw
+`, `    workspace: rw
+  reviewer:
+    prompt:
+      system: system.md
+      task: "review the thing"
+    workspace: ro
+    docker:
+      base_image: node:22-bookworm-slim
+`, 1)
+	yaml = strings.Replace(yaml, "  - { name: build, agent: worker }\n",
+		"  - { name: build, agent: worker }\n  - { name: review, agent: reviewer, depends_on: [build] }\n", 1)
+	cfgPath := writeConfig(t, yaml)
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	images, err := infra.AgentImages(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if images["worker"] == images["reviewer"] {
+		t.Fatalf("worker and reviewer share image %s", images["worker"])
+	}
+	var stdout, stderr bytes.Buffer
+
+	if code := run([]string{"--config", cfgPath, "--state-dir", t.TempDir(), "run", "--auto-build"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("want exit 0, got %d; stderr: %s", code, stderr.String())
+	}
+	want := []string{images["worker"], images["reviewer"]}
+	if runs := dockerLog(t, docker, "runs"); !slices.Equal(runs, want) {
+		t.Errorf("ran images %v, want %v", runs, want)
+	}
+	if builds := dockerLog(t, docker, "builds"); len(builds) != 2 {
+		t.Errorf("built %v, want both images", builds)
+	}
+}
+
+// TestRunBatch verifies that --batch runs the pipeline once per issue, each
+// as its own run, and prints the batch summary.
+func TestRunBatch(t *testing.T) {
+	fakeDocker(t)
+	cfgPath := writeConfig(t, localRepoConfig(t))
+	batch := filepath.Join(t.TempDir(), "issues.txt")
+	if err := os.WriteFile(batch, []byte("101\n102\n103\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stateDir := t.TempDir()
+	var stdout, stderr bytes.Buffer
+
+	code := run([]string{"--config", cfgPath, "--state-dir", stateDir, "run", "--auto-build",
+		"--batch", batch, "--parallel", "2", "--input", "label=bug"}, &stdout, &stderr)
+
+	if code != 0 {
+		t.Fatalf("want exit 0, got %d; stderr: %s", code, stderr.String())
+	}
+	for _, want := range []string{"Batch Summary", "#101", "#102", "#103", "Runs:     3 succeeded, 0 failed"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("stdout = %q, want it to contain %q", stdout.String(), want)
+		}
+	}
+	if entries, _ := os.ReadDir(filepath.Join(stateDir, "runs")); len(entries) != 3 {
+		t.Errorf("got %d run records, want 3", len(entries))
+	}
+}
+
+// TestRunTUIAndWatch verifies that run --tui prints progress lines when
+// stdout is not a terminal, and that watch replays the journal of the
+// finished run.
+func TestRunTUIAndWatch(t *testing.T) {
+	fakeDocker(t)
+	cfgPath := writeConfig(t, localRepoConfig(t))
+	stateDir := t.TempDir()
+	var stdout, stderr bytes.Buffer
+
+	code := run([]string{"--config", cfgPath, "--state-dir", stateDir, "run", "--auto-build", "--tui"}, &stdout, &stderr)
+
+	if code != 0 {
+		t.Fatalf("want exit 0, got %d; stderr: %s", code, stderr.String())
+	}
+	for _, want := range []string{" build ── running\n", " build ── session success", " build ── success\n", "Pipeline Summary"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("stdout = %q, want it to contain %q", stdout.String(), want)
+		}
+	}
+
+	runs, err := state.Open(stateDir).Runs()
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("runs = %v, %v", runs, err)
+	}
+	stdout.Reset()
+	if code := run([]string{"--config", cfgPath, "--state-dir", stateDir, "watch", runs[0].ID}, &stdout, &stderr); code != 0 {
+		t.Fatalf("watch: want exit 0, got %d; stderr: %s", code, stderr.String())
+	}
+	for _, want := range []string{" build ── running\n", " build ── success\n", "run " + runs[0].ID + ": success\n"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("watch stdout = %q, want it to contain %q", stdout.String(), want)
+		}
+	}
+	for _, args := range [][]string{{"watch"}, {"watch", "20200101-000000-abcdef"}} {
+		if code := run(append([]string{"--state-dir", stateDir}, args...), &stdout, &stderr); code == 0 {
+			t.Errorf("%v: want non-zero exit", args)
+		}
+	}
+}
+
+// TestRunsSubcommand verifies listing, showing and printing the logs of a
+// finished run.
+func TestRunsSubcommand(t *testing.T) {
+	fakeDocker(t)
+	cfgPath := writeConfig(t, localRepoConfig(t))
+	stateDir := t.TempDir()
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--config", cfgPath, "--state-dir", stateDir, "run", "--auto-build",
+		"--input", "issue=7"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("run: want exit 0, got %d; stderr: %s", code, stderr.String())
+	}
+	recorded, err := state.Open(stateDir).Runs()
+	if err != nil || len(recorded) != 1 {
+		t.Fatalf("runs = %v, %v", recorded, err)
+	}
+	id := recorded[0].ID
+
+	runs := func(args ...string) string {
+		t.Helper()
+		stdout.Reset()
+		if code := run(append([]string{"--state-dir", stateDir, "runs"}, args...), &stdout, &stderr); code != 0 {
+			t.Fatalf("runs %v: want exit 0, got %d; stderr: %s", args, code, stderr.String())
+		}
+		return stdout.String()
+	}
+	if out := runs("list"); !strings.Contains(out, id+"  success") || !strings.Contains(out, "issue=7") {
+		t.Errorf("list = %q", out)
+	}
+	for _, args := range [][]string{{"--status", "failure"}, {"--input", "issue=8"}, {"--since", "2999-01-01"}} {
+		if out := runs(append([]string{"list"}, args...)...); strings.Contains(out, id) {
+			t.Errorf("list %v = %q, want no runs", args, out)
+		}
+	}
+	out := runs("show", id)
+	for _, want := range []string{"Status:   success", "Inputs:   issue=7", "build  success", "Output of build:", `"status": "success"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("show = %q, want it to contain %q", out, want)
+		}
+	}
+	if out := runs("logs", id, "build"); !strings.Contains(out, "###PIPELINE_OUTPUT###") {
+		t.Errorf("logs = %q", out)
+	}
+	if out := runs("logs", "-f", id, "build"); !strings.Contains(out, "###PIPELINE_OUTPUT###") {
+		t.Errorf("logs -f = %q", out)
+	}
+
+	for _, args := range [][]string{{"runs"}, {"runs", "prune"}, {"runs", "show"}, {"runs", "show", "20200101-000000-abcdef"},
+		{"runs", "logs", id, "deploy"}, {"runs", "list", "--since", "yesterday"}} {
+		if code := run(append([]string{"--state-dir", stateDir}, args...), &stdout, &stderr); code == 0 {
+			t.Errorf("%v: want non-zero exit", args)
+		}
+	}
+}
+
+// TestRunReport verifies that run writes its report to stdout in place of
+// the summary, or to the report file in the format of its extension.
+func TestRunReport(t *testing.T) {
+	fakeDocker(t)
+	cfgPath := writeConfig(t, localRepoConfig(t))
+	stateDir := t.TempDir()
+	var stdout, stderr bytes.Buffer
+
+	code := run([]string{"--config", cfgPath, "--state-dir", stateDir, "run", "--auto-build",
+		"--input", "issue=7", "--report-format", "json"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("want exit 0, got %d; stderr: %s", code, stderr.String())
+	}
+	var report pipeline.Report
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("stdout is not a JSON report: %v\n%s", err, stdout.String())
+	}
+	if report.Version != pipeline.ReportVersion || report.Status != "success" || report.Inputs["issue"] != "7" ||
+		len(report.Steps) != 1 || report.Steps[0].Usage.CostUSD != 0.5 {
+		t.Errorf("report = %+v", report)
+	}
+
+	stdout.Reset()
+	file := filepath.Join(t.TempDir(), "report.xml")
+	code = run([]string{"--config", cfgPath, "--state-dir", stateDir, "run", "--report-file", file}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("want exit 0, got %d; stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Pipeline Summary") {
+		t.Errorf("stdout = %q, want the summary", stdout.String())
+	}
+	if data, err := os.ReadFile(file); err != nil || !strings.Contains(string(data), `<testcase name="build"`) {
+		t.Errorf("report file = %s, %v", data, err)
+	}
+}
+
+// TestRunInputFlag verifies that malformed --input values are rejected.
+func TestRunInputFlag(t *testing.T) {
+	for _, args := range [][]string{{"--input", "issue"}, {"--input", "=1"}, {"--parallel", "0"}, {"--tui", "--batch", "issues.txt"},
+		{"--report-format", "html"}, {"--report-file", "report.txt"}, {"--report-format", "json", "--batch", "issues.txt"}} {
+		var stdout, stderr bytes.Buffer
+		if code := run(append([]string{"run"}, args...), &stdout, &stderr); code == 0 {
+			t.Errorf("%v: want non-zero exit", args)
+		}
+	}
+	opts, err := parseRunFlags([]string{"--input", "issue=12", "--input", "query=a=b"}, io.Discard)
+	if err != nil || opts.inputs["issue"] != "12" || opts.inputs["query"] != "a=b" {
+		t.Errorf("inputs = %v, %v", opts.inputs, err)
+	}
+}
+
+// TestRunRefusesMissingImage verifies that `run` refuses to start when the
+// image for the current config has not been built.
+func TestRunRefusesMissingImage(t *testing.T) {
+	fakeDocker(t)
 	cfgPath := writeConfig(t, validYAML)
 	var stdout, stderr bytes.Buffer
 
-	_ = run([]string{"--config", cfgPath, "run"}, &stdout, &stderr)
+	code := run([]string{"--config", cfgPath, "run"}, &stdout, &stderr)
 
-	// run is a stub that exits non-zero, but should not report "unknown subcommand"
-	if strings.Contains(stderr.String(), "unknown subcommand") {
-		t.Fatalf("run should be recognized, stderr: %s", stderr.String())
+	if code == 0 {
+		t.Fatal("want non-zero exit for missing image, got 0")
 	}
-	if !strings.Contains(stderr.String(), "not yet implemented") {
-		t.Fatalf("stderr = %q, want it to contain %q", stderr.String(), "not yet implemented")
+	if !strings.Contains(stderr.String(), "conductor build") {
+		t.Fatalf("stderr = %q, want it to suggest %q", stderr.String(), "conductor build")
 	}
 }
 
-// TestFR4_BuildSubcommandRecognized verifies that the `build` subcommand is
-// recognized and loads config before reporting unimplemented.
-func TestFR4_BuildSubcommandRecognized(t *testing.T) {
+// TestBuildSubcommand verifies that `conductor build` builds the images and
+// prints each agent's content-addressed tag.
+func TestBuildSubcommand(t *testing.T) {
+	fakeDocker(t)
 	cfgPath := writeConfig(t, validYAML)
+	t.Chdir(t.TempDir())
 	var stdout, stderr bytes.Buffer
 
-	_ = run([]string{"--config", cfgPath, "build"}, &stdout, &stderr)
-	if strings.Contains(stderr.String(), "unknown subcommand") {
-		t.Fatalf("build should be recognized, stderr: %s", stderr.String())
+	code := run([]string{"--config", cfgPath, "build"}, &stdout, &stderr)
+
+	if code != 0 {
+		t.Fatalf("want exit 0, got %d; stderr: %s", code, stderr.String())
 	}
-	if !strings.Contains(stderr.String(), "not yet implemented") {
-		t.Fatalf("stderr = %q, want it to contain %q", stderr.String(), "not yet implemented")
+	if !strings.HasPrefix(stdout.String(), "worker\tconductor-test-project:") {
+		t.Fatalf("stdout = %q, want agent image tag", stdout.String())
+	}
+}
+
+// TestApproveSubcommand verifies that approve and reject record decisions
+// for pending approval steps without needing a config.
+func TestApproveSubcommand(t *testing.T) {
+	dir := t.TempDir()
+	r, err := state.Open(dir).NewRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RequestApproval(state.ApprovalRequest{Step: "gate"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"--state-dir", dir, "approve", "--comment", "lgtm", r.ID, "gate"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("want exit 0, got %d; stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "approved step gate of run "+r.ID) {
+		t.Errorf("stdout = %q", stdout.String())
+	}
+	d, err := r.WaitDecision(context.Background(), "gate", time.Second)
+	if err != nil || !d.Approved || d.Comment != "lgtm" {
+		t.Errorf("decision = %+v, %v", d, err)
+	}
+
+	// A second decision and unknown steps are refused.
+	for _, args := range [][]string{{"reject", r.ID, "gate"}, {"approve", r.ID, "other"}, {"approve", r.ID}} {
+		stderr.Reset()
+		if code := run(append([]string{"--state-dir", dir}, args...), &stdout, &stderr); code == 0 {
+			t.Errorf("%v: want non-zero exit", args)
+		}
 	}
 }
 
@@ -220,3 +599,251 @@ func TestFR6_NoGlobalState(t *testing.T) {
 		t.Fatal("second run: want non-zero exit, got 0")
 	}
 }
+
+// TestDiffSubcommand verifies that diff prints a step's commits and patch.
+func TestDiffSubcommand(t *testing.T) {
+	dir := t.TempDir()
+	r, err := state.Open(dir).NewRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+	change := state.Change{Repo: "repo", Base: "b", Snapshot: "s",
+		Commits: []state.Commit{{SHA: "0123456789abcdef0123", Subject: "fix parser"}}}
+	if err := r.RecordChange("implement", change, []byte("diff --git a/p.go b/p.go\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--state-dir", dir, "diff", r.ID, "implement"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("want exit 0, got %d; stderr: %s", code, stderr.String())
+	}
+	want := "# repository repo: 1 commits\n#   0123456789ab fix parser\ndiff --git a/p.go b/p.go\n"
+	if stdout.String() != want {
+		t.Errorf("stdout = %q, want %q", stdout.String(), want)
+	}
+
+	for _, args := range [][]string{{"diff", r.ID, "review"}, {"diff", r.ID}} {
+		if code := run(append([]string{"--state-dir", dir}, args...), &stdout, &stderr); code == 0 {
+			t.Errorf("%v: want non-zero exit", args)
+		}
+	}
+}
+
+// TestServe verifies that serve starts a run for a matching inbox event and
+// answers signed webhook deliveries.
+func TestServe(t *testing.T) {
+	fakeDocker(t)
+	t.Setenv("CONDUCTOR_TEST_WEBHOOK", "s3cret")
+	yaml := localRepoConfig(t) + `
+triggers:
+  secret: CONDUCTOR_TEST_WEBHOOK
+  rules:
+    - { event: issues, label: conductor }
+`
+	cfg, err := config.Load(writeConfig(t, yaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Validate(cfg); err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	images, err := infra.EnsureImages(context.Background(), cfg, true, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stateDir := t.TempDir()
+	inbox := filepath.Join(stateDir, "inbox")
+	os.MkdirAll(inbox, 0700)
+	event := `{"event": "issues", "payload": {"action": "labeled", "issue": {"number": 7}, "label": {"name": "conductor"}}}`
+	if err := os.WriteFile(filepath.Join(inbox, "labeled.json"), []byte(event), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan int)
+	go func() {
+		done <- serve(ctx, cfg, state.Open(stateDir), &serveOptions{addr: "127.0.0.1:0", inbox: inbox}, images, logger)
+	}()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, err := os.Stat(filepath.Join(inbox, "done", "labeled.json")); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("inbox event was not processed")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	cancel()
+	if code := <-done; code != 0 {
+		t.Errorf("serve exited %d, want 0", code)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(stateDir, "runs")); len(entries) != 1 {
+		t.Errorf("got %d run records, want 1", len(entries))
+	}
+
+	var stdout, stderr bytes.Buffer
+	cfgPath := writeConfig(t, localRepoConfig(t))
+	if code := run([]string{"--config", cfgPath, "serve", "--auto-build", "--addr", "127.0.0.1:0"}, &stdout, &stderr); code == 0 {
+		t.Error("serve without trigger rules exited 0")
+	}
+	if !strings.Contains(stderr.String(), "serve needs trigger rules") {
+		t.Errorf("stderr = %q, want the missing rules reported", stderr.String())
+	}
+}
+
+// TestScheduler verifies that the scheduler catches up on a missed
+// activation with a run recorded in the schedule's history, and that it
+// refuses a config without schedules.
+func TestScheduler(t *testing.T) {
+	fakeDocker(t)
+	yaml := localRepoConfig(t) + `
+schedules:
+  nightly:
+    cron: "0 3 * * *"
+    missed: run_once
+`
+	cfg, err := config.Load(writeConfig(t, yaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Validate(cfg); err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	images, err := infra.EnsureImages(context.Background(), cfg, true, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runs := state.Open(t.TempDir())
+	last := time.Now().Add(-72 * time.Hour)
+	if err := runs.RecordActivation("nightly", state.Activation{Scheduled: last, RunID: "earlier", Status: "success"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan int)
+	go func() { done <- runScheduler(ctx, cfg, runs, images, logger) }()
+	deadline := time.Now().Add(10 * time.Second)
+	var latest state.Activation
+	for {
+		history, _ := runs.Activations("nightly")
+		if len(history) == 2 && history[1].Status != state.ActivationRunning {
+			latest = history[1]
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("catch-up run did not finish, history = %+v", history)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	cancel()
+	if code := <-done; code != 0 {
+		t.Errorf("scheduler exited %d, want 0", code)
+	}
+	if latest.Status != "success" || !latest.Scheduled.After(last) {
+		t.Errorf("catch-up activation = %+v, want a successful run", latest)
+	}
+	if _, err := runs.Run(latest.RunID); err != nil {
+		t.Errorf("catch-up run record: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cfgPath := writeConfig(t, localRepoConfig(t))
+	if code := run([]string{"--config", cfgPath, "scheduler", "--auto-build"}, &stdout, &stderr); code == 0 {
+		t.Error("scheduler without schedules exited 0")
+	}
+	if !strings.Contains(stderr.String(), "scheduler needs schedules") {
+		t.Errorf("stderr = %q, want the missing schedules reported", stderr.String())
+	}
+}
+
+// TestAPI verifies that a run started through the API executes and leaves
+// its summary and steps in the run record, and that the API refuses to
+// start without a token.
+func TestAPI(t *testing.T) {
+	fakeDocker(t)
+	t.Setenv("CONDUCTOR_TEST_API_TOKEN", "t0ken")
+	yaml := localRepoConfig(t) + `
+api:
+  token: CONDUCTOR_TEST_API_TOKEN
+`
+	cfg, err := config.Load(writeConfig(t, yaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Validate(cfg); err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	images, err := infra.EnsureImages(context.Background(), cfg, true, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	runs := state.Open(t.TempDir())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan int)
+	go func() { done <- serveAPI(ctx, cfg, runs, &apiOptions{addr: addr}, images, logger) }()
+
+	call := func(method, path, body string, out any) int {
+		req, _ := http.NewRequest(method, "http://"+addr+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer t0ken")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return 0
+		}
+		defer resp.Body.Close()
+		if out != nil {
+			json.NewDecoder(resp.Body).Decode(out)
+		}
+		return resp.StatusCode
+	}
+	var started api.RunDetail
+	deadline := time.Now().Add(10 * time.Second)
+	for call("POST", "/v1/runs", `{"inputs": {"issue": "7"}}`, &started) != http.StatusAccepted {
+		if time.Now().After(deadline) {
+			t.Fatal("api did not start a run")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	var detail api.RunDetail
+	for {
+		call("GET", "/v1/runs/"+started.ID, "", &detail)
+		if detail.Status != state.StatusRunning && detail.Status != "" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("run did not finish: %+v", detail)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	cancel()
+	if code := <-done; code != 0 {
+		t.Errorf("api exited %d, want 0", code)
+	}
+	if detail.Status != "success" || detail.Inputs["issue"] != "7" || detail.Finished.IsZero() {
+		t.Errorf("run = %+v, want a finished successful run", detail.RunInfo)
+	}
+	if len(detail.Steps) != 1 || detail.Steps[0].Name != "build" || detail.Steps[0].Status != "success" {
+		t.Errorf("steps = %+v, want build succeeded", detail.Steps)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cfgPath := writeConfig(t, localRepoConfig(t))
+	if code := run([]string{"--config", cfgPath, "api", "--auto-build", "--addr", "127.0.0.1:0"}, &stdout, &stderr); code == 0 {
+		t.Error("api without token exited 0")
+	}
+	if !strings.Contains(stderr.String(), "api needs a token") {
+		t.Errorf("stderr = %q, want the missing token reported", stderr.String())
+	}
+}